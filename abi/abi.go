@@ -46,6 +46,8 @@ const (
 	SectorID
 	// CommitmentsMap is a map of stringified sector id (uint64) to commitments
 	CommitmentsMap
+	// AddressArray is an array of address.Address
+	AddressArray
 )
 
 func (t Type) String() string {
@@ -76,6 +78,8 @@ func (t Type) String() string {
 		return "uint64"
 	case CommitmentsMap:
 		return "map[string]Commitments"
+	case AddressArray:
+		return "[]address.Address"
 	default:
 		return "<unknown type>"
 	}
@@ -115,6 +119,8 @@ func (av *Value) String() string {
 		return fmt.Sprint(av.Val.(uint64))
 	case CommitmentsMap:
 		return fmt.Sprint(av.Val.(map[string]types.Commitments))
+	case AddressArray:
+		return fmt.Sprint(av.Val.([]address.Address))
 	default:
 		return "<unknown type>"
 	}
@@ -214,6 +220,13 @@ func (av *Value) Serialize() ([]byte, error) {
 		}
 
 		return cbor.DumpObject(m)
+	case AddressArray:
+		arr, ok := av.Val.([]address.Address)
+		if !ok {
+			return nil, &typeError{[]address.Address{}, av.Val}
+		}
+
+		return cbor.DumpObject(arr)
 	default:
 		return nil, fmt.Errorf("unrecognized Type: %d", av.Type)
 	}
@@ -253,6 +266,8 @@ func ToValues(i []interface{}) ([]*Value, error) {
 			out = append(out, &Value{Type: SectorID, Val: v})
 		case map[string]types.Commitments:
 			out = append(out, &Value{Type: CommitmentsMap, Val: v})
+		case []address.Address:
+			out = append(out, &Value{Type: AddressArray, Val: v})
 		default:
 			return nil, fmt.Errorf("unsupported type: %T", v)
 		}
@@ -357,6 +372,15 @@ func Deserialize(data []byte, t Type) (*Value, error) {
 			Type: t,
 			Val:  m,
 		}, nil
+	case AddressArray:
+		var arr []address.Address
+		if err := cbor.DecodeInto(data, &arr); err != nil {
+			return nil, err
+		}
+		return &Value{
+			Type: t,
+			Val:  arr,
+		}, nil
 	case Invalid:
 		return nil, ErrInvalidType
 	default:
@@ -377,6 +401,7 @@ var typeTable = map[Type]reflect.Type{
 	PeerID:         reflect.TypeOf(peer.ID("")),
 	SectorID:       reflect.TypeOf(uint64(0)),
 	CommitmentsMap: reflect.TypeOf(map[string]types.Commitments{}),
+	AddressArray:   reflect.TypeOf([]address.Address{}),
 }
 
 // TypeMatches returns whether or not 'val' is the go type expected for the given ABI type
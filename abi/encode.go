@@ -69,3 +69,75 @@ func ToEncodedValues(params ...interface{}) ([]byte, error) {
 
 	return bytes, nil
 }
+
+// typedValue is the encoding of a Value by EncodeTypedValues.
+type typedValue struct {
+	Type Type
+	Data []byte
+}
+
+func init() {
+	cbor.RegisterCborType(typedValue{})
+}
+
+// EncodeTypedValues encodes a set of abi values, along with their types, to
+// raw bytes. Unlike the output of EncodeValues, the result can be decoded
+// without knowing the types of the values in advance. Zero length arrays of
+// values are normalized to nil
+func EncodeTypedValues(vals []*Value) ([]byte, error) {
+	if len(vals) == 0 {
+		return nil, nil
+	}
+
+	var arr []typedValue
+
+	for _, val := range vals {
+		data, err := val.Serialize()
+		if err != nil {
+			return nil, err
+		}
+
+		arr = append(arr, typedValue{Type: val.Type, Data: data})
+	}
+
+	return cbor.DumpObject(arr)
+}
+
+// DecodeTypedValues decodes an array of abi values encoded by
+// EncodeTypedValues.
+func DecodeTypedValues(data []byte) ([]*Value, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var arr []typedValue
+	if err := cbor.DecodeInto(data, &arr); err != nil {
+		return nil, err
+	}
+
+	out := make([]*Value, 0, len(arr))
+	for _, tv := range arr {
+		v, err := Deserialize(tv.Data, tv.Type)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// ToEncodedTypedValues converts from a list of go abi-compatible values to abi
+// values and then encodes them, with their types, to raw bytes.
+func ToEncodedTypedValues(params ...interface{}) ([]byte, error) {
+	vals, err := ToValues(params)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to convert params to values")
+	}
+
+	bytes, err := EncodeTypedValues(vals)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to encode values")
+	}
+
+	return bytes, nil
+}
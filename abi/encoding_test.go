@@ -25,6 +25,7 @@ func TestBasicEncodingRoundTrip(t *testing.T) {
 		"a string":   {"flugzeug"},
 		"mixed":      {big.NewInt(17), []byte("beep"), "mr rogers", addrGetter()},
 		"sector ids": {uint64(1234), uint64(0)},
+		"addresses":  {[]address.Address{addrGetter(), addrGetter()}},
 	}
 
 	for tname, tcase := range cases {
@@ -46,6 +47,13 @@ func TestBasicEncodingRoundTrip(t *testing.T) {
 			assert.Equal(vals, outVals)
 
 			assert.Equal(tcase, FromValues(outVals))
+
+			typedData, err := EncodeTypedValues(vals)
+			assert.NoError(err)
+
+			typedVals, err := DecodeTypedValues(typedData)
+			assert.NoError(err)
+			assert.Equal(vals, typedVals)
 		})
 	}
 }
@@ -6,9 +6,12 @@ import (
 
 	"github.com/filecoin-project/go-filecoin/actor/builtin/account"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/miner"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/multisig"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/paymentbroker"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/storagemarket"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/vesting"
 	"github.com/filecoin-project/go-filecoin/exec"
+	"github.com/filecoin-project/go-filecoin/proofs"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
@@ -23,4 +26,19 @@ func init() {
 	Actors[types.PaymentBrokerActorCodeCid] = &paymentbroker.Actor{}
 	Actors[types.MinerActorCodeCid] = &miner.Actor{}
 	Actors[types.BootstrapMinerActorCodeCid] = &miner.Actor{Bootstrap: true}
+	Actors[types.MultisigActorCodeCid] = &multisig.Actor{}
+	Actors[types.VestingActorCodeCid] = &vesting.Actor{}
+}
+
+// ActorsWithVerifier returns the actors that ship with Filecoin, indexed by
+// their CID like Actors, with the actors which check proofs verifying them
+// with v.
+func ActorsWithVerifier(v proofs.Verifier) map[cid.Cid]exec.ExecutableActor {
+	actors := make(map[cid.Cid]exec.ExecutableActor, len(Actors))
+	for c, a := range Actors {
+		actors[c] = a
+	}
+	actors[types.MinerActorCodeCid] = &miner.Actor{Verifier: v}
+	actors[types.BootstrapMinerActorCodeCid] = &miner.Actor{Bootstrap: true, Verifier: v}
+	return actors
 }
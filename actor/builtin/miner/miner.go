@@ -1,6 +1,7 @@
 package miner
 
 import (
+	"bytes"
 	"math/big"
 	"os"
 	"strconv"
@@ -61,6 +62,8 @@ const (
 	ErrAskNotFound = 40
 	// ErrInvalidSealProof signals that the passed in seal proof was invalid.
 	ErrInvalidSealProof = 41
+	// ErrSectorNotProven indicates a sector is not covered by a current PoSt.
+	ErrSectorNotProven = 42
 )
 
 // Errors map error codes to revert errors this actor may return.
@@ -74,6 +77,7 @@ var Errors = map[uint8]error{
 	ErrInvalidPoSt:             errors.NewCodedRevertErrorf(ErrInvalidPoSt, "PoSt proof did not validate"),
 	ErrAskNotFound:             errors.NewCodedRevertErrorf(ErrAskNotFound, "no ask was found"),
 	ErrInvalidSealProof:        errors.NewCodedRevertErrorf(ErrInvalidSealProof, "seal proof was invalid"),
+	ErrSectorNotProven:         errors.NewCodedRevertErrorf(ErrSectorNotProven, "sector is not covered by a current PoSt"),
 }
 
 // Actor is the miner actor.
@@ -86,8 +90,12 @@ var Errors = map[uint8]error{
 // The `Bootstrap` field must be set to `true` if the miner was created in the
 // genesis block. If the miner was created in any other block, `Bootstrap` must
 // be false.
+//
+// `Verifier` verifies seal proofs and PoSts. If it is nil, proofs are verified
+// by rust-proofs. Every node on a network must use the same kind of verifier.
 type Actor struct {
 	Bootstrap bool
+	Verifier  proofs.Verifier
 }
 
 // Ask is a price advertisement by the miner
@@ -133,6 +141,67 @@ type State struct {
 	Power *big.Int
 }
 
+// CommitSectorVerificationRequest returns the seal verification request a
+// miner actor makes when processing msg, a call to its commitSector method.
+// It lets callers verify the proofs in a block ahead of processing it.
+func CommitSectorVerificationRequest(msg *types.Message) (proofs.VerifySealRequest, error) {
+	if msg.Method != "commitSector" {
+		return proofs.VerifySealRequest{}, xerrors.Errorf("message calls %s, not commitSector", msg.Method)
+	}
+
+	params, err := abi.DecodeValues(msg.Params, minerExports["commitSector"].Params)
+	if err != nil {
+		return proofs.VerifySealRequest{}, xerrors.Wrap(err, "invalid commitSector params")
+	}
+	if len(params) != len(minerExports["commitSector"].Params) {
+		return proofs.VerifySealRequest{}, xerrors.New("missing commitSector params")
+	}
+
+	return sealVerificationRequest(
+		msg.To,
+		params[0].Val.(uint64),
+		params[1].Val.([]byte),
+		params[2].Val.([]byte),
+		params[3].Val.([]byte),
+		params[4].Val.([]byte),
+	), nil
+}
+
+// sealVerificationRequest creates the request with which the miner actor at
+// minerAddr verifies the seal proof for a sector.
+func sealVerificationRequest(minerAddr address.Address, sectorID uint64, commD, commR, commRStar, proof []byte) proofs.VerifySealRequest {
+	// This unfortunate environment variable-checking needs to happen because
+	// the PoRep verification operation needs to know some things (e.g. size)
+	// about the sector for which the proof was generated in order to verify.
+	//
+	// It is undefined behavior for a miner in "Live" mode to verify a proof
+	// created by a miner in "ProofsTest" mode (and vice-versa).
+	//
+	sectorStoreType := proofs.Live
+	if os.Getenv("FIL_USE_SMALL_SECTORS") == "true" {
+		sectorStoreType = proofs.Test
+	}
+
+	req := proofs.VerifySealRequest{}
+	copy(req.CommD[:], commD)
+	copy(req.CommR[:], commR)
+	copy(req.CommRStar[:], commRStar)
+	copy(req.Proof[:], proof)
+	req.ProverID = sectorbuilder.AddressToProverID(minerAddr)
+	req.SectorID = sectorbuilder.SectorIDToBytes(sectorID)
+	req.StoreType = sectorStoreType
+
+	return req
+}
+
+// verifier returns the Verifier with which the actor checks proofs.
+func (ma *Actor) verifier() proofs.Verifier {
+	if ma.Verifier == nil {
+		return &proofs.RustVerifier{}
+	}
+	return ma.Verifier
+}
+
 // NewActor returns a new miner actor
 func NewActor() *actor.Actor {
 	return actor.NewActor(types.MinerActorCodeCid, types.NewZeroAttoFIL())
@@ -237,6 +306,10 @@ var minerExports = exec.Exports{
 		Params: nil,
 		Return: []abi.Type{abi.CommitmentsMap},
 	},
+	"verifySectorProven": &exec.FunctionSignature{
+		Params: []abi.Type{abi.SectorID, abi.Bytes},
+		Return: []abi.Type{},
+	},
 }
 
 // Exports returns the miner actors exported functions.
@@ -445,28 +518,9 @@ func (ma *Actor) CommitSector(ctx exec.VMContext, sectorID uint64, commD, commR,
 	}
 
 	if !ma.Bootstrap {
-		// This unfortunate environment variable-checking needs to happen because
-		// the PoRep verification operation needs to know some things (e.g. size)
-		// about the sector for which the proof was generated in order to verify.
-		//
-		// It is undefined behavior for a miner in "Live" mode to verify a proof
-		// created by a miner in "ProofsTest" mode (and vice-versa).
-		//
-		sectorStoreType := proofs.Live
-		if os.Getenv("FIL_USE_SMALL_SECTORS") == "true" {
-			sectorStoreType = proofs.Test
-		}
+		req := sealVerificationRequest(ctx.Message().To, sectorID, commD, commR, commRStar, proof)
 
-		req := proofs.VerifySealRequest{}
-		copy(req.CommD[:], commD)
-		copy(req.CommR[:], commR)
-		copy(req.CommRStar[:], commRStar)
-		copy(req.Proof[:], proof)
-		req.ProverID = sectorbuilder.AddressToProverID(ctx.Message().To)
-		req.SectorID = sectorbuilder.SectorIDToBytes(sectorID)
-		req.StoreType = sectorStoreType
-
-		res, err := (&proofs.RustVerifier{}).VerifySeal(req)
+		res, err := ma.verifier().VerifySeal(req)
 		if err != nil {
 			return 1, errors.RevertErrorWrap(err, "failed to verify seal proof")
 		}
@@ -668,7 +722,7 @@ func (ma *Actor) SubmitPoSt(ctx exec.VMContext, proof []byte) (uint8, error) {
 			Proof:         postProof,
 		}
 
-		res, err := (&proofs.RustVerifier{}).VerifyPoST(req)
+		res, err := ma.verifier().VerifyPoST(req)
 		if err != nil {
 			return nil, errors.RevertErrorWrap(err, "failed to verify PoSt")
 		}
@@ -715,3 +769,34 @@ func (ma *Actor) GetProvingPeriodStart(ctx exec.VMContext) (*types.BlockHeight,
 
 	return state.ProvingPeriodStart, 0, nil
 }
+
+// VerifySectorProven succeeds if the sector with the given id is committed
+// with the given commD and the miner's last PoSt is current, i.e. the proving
+// period following it has not ended. It is meant to be used as a payment
+// voucher condition, so that storage is only paid for while it is proven.
+func (ma *Actor) VerifySectorProven(ctx exec.VMContext, sectorID uint64, commD []byte) (uint8, error) {
+	if err := ctx.Charge(100); err != nil {
+		return exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
+	}
+
+	chunk, err := ctx.ReadStorage()
+	if err != nil {
+		return errors.CodeError(err), err
+	}
+
+	var state State
+	if err := actor.UnmarshalStorage(chunk, &state); err != nil {
+		return errors.CodeError(err), err
+	}
+
+	comms, ok := state.SectorCommitments[strconv.FormatUint(sectorID, 10)]
+	if !ok || !bytes.Equal(comms.CommD[:], commD) {
+		return errors.CodeError(Errors[ErrInvalidSector]), Errors[ErrInvalidSector]
+	}
+
+	if state.LastPoSt == nil || ctx.BlockHeight().GreaterThan(state.ProvingPeriodStart.Add(ProvingPeriodBlocks)) {
+		return errors.CodeError(Errors[ErrSectorNotProven]), Errors[ErrSectorNotProven]
+	}
+
+	return 0, nil
+}
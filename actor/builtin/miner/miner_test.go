@@ -14,6 +14,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/core"
 	"github.com/filecoin-project/go-filecoin/proofs"
+	"github.com/filecoin-project/go-filecoin/proofs/sectorbuilder"
 	"github.com/filecoin-project/go-filecoin/state"
 	th "github.com/filecoin-project/go-filecoin/testhelpers"
 	"github.com/filecoin-project/go-filecoin/types"
@@ -305,6 +306,40 @@ func TestMinerCommitSector(t *testing.T) {
 	require.Equal(uint8(0x23), res.Receipt.ExitCode)
 }
 
+func TestCommitSectorVerificationRequest(t *testing.T) {
+	minerAddr := address.MakeTestAddress("miner")
+	commD := th.MakeCommitment()
+	proof := th.MakeRandomBytes(int(proofs.SealBytesLen))
+
+	t.Run("decodes the params of a commitSector message", func(t *testing.T) {
+		require := require.New(t)
+
+		params := actor.MustConvertParams(uint64(7), commD, th.MakeCommitment(), th.MakeCommitment(), proof)
+		msg := types.NewMessage(address.TestAddress, minerAddr, 0, nil, "commitSector", params)
+
+		req, err := CommitSectorVerificationRequest(msg)
+		require.NoError(err)
+		require.Equal(commD, req.CommD[:])
+		require.Equal(proof, req.Proof[:])
+		require.Equal(sectorbuilder.AddressToProverID(minerAddr), req.ProverID)
+		require.Equal(sectorbuilder.SectorIDToBytes(7), req.SectorID)
+	})
+
+	t.Run("rejects other methods", func(t *testing.T) {
+		msg := types.NewMessage(address.TestAddress, minerAddr, 0, nil, "submitPoSt", nil)
+
+		_, err := CommitSectorVerificationRequest(msg)
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects missing params", func(t *testing.T) {
+		msg := types.NewMessage(address.TestAddress, minerAddr, 0, nil, "commitSector", nil)
+
+		_, err := CommitSectorVerificationRequest(msg)
+		assert.Error(t, err)
+	})
+}
+
 func TestMinerSubmitPoSt(t *testing.T) {
 	require := require.New(t)
 	ctx := context.Background()
@@ -344,3 +379,37 @@ func TestMinerSubmitPoSt(t *testing.T) {
 	require.NoError(err)
 	require.EqualError(res.ExecutionError, "submitted PoSt late, need to pay a fee")
 }
+
+func TestMinerVerifySectorProven(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	st, vms := core.CreateStorages(ctx, t)
+
+	minerAddr := createTestMiner(assert.New(t), st, vms, address.TestAddress, []byte("my public key"), th.RequireRandomPeerID())
+
+	commD := th.MakeCommitment()
+	res, err := th.CreateAndApplyTestMessage(t, st, vms, minerAddr, 0, 3, "commitSector", uint64(1), commD, th.MakeCommitment(), th.MakeCommitment(), th.MakeRandomBytes(int(proofs.SealBytesLen)))
+	require.NoError(err)
+	require.NoError(res.ExecutionError)
+
+	verify := func(sectorID uint64, commD []byte, height uint64) uint8 {
+		res, err := th.CreateAndApplyTestMessage(t, st, vms, minerAddr, 0, height, "verifySectorProven", sectorID, commD)
+		require.NoError(err)
+		return res.Receipt.ExitCode
+	}
+
+	// no PoSt has been submitted yet
+	require.Equal(uint8(ErrSectorNotProven), verify(1, commD, 4))
+
+	proof := th.MakeRandomPoSTProofForTest()
+	res, err = th.CreateAndApplyTestMessage(t, st, vms, minerAddr, 0, 8, "submitPoSt", proof[:])
+	require.NoError(err)
+	require.NoError(res.ExecutionError)
+
+	require.Equal(uint8(0), verify(1, commD, 9))
+	require.Equal(uint8(ErrInvalidSector), verify(2, commD, 9))
+	require.Equal(uint8(ErrInvalidSector), verify(1, th.MakeCommitment(), 9))
+
+	// the next PoSt was due at the end of the following proving period
+	require.Equal(uint8(ErrSectorNotProven), verify(1, commD, 40004))
+}
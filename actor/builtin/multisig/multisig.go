@@ -0,0 +1,425 @@
+package multisig
+
+import (
+	"math/big"
+	"strconv"
+
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	cbor "gx/ipfs/QmRoARq3nkUb13HSKZGepCZSWe5GrVPwx7xURJGZ7KWv9V/go-ipld-cbor"
+
+	"github.com/filecoin-project/go-filecoin/abi"
+	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/exec"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm/errors"
+)
+
+const (
+	// ErrNotFactory indicates an attempt to create a wallet through a multisig wallet.
+	ErrNotFactory = 33
+	// ErrInvalidThreshold indicates a threshold of zero or more than the number of signers.
+	ErrInvalidThreshold = 34
+	// ErrNotSigner indicates the caller is not one of the wallet's signers.
+	ErrNotSigner = 35
+	// ErrUnknownTransaction indicates an invalid transaction id.
+	ErrUnknownTransaction = 36
+	// ErrAlreadyApproved indicates the caller has already approved the transaction.
+	ErrAlreadyApproved = 37
+	// ErrNotProposer indicates an attempt to cancel a transaction proposed by someone else.
+	ErrNotProposer = 38
+	// ErrInvalidParams indicates the params of a transaction could not be decoded.
+	ErrInvalidParams = 39
+	// ErrUnsupportedSelfCall indicates a transaction calls a method of the wallet itself
+	// other than changeThreshold.
+	ErrUnsupportedSelfCall = 40
+)
+
+// Errors map error codes to revert errors this actor may return.
+var Errors = map[uint8]error{
+	ErrNotFactory:          errors.NewCodedRevertErrorf(ErrNotFactory, "multisig wallets can only be created by the actor at %s", address.MultisigAddress),
+	ErrInvalidThreshold:    errors.NewCodedRevertError(ErrInvalidThreshold, "threshold must be between 1 and the number of signers"),
+	ErrNotSigner:           errors.NewCodedRevertError(ErrNotSigner, "caller is not a signer of the wallet"),
+	ErrUnknownTransaction:  errors.NewCodedRevertError(ErrUnknownTransaction, "transaction is unknown"),
+	ErrAlreadyApproved:     errors.NewCodedRevertError(ErrAlreadyApproved, "caller has already approved the transaction"),
+	ErrNotProposer:         errors.NewCodedRevertError(ErrNotProposer, "only the proposer may cancel a transaction"),
+	ErrInvalidParams:       errors.NewCodedRevertError(ErrInvalidParams, "transaction params could not be decoded"),
+	ErrUnsupportedSelfCall: errors.NewCodedRevertError(ErrUnsupportedSelfCall, "a wallet may only call changeThreshold on itself"),
+}
+
+func init() {
+	cbor.RegisterCborType(State{})
+	cbor.RegisterCborType(Transaction{})
+}
+
+// Transaction is a message a wallet will send once enough of its signers
+// approve it.
+type Transaction struct {
+	To     address.Address `json:"to"`
+	Value  *types.AttoFIL  `json:"value"`
+	Method string          `json:"method"`
+	// Params are the message's params, encoded with abi.EncodeTypedValues.
+	Params []byte `json:"params"`
+	// Approvals lists the signers who approved the transaction, starting
+	// with the one who proposed it.
+	Approvals []address.Address `json:"approvals"`
+}
+
+// State is the state of a multisig wallet. The actor at
+// address.MultisigAddress, which creates wallets, has no signers.
+type State struct {
+	Signers   []address.Address `json:"signers"`
+	Threshold uint64            `json:"threshold"`
+	NextTxID  uint64            `json:"nextTxId"`
+	// Pending maps stringified transaction ids (uint64) to transactions
+	// awaiting approval.
+	Pending map[string]Transaction `json:"pending"`
+}
+
+// Actor holds funds which may only be spent with the approval of a threshold
+// number of its signers. Any signer may propose a transaction; it is sent once
+// enough signers have approved it. Changes to the threshold are transactions
+// too.
+//
+// Wallets are created by calling create on the actor at
+// address.MultisigAddress.
+type Actor struct{}
+
+// NewActor returns a new multisig actor.
+func NewActor() *actor.Actor {
+	return actor.NewActor(types.MultisigActorCodeCid, types.NewZeroAttoFIL())
+}
+
+// InitializeState stores the actor's initial data structure. initializerData
+// is a *State, or nil for the actor which creates wallets.
+func (ma *Actor) InitializeState(storage exec.Storage, initializerData interface{}) error {
+	msState, ok := initializerData.(*State)
+	if !ok {
+		msState = &State{}
+	}
+	if msState.Pending == nil {
+		msState.Pending = map[string]Transaction{}
+	}
+
+	stateBytes, err := cbor.DumpObject(msState)
+	if err != nil {
+		return err
+	}
+
+	id, err := storage.Put(stateBytes)
+	if err != nil {
+		return err
+	}
+
+	return storage.Commit(id, cid.Undef)
+}
+
+var _ exec.ExecutableActor = (*Actor)(nil)
+
+// Exports returns the actor's exports.
+func (ma *Actor) Exports() exec.Exports {
+	return multisigExports
+}
+
+var multisigExports = exec.Exports{
+	"create": &exec.FunctionSignature{
+		Params: []abi.Type{abi.AddressArray, abi.Integer},
+		Return: []abi.Type{abi.Address},
+	},
+	"propose": &exec.FunctionSignature{
+		Params: []abi.Type{abi.Address, abi.AttoFIL, abi.String, abi.Bytes},
+		Return: []abi.Type{abi.Integer},
+	},
+	"approve": &exec.FunctionSignature{
+		Params: []abi.Type{abi.Integer},
+		Return: nil,
+	},
+	"cancel": &exec.FunctionSignature{
+		Params: []abi.Type{abi.Integer},
+		Return: nil,
+	},
+	"changeThreshold": &exec.FunctionSignature{
+		Params: []abi.Type{abi.Integer},
+		Return: []abi.Type{abi.Integer},
+	},
+	"getState": &exec.FunctionSignature{
+		Params: nil,
+		Return: []abi.Type{abi.Bytes},
+	},
+}
+
+// Create creates a new wallet controlled by the given signers, funded with the
+// value of the message. threshold signers must approve each transaction.
+func (ma *Actor) Create(vmctx exec.VMContext, signers []address.Address, threshold *big.Int) (address.Address, uint8, error) {
+	if err := vmctx.Charge(100); err != nil {
+		return address.Address{}, exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
+	}
+
+	if vmctx.Message().To != address.MultisigAddress {
+		return address.Address{}, errors.CodeError(Errors[ErrNotFactory]), Errors[ErrNotFactory]
+	}
+
+	signers = uniqueAddresses(signers)
+	if !validThreshold(threshold, len(signers)) {
+		return address.Address{}, errors.CodeError(Errors[ErrInvalidThreshold]), Errors[ErrInvalidThreshold]
+	}
+
+	addr, err := vmctx.AddressForNewActor()
+	if err != nil {
+		return address.Address{}, 1, errors.FaultErrorWrap(err, "could not get address for new actor")
+	}
+
+	initState := &State{
+		Signers:   signers,
+		Threshold: threshold.Uint64(),
+		Pending:   map[string]Transaction{},
+	}
+	if err := vmctx.CreateNewActor(addr, types.MultisigActorCodeCid, initState); err != nil {
+		return address.Address{}, errors.CodeError(err), err
+	}
+
+	if _, code, err := vmctx.Send(addr, "", vmctx.Message().Value, nil); err != nil {
+		return address.Address{}, code, err
+	}
+
+	return addr, 0, nil
+}
+
+// Propose proposes a transaction sending value to an actor, calling method
+// with params encoded by abi.EncodeTypedValues. The proposal counts as the
+// caller's approval, so the transaction is sent immediately if the threshold
+// is one. Returns the transaction id.
+func (ma *Actor) Propose(vmctx exec.VMContext, to address.Address, value *types.AttoFIL, method string, params []byte) (*big.Int, uint8, error) {
+	if err := vmctx.Charge(100); err != nil {
+		return nil, exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
+	}
+
+	return ma.propose(vmctx, Transaction{
+		To:     to,
+		Value:  value,
+		Method: method,
+		Params: params,
+	})
+}
+
+// ChangeThreshold proposes a transaction changing the number of signers which
+// must approve transactions. Returns the transaction id.
+func (ma *Actor) ChangeThreshold(vmctx exec.VMContext, threshold *big.Int) (*big.Int, uint8, error) {
+	if err := vmctx.Charge(100); err != nil {
+		return nil, exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
+	}
+
+	params, err := abi.ToEncodedTypedValues(threshold)
+	if err != nil {
+		return nil, 1, errors.FaultErrorWrap(err, "could not encode threshold")
+	}
+
+	return ma.propose(vmctx, Transaction{
+		To:     vmctx.Message().To,
+		Value:  types.NewZeroAttoFIL(),
+		Method: "changeThreshold",
+		Params: params,
+	})
+}
+
+// Approve approves a pending transaction, sending it if enough signers have
+// now approved it. A signer who already approved the transaction may approve
+// it again to send it, if the threshold was lowered since.
+func (ma *Actor) Approve(vmctx exec.VMContext, txID *big.Int) (uint8, error) {
+	if err := vmctx.Charge(100); err != nil {
+		return exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
+	}
+
+	var state State
+	out, err := actor.WithState(vmctx, &state, func() (interface{}, error) {
+		if !isSigner(state.Signers, vmctx.Message().From) {
+			return nil, Errors[ErrNotSigner]
+		}
+
+		key := txKey(txID.Uint64())
+		tx, ok := state.Pending[key]
+		if !ok {
+			return nil, Errors[ErrUnknownTransaction]
+		}
+		if isSigner(tx.Approvals, vmctx.Message().From) {
+			if uint64(len(tx.Approvals)) < state.Threshold {
+				return nil, Errors[ErrAlreadyApproved]
+			}
+		} else {
+			tx.Approvals = append(tx.Approvals, vmctx.Message().From)
+		}
+		return state.settle(vmctx.Message().To, key, tx)
+	})
+	if err != nil {
+		return errors.CodeError(err), err
+	}
+
+	return ma.send(vmctx, out)
+}
+
+// Cancel removes a pending transaction. Only the signer who proposed it may
+// cancel it.
+func (ma *Actor) Cancel(vmctx exec.VMContext, txID *big.Int) (uint8, error) {
+	if err := vmctx.Charge(100); err != nil {
+		return exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
+	}
+
+	var state State
+	_, err := actor.WithState(vmctx, &state, func() (interface{}, error) {
+		key := txKey(txID.Uint64())
+		tx, ok := state.Pending[key]
+		if !ok {
+			return nil, Errors[ErrUnknownTransaction]
+		}
+		if tx.Approvals[0] != vmctx.Message().From {
+			return nil, Errors[ErrNotProposer]
+		}
+
+		delete(state.Pending, key)
+		return nil, nil
+	})
+	if err != nil {
+		return errors.CodeError(err), err
+	}
+
+	return 0, nil
+}
+
+// GetState returns the cbor-encoded state of the wallet.
+func (ma *Actor) GetState(vmctx exec.VMContext) ([]byte, uint8, error) {
+	if err := vmctx.Charge(100); err != nil {
+		return nil, exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
+	}
+
+	var state State
+	out, err := actor.WithState(vmctx, &state, func() (interface{}, error) {
+		return cbor.DumpObject(state)
+	})
+	if err != nil {
+		return nil, errors.CodeError(err), err
+	}
+
+	stateBytes, ok := out.([]byte)
+	if !ok {
+		return nil, 1, errors.NewFaultErrorf("expected a []byte return value from call, but got %T instead", out)
+	}
+
+	return stateBytes, 0, nil
+}
+
+// propose records tx as a pending transaction approved by the caller, and
+// sends it if that is enough approvals.
+func (ma *Actor) propose(vmctx exec.VMContext, tx Transaction) (*big.Int, uint8, error) {
+	if tx.To == vmctx.Message().To && tx.Method != "changeThreshold" {
+		return nil, errors.CodeError(Errors[ErrUnsupportedSelfCall]), Errors[ErrUnsupportedSelfCall]
+	}
+
+	var txID uint64
+
+	var state State
+	out, err := actor.WithState(vmctx, &state, func() (interface{}, error) {
+		if !isSigner(state.Signers, vmctx.Message().From) {
+			return nil, Errors[ErrNotSigner]
+		}
+
+		txID = state.NextTxID
+		state.NextTxID++
+
+		tx.Approvals = []address.Address{vmctx.Message().From}
+		return state.settle(vmctx.Message().To, txKey(txID), tx)
+	})
+	if err != nil {
+		return nil, errors.CodeError(err), err
+	}
+
+	code, err := ma.send(vmctx, out)
+	if err != nil {
+		return nil, code, err
+	}
+
+	return big.NewInt(0).SetUint64(txID), 0, nil
+}
+
+// settle stores tx as pending under key if it needs more approvals. Otherwise
+// it removes it and returns it, to be sent once the state has been written.
+// Threshold changes, which are transactions calling the wallet at self, are
+// applied immediately instead.
+func (state *State) settle(self address.Address, key string, tx Transaction) (interface{}, error) {
+	if uint64(len(tx.Approvals)) < state.Threshold {
+		state.Pending[key] = tx
+		return nil, nil
+	}
+
+	delete(state.Pending, key)
+
+	if tx.To != self {
+		return &tx, nil
+	}
+	if tx.Method != "changeThreshold" {
+		return nil, Errors[ErrUnsupportedSelfCall]
+	}
+
+	params, err := abi.DecodeTypedValues(tx.Params)
+	if err != nil || len(params) != 1 {
+		return nil, Errors[ErrInvalidParams]
+	}
+	threshold, ok := params[0].Val.(*big.Int)
+	if !ok {
+		return nil, Errors[ErrInvalidParams]
+	}
+	if !validThreshold(threshold, len(state.Signers)) {
+		return nil, Errors[ErrInvalidThreshold]
+	}
+	state.Threshold = threshold.Uint64()
+
+	return nil, nil
+}
+
+// send sends an approved transaction returned by settle, if any.
+func (ma *Actor) send(vmctx exec.VMContext, out interface{}) (uint8, error) {
+	tx, ok := out.(*Transaction)
+	if !ok || tx == nil {
+		return 0, nil
+	}
+
+	params, err := abi.DecodeTypedValues(tx.Params)
+	if err != nil {
+		return errors.CodeError(Errors[ErrInvalidParams]), Errors[ErrInvalidParams]
+	}
+
+	_, code, err := vmctx.Send(tx.To, tx.Method, tx.Value, abi.FromValues(params))
+	if err != nil {
+		return code, err
+	}
+
+	return 0, nil
+}
+
+func validThreshold(threshold *big.Int, signers int) bool {
+	return threshold.Sign() > 0 && threshold.Cmp(big.NewInt(int64(signers))) <= 0
+}
+
+func isSigner(signers []address.Address, addr address.Address) bool {
+	for _, signer := range signers {
+		if signer == addr {
+			return true
+		}
+	}
+	return false
+}
+
+func uniqueAddresses(addrs []address.Address) []address.Address {
+	var out []address.Address
+	for _, addr := range addrs {
+		if !isSigner(out, addr) {
+			out = append(out, addr)
+		}
+	}
+	return out
+}
+
+func txKey(txID uint64) string {
+	// TODO: use uint64 instead of this abomination, once refmt is fixed
+	// https://github.com/polydawn/refmt/issues/35
+	return strconv.FormatUint(txID, 10)
+}
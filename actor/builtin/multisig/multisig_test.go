@@ -0,0 +1,225 @@
+package multisig_test
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	cbor "gx/ipfs/QmRoARq3nkUb13HSKZGepCZSWe5GrVPwx7xURJGZ7KWv9V/go-ipld-cbor"
+
+	"github.com/filecoin-project/go-filecoin/abi"
+	"github.com/filecoin-project/go-filecoin/actor"
+	. "github.com/filecoin-project/go-filecoin/actor/builtin/multisig"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/core"
+	"github.com/filecoin-project/go-filecoin/state"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type multisigTestSystem struct {
+	t      *testing.T
+	st     state.Tree
+	vms    vm.StorageMap
+	wallet address.Address
+}
+
+// setup creates a 2-of-2 wallet controlled by address.TestAddress and
+// address.TestAddress2, holding 100 FIL.
+func setup(t *testing.T) *multisigTestSystem {
+	require := require.New(t)
+	ctx := context.Background()
+	st, vms := core.CreateStorages(ctx, t)
+
+	signers := []address.Address{address.TestAddress, address.TestAddress2}
+	res, err := th.CreateAndApplyTestMessage(t, st, vms, address.MultisigAddress, 100, 0, "create", signers, big.NewInt(2))
+	require.NoError(err)
+	require.NoError(res.ExecutionError)
+
+	wallet, err := address.NewFromBytes(res.Receipt.Return[0])
+	require.NoError(err)
+
+	return &multisigTestSystem{t: t, st: st, vms: vms, wallet: wallet}
+}
+
+func (sys *multisigTestSystem) call(from address.Address, method string, params ...interface{}) *consensus.ApplicationResult {
+	msg := types.NewMessage(from, sys.wallet, 0, types.NewZeroAttoFIL(), method, actor.MustConvertParams(params...))
+	res, err := th.ApplyTestMessage(sys.st, sys.vms, msg, types.NewBlockHeight(0))
+	require.NoError(sys.t, err)
+	return res
+}
+
+func (sys *multisigTestSystem) state() State {
+	ret, code, err := consensus.CallQueryMethod(context.Background(), sys.st, sys.vms, sys.wallet, "getState", nil, address.TestAddress, nil)
+	require.NoError(sys.t, err)
+	require.Equal(sys.t, uint8(0), code)
+
+	var st State
+	require.NoError(sys.t, cbor.DecodeInto(ret[0], &st))
+	return st
+}
+
+func (sys *multisigTestSystem) balance(addr address.Address) *types.AttoFIL {
+	act, err := sys.st.GetActor(context.Background(), addr)
+	if err != nil {
+		return types.NewZeroAttoFIL()
+	}
+	return act.Balance
+}
+
+func TestMultisigCreate(t *testing.T) {
+	assert := assert.New(t)
+
+	sys := setup(t)
+
+	assert.Equal(types.NewAttoFILFromFIL(100), sys.balance(sys.wallet))
+
+	st := sys.state()
+	assert.Equal([]address.Address{address.TestAddress, address.TestAddress2}, st.Signers)
+	assert.Equal(uint64(2), st.Threshold)
+	assert.Empty(st.Pending)
+
+	t.Run("threshold may not exceed the number of signers", func(t *testing.T) {
+		res, err := th.CreateAndApplyTestMessage(t, sys.st, sys.vms, address.MultisigAddress, 0, 0, "create", []address.Address{address.TestAddress}, big.NewInt(2))
+		require.NoError(t, err)
+		assert.Equal(uint8(ErrInvalidThreshold), res.Receipt.ExitCode)
+	})
+
+	t.Run("wallets cannot create wallets", func(t *testing.T) {
+		res := sys.call(address.TestAddress, "create", []address.Address{address.TestAddress}, big.NewInt(1))
+		assert.Equal(uint8(ErrNotFactory), res.Receipt.ExitCode)
+	})
+}
+
+func TestMultisigProposeAndApprove(t *testing.T) {
+	require := require.New(t)
+
+	sys := setup(t)
+	target := address.NewForTestGetter()()
+
+	res := sys.call(address.TestAddress, "propose", target, types.NewAttoFILFromFIL(10), "", []byte{})
+	require.NoError(res.ExecutionError)
+	txID := big.NewInt(0).SetBytes(res.Receipt.Return[0])
+
+	// one approval is not enough
+	require.Equal(types.NewZeroAttoFIL(), sys.balance(target))
+	require.Len(sys.state().Pending, 1)
+
+	res = sys.call(address.TestAddress, "approve", txID)
+	require.Equal(uint8(ErrAlreadyApproved), res.Receipt.ExitCode)
+
+	res = sys.call(address.NetworkAddress, "approve", txID)
+	require.Equal(uint8(ErrNotSigner), res.Receipt.ExitCode)
+
+	res = sys.call(address.TestAddress2, "approve", txID)
+	require.NoError(res.ExecutionError)
+
+	require.Equal(types.NewAttoFILFromFIL(10), sys.balance(target))
+	require.Equal(types.NewAttoFILFromFIL(90), sys.balance(sys.wallet))
+	require.Empty(sys.state().Pending)
+
+	res = sys.call(address.TestAddress2, "approve", txID)
+	require.Equal(uint8(ErrUnknownTransaction), res.Receipt.ExitCode)
+}
+
+func TestMultisigProposedCallsCarryParams(t *testing.T) {
+	require := require.New(t)
+
+	sys := setup(t)
+	params, err := abi.ToEncodedTypedValues(big.NewInt(1))
+	require.NoError(err)
+
+	// a transaction which changes the threshold to one through the wallet's
+	// own method, as ChangeThreshold would propose it
+	res := sys.call(address.TestAddress, "propose", sys.wallet, types.NewZeroAttoFIL(), "changeThreshold", params)
+	require.NoError(res.ExecutionError)
+	txID := big.NewInt(0).SetBytes(res.Receipt.Return[0])
+
+	res = sys.call(address.TestAddress2, "approve", txID)
+	require.NoError(res.ExecutionError)
+	require.Equal(uint64(1), sys.state().Threshold)
+
+	res = sys.call(address.TestAddress, "propose", sys.wallet, types.NewZeroAttoFIL(), "cancel", params)
+	require.Equal(uint8(ErrUnsupportedSelfCall), res.Receipt.ExitCode)
+}
+
+func TestMultisigCancel(t *testing.T) {
+	require := require.New(t)
+
+	sys := setup(t)
+
+	res := sys.call(address.TestAddress, "propose", address.TestAddress, types.NewAttoFILFromFIL(10), "", []byte{})
+	require.NoError(res.ExecutionError)
+	txID := big.NewInt(0).SetBytes(res.Receipt.Return[0])
+
+	res = sys.call(address.TestAddress2, "cancel", txID)
+	require.Equal(uint8(ErrNotProposer), res.Receipt.ExitCode)
+
+	res = sys.call(address.TestAddress, "cancel", txID)
+	require.NoError(res.ExecutionError)
+	require.Empty(sys.state().Pending)
+
+	res = sys.call(address.TestAddress2, "approve", txID)
+	require.Equal(uint8(ErrUnknownTransaction), res.Receipt.ExitCode)
+}
+
+func TestMultisigChangeThreshold(t *testing.T) {
+	require := require.New(t)
+
+	sys := setup(t)
+	target := address.NewForTestGetter()()
+
+	res := sys.call(address.TestAddress, "changeThreshold", big.NewInt(3))
+	require.NoError(res.ExecutionError)
+	txID := big.NewInt(0).SetBytes(res.Receipt.Return[0])
+
+	// the threshold is validated once the change is approved
+	res = sys.call(address.TestAddress2, "approve", txID)
+	require.Equal(uint8(ErrInvalidThreshold), res.Receipt.ExitCode)
+
+	res = sys.call(address.TestAddress, "changeThreshold", big.NewInt(1))
+	require.NoError(res.ExecutionError)
+	txID = big.NewInt(0).SetBytes(res.Receipt.Return[0])
+
+	res = sys.call(address.TestAddress2, "approve", txID)
+	require.NoError(res.ExecutionError)
+	require.Equal(uint64(1), sys.state().Threshold)
+
+	// with a threshold of one, proposals are sent immediately
+	res = sys.call(address.TestAddress2, "propose", target, types.NewAttoFILFromFIL(5), "", []byte{})
+	require.NoError(res.ExecutionError)
+	require.Equal(types.NewAttoFILFromFIL(5), sys.balance(target))
+}
+
+func TestMultisigApproveAgainAfterThresholdLowered(t *testing.T) {
+	require := require.New(t)
+
+	sys := setup(t)
+	target := address.NewForTestGetter()()
+
+	res := sys.call(address.TestAddress, "propose", target, types.NewAttoFILFromFIL(10), "", []byte{})
+	require.NoError(res.ExecutionError)
+	txID := big.NewInt(0).SetBytes(res.Receipt.Return[0])
+
+	res = sys.call(address.TestAddress, "changeThreshold", big.NewInt(1))
+	require.NoError(res.ExecutionError)
+	changeID := big.NewInt(0).SetBytes(res.Receipt.Return[0])
+	res = sys.call(address.TestAddress2, "approve", changeID)
+	require.NoError(res.ExecutionError)
+	require.Equal(uint64(1), sys.state().Threshold)
+
+	// lowering the threshold does not send the pending transaction
+	require.Equal(types.NewZeroAttoFIL(), sys.balance(target))
+	require.Len(sys.state().Pending, 1)
+
+	// its proposer's approval now meets the threshold, approving again sends it
+	res = sys.call(address.TestAddress, "approve", txID)
+	require.NoError(res.ExecutionError)
+	require.Equal(types.NewAttoFILFromFIL(10), sys.balance(target))
+	require.Empty(sys.state().Pending)
+}
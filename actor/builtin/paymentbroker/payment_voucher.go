@@ -4,21 +4,75 @@ import (
 	cbor "gx/ipfs/QmRoARq3nkUb13HSKZGepCZSWe5GrVPwx7xURJGZ7KWv9V/go-ipld-cbor"
 	"gx/ipfs/QmekxXDhCxCJRNuzmHreuaT3BsuJcsjcXWNrtV9C8DRHtd/go-multibase"
 
+	"github.com/filecoin-project/go-filecoin/abi"
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
 func init() {
 	cbor.RegisterCborType(PaymentVoucher{})
+	cbor.RegisterCborType(Condition{})
+}
+
+// Condition is a call which must succeed for a voucher to be redeemed. When the
+// voucher is redeemed the payment broker queries the actor at To, calling
+// Method with Params without changing any state, and refuses payment if it
+// fails.
+type Condition struct {
+	To     address.Address `json:"to"`
+	Method string          `json:"method"`
+	// Params are the call's params, encoded with abi.EncodeTypedValues.
+	Params []byte `json:"params"`
+}
+
+// NewCondition creates a condition calling method on the actor at to with the
+// given params.
+func NewCondition(to address.Address, method string, params ...interface{}) (*Condition, error) {
+	encodedParams, err := abi.ToEncodedTypedValues(params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Condition{
+		To:     to,
+		Method: method,
+		Params: encodedParams,
+	}, nil
+}
+
+// EncodeCondition returns the bytes passed for a condition in redeem and close
+// messages. A nil condition is encoded as no bytes.
+func EncodeCondition(condition *Condition) ([]byte, error) {
+	if condition == nil {
+		return []byte{}, nil
+	}
+	return cbor.DumpObject(condition)
+}
+
+// DecodeCondition decodes a condition encoded by EncodeCondition.
+func DecodeCondition(data []byte) (*Condition, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var condition Condition
+	if err := cbor.DecodeInto(data, &condition); err != nil {
+		return nil, err
+	}
+
+	return &condition, nil
 }
 
 // PaymentVoucher is a voucher for a payment channel that can be transferred off-chain but guarantees a future payment.
+// A voucher with a Condition can only be redeemed while the condition holds.
 type PaymentVoucher struct {
 	Channel   types.ChannelID   `json:"channel"`
+	Lane      uint64            `json:"lane"`
 	Payer     address.Address   `json:"payer"`
 	Target    address.Address   `json:"target"`
 	Amount    types.AttoFIL     `json:"amount"`
 	ValidAt   types.BlockHeight `json:"valid_at"`
+	Condition *Condition        `json:"condition"`
 	Signature types.Signature   `json:"signature"`
 }
 
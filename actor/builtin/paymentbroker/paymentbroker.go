@@ -2,10 +2,13 @@ package paymentbroker
 
 import (
 	"context"
+	"math/big"
+	"strconv"
 
 	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
 	"gx/ipfs/QmRXf2uUSdGSunRJsM9wXSUNVwLUGCY3So5fAs7h2CBJVf/go-hamt-ipld"
 	cbor "gx/ipfs/QmRoARq3nkUb13HSKZGepCZSWe5GrVPwx7xURJGZ7KWv9V/go-ipld-cbor"
+	"gx/ipfs/QmSKyB5faguXT4NqbrXpnRXqaVj5DhSm7x9BtzFydBY1UK/go-leb128"
 
 	"github.com/filecoin-project/go-filecoin/abi"
 	"github.com/filecoin-project/go-filecoin/actor"
@@ -38,6 +41,10 @@ const (
 	ErrInvalidSignature = 42
 	//ErrTooEarly indicates that the block height is too low to satisfy a voucher
 	ErrTooEarly = 43
+	// ErrConditionFailed indicates the condition of a voucher does not hold.
+	ErrConditionFailed = 44
+	// ErrInvalidLane indicates a voucher lane which is not a uint64.
+	ErrInvalidLane = 45
 )
 
 // Errors map error codes to revert errors this actor may return.
@@ -53,6 +60,8 @@ var Errors = map[uint8]error{
 	ErrExpired:                  errors.NewCodedRevertError(ErrExpired, "block height has exceeded channel's end of life"),
 	ErrAlreadyWithdrawn:         errors.NewCodedRevertError(ErrAlreadyWithdrawn, "update amount has already been redeemed"),
 	ErrInvalidSignature:         errors.NewCodedRevertErrorf(ErrInvalidSignature, "signature failed to validate"),
+	ErrConditionFailed:          errors.NewCodedRevertError(ErrConditionFailed, "voucher condition does not hold"),
+	ErrInvalidLane:              errors.NewCodedRevertError(ErrInvalidLane, "voucher lane must be a uint64"),
 }
 
 func init() {
@@ -60,11 +69,39 @@ func init() {
 }
 
 // PaymentChannel records the intent to pay funds to a target account.
+//
+// Vouchers for a channel are issued in independent lanes, so that concurrent
+// deals between the same payer and target can share a channel. Within a lane
+// each voucher's amount is the total paid in that lane so far; AmountRedeemed
+// is the sum over all lanes.
 type PaymentChannel struct {
 	Target         address.Address    `json:"target"`
 	Amount         *types.AttoFIL     `json:"amount"`
 	AmountRedeemed *types.AttoFIL     `json:"amount_redeemed"`
 	Eol            *types.BlockHeight `json:"eol"`
+	// LaneRedeemed maps stringified lane numbers (uint64) to the amount
+	// redeemed in the lane. Lanes without redemptions are absent.
+	LaneRedeemed map[string]*types.AttoFIL `json:"lane_redeemed"`
+}
+
+// RedeemedInLane returns the amount redeemed so far in the given lane.
+func (pc *PaymentChannel) RedeemedInLane(lane uint64) *types.AttoFIL {
+	if redeemed, ok := pc.LaneRedeemed[laneKey(lane)]; ok {
+		return redeemed
+	}
+	return types.NewZeroAttoFIL()
+}
+
+// isValidLane returns true if a lane passed to the actor fits in a uint64, the
+// type lanes are signed and recorded as.
+func isValidLane(lane *big.Int) bool {
+	return lane != nil && lane.Sign() >= 0 && lane.IsUint64()
+}
+
+// laneKey returns the key of a lane in LaneRedeemed.
+// TODO: use uint64 keys instead of strings once refmt supports them.
+func laneKey(lane uint64) string {
+	return strconv.FormatUint(lane, 10)
 }
 
 // Actor provides a mechanism for off chain payments.
@@ -87,8 +124,12 @@ func (pb *Actor) Exports() exec.Exports {
 var _ exec.ExecutableActor = (*Actor)(nil)
 
 var paymentBrokerExports = exec.Exports{
+	"addFunds": &exec.FunctionSignature{
+		Params: []abi.Type{abi.ChannelID},
+		Return: nil,
+	},
 	"close": &exec.FunctionSignature{
-		Params: []abi.Type{abi.Address, abi.ChannelID, abi.AttoFIL, abi.BlockHeight, abi.Bytes},
+		Params: []abi.Type{abi.Address, abi.ChannelID, abi.Integer, abi.AttoFIL, abi.BlockHeight, abi.Bytes, abi.Bytes},
 		Return: nil,
 	},
 	"createChannel": &exec.FunctionSignature{
@@ -108,11 +149,11 @@ var paymentBrokerExports = exec.Exports{
 		Return: nil,
 	},
 	"redeem": &exec.FunctionSignature{
-		Params: []abi.Type{abi.Address, abi.ChannelID, abi.AttoFIL, abi.BlockHeight, abi.Bytes},
+		Params: []abi.Type{abi.Address, abi.ChannelID, abi.Integer, abi.AttoFIL, abi.BlockHeight, abi.Bytes, abi.Bytes},
 		Return: nil,
 	},
 	"voucher": &exec.FunctionSignature{
-		Params: []abi.Type{abi.ChannelID, abi.AttoFIL, abi.BlockHeight},
+		Params: []abi.Type{abi.ChannelID, abi.Integer, abi.AttoFIL, abi.BlockHeight},
 		Return: []abi.Type{abi.Bytes},
 	},
 }
@@ -151,6 +192,7 @@ func (pb *Actor) CreateChannel(vmctx exec.VMContext, target address.Address, eol
 			Amount:         vmctx.Message().Value,
 			AmountRedeemed: types.NewAttoFILFromFIL(0),
 			Eol:            eol,
+			LaneRedeemed:   map[string]*types.AttoFIL{},
 		})
 		if err != nil {
 			return errors.FaultErrorWrap(err, "Could not set payment channel")
@@ -173,28 +215,44 @@ func (pb *Actor) CreateChannel(vmctx exec.VMContext, target address.Address, eol
 // Redeem is called by the target account to withdraw funds with authorization from the payer.
 // This method is exactly like Close except it doesn't close the channel.
 // This is useful when you want to checkpoint the value in a payment, but continue to use the
-// channel afterwards. The amt represents the total funds authorized so far, so that subsequent
-// calls to Update will only transfer the difference between the given amt and the greatest
-// amt taken so far. A series of channel transactions might look like this:
+// channel afterwards. The amt represents the total funds authorized so far in the voucher's lane,
+// so that subsequent calls to Update will only transfer the difference between the given amt and
+// the greatest amt taken so far in that lane. A series of channel transactions in one lane might
+// look like this:
 //                                Payer: 2000, Target: 0, Channel: 0
 // payer createChannel(1000)   -> Payer: 1000, Target: 0, Channel: 1000
 // target Redeem(100)          -> Payer: 1000, Target: 100, Channel: 900
 // target Redeem(200)          -> Payer: 1000, Target: 200, Channel: 800
 // target Close(500)           -> Payer: 1500, Target: 500, Channel: 0
 //
-func (pb *Actor) Redeem(vmctx exec.VMContext, payer address.Address, chid *types.ChannelID, amt *types.AttoFIL, validAt *types.BlockHeight, sig []byte) (uint8, error) {
+// If the voucher has a condition, given encoded by EncodeCondition, the
+// condition is called first and the voucher is only redeemed if it succeeds.
+func (pb *Actor) Redeem(vmctx exec.VMContext, payer address.Address, chid *types.ChannelID, lane *big.Int, amt *types.AttoFIL, validAt *types.BlockHeight, condition []byte, sig []byte) (uint8, error) {
 	if err := vmctx.Charge(100); err != nil {
 		return exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
 	}
 
-	if !VerifyVoucherSignature(payer, chid, amt, validAt, sig) {
+	if !isValidLane(lane) {
+		return errors.CodeError(Errors[ErrInvalidLane]), Errors[ErrInvalidLane]
+	}
+
+	cond, err := DecodeCondition(condition)
+	if err != nil {
+		return errors.CodeError(Errors[ErrInvalidSignature]), Errors[ErrInvalidSignature]
+	}
+
+	if !VerifyVoucherSignature(payer, chid, lane.Uint64(), amt, validAt, cond, sig) {
 		return errors.CodeError(Errors[ErrInvalidSignature]), Errors[ErrInvalidSignature]
 	}
 
+	if err := checkCondition(vmctx, cond); err != nil {
+		return errors.CodeError(err), err
+	}
+
 	ctx := context.Background()
 	storage := vmctx.Storage()
 
-	err := withPayerChannels(ctx, storage, payer, func(byChannelID exec.Lookup) error {
+	err = withPayerChannels(ctx, storage, payer, func(byChannelID exec.Lookup) error {
 		var channel *PaymentChannel
 
 		chInt, err := byChannelID.Find(ctx, chid.KeyString())
@@ -211,7 +269,7 @@ func (pb *Actor) Redeem(vmctx exec.VMContext, payer address.Address, chid *types
 		}
 
 		// validate the amount can be sent to the target and send payment to that address.
-		err = updateChannel(vmctx, vmctx.Message().From, channel, amt, validAt)
+		err = updateChannel(vmctx, vmctx.Message().From, channel, lane.Uint64(), amt, validAt)
 		if err != nil {
 			return err
 		}
@@ -232,19 +290,32 @@ func (pb *Actor) Redeem(vmctx exec.VMContext, payer address.Address, chid *types
 
 // Close first executes the logic performed in the the Update method, then returns all
 // funds remaining in the channel to the payer account and deletes the channel.
-func (pb *Actor) Close(vmctx exec.VMContext, payer address.Address, chid *types.ChannelID, amt *types.AttoFIL, validAt *types.BlockHeight, sig []byte) (uint8, error) {
+func (pb *Actor) Close(vmctx exec.VMContext, payer address.Address, chid *types.ChannelID, lane *big.Int, amt *types.AttoFIL, validAt *types.BlockHeight, condition []byte, sig []byte) (uint8, error) {
 	if err := vmctx.Charge(100); err != nil {
 		return exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
 	}
 
-	if !VerifyVoucherSignature(payer, chid, amt, validAt, sig) {
+	if !isValidLane(lane) {
+		return errors.CodeError(Errors[ErrInvalidLane]), Errors[ErrInvalidLane]
+	}
+
+	cond, err := DecodeCondition(condition)
+	if err != nil {
+		return errors.CodeError(Errors[ErrInvalidSignature]), Errors[ErrInvalidSignature]
+	}
+
+	if !VerifyVoucherSignature(payer, chid, lane.Uint64(), amt, validAt, cond, sig) {
 		return errors.CodeError(Errors[ErrInvalidSignature]), Errors[ErrInvalidSignature]
 	}
 
+	if err := checkCondition(vmctx, cond); err != nil {
+		return errors.CodeError(err), err
+	}
+
 	ctx := context.Background()
 	storage := vmctx.Storage()
 
-	err := withPayerChannels(ctx, storage, payer, func(byChannelID exec.Lookup) error {
+	err = withPayerChannels(ctx, storage, payer, func(byChannelID exec.Lookup) error {
 		chInt, err := byChannelID.Find(ctx, chid.KeyString())
 		if err != nil {
 			if err == hamt.ErrNotFound {
@@ -259,7 +330,7 @@ func (pb *Actor) Close(vmctx exec.VMContext, payer address.Address, chid *types.
 		}
 
 		// validate the amount can be sent to the target and send payment to that address.
-		err = updateChannel(vmctx, vmctx.Message().From, channel, amt, validAt)
+		err = updateChannel(vmctx, vmctx.Message().From, channel, lane.Uint64(), amt, validAt)
 		if err != nil {
 			return err
 		}
@@ -334,6 +405,52 @@ func (pb *Actor) Extend(vmctx exec.VMContext, chid *types.ChannelID, eol *types.
 	return 0, nil
 }
 
+// AddFunds can be used by the owner of a channel to add the value of the
+// message to it without changing its lifespan.
+func (pb *Actor) AddFunds(vmctx exec.VMContext, chid *types.ChannelID) (uint8, error) {
+	if err := vmctx.Charge(100); err != nil {
+		return exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
+	}
+
+	ctx := context.Background()
+	storage := vmctx.Storage()
+	payerAddress := vmctx.Message().From
+
+	err := withPayerChannels(ctx, storage, payerAddress, func(byChannelID exec.Lookup) error {
+		chInt, err := byChannelID.Find(ctx, chid.KeyString())
+		if err != nil {
+			if err == hamt.ErrNotFound {
+				return Errors[ErrUnknownChannel]
+			}
+			return errors.FaultErrorWrapf(err, "Could not retrieve payment channel with ID: %s", chid)
+		}
+
+		channel, ok := chInt.(*PaymentChannel)
+		if !ok {
+			return errors.NewFaultError("Expected PaymentChannel from channels lookup")
+		}
+
+		// funds added to an expired channel could only be reclaimed
+		if vmctx.BlockHeight().GreaterEqual(channel.Eol) {
+			return Errors[ErrExpired]
+		}
+
+		channel.Amount = channel.Amount.Add(vmctx.Message().Value)
+
+		return byChannelID.Set(ctx, chid.KeyString(), channel)
+	})
+
+	if err != nil {
+		// ensure error is properly wrapped
+		if !errors.IsFault(err) && !errors.ShouldRevert(err) {
+			return 1, errors.FaultErrorWrap(err, "Error adding funds to channel")
+		}
+		return errors.CodeError(err), err
+	}
+
+	return 0, nil
+}
+
 // Reclaim is used by the owner of a channel to reclaim unspent funds in timed
 // out payment Channels they own.
 func (pb *Actor) Reclaim(vmctx exec.VMContext, chid *types.ChannelID) (uint8, error) {
@@ -379,16 +496,20 @@ func (pb *Actor) Reclaim(vmctx exec.VMContext, chid *types.ChannelID) (uint8, er
 	return 0, nil
 }
 
-// Voucher takes a channel id and amount creates a new unsigned PaymentVoucher
+// Voucher takes a channel id, lane and amount creates a new unsigned PaymentVoucher
 // against the given channel.  It also takes a block height parameter "validAt"
 // enforcing that the voucher is not reclaimed until the given block height
 // Voucher errors if the channel doesn't exist or contains less than request
 // amount.
-func (pb *Actor) Voucher(vmctx exec.VMContext, chid *types.ChannelID, amount *types.AttoFIL, validAt *types.BlockHeight) ([]byte, uint8, error) {
+func (pb *Actor) Voucher(vmctx exec.VMContext, chid *types.ChannelID, lane *big.Int, amount *types.AttoFIL, validAt *types.BlockHeight) ([]byte, uint8, error) {
 	if err := vmctx.Charge(100); err != nil {
 		return []byte{}, exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
 	}
 
+	if !isValidLane(lane) {
+		return []byte{}, errors.CodeError(Errors[ErrInvalidLane]), Errors[ErrInvalidLane]
+	}
+
 	ctx := context.Background()
 	storage := vmctx.Storage()
 	payerAddress := vmctx.Message().From
@@ -418,6 +539,7 @@ func (pb *Actor) Voucher(vmctx exec.VMContext, chid *types.ChannelID, amount *ty
 		// set voucher
 		voucher = PaymentVoucher{
 			Channel: *chid,
+			Lane:    lane.Uint64(),
 			Payer:   vmctx.Message().From,
 			Target:  channel.Target,
 			Amount:  *amount,
@@ -487,7 +609,7 @@ func (pb *Actor) Ls(vmctx exec.VMContext, payer address.Address) ([]byte, uint8,
 	return channelsBytes, 0, nil
 }
 
-func updateChannel(ctx exec.VMContext, target address.Address, channel *PaymentChannel, amt *types.AttoFIL, validAt *types.BlockHeight) error {
+func updateChannel(ctx exec.VMContext, target address.Address, channel *PaymentChannel, lane uint64, amt *types.AttoFIL, validAt *types.BlockHeight) error {
 	if target != channel.Target {
 		return Errors[ErrWrongTarget]
 	}
@@ -500,23 +622,55 @@ func updateChannel(ctx exec.VMContext, target address.Address, channel *PaymentC
 		return Errors[ErrExpired]
 	}
 
-	if amt.GreaterThan(channel.Amount) {
-		return Errors[ErrInsufficientChannelFunds]
+	laneRedeemed := channel.RedeemedInLane(lane)
+	if amt.LessEqual(laneRedeemed) {
+		return Errors[ErrAlreadyWithdrawn]
 	}
 
-	if amt.LessEqual(channel.AmountRedeemed) {
-		return Errors[ErrAlreadyWithdrawn]
+	updateAmount := amt.Sub(laneRedeemed)
+	if channel.AmountRedeemed.Add(updateAmount).GreaterThan(channel.Amount) {
+		return Errors[ErrInsufficientChannelFunds]
 	}
 
 	// transfer funds to sender
-	updateAmount := amt.Sub(channel.AmountRedeemed)
 	_, _, err := ctx.Send(ctx.Message().From, "", updateAmount, nil)
 	if err != nil {
 		return err
 	}
 
-	// update amount redeemed from this channel
-	channel.AmountRedeemed = amt
+	// update amount redeemed from this channel and lane
+	if channel.LaneRedeemed == nil {
+		channel.LaneRedeemed = map[string]*types.AttoFIL{}
+	}
+	channel.LaneRedeemed[laneKey(lane)] = amt
+	channel.AmountRedeemed = channel.AmountRedeemed.Add(updateAmount)
+
+	return nil
+}
+
+// checkCondition queries a voucher's condition, if it has one, and returns an
+// error unless the call succeeds. The condition is a query so that the payer
+// cannot make the broker change the state of other actors.
+func checkCondition(vmctx exec.VMContext, condition *Condition) error {
+	if condition == nil {
+		return nil
+	}
+
+	params, err := abi.DecodeTypedValues(condition.Params)
+	if err != nil {
+		return Errors[ErrConditionFailed]
+	}
+
+	_, code, err := vmctx.Query(condition.To, condition.Method, abi.FromValues(params))
+	if err != nil {
+		if errors.IsFault(err) {
+			return err
+		}
+		return Errors[ErrConditionFailed]
+	}
+	if code != 0 {
+		return Errors[ErrConditionFailed]
+	}
 
 	return nil
 }
@@ -547,24 +701,43 @@ func reclaim(ctx context.Context, vmctx exec.VMContext, byChannelID exec.Lookup,
 const separator = 0x0
 
 // SignVoucher creates the signature for the given combination of
-// channel, amount, validAt (earliest block height for redeem) and from address.
-// It does so by signing the following bytes: (channelID | 0x0 | amount | 0x0 | validAt)
-func SignVoucher(channelID *types.ChannelID, amount *types.AttoFIL, validAt *types.BlockHeight, addr address.Address, signer types.Signer) (types.Signature, error) {
-	data := createVoucherSignatureData(channelID, amount, validAt)
+// channel, lane, amount, validAt (earliest block height for redeem), condition and from address.
+// It does so by signing the following bytes: (channelID | 0x0 | lane | 0x0 | amount | 0x0 | validAt),
+// followed by (0x0 | condition) if condition is not nil.
+func SignVoucher(channelID *types.ChannelID, lane uint64, amount *types.AttoFIL, validAt *types.BlockHeight, condition *Condition, addr address.Address, signer types.Signer) (types.Signature, error) {
+	data, err := createVoucherSignatureData(channelID, lane, amount, validAt, condition)
+	if err != nil {
+		return nil, err
+	}
 	return signer.SignBytes(data, addr)
 }
 
 // VerifyVoucherSignature returns whether the voucher's signature is valid
-func VerifyVoucherSignature(payer address.Address, chid *types.ChannelID, amt *types.AttoFIL, validAt *types.BlockHeight, sig []byte) bool {
-	data := createVoucherSignatureData(chid, amt, validAt)
+func VerifyVoucherSignature(payer address.Address, chid *types.ChannelID, lane uint64, amt *types.AttoFIL, validAt *types.BlockHeight, condition *Condition, sig []byte) bool {
+	data, err := createVoucherSignatureData(chid, lane, amt, validAt, condition)
+	if err != nil {
+		return false
+	}
 	return types.IsValidSignature(data, payer, sig)
 }
 
-func createVoucherSignatureData(channelID *types.ChannelID, amount *types.AttoFIL, validAt *types.BlockHeight) []byte {
+func createVoucherSignatureData(channelID *types.ChannelID, lane uint64, amount *types.AttoFIL, validAt *types.BlockHeight, condition *Condition) ([]byte, error) {
 	data := append(channelID.Bytes(), separator)
+	data = append(data, leb128.FromUInt64(lane)...)
+	data = append(data, separator)
 	data = append(data, amount.Bytes()...)
 	data = append(data, separator)
-	return append(data, validAt.Bytes()...)
+	data = append(data, validAt.Bytes()...)
+	if condition == nil {
+		return data, nil
+	}
+
+	conditionBytes, err := EncodeCondition(condition)
+	if err != nil {
+		return nil, err
+	}
+	data = append(data, separator)
+	return append(data, conditionBytes...), nil
 }
 
 func withPayerChannels(ctx context.Context, storage exec.Storage, payer address.Address, f func(exec.Lookup) error) error {
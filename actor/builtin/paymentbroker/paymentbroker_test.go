@@ -274,13 +274,13 @@ func TestPaymentBrokerCloseInvalidSig(t *testing.T) {
 	sys := setup(t)
 
 	amt := types.NewAttoFILFromFIL(100)
-	signature, err := sys.Signature(amt, sys.defaultValidAt)
+	signature, err := sys.Signature(0, amt, sys.defaultValidAt)
 	require.NoError(err)
 	// make the signature invalid
 	signature[0] = 0
 	signature[1] = 1
 
-	pdata := core.MustConvertParams(sys.payer, sys.channelID, amt, sys.defaultValidAt, ([]byte)(signature))
+	pdata := core.MustConvertParams(sys.payer, sys.channelID, big.NewInt(0), amt, sys.defaultValidAt, []byte{}, ([]byte)(signature))
 	msg := types.NewMessage(sys.target, address.PaymentBrokerAddress, 0, types.NewAttoFILFromFIL(0), "close", pdata)
 	res, err := sys.ApplyMessage(msg, 0)
 	require.EqualError(res.ExecutionError, Errors[ErrInvalidSignature].Error())
@@ -292,13 +292,13 @@ func TestPaymentBrokerRedeemInvalidSig(t *testing.T) {
 	sys := setup(t)
 
 	amt := types.NewAttoFILFromFIL(100)
-	signature, err := sys.Signature(amt, sys.defaultValidAt)
+	signature, err := sys.Signature(0, amt, sys.defaultValidAt)
 	require.NoError(err)
 	// make the signature invalid
 	signature[0] = 0
 	signature[1] = 1
 
-	pdata := core.MustConvertParams(sys.payer, sys.channelID, amt, sys.defaultValidAt, ([]byte)(signature))
+	pdata := core.MustConvertParams(sys.payer, sys.channelID, big.NewInt(0), amt, sys.defaultValidAt, []byte{}, ([]byte)(signature))
 	msg := types.NewMessage(sys.target, address.PaymentBrokerAddress, 0, types.NewAttoFILFromFIL(0), "redeem", pdata)
 	res, err := sys.ApplyMessage(msg, 0)
 	require.EqualError(res.ExecutionError, Errors[ErrInvalidSignature].Error())
@@ -412,6 +412,177 @@ func TestPaymentBrokerExtendRefusesToShortenTheEol(t *testing.T) {
 	assert.Contains(result.ExecutionError.Error(), "payment channel eol may not be decreased")
 }
 
+func TestPaymentBrokerAddFunds(t *testing.T) {
+	require := require.New(t)
+	sys := setup(t)
+
+	pdata := core.MustConvertParams(sys.channelID)
+	msg := types.NewMessage(sys.payer, address.PaymentBrokerAddress, 1, types.NewAttoFILFromFIL(500), "addFunds", pdata)
+	result, err := sys.ApplyMessage(msg, 9)
+	require.NoError(err)
+	require.NoError(result.ExecutionError)
+
+	paymentBroker := state.MustGetActor(sys.st, address.PaymentBrokerAddress)
+	require.Equal(types.NewAttoFILFromFIL(1500), paymentBroker.Balance)
+
+	channel := sys.retrieveChannel(paymentBroker)
+	require.Equal(types.NewAttoFILFromFIL(1500), channel.Amount)
+	require.Equal(types.NewBlockHeight(10), channel.Eol)
+
+	// the added funds can be redeemed
+	result, err = sys.ApplyRedeemMessage(sys.target, 1200, 0)
+	require.NoError(err)
+	require.NoError(result.ExecutionError)
+
+	t.Run("fails after eol", func(t *testing.T) {
+		msg := types.NewMessage(sys.payer, address.PaymentBrokerAddress, 2, types.NewAttoFILFromFIL(500), "addFunds", pdata)
+		result, err := sys.ApplyMessage(msg, 10)
+		require.NoError(err)
+		require.Equal(uint8(ErrExpired), result.Receipt.ExitCode)
+	})
+
+	t.Run("fails with non-existent channel", func(t *testing.T) {
+		pdata := core.MustConvertParams(types.NewChannelID(383))
+		msg := types.NewMessage(sys.payer, address.PaymentBrokerAddress, 2, types.NewAttoFILFromFIL(500), "addFunds", pdata)
+		result, err := sys.ApplyMessage(msg, 9)
+		require.NoError(err)
+		require.Equal(uint8(ErrUnknownChannel), result.Receipt.ExitCode)
+	})
+}
+
+func TestPaymentBrokerLanes(t *testing.T) {
+	require := require.New(t)
+	sys := setup(t)
+
+	// lanes are redeemed independently
+	result, err := sys.ApplyRedeemMessageInLane(sys.target, 0, 300, 0)
+	require.NoError(err)
+	require.NoError(result.ExecutionError)
+
+	result, err = sys.ApplyRedeemMessageInLane(sys.target, 1, 200, 1)
+	require.NoError(err)
+	require.NoError(result.ExecutionError)
+
+	result, err = sys.ApplyRedeemMessageInLane(sys.target, 1, 400, 2)
+	require.NoError(err)
+	require.NoError(result.ExecutionError)
+
+	payee := state.MustGetActor(sys.st, sys.target)
+	require.Equal(types.NewAttoFILFromFIL(700), payee.Balance)
+
+	channel := sys.retrieveChannel(state.MustGetActor(sys.st, address.PaymentBrokerAddress))
+	require.Equal(types.NewAttoFILFromFIL(700), channel.AmountRedeemed)
+	require.Equal(types.NewAttoFILFromFIL(300), channel.LaneRedeemed["0"])
+	require.Equal(types.NewAttoFILFromFIL(400), channel.LaneRedeemed["1"])
+
+	// a voucher already redeemed in its lane is rejected
+	result, err = sys.ApplyRedeemMessageInLane(sys.target, 1, 300, 3)
+	require.NoError(err)
+	require.Equal(uint8(ErrAlreadyWithdrawn), result.Receipt.ExitCode)
+
+	// lanes together may not redeem more than the channel holds
+	result, err = sys.ApplyRedeemMessageInLane(sys.target, 2, 301, 3)
+	require.NoError(err)
+	require.Equal(uint8(ErrInsufficientChannelFunds), result.Receipt.ExitCode)
+
+	result, err = sys.ApplyRedeemMessageInLane(sys.target, 2, 300, 4)
+	require.NoError(err)
+	require.NoError(result.ExecutionError)
+
+	t.Run("signatures cover the lane", func(t *testing.T) {
+		amt := types.NewAttoFILFromFIL(100)
+		signature, err := sys.Signature(0, amt, sys.defaultValidAt)
+		require.NoError(err)
+
+		require.True(VerifyVoucherSignature(sys.payer, sys.channelID, 0, amt, sys.defaultValidAt, nil, signature))
+		require.False(VerifyVoucherSignature(sys.payer, sys.channelID, 1, amt, sys.defaultValidAt, nil, signature))
+	})
+
+	t.Run("lanes which are not a uint64 are rejected", func(t *testing.T) {
+		amt := types.NewAttoFILFromFIL(100)
+		signature, err := sys.Signature(0, amt, sys.defaultValidAt)
+		require.NoError(err)
+
+		tooLarge := big.NewInt(0).Lsh(big.NewInt(1), 64)
+		for _, lane := range []*big.Int{big.NewInt(-1), tooLarge} {
+			for _, method := range []string{"redeem", "close"} {
+				pdata := core.MustConvertParams(sys.payer, sys.channelID, lane, amt, sys.defaultValidAt, []byte{}, signature)
+				msg := types.NewMessage(sys.target, address.PaymentBrokerAddress, 5, types.NewAttoFILFromFIL(0), method, pdata)
+				result, err := sys.ApplyMessage(msg, 0)
+				require.NoError(err)
+				require.Equal(uint8(ErrInvalidLane), result.Receipt.ExitCode)
+			}
+		}
+	})
+}
+
+func TestPaymentBrokerConditionalVouchers(t *testing.T) {
+	require := require.New(t)
+
+	sys := setup(t)
+
+	fakeActorCodeCid := types.NewCidForTestGetter()()
+	builtin.Actors[fakeActorCodeCid] = &actor.FakeActor{}
+	defer func() {
+		delete(builtin.Actors, fakeActorCodeCid)
+	}()
+	conditionAddr := sys.addressGetter()
+	state.MustSetActor(sys.st, conditionAddr, th.RequireNewFakeActor(require, sys.vms, conditionAddr, fakeActorCodeCid))
+
+	redeem := func(condition *Condition, signedCondition *Condition, amt *types.AttoFIL, nonce uint64) *consensus.ApplicationResult {
+		signature, err := SignVoucher(sys.channelID, 0, amt, sys.defaultValidAt, signedCondition, sys.payer, mockSigner)
+		require.NoError(err)
+		conditionBytes, err := EncodeCondition(condition)
+		require.NoError(err)
+
+		pdata := core.MustConvertParams(sys.payer, sys.channelID, big.NewInt(0), amt, sys.defaultValidAt, conditionBytes, ([]byte)(signature))
+		msg := types.NewMessage(sys.target, address.PaymentBrokerAddress, nonce, types.NewAttoFILFromFIL(0), "redeem", pdata)
+		res, err := sys.ApplyMessage(msg, 0)
+		require.NoError(err)
+		return res
+	}
+
+	t.Run("redeems when the condition succeeds", func(t *testing.T) {
+		condition, err := NewCondition(conditionAddr, "hasReturnValue")
+		require.NoError(err)
+
+		res := redeem(condition, condition, types.NewAttoFILFromFIL(100), 0)
+		require.NoError(res.ExecutionError)
+		require.Equal(uint8(0), res.Receipt.ExitCode)
+
+		channel := requireGetPaymentChannel(t, sys.ctx, sys.st, sys.vms, sys.payer, sys.channelID)
+		require.Equal(types.NewAttoFILFromFIL(100), channel.AmountRedeemed)
+	})
+
+	t.Run("refuses when the condition fails", func(t *testing.T) {
+		condition, err := NewCondition(conditionAddr, "returnRevertError")
+		require.NoError(err)
+
+		res := redeem(condition, condition, types.NewAttoFILFromFIL(200), 1)
+		require.EqualError(res.ExecutionError, Errors[ErrConditionFailed].Error())
+		require.Equal(uint8(ErrConditionFailed), res.Receipt.ExitCode)
+	})
+
+	t.Run("refuses a condition the payer did not sign", func(t *testing.T) {
+		condition, err := NewCondition(conditionAddr, "hasReturnValue")
+		require.NoError(err)
+
+		res := redeem(nil, condition, types.NewAttoFILFromFIL(200), 2)
+		require.EqualError(res.ExecutionError, Errors[ErrInvalidSignature].Error())
+	})
+
+	t.Run("refuses a condition changing state", func(t *testing.T) {
+		condition, err := NewCondition(conditionAddr, "sendTokens", sys.payer)
+		require.NoError(err)
+
+		res := redeem(condition, condition, types.NewAttoFILFromFIL(200), 3)
+		require.EqualError(res.ExecutionError, Errors[ErrConditionFailed].Error())
+
+		channel := requireGetPaymentChannel(t, sys.ctx, sys.st, sys.vms, sys.payer, sys.channelID)
+		require.Equal(types.NewAttoFILFromFIL(100), channel.AmountRedeemed)
+	})
+}
+
 func TestPaymentBrokerLs(t *testing.T) {
 	require := require.New(t)
 	assert := assert.New(t)
@@ -492,7 +663,7 @@ func TestNewPaymentBrokerVoucher(t *testing.T) {
 
 		// create voucher
 		voucherAmount := types.NewAttoFILFromFIL(100)
-		pdata := core.MustConvertParams(sys.channelID, voucherAmount, sys.defaultValidAt)
+		pdata := core.MustConvertParams(sys.channelID, big.NewInt(3), voucherAmount, sys.defaultValidAt)
 		msg := types.NewMessage(sys.payer, address.PaymentBrokerAddress, 1, nil, "voucher", pdata)
 		res, err := sys.ApplyMessage(msg, 9)
 		assert.NoError(err)
@@ -504,6 +675,7 @@ func TestNewPaymentBrokerVoucher(t *testing.T) {
 		require.NoError(err)
 
 		assert.Equal(*sys.channelID, voucher.Channel)
+		assert.Equal(uint64(3), voucher.Lane)
 		assert.Equal(sys.payer, voucher.Payer)
 		assert.Equal(sys.target, voucher.Target)
 		assert.Equal(*voucherAmount, voucher.Amount)
@@ -516,7 +688,7 @@ func TestNewPaymentBrokerVoucher(t *testing.T) {
 
 		// create voucher
 		voucherAmount := types.NewAttoFILFromFIL(100)
-		_, exitCode, err := sys.CallQueryMethod("voucher", 9, notChannelID, voucherAmount, sys.defaultValidAt)
+		_, exitCode, err := sys.CallQueryMethod("voucher", 9, notChannelID, big.NewInt(0), voucherAmount, sys.defaultValidAt)
 		assert.NotEqual(uint8(0), exitCode)
 		assert.Contains(fmt.Sprintf("%v", err), "unknown")
 	})
@@ -526,7 +698,7 @@ func TestNewPaymentBrokerVoucher(t *testing.T) {
 
 		// create voucher
 		voucherAmount := types.NewAttoFILFromFIL(2000)
-		args := core.MustConvertParams(sys.channelID, voucherAmount, sys.defaultValidAt)
+		args := core.MustConvertParams(sys.channelID, big.NewInt(0), voucherAmount, sys.defaultValidAt)
 
 		msg := types.NewMessage(sys.payer, address.PaymentBrokerAddress, 1, nil, "voucher", args)
 		res, err := sys.ApplyMessage(msg, 9)
@@ -615,8 +787,8 @@ func setup(t *testing.T) system {
 	}
 }
 
-func (sys *system) Signature(amt *types.AttoFIL, validAt *types.BlockHeight) ([]byte, error) {
-	sig, err := SignVoucher(sys.channelID, amt, validAt, sys.payer, mockSigner)
+func (sys *system) Signature(lane uint64, amt *types.AttoFIL, validAt *types.BlockHeight) ([]byte, error) {
+	sig, err := SignVoucher(sys.channelID, lane, amt, validAt, nil, sys.payer, mockSigner)
 	if err != nil {
 		return nil, err
 	}
@@ -634,19 +806,25 @@ func (sys *system) CallQueryMethod(method string, height uint64, params ...inter
 func (sys *system) ApplyRedeemMessage(target address.Address, amtInt uint64, nonce uint64) (*consensus.ApplicationResult, error) {
 	sys.t.Helper()
 
-	return sys.applySignatureMessage(target, amtInt, sys.defaultValidAt, nonce, "redeem", 0)
+	return sys.applySignatureMessage(target, 0, amtInt, sys.defaultValidAt, nonce, "redeem", 0)
+}
+
+func (sys *system) ApplyRedeemMessageInLane(target address.Address, lane uint64, amtInt uint64, nonce uint64) (*consensus.ApplicationResult, error) {
+	sys.t.Helper()
+
+	return sys.applySignatureMessage(target, lane, amtInt, sys.defaultValidAt, nonce, "redeem", 0)
 }
 
 func (sys *system) ApplyRedeemMessageWithBlockHeight(target address.Address, amtInt uint64, nonce uint64, height uint64) (*consensus.ApplicationResult, error) {
 	sys.t.Helper()
 
-	return sys.applySignatureMessage(target, amtInt, sys.defaultValidAt, nonce, "redeem", height)
+	return sys.applySignatureMessage(target, 0, amtInt, sys.defaultValidAt, nonce, "redeem", height)
 }
 
 func (sys *system) ApplyCloseMessage(target address.Address, amtInt uint64, nonce uint64) (*consensus.ApplicationResult, error) {
 	sys.t.Helper()
 
-	return sys.applySignatureMessage(target, amtInt, sys.defaultValidAt, nonce, "close", 0)
+	return sys.applySignatureMessage(target, 0, amtInt, sys.defaultValidAt, nonce, "close", 0)
 }
 
 func (sys *system) ApplySignatureMessageWithValidAtAndBlockHeight(target address.Address, amtInt uint64, nonce uint64, validAt uint64, height uint64, method string) (*consensus.ApplicationResult, error) {
@@ -656,7 +834,7 @@ func (sys *system) ApplySignatureMessageWithValidAtAndBlockHeight(target address
 		sys.t.Fatalf("method %s is not a signature method", method)
 	}
 
-	return sys.applySignatureMessage(target, amtInt, types.NewBlockHeight(validAt), nonce, method, height)
+	return sys.applySignatureMessage(target, 0, amtInt, types.NewBlockHeight(validAt), nonce, method, height)
 }
 
 func (sys *system) retrieveChannel(paymentBroker *actor.Actor) *PaymentChannel {
@@ -680,16 +858,16 @@ func (sys *system) retrieveChannel(paymentBroker *actor.Actor) *PaymentChannel {
 	return channel
 }
 
-func (sys *system) applySignatureMessage(target address.Address, amtInt uint64, validAt *types.BlockHeight, nonce uint64, method string, height uint64) (*consensus.ApplicationResult, error) {
+func (sys *system) applySignatureMessage(target address.Address, lane uint64, amtInt uint64, validAt *types.BlockHeight, nonce uint64, method string, height uint64) (*consensus.ApplicationResult, error) {
 	sys.t.Helper()
 
 	require := require.New(sys.t)
 
 	amt := types.NewAttoFILFromFIL(amtInt)
-	signature, err := sys.Signature(amt, validAt)
+	signature, err := sys.Signature(lane, amt, validAt)
 	require.NoError(err)
 
-	pdata := core.MustConvertParams(sys.payer, sys.channelID, amt, validAt, signature)
+	pdata := core.MustConvertParams(sys.payer, sys.channelID, big.NewInt(0).SetUint64(lane), amt, validAt, []byte{}, signature)
 	msg := types.NewMessage(target, address.PaymentBrokerAddress, nonce, types.NewAttoFILFromFIL(0), method, pdata)
 
 	return sys.ApplyMessage(msg, height)
@@ -0,0 +1,207 @@
+package vesting
+
+import (
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	cbor "gx/ipfs/QmRoARq3nkUb13HSKZGepCZSWe5GrVPwx7xURJGZ7KWv9V/go-ipld-cbor"
+
+	"github.com/filecoin-project/go-filecoin/abi"
+	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/exec"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm/errors"
+)
+
+const (
+	// ErrNotOwner indicates a caller other than the owner attempted to transfer funds.
+	ErrNotOwner = 33
+	// ErrInsufficientUnlockedFunds indicates an attempt to transfer more than has vested.
+	ErrInsufficientUnlockedFunds = 34
+	// ErrInvalidSchedule indicates a vesting schedule which ends before it starts.
+	ErrInvalidSchedule = 35
+)
+
+// Errors map error codes to revert errors this actor may return.
+var Errors = map[uint8]error{
+	ErrNotOwner:                  errors.NewCodedRevertError(ErrNotOwner, "only the owner may transfer funds"),
+	ErrInsufficientUnlockedFunds: errors.NewCodedRevertError(ErrInsufficientUnlockedFunds, "transfer exceeds unlocked funds"),
+	ErrInvalidSchedule:           errors.NewCodedRevertError(ErrInvalidSchedule, "vesting must not end before it starts"),
+}
+
+func init() {
+	cbor.RegisterCborType(State{})
+}
+
+// State is the state of a vesting account.
+type State struct {
+	// Owner is the only address which may transfer funds out of the account.
+	Owner address.Address
+	// Total is the amount which vests over the schedule.
+	Total *types.AttoFIL
+	// StartHeight is the block height before which nothing is unlocked.
+	StartHeight *types.BlockHeight
+	// EndHeight is the block height from which Total is unlocked.
+	EndHeight *types.BlockHeight
+	// Transferred is the amount the owner has transferred out so far.
+	Transferred *types.AttoFIL
+}
+
+// Unlocked returns the amount that has vested by height h: nothing before
+// StartHeight, all of Total from EndHeight, and a linear share in between.
+func (s *State) Unlocked(h *types.BlockHeight) *types.AttoFIL {
+	if h.GreaterEqual(s.EndHeight) {
+		return s.Total
+	}
+	if h.LessEqual(s.StartHeight) {
+		return types.NewZeroAttoFIL()
+	}
+
+	elapsed := h.Sub(s.StartHeight).AsBigInt()
+	duration := s.EndHeight.Sub(s.StartHeight).AsBigInt()
+
+	return s.Total.MulBigInt(elapsed).DivBigInt(duration)
+}
+
+// Spendable returns the amount the owner may still transfer at height h.
+func (s *State) Spendable(h *types.BlockHeight) *types.AttoFIL {
+	spendable := s.Unlocked(h).Sub(s.Transferred)
+	if spendable.IsNegative() {
+		return types.NewZeroAttoFIL()
+	}
+	return spendable
+}
+
+// Actor is an account whose funds are released to its owner linearly between
+// a start and an end height. Funds are created with the actor, typically in
+// the genesis block; any sent to it later are not released.
+type Actor struct{}
+
+// NewActor returns a new vesting account actor holding the given balance.
+// Its state must be initialized with InitializeState.
+func NewActor(balance *types.AttoFIL) *actor.Actor {
+	return actor.NewActor(types.VestingActorCodeCid, balance)
+}
+
+// InitializeState stores the actor's initial data structure. initializerData
+// must be a *State.
+func (va *Actor) InitializeState(storage exec.Storage, initializerData interface{}) error {
+	vestingState, ok := initializerData.(*State)
+	if !ok {
+		return errors.NewFaultError("Initial state to vesting actor is not a vesting.State struct")
+	}
+	if vestingState.EndHeight.LessThan(vestingState.StartHeight) {
+		return Errors[ErrInvalidSchedule]
+	}
+	if vestingState.Transferred == nil {
+		vestingState.Transferred = types.NewZeroAttoFIL()
+	}
+
+	stateBytes, err := cbor.DumpObject(vestingState)
+	if err != nil {
+		return err
+	}
+
+	id, err := storage.Put(stateBytes)
+	if err != nil {
+		return err
+	}
+
+	return storage.Commit(id, cid.Undef)
+}
+
+var _ exec.ExecutableActor = (*Actor)(nil)
+
+// Exports returns the actor's exports.
+func (va *Actor) Exports() exec.Exports {
+	return vestingExports
+}
+
+var vestingExports = exec.Exports{
+	"transfer": &exec.FunctionSignature{
+		Params: []abi.Type{abi.Address, abi.AttoFIL},
+		Return: nil,
+	},
+	"getSpendable": &exec.FunctionSignature{
+		Params: nil,
+		Return: []abi.Type{abi.AttoFIL},
+	},
+	"getState": &exec.FunctionSignature{
+		Params: nil,
+		Return: []abi.Type{abi.Bytes},
+	},
+}
+
+// Transfer sends value to an address. Only the owner may call it, and only
+// up to the amount that has vested and not yet been transferred.
+func (va *Actor) Transfer(vmctx exec.VMContext, to address.Address, value *types.AttoFIL) (uint8, error) {
+	if err := vmctx.Charge(100); err != nil {
+		return exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
+	}
+
+	var state State
+	_, err := actor.WithState(vmctx, &state, func() (interface{}, error) {
+		if vmctx.Message().From != state.Owner {
+			return nil, Errors[ErrNotOwner]
+		}
+		if value.GreaterThan(state.Spendable(vmctx.BlockHeight())) {
+			return nil, Errors[ErrInsufficientUnlockedFunds]
+		}
+
+		state.Transferred = state.Transferred.Add(value)
+		return nil, nil
+	})
+	if err != nil {
+		return errors.CodeError(err), err
+	}
+
+	if _, code, err := vmctx.Send(to, "", value, nil); err != nil {
+		return code, err
+	}
+
+	return 0, nil
+}
+
+// GetSpendable returns the amount the owner may transfer at the current
+// block height.
+func (va *Actor) GetSpendable(vmctx exec.VMContext) (*types.AttoFIL, uint8, error) {
+	if err := vmctx.Charge(100); err != nil {
+		return nil, exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
+	}
+
+	var state State
+	out, err := actor.WithState(vmctx, &state, func() (interface{}, error) {
+		return state.Spendable(vmctx.BlockHeight()), nil
+	})
+	if err != nil {
+		return nil, errors.CodeError(err), err
+	}
+
+	spendable, ok := out.(*types.AttoFIL)
+	if !ok {
+		return nil, 1, errors.NewFaultErrorf("expected a *AttoFIL return value from call, but got %T instead", out)
+	}
+
+	return spendable, 0, nil
+}
+
+// GetState returns the cbor-encoded state of the account.
+func (va *Actor) GetState(vmctx exec.VMContext) ([]byte, uint8, error) {
+	if err := vmctx.Charge(100); err != nil {
+		return nil, exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
+	}
+
+	var state State
+	out, err := actor.WithState(vmctx, &state, func() (interface{}, error) {
+		return cbor.DumpObject(state)
+	})
+	if err != nil {
+		return nil, errors.CodeError(err), err
+	}
+
+	stateBytes, ok := out.([]byte)
+	if !ok {
+		return nil, 1, errors.NewFaultErrorf("expected a []byte return value from call, but got %T instead", out)
+	}
+
+	return stateBytes, 0, nil
+}
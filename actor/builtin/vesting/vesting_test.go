@@ -0,0 +1,92 @@
+package vesting_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-filecoin/actor"
+	. "github.com/filecoin-project/go-filecoin/actor/builtin/vesting"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/core"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	"github.com/filecoin-project/go-filecoin/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnlocked(t *testing.T) {
+	assert := assert.New(t)
+
+	st := &State{
+		Total:       types.NewAttoFILFromFIL(100),
+		StartHeight: types.NewBlockHeight(10),
+		EndHeight:   types.NewBlockHeight(20),
+		Transferred: types.NewAttoFILFromFIL(30),
+	}
+
+	assert.Equal(types.NewZeroAttoFIL(), st.Unlocked(types.NewBlockHeight(0)))
+	assert.Equal(types.NewZeroAttoFIL(), st.Unlocked(types.NewBlockHeight(10)))
+	assert.Equal(types.NewAttoFILFromFIL(40), st.Unlocked(types.NewBlockHeight(14)))
+	assert.Equal(types.NewAttoFILFromFIL(100), st.Unlocked(types.NewBlockHeight(20)))
+	assert.Equal(types.NewAttoFILFromFIL(100), st.Unlocked(types.NewBlockHeight(25)))
+
+	assert.Equal(types.NewZeroAttoFIL(), st.Spendable(types.NewBlockHeight(12)))
+	assert.Equal(types.NewAttoFILFromFIL(10), st.Spendable(types.NewBlockHeight(14)))
+
+	immediate := &State{
+		Total:       types.NewAttoFILFromFIL(100),
+		StartHeight: types.NewBlockHeight(0),
+		EndHeight:   types.NewBlockHeight(0),
+		Transferred: types.NewZeroAttoFIL(),
+	}
+	assert.Equal(types.NewAttoFILFromFIL(100), immediate.Unlocked(types.NewBlockHeight(0)))
+}
+
+func TestTransfer(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	st, vms := core.CreateStorages(ctx, t)
+
+	owner := address.TestAddress2
+	addrGetter := address.NewForTestGetter()
+	vestingAddr := addrGetter()
+	target := addrGetter()
+
+	act := NewActor(types.NewAttoFILFromFIL(100))
+	initState := &State{
+		Owner:       owner,
+		Total:       types.NewAttoFILFromFIL(100),
+		StartHeight: types.NewBlockHeight(10),
+		EndHeight:   types.NewBlockHeight(20),
+	}
+	require.NoError((&Actor{}).InitializeState(vms.NewStorage(vestingAddr, act), initState))
+	require.NoError(st.SetActor(ctx, vestingAddr, act))
+
+	transfer := func(from address.Address, value uint64, height uint64) uint8 {
+		pdata := actor.MustConvertParams(target, types.NewAttoFILFromFIL(value))
+		msg := types.NewMessage(from, vestingAddr, 0, types.NewZeroAttoFIL(), "transfer", pdata)
+		res, err := th.ApplyTestMessage(st, vms, msg, types.NewBlockHeight(height))
+		require.NoError(err)
+		return res.Receipt.ExitCode
+	}
+
+	require.Equal(uint8(ErrInsufficientUnlockedFunds), transfer(owner, 1, 5))
+	require.Equal(uint8(ErrNotOwner), transfer(address.TestAddress, 1, 15))
+
+	// half has vested by height 15
+	require.Equal(uint8(ErrInsufficientUnlockedFunds), transfer(owner, 51, 15))
+	require.Equal(uint8(0), transfer(owner, 50, 15))
+	require.Equal(uint8(ErrInsufficientUnlockedFunds), transfer(owner, 1, 15))
+
+	require.Equal(uint8(0), transfer(owner, 50, 20))
+
+	targetActor, err := st.GetActor(ctx, target)
+	require.NoError(err)
+	require.Equal(types.NewAttoFILFromFIL(100), targetActor.Balance)
+
+	vestingActor, err := st.GetActor(ctx, vestingAddr)
+	require.NoError(err)
+	require.Equal(types.NewZeroAttoFIL(), vestingActor.Balance)
+}
@@ -96,13 +96,27 @@ func NewTestnet(hash []byte) Address {
 
 // New constructs a new address for the given nework.
 func New(network Network, hash []byte) Address {
+	return newWithVersion(network, Version, hash)
+}
+
+// NewBLS constructs a new address for the given network from the hash of a
+// BLS public key.
+func NewBLS(network Network, hash []byte) Address {
+	return newWithVersion(network, BLSVersion, hash)
+}
+
+func newWithVersion(network Network, version byte, hash []byte) Address {
 	var addr [Length]byte
 	addr[0] = network
-	addr[1] = Version
+	addr[1] = version
 	copy(addr[2:], hash)
 	return addr
 }
 
+func isKnownVersion(version byte) bool {
+	return version == Version || version == BLSVersion
+}
+
 // NewFromString tries to parse a given string into a filecoin address.
 func NewFromString(s string) (Address, error) {
 	networkString, version, hash, err := decode(s)
@@ -115,11 +129,11 @@ func NewFromString(s string) (Address, error) {
 		return Address{}, err
 	}
 
-	if version != Version {
+	if !isKnownVersion(version) {
 		return Address{}, ErrUnknownVersion
 	}
 
-	return New(network, hash), nil
+	return newWithVersion(network, version, hash), nil
 }
 
 // NewFromBytes tries to create an address from the given bytes.
@@ -134,11 +148,11 @@ func NewFromBytes(raw []byte) (Address, error) {
 	}
 
 	version := raw[1]
-	if version != Version {
+	if !isKnownVersion(version) {
 		return Address{}, ErrUnknownVersion
 	}
 
-	return New(network, raw[2:]), nil
+	return newWithVersion(network, version, raw[2:]), nil
 }
 
 // ParseError checks if the given address parses as a valid filecoin address.
@@ -152,7 +166,7 @@ func ParseError(addr string) error {
 		return errors.Wrap(err, "invalid network")
 	}
 
-	if version != Version {
+	if !isKnownVersion(version) {
 		return fmt.Errorf("invalid version: version=%d", version)
 	}
 
@@ -183,6 +197,11 @@ func (a Address) Hash() []byte {
 	return a[2:]
 }
 
+// IsBLS returns true if the address is derived from a BLS public key.
+func (a Address) IsBLS() bool {
+	return a.Version() == BLSVersion
+}
+
 // Format implements the Formatter interface.
 func (a Address) Format(f fmt.State, c rune) {
 	switch c {
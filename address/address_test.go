@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var hashes = make([][]byte, 5)
@@ -34,6 +35,26 @@ func TestNew(t *testing.T) {
 	assert.Len(a.String(), 41)
 }
 
+func TestNewBLS(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	a := NewBLS(Testnet, hashes[0])
+	assert.True(a.IsBLS())
+	assert.False(NewTestnet(hashes[0]).IsBLS())
+	assert.NotEqual(NewTestnet(hashes[0]), a)
+
+	fromString, err := NewFromString(a.String())
+	require.NoError(err)
+	assert.Equal(a, fromString)
+
+	fromBytes, err := NewFromBytes(a.Bytes())
+	require.NoError(err)
+	assert.Equal(a, fromBytes)
+
+	assert.NoError(ParseError(a.String()))
+}
+
 func TestValidAddresses(t *testing.T) {
 	testCases := []struct {
 		input  Address
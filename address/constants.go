@@ -13,6 +13,11 @@ const Length = 1 + 1 + HashLength
 // Version is the current version of the address format.
 const Version byte = 0
 
+// BLSVersion is the version of addresses whose hash is derived from a BLS
+// public key. Signatures for these addresses carry the public key, as BLS
+// signatures do not allow recovering it.
+const BLSVersion byte = 1
+
 // Base32Charset is the character set used for base32 encoding in addresses.
 const Base32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
 
@@ -42,6 +47,8 @@ var (
 	StorageMarketAddress Address
 	// PaymentBrokerAddress is the hard-coded address of the filecoin storage market
 	PaymentBrokerAddress Address
+	// MultisigAddress is the hard-coded address of the actor which creates multisig wallets
+	MultisigAddress Address
 )
 
 func init() {
@@ -59,4 +66,7 @@ func init() {
 
 	p := Hash([]byte("payments"))
 	PaymentBrokerAddress = NewMainnet(p)
+
+	m := Hash([]byte("multisig"))
+	MultisigAddress = NewMainnet(m)
 }
@@ -16,11 +16,12 @@ type Address interface {
 	Balance(ctx context.Context, addr address.Address) (*types.AttoFIL, error)
 	Import(ctx context.Context, f files.File) ([]address.Address, error)
 	Export(ctx context.Context, addrs []address.Address) ([]*types.KeyInfo, error)
+	InitHD(ctx context.Context, mnemonic string) ([]address.Address, error)
 }
 
 // Addrs is the interface that defines method to interact with addresses.
 type Addrs interface {
-	New(ctx context.Context) (address.Address, error)
+	New(ctx context.Context, keyType string) (address.Address, error)
 	Ls(ctx context.Context) ([]address.Address, error)
 	Lookup(ctx context.Context, addr address.Address) (peer.ID, error)
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/config"
 )
 
 // Daemon is the interface that defines methods to change the state of the daemon.
@@ -35,6 +36,10 @@ type DaemonInitConfig struct {
 	// AutoSealIntervalSeconds, when set, configures the daemon to check for and seal any staged sectors on an interval
 	AutoSealIntervalSeconds uint
 	DefaultAddress          address.Address
+	// Checkpoints, if set, are added to the trusted checkpoints the chain is synced from.
+	Checkpoints []config.Checkpoint
+	// WalletPassphrase, if set, is the passphrase the keys of the wallet are encrypted with.
+	WalletPassphrase []byte
 }
 
 // DaemonInitOpt is the signature a daemon init option has to fulfill.
@@ -102,3 +107,17 @@ func DefaultAddress(address address.Address) DaemonInitOpt {
 		dc.DefaultAddress = address
 	}
 }
+
+// WalletPassphrase sets the passphrase to encrypt the keys of the wallet with.
+func WalletPassphrase(passphrase []byte) DaemonInitOpt {
+	return func(dc *DaemonInitConfig) {
+		dc.WalletPassphrase = passphrase
+	}
+}
+
+// Checkpoint adds a trusted checkpoint to sync the chain from.
+func Checkpoint(cp config.Checkpoint) DaemonInitOpt {
+	return func(dc *DaemonInitConfig) {
+		dc.Checkpoints = append(dc.Checkpoints, cp)
+	}
+}
@@ -3,7 +3,6 @@ package impl
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"io"
 
 	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
@@ -63,8 +62,8 @@ func newNodeAddrs(api *nodeAPI) *nodeAddrs {
 	return &nodeAddrs{api: api}
 }
 
-func (api *nodeAddrs) New(ctx context.Context) (address.Address, error) {
-	return api.api.node.NewAddress()
+func (api *nodeAddrs) New(ctx context.Context, keyType string) (address.Address, error) {
+	return wallet.NewAddressOfType(api.api.node.Wallet, keyType)
 }
 
 func (api *nodeAddrs) Ls(ctx context.Context) ([]address.Address, error) {
@@ -88,14 +87,9 @@ func (api *nodeAddress) Import(ctx context.Context, f files.File) ([]address.Add
 		return nil, err
 	}
 
-	dsb := nd.Wallet.Backends(wallet.DSBackendType)
-	if len(dsb) != 1 {
-		return nil, fmt.Errorf("expected exactly one datastore wallet backend")
-	}
-
-	imp, ok := dsb[0].(wallet.Importer)
-	if !ok {
-		return nil, fmt.Errorf("datastore backend wallets should implement importer")
+	imp, err := wallet.KeyImporter(nd.Wallet)
+	if err != nil {
+		return nil, err
 	}
 
 	var out []address.Address
@@ -133,6 +127,29 @@ func (api *nodeAddress) Export(ctx context.Context, addrs []address.Address) ([]
 	return out, nil
 }
 
+// InitHD initializes the HD wallet with the seed of mnemonic and restores the
+// addresses which have an actor in the latest state.
+func (api *nodeAddress) InitHD(ctx context.Context, mnemonic string) ([]address.Address, error) {
+	nd := api.api.node
+
+	if err := wallet.InitHD(nd.Wallet, mnemonic); err != nil {
+		return nil, err
+	}
+
+	tree, err := nd.ChainReader.LatestState(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return wallet.RestoreHD(nd.Wallet, func(addr address.Address) (bool, error) {
+		_, err := tree.GetActor(ctx, addr)
+		if state.IsActorNotFoundError(err) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+}
+
 func parseKeyInfos(f files.File) ([]*types.KeyInfo, error) {
 	var kinfos []*types.KeyInfo
 	for {
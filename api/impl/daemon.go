@@ -91,6 +91,10 @@ func (nd *nodeDaemon) Init(ctx context.Context, opts ...api.DaemonInitOpt) error
 
 	initopts = append(initopts, node.AutoSealIntervalSecondsOpt(cfg.AutoSealIntervalSeconds))
 
+	if cfg.WalletPassphrase != nil {
+		initopts = append(initopts, node.WalletPassphraseOpt(cfg.WalletPassphrase))
+	}
+
 	if cfg.WithMiner != (address.Address{}) {
 		newConfig := rep.Config()
 		newConfig.Mining.MinerAddress = cfg.WithMiner
@@ -107,6 +111,14 @@ func (nd *nodeDaemon) Init(ctx context.Context, opts ...api.DaemonInitOpt) error
 		}
 	}
 
+	if len(cfg.Checkpoints) > 0 {
+		newConfig := rep.Config()
+		newConfig.Sync.Checkpoints = append(newConfig.Sync.Checkpoints, cfg.Checkpoints...)
+		if err := rep.ReplaceConfig(newConfig); err != nil {
+			return err
+		}
+	}
+
 	if cfg.DevnetTest && cfg.DevnetNightly {
 		return fmt.Errorf(`cannot use both "--devnet-test" and "--devnet-nightly" options`)
 	}
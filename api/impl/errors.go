@@ -13,4 +13,8 @@ var (
 	ErrCannotPingSelf = errors.New("cannot ping self")
 	// ErrNodeOffline indicates that the node must not be offline for the operation performed.
 	ErrNodeOffline = errors.New("node must be online")
+	// ErrNoSealScheduler indicates that sealing was managed on a node which
+	// has no sector builder, i.e. which has no miner configured, or whose
+	// sector builder cannot seal single sectors.
+	ErrNoSealScheduler = errors.New("node has no seal scheduler; is a miner configured with a sector builder which can seal single sectors?")
 )
@@ -2,11 +2,13 @@ package impl
 
 import (
 	"context"
+
 	"github.com/filecoin-project/go-filecoin/actor/builtin"
 	"github.com/filecoin-project/go-filecoin/chain"
 	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/mining"
 	"github.com/filecoin-project/go-filecoin/node"
+	"github.com/filecoin-project/go-filecoin/proofs/sectorbuilder"
 	"github.com/filecoin-project/go-filecoin/state"
 	"github.com/filecoin-project/go-filecoin/types"
 )
@@ -82,3 +84,30 @@ func (api *nodeMining) Stop(ctx context.Context) error {
 	api.api.node.StopMining(ctx)
 	return nil
 }
+
+func (api *nodeMining) PauseSealing(ctx context.Context) error {
+	scheduler := api.api.node.SealScheduler()
+	if scheduler == nil {
+		return ErrNoSealScheduler
+	}
+	scheduler.Pause()
+	return nil
+}
+
+func (api *nodeMining) ResumeSealing(ctx context.Context) error {
+	scheduler := api.api.node.SealScheduler()
+	if scheduler == nil {
+		return ErrNoSealScheduler
+	}
+	scheduler.Resume()
+	return nil
+}
+
+func (api *nodeMining) SealingStatus(ctx context.Context) (*sectorbuilder.SealSchedulerStatus, error) {
+	scheduler := api.api.node.SealScheduler()
+	if scheduler == nil {
+		return nil, ErrNoSealScheduler
+	}
+	status := scheduler.Status()
+	return &status, nil
+}
@@ -2,6 +2,7 @@ package impl
 
 import (
 	"context"
+	"math/big"
 
 	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
 	cbor "gx/ipfs/QmRoARq3nkUb13HSKZGepCZSWe5GrVPwx7xURJGZ7KWv9V/go-ipld-cbor"
@@ -65,7 +66,7 @@ func (np *nodePaych) Ls(ctx context.Context, fromAddr, payerAddr address.Address
 	return channels, nil
 }
 
-func (np *nodePaych) Voucher(ctx context.Context, fromAddr address.Address, channel *types.ChannelID, amount *types.AttoFIL, validAt *types.BlockHeight) (string, error) {
+func (np *nodePaych) Voucher(ctx context.Context, fromAddr address.Address, channel *types.ChannelID, lane uint64, amount *types.AttoFIL, validAt *types.BlockHeight, condition *paymentbroker.Condition) (string, error) {
 	nd := np.api.node
 
 	if err := setDefaultFromAddr(&fromAddr, nd); err != nil {
@@ -77,7 +78,7 @@ func (np *nodePaych) Voucher(ctx context.Context, fromAddr address.Address, chan
 		fromAddr,
 		address.PaymentBrokerAddress,
 		"voucher",
-		channel, amount, validAt,
+		channel, big.NewInt(0).SetUint64(lane), amount, validAt,
 	)
 	if err != nil {
 		return "", err
@@ -88,12 +89,18 @@ func (np *nodePaych) Voucher(ctx context.Context, fromAddr address.Address, chan
 		return "", err
 	}
 
-	sig, err := paymentbroker.SignVoucher(channel, amount, validAt, fromAddr, nd.Wallet)
+	voucher.Condition = condition
+
+	sig, err := paymentbroker.SignVoucher(channel, lane, amount, validAt, condition, fromAddr, nd.Wallet)
 	if err != nil {
 		return "", err
 	}
 	voucher.Signature = sig
 
+	if err := np.porcelainAPI.PaychRecordIssued(ctx, &voucher); err != nil {
+		return "", err
+	}
+
 	return voucher.Encode()
 }
 
@@ -103,6 +110,11 @@ func (np *nodePaych) Redeem(ctx context.Context, fromAddr address.Address, gasPr
 		return cid.Undef, err
 	}
 
+	condition, err := paymentbroker.EncodeCondition(voucher.Condition)
+	if err != nil {
+		return cid.Undef, err
+	}
+
 	return np.porcelainAPI.MessageSendWithDefaultAddress(
 		ctx,
 		fromAddr,
@@ -111,7 +123,7 @@ func (np *nodePaych) Redeem(ctx context.Context, fromAddr address.Address, gasPr
 		gasPrice,
 		gasLimit,
 		"redeem",
-		voucher.Payer, &voucher.Channel, &voucher.Amount, &voucher.ValidAt, []byte(voucher.Signature),
+		voucher.Payer, &voucher.Channel, big.NewInt(0).SetUint64(voucher.Lane), &voucher.Amount, &voucher.ValidAt, condition, []byte(voucher.Signature),
 	)
 }
 
@@ -134,6 +146,11 @@ func (np *nodePaych) Close(ctx context.Context, fromAddr address.Address, gasPri
 		return cid.Undef, err
 	}
 
+	condition, err := paymentbroker.EncodeCondition(voucher.Condition)
+	if err != nil {
+		return cid.Undef, err
+	}
+
 	return np.porcelainAPI.MessageSendWithDefaultAddress(
 		ctx,
 		fromAddr,
@@ -142,7 +159,7 @@ func (np *nodePaych) Close(ctx context.Context, fromAddr address.Address, gasPri
 		gasPrice,
 		gasLimit,
 		"close",
-		voucher.Payer, &voucher.Channel, &voucher.Amount, &voucher.ValidAt, []byte(voucher.Signature),
+		voucher.Payer, &voucher.Channel, big.NewInt(0).SetUint64(voucher.Lane), &voucher.Amount, &voucher.ValidAt, condition, []byte(voucher.Signature),
 	)
 }
 
@@ -158,3 +175,16 @@ func (np *nodePaych) Extend(ctx context.Context, fromAddr address.Address, gasPr
 		channel, eol,
 	)
 }
+
+func (np *nodePaych) AddFunds(ctx context.Context, fromAddr address.Address, gasPrice types.AttoFIL, gasLimit types.GasUnits, channel *types.ChannelID, amount *types.AttoFIL) (cid.Cid, error) {
+	return np.porcelainAPI.MessageSendWithDefaultAddress(
+		ctx,
+		fromAddr,
+		address.PaymentBrokerAddress,
+		amount,
+		gasPrice,
+		gasLimit,
+		"addFunds",
+		channel,
+	)
+}
@@ -25,7 +25,7 @@ func newNodeSwarm(api *nodeAPI) *nodeSwarm {
 	return &nodeSwarm{api: api}
 }
 
-func (ns *nodeSwarm) Peers(ctx context.Context, verbose, latency, streams bool) (*api.SwarmConnInfos, error) {
+func (ns *nodeSwarm) Peers(ctx context.Context, verbose, latency, streams, scores, chain bool) (*api.SwarmConnInfos, error) {
 	nd := ns.api.node
 
 	if nd.Host() == nil {
@@ -59,6 +59,20 @@ func (ns *nodeSwarm) Peers(ctx context.Context, verbose, latency, streams bool)
 				ci.Latency = lat.String()
 			}
 		}
+		if verbose || scores {
+			ci.Score = fmt.Sprintf("%.1f", nd.Reputation.Score(pid))
+		}
+		if verbose || chain {
+			if hello, ok := nd.HelloSvc.PeerInfo(pid); ok {
+				ci.Chain = &api.SwarmChainInfo{
+					HeadHeight:      hello.HeaviestTipSetHeight,
+					HeadWeight:      hello.HeaviestTipSetWeight,
+					NetworkVersion:  hello.NetworkVersion,
+					SoftwareVersion: hello.SoftwareVersion,
+					Protocols:       hello.SupportedProtocols,
+				}
+			}
+		}
 		if verbose || streams {
 			strs := c.GetStreams()
 
@@ -71,6 +85,14 @@ func (ns *nodeSwarm) Peers(ctx context.Context, verbose, latency, streams bool)
 	}
 
 	sort.Sort(&out)
+
+	if verbose || scores {
+		for _, s := range nd.Reputation.Scores() {
+			if s.Banned {
+				out.Banned = append(out.Banned, s.Peer.Pretty())
+			}
+		}
+	}
 	return &out, nil
 }
 
@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 
+	"github.com/filecoin-project/go-filecoin/proofs/sectorbuilder"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
@@ -11,4 +12,7 @@ type Mining interface {
 	Once(ctx context.Context) (*types.Block, error)
 	Start(ctx context.Context) error
 	Stop(ctx context.Context) error
+	PauseSealing(ctx context.Context) error
+	ResumeSealing(ctx context.Context) error
+	SealingStatus(ctx context.Context) (*sectorbuilder.SealSchedulerStatus, error)
 }
@@ -8,7 +8,7 @@ import (
 
 // Swarm is the interface that defines methods to interact with the p2p swarm of the node.
 type Swarm interface {
-	Peers(ctx context.Context, verbose, latency, streams bool) (*SwarmConnInfos, error)
+	Peers(ctx context.Context, verbose, latency, streams, scores, chain bool) (*SwarmConnInfos, error)
 	Connect(ctx context.Context, addrs []string) ([]SwarmConnectResult, error)
 	FindPeer(ctx context.Context, peerID peer.ID) (peerstore.PeerInfo, error)
 }
@@ -18,8 +18,21 @@ type SwarmConnInfo struct {
 	Addr    string
 	Peer    string
 	Latency string
+	Score   string
 	Muxer   string
 	Streams []SwarmStreamInfo
+	// Chain is what the peer told about its chain, if it completed the
+	// hello handshake and chain info is requested.
+	Chain *SwarmChainInfo `json:",omitempty"`
+}
+
+// SwarmChainInfo describes the chain of a peer, as told by its hello message.
+type SwarmChainInfo struct {
+	HeadHeight      uint64
+	HeadWeight      uint64
+	NetworkVersion  uint64
+	SoftwareVersion string
+	Protocols       []string
 }
 
 // SwarmStreamInfo represents details about a single swarm stream.
@@ -42,6 +55,9 @@ func (ci *SwarmConnInfo) Swap(i, j int) {
 // SwarmConnInfos represent details about a list of swarm connections.
 type SwarmConnInfos struct {
 	Peers []SwarmConnInfo
+	// Banned are the peers which are banned for misbehaving, only listed
+	// when scores are requested.
+	Banned []string
 }
 
 func (ci SwarmConnInfos) Less(i, j int) bool {
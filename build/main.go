@@ -138,6 +138,9 @@ func deps() {
 		cmd("go get -u github.com/prometheus/client_golang/prometheus/promhttp"),
 		cmd("go get -u github.com/jstemmer/go-junit-report"),
 		cmd("go get -u github.com/pmezard/go-difflib/difflib"),
+		cmd("go get -u golang.org/x/crypto/scrypt"),
+		cmd("go get -u github.com/tyler-smith/go-bip32"),
+		cmd("go get -u github.com/tyler-smith/go-bip39"),
 		cmd("./scripts/install-rust-proofs.sh"),
 		cmd("./scripts/install-bls-signatures.sh"),
 		cmd("./proofs/bin/paramcache"),
@@ -184,6 +187,9 @@ func smartdeps() {
 		"github.com/prometheus/client_golang/prometheus",
 		"github.com/jstemmer/go-junit-report",
 		"github.com/pmezard/go-difflib/difflib",
+		"golang.org/x/crypto/scrypt",
+		"github.com/tyler-smith/go-bip32",
+		"github.com/tyler-smith/go-bip39",
 	}
 
 	gopath := os.Getenv("GOPATH")
@@ -265,6 +271,7 @@ func build() {
 	buildGengen()
 	buildFaucet()
 	buildGenesisFileServer()
+	buildRemoteSigner()
 	generateGenesis()
 }
 
@@ -309,6 +316,12 @@ func buildGenesisFileServer() {
 	runCmd(cmd([]string{"go", "build", "-o", "./tools/genesis-file-server/genesis-file-server", "./tools/genesis-file-server/"}...))
 }
 
+func buildRemoteSigner() {
+	log.Println("Building remote signer...")
+
+	runCmd(cmd([]string{"go", "build", "-o", "./tools/remote-signer/remote-signer", "./tools/remote-signer/"}...))
+}
+
 func install() {
 	log.Println("Installing...")
 
@@ -1,39 +1,218 @@
 package chain
 
 import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+	"gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore"
+	dsq "gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore/query"
+
+	"github.com/filecoin-project/go-filecoin/repo"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
-// badTipSetCache keeps track of bad tipsets that the syncer should not try to
-// download.  Readers and writers grab a lock.
-// TODO: this needs to be limited.
-type badTipSetCache struct {
-	mu  sync.Mutex
-	bad map[string]struct{}
+// maxBadTipSets bounds the number of tipsets the BadTipSetCache remembers.
+// When it is full the tipsets seen first are forgotten first.
+const maxBadTipSets = 10000
+
+// ErrBadTipSetNotFound is returned when removing a tipset which is not in
+// the BadTipSetCache.
+var ErrBadTipSetNotFound = errors.New("tipset not found in bad tipsets")
+
+// badTipSetsKey is the datastore key under which bad tipsets are persisted,
+// a record per tipset keyed by the tipset key.
+var badTipSetsKey = datastore.NewKey("/chain/badtipsets")
+
+// BadTipSet records why a tipset was rejected.
+type BadTipSet struct {
+	// Key is the key of the tipset.
+	Key string `json:"key"`
+	// Reason is the validation error the tipset was rejected with.
+	Reason string `json:"reason"`
+	// FirstSeen is the time the tipset was first rejected.
+	FirstSeen time.Time `json:"firstSeen"`
+	// Peer is the peer the tipset came from, if it came from the network.
+	Peer string `json:"peer,omitempty"`
+}
+
+// BadTipSetCache keeps track of bad tipsets that the syncer should not try to
+// download. The tipsets which are invalid whichever node validates them are
+// persisted, so that known bad chains are not revalidated after a restart.
+// Others, e.g. tipsets conflicting with a checkpoint of the node, are only
+// remembered until then. Readers and writers grab a lock.
+type BadTipSetCache struct {
+	mu sync.Mutex
+	ds repo.Datastore
+	// bad holds the bad tipsets by key.
+	bad map[string]*BadTipSet
+	// order lists the keys of bad in the order they were first seen.
+	order   []string
+	maxSize int
 }
 
-// AddChain adds the chain of tipsets to the badTipSetCache.  For now it just
-// does the simplest thing and adds all blocks of the chain to the cache.
-// TODO: might want to cache a random subset once cache size is limited.
-func (cache *badTipSetCache) AddChain(chain []types.TipSet) {
+// NewBadTipSetCache returns a BadTipSetCache persisted in ds, loaded with the
+// bad tipsets recorded there.
+func NewBadTipSetCache(ds repo.Datastore) (*BadTipSetCache, error) {
+	cache := &BadTipSetCache{
+		ds:      ds,
+		bad:     make(map[string]*BadTipSet),
+		maxSize: maxBadTipSets,
+	}
+
+	prefix := badTipSetsKey.String() + "/"
+	res, err := ds.Query(dsq.Query{Prefix: prefix})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query bad tipsets")
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query bad tipsets")
+	}
+
+	var loaded []*BadTipSet
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Key, prefix) {
+			continue
+		}
+		var bts BadTipSet
+		if err := json.Unmarshal(e.Value, &bts); err != nil {
+			return nil, errors.Wrapf(err, "failed to decode bad tipset entry %s", e.Key)
+		}
+		loaded = append(loaded, &bts)
+	}
+	sort.Slice(loaded, func(i, j int) bool {
+		if loaded[i].FirstSeen.Equal(loaded[j].FirstSeen) {
+			return loaded[i].Key < loaded[j].Key
+		}
+		return loaded[i].FirstSeen.Before(loaded[j].FirstSeen)
+	})
+	for _, bts := range loaded {
+		cache.bad[bts.Key] = bts
+		cache.order = append(cache.order, bts.Key)
+	}
+	return cache, nil
+}
+
+// AddChain adds the chain of tipsets built on the bad tipset with key tsKey
+// to the BadTipSetCache, persisted if persist is true. For now it just does
+// the simplest thing and adds all tipsets of the chain to the cache.
+// TODO: might want to cache a random subset.
+func (cache *BadTipSetCache) AddChain(chain []types.TipSet, tsKey string, peer string, persist bool) {
+	reason := fmt.Sprintf("descends from bad tipset %s", tsKey)
 	for _, ts := range chain {
-		cache.Add(ts.String())
+		cache.Add(ts.String(), reason, peer, persist)
 	}
 }
 
-// Add adds a single tipset key to the badTipSetCache.
-func (cache *badTipSetCache) Add(tsKey string) {
+// Add adds a single tipset key to the BadTipSetCache with the reason it was
+// rejected and the peer it came from. The tipset is persisted if persist is
+// true, and otherwise forgotten on restart. A tipset already in the cache
+// keeps the reason and peer it was first rejected with.
+func (cache *BadTipSetCache) Add(tsKey string, reason string, peer string, persist bool) {
 	cache.mu.Lock()
 	defer cache.mu.Unlock()
-	cache.bad[tsKey] = struct{}{}
+
+	if _, ok := cache.bad[tsKey]; ok {
+		return
+	}
+	bts := &BadTipSet{
+		Key:       tsKey,
+		Reason:    reason,
+		FirstSeen: time.Now(),
+		Peer:      peer,
+	}
+	cache.bad[tsKey] = bts
+	cache.order = append(cache.order, tsKey)
+	if persist {
+		if err := cache.put(bts); err != nil {
+			logSyncer.Warningf("failed to persist bad tipset %s: %s", tsKey, err)
+		}
+	}
+
+	for len(cache.bad) > cache.maxSize {
+		oldest := cache.order[0]
+		cache.order = cache.order[1:]
+		if err := cache.remove(oldest); err != nil {
+			logSyncer.Warningf("failed to evict bad tipset %s: %s", oldest, err)
+		}
+	}
 }
 
-// Has checks for membership in the badTipSetCache.
-func (cache *badTipSetCache) Has(tsKey string) bool {
+// Has checks for membership in the BadTipSetCache.
+func (cache *BadTipSetCache) Has(tsKey string) bool {
 	cache.mu.Lock()
 	defer cache.mu.Unlock()
 	_, ok := cache.bad[tsKey]
 	return ok
 }
+
+// List returns the bad tipsets in the order they were first seen.
+func (cache *BadTipSetCache) List() []BadTipSet {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	out := []BadTipSet{}
+	for _, tsKey := range cache.order {
+		out = append(out, *cache.bad[tsKey])
+	}
+	return out
+}
+
+// Remove removes the tipset with the input key from the BadTipSetCache, so
+// that the syncer validates it again. It returns ErrBadTipSetNotFound if the
+// tipset is not in the cache.
+func (cache *BadTipSetCache) Remove(tsKey string) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if _, ok := cache.bad[tsKey]; !ok {
+		return ErrBadTipSetNotFound
+	}
+	for i, k := range cache.order {
+		if k == tsKey {
+			cache.order = append(cache.order[:i], cache.order[i+1:]...)
+			break
+		}
+	}
+	return cache.remove(tsKey)
+}
+
+// Clear removes all tipsets from the BadTipSetCache.
+func (cache *BadTipSetCache) Clear() error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	var err error
+	for _, tsKey := range cache.order {
+		if rerr := cache.remove(tsKey); rerr != nil && err == nil {
+			err = rerr
+		}
+	}
+	cache.order = nil
+	return err
+}
+
+// put persists bts. Precondition: the caller holds cache.mu.
+func (cache *BadTipSetCache) put(bts *BadTipSet) error {
+	val, err := json.Marshal(bts)
+	if err != nil {
+		return err
+	}
+	return cache.ds.Put(badTipSetsKey.ChildString(bts.Key), val)
+}
+
+// remove forgets the tipset with the input key, leaving its key in the order
+// to the caller. Precondition: the caller holds cache.mu.
+func (cache *BadTipSetCache) remove(tsKey string) error {
+	delete(cache.bad, tsKey)
+	err := cache.ds.Delete(badTipSetsKey.ChildString(tsKey))
+	if err != nil && err != datastore.ErrNotFound {
+		return errors.Wrapf(err, "failed to delete bad tipset %s", tsKey)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package chain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/repo"
+)
+
+func TestBadTipSetCacheEvictsOldest(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	r := repo.NewInMemoryRepo()
+
+	bad := requireBadTipSetCache(require, r.ChainDatastore())
+	bad.maxSize = 2
+	bad.Add("a", "reason a", "peer", true)
+	bad.Add("b", "reason b", "", true)
+	bad.Add("c", "reason c", "", true)
+	assert.False(bad.Has("a"))
+	assert.True(bad.Has("b"))
+	assert.True(bad.Has("c"))
+
+	// Evicted tipsets are forgotten by the datastore too.
+	loaded := requireBadTipSetCache(require, r.ChainDatastore()).List()
+	require.Len(loaded, 2)
+	assert.Equal("b", loaded[0].Key)
+	assert.Equal("reason b", loaded[0].Reason)
+	assert.Equal("c", loaded[1].Key)
+}
+
+func TestBadTipSetCacheRemove(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	r := repo.NewInMemoryRepo()
+
+	bad := requireBadTipSetCache(require, r.ChainDatastore())
+	bad.Add("a", "reason a", "", true)
+	bad.Add("b", "reason b", "", true)
+	bad.Add("a", "other reason", "", true)
+
+	assert.Equal(ErrBadTipSetNotFound, bad.Remove("c"))
+	require.NoError(bad.Remove("a"))
+	assert.False(bad.Has("a"))
+	assert.False(requireBadTipSetCache(require, r.ChainDatastore()).Has("a"))
+
+	require.NoError(bad.Clear())
+	assert.Empty(bad.List())
+	assert.Empty(requireBadTipSetCache(require, r.ChainDatastore()).List())
+}
+
+func TestBadTipSetCacheOnlyPersistsWhenAsked(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	r := repo.NewInMemoryRepo()
+
+	bad := requireBadTipSetCache(require, r.ChainDatastore())
+	bad.Add("a", "invalid", "", true)
+	bad.Add("b", "conflicts with checkpoint", "", false)
+	assert.True(bad.Has("b"))
+
+	loaded := requireBadTipSetCache(require, r.ChainDatastore())
+	assert.True(loaded.Has("a"))
+	assert.False(loaded.Has("b"))
+}
@@ -0,0 +1,220 @@
+package chain
+
+import (
+	"context"
+	"time"
+
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	cbor "gx/ipfs/QmRoARq3nkUb13HSKZGepCZSWe5GrVPwx7xURJGZ7KWv9V/go-ipld-cbor"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+
+	"github.com/filecoin-project/go-filecoin/actor/builtin"
+	"github.com/filecoin-project/go-filecoin/config"
+	"github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/state"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// ErrChainMissesCheckpoint is returned when the syncer traverses a chain which
+// does not contain a trusted checkpoint at its height.
+var ErrChainMissesCheckpoint = errors.New("input chain does not contain a trusted checkpoint")
+
+// ErrUnresolvedCheckpoint is returned when the syncer cannot tell whether a
+// chain contains a trusted checkpoint, as the checkpoint's blocks cannot be
+// fetched and the chain does not contain them.
+var ErrUnresolvedCheckpoint = errors.New("trusted checkpoint could not be resolved")
+
+// checkpointStateTimeout bounds the time spent fetching the state of a
+// checkpoint from the network.
+const checkpointStateTimeout = 10 * time.Minute
+
+// checkpoint is a trusted tipset chains are checked against. Its height is
+// known once its blocks have been resolved, either up front or when a synced
+// chain reaches it.
+type checkpoint struct {
+	config.Checkpoint
+	height   uint64
+	resolved bool
+}
+
+func newCheckpoints(cps []config.Checkpoint) []*checkpoint {
+	var checkpoints []*checkpoint
+	for _, cp := range cps {
+		checkpoints = append(checkpoints, &checkpoint{Checkpoint: cp})
+	}
+	return checkpoints
+}
+
+// resolveCheckpoints resolves the heights of the checkpoints which are not
+// resolved yet, fetching their blocks if needed. Resolved heights are kept,
+// so each checkpoint is resolved once. A checkpoint which cannot be resolved
+// up front is resolved if the synced chain reaches it, or else retried once
+// the chain has been traversed, see checkUnresolvedCheckpoints.
+func (syncer *DefaultSyncer) resolveCheckpoints(ctx context.Context, fetched map[string]types.TipSet) {
+	for _, cp := range syncer.checkpoints {
+		if cp.resolved {
+			continue
+		}
+		if err := syncer.resolveCheckpoint(ctx, cp, fetched); err != nil {
+			logSyncer.Warningf("failed to resolve checkpoint %s: %s", cp.TipSet.String(), err)
+		}
+	}
+}
+
+func (syncer *DefaultSyncer) resolveCheckpoint(ctx context.Context, cp *checkpoint, fetched map[string]types.TipSet) error {
+	blks, err := syncer.getBlks(ctx, cp.TipSet.ToSlice(), fetched)
+	if err != nil {
+		return err
+	}
+	ts, err := types.NewTipSet(blks...)
+	if err != nil {
+		return errors.Wrap(err, "bad checkpoint")
+	}
+	if cp.height, err = ts.Height(); err != nil {
+		return err
+	}
+	cp.resolved = true
+	return nil
+}
+
+// checkCheckpoints checks a tipset at height h of a chain traversed from its
+// head down, given the checkpoints the chain already passed. It returns the
+// checkpoint ts is, if any, and ErrChainMissesCheckpoint if ts is at or below
+// the height of a checkpoint the chain did not pass. Checkpoints which are
+// not resolved are only matched against ts.
+func (syncer *DefaultSyncer) checkCheckpoints(ts types.TipSet, h uint64, passed map[*checkpoint]bool) (*checkpoint, error) {
+	tsKey := ts.ToSortedCidSet()
+	for _, cp := range syncer.checkpoints {
+		if cp.TipSet.Equals(tsKey) {
+			cp.height, cp.resolved = h, true
+			passed[cp] = true
+			return cp, nil
+		}
+	}
+	for _, cp := range syncer.checkpoints {
+		if cp.resolved && h <= cp.height && !passed[cp] {
+			return nil, ErrChainMissesCheckpoint
+		}
+	}
+	return nil, nil
+}
+
+// checkUnresolvedCheckpoints is called once the traversal of a chain reaches
+// a tipset in the store, having collected chain on the way. It resolves the
+// checkpoints the traversal did not meet and checks the collected chain
+// against them. It returns ErrUnresolvedCheckpoint if a checkpoint still
+// cannot be resolved: the chain is not trusted without knowing its height.
+func (syncer *DefaultSyncer) checkUnresolvedCheckpoints(ctx context.Context, chain []types.TipSet, passed map[*checkpoint]bool, fetched map[string]types.TipSet) error {
+	for _, cp := range syncer.checkpoints {
+		if cp.resolved {
+			continue
+		}
+		if err := syncer.resolveCheckpoint(ctx, cp, fetched); err != nil {
+			return errors.Wrapf(ErrUnresolvedCheckpoint, "checkpoint %s: %s", cp.TipSet.String(), err)
+		}
+		if len(chain) == 0 || passed[cp] {
+			continue
+		}
+		// chain is ordered from its lowest tipset up
+		if h, err := chain[0].Height(); err != nil || h <= cp.height {
+			return ErrChainMissesCheckpoint
+		}
+	}
+	return nil
+}
+
+// putCheckpoint adds the checkpoint tipset ts to the store along with its
+// state, which is fetched from the network instead of being computed, and the
+// ancestors needed to validate its children. The blockstore is pinned until
+// the fetched state is referenced from the store.
+func (syncer *DefaultSyncer) putCheckpoint(ctx context.Context, cp *checkpoint, ts types.TipSet, fetched map[string]types.TipSet) error {
+	logSyncer.Infof("syncing from checkpoint %s at height %d", ts.String(), cp.height)
+	defer syncer.pin()()
+
+	sctx, cancel := context.WithTimeout(ctx, checkpointStateTimeout)
+	defer cancel()
+	if err := syncer.fetchState(sctx, cp.StateRoot, cid.NewSet()); err != nil {
+		return errors.Wrapf(err, "failed to fetch state %s of checkpoint", cp.StateRoot)
+	}
+	if _, err := state.LoadStateTree(ctx, syncer.cstOffline, cp.StateRoot, builtin.Actors); err != nil {
+		return errors.Wrapf(err, "failed to load state %s of checkpoint", cp.StateRoot)
+	}
+
+	ancestors, err := syncer.checkpointAncestors(ctx, ts, fetched)
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch ancestors of checkpoint")
+	}
+
+	return syncer.chainStore.PutCheckpoint(ctx, &TipSetAndState{
+		TipSet:          ts,
+		TipSetStateRoot: cp.StateRoot,
+	}, ancestors)
+}
+
+// fetchState copies the state DAG under root into the node's offline storage,
+// fetching the blocks it does not have over the network. Links to blocks
+// which are not dag-cbor, such as the cids of builtin actor code, are not
+// followed.
+func (syncer *DefaultSyncer) fetchState(ctx context.Context, root cid.Cid, seen *cid.Set) error {
+	if !seen.Visit(root) || root.Type() != cid.DagCBOR {
+		return nil
+	}
+
+	blk, err := syncer.cstOffline.Blocks.GetBlock(ctx, root)
+	if err != nil {
+		if blk, err = syncer.cstOnline.Blocks.GetBlock(ctx, root); err != nil {
+			return err
+		}
+		if err := syncer.cstOffline.Blocks.AddBlock(blk); err != nil {
+			return err
+		}
+	}
+
+	nd, err := cbor.DecodeBlock(blk)
+	if err != nil {
+		return err
+	}
+	for _, link := range nd.Links() {
+		if err := syncer.fetchState(ctx, link.Cid, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkpointAncestors resolves the ancestors of the checkpoint tipset ts
+// which are needed to validate its children, see GetRecentAncestors.
+func (syncer *DefaultSyncer) checkpointAncestors(ctx context.Context, ts types.TipSet, fetched map[string]types.TipSet) ([]types.TipSet, error) {
+	h, err := ts.Height()
+	if err != nil {
+		return nil, err
+	}
+	earliest := types.NewBlockHeight(h + 1).Sub(consensus.AncestorRoundsNeeded)
+
+	var ancestors []types.TipSet
+	for lookback := 0; lookback < consensus.LookBackParameter; {
+		pSet, err := ts.Parents()
+		if err != nil {
+			return nil, err
+		}
+		if pSet.Empty() {
+			break
+		}
+		blks, err := syncer.getBlks(ctx, pSet.ToSlice(), fetched)
+		if err != nil {
+			return nil, err
+		}
+		if ts, err = types.NewTipSet(blks...); err != nil {
+			return nil, err
+		}
+		ancestors = append(ancestors, ts)
+
+		if h, err = ts.Height(); err != nil {
+			return nil, err
+		}
+		if types.NewBlockHeight(h).LessThan(earliest) {
+			lookback++
+		}
+	}
+	return ancestors, nil
+}
@@ -36,6 +36,9 @@ type DefaultStore struct {
 	// simplify checking the security guarantee that only tipsets of a
 	// validated chain are stored in the filecoin node's DefaultStore.
 	privateStore *hamt.CborIpldStore
+	// blocks is the blockstore backing the privateStore, used to remove the
+	// blocks of pruned tipsets.
+	blocks bstore.Blockstore
 	// stateStore is the on disk storage used for loading states.  It can be
 	// shared with the rest of the filecoin node.
 	stateStore *hamt.CborIpldStore
@@ -48,7 +51,9 @@ type DefaultStore struct {
 	genesis cid.Cid
 	// head is the tipset at the head of the best known chain.
 	head types.TipSet
-	// Protects head and genesisCid.
+	// migrated is true once the migrated marker of the tip index is written.
+	migrated bool
+	// Protects head, migrated and genesisCid.
 	mu sync.RWMutex
 
 	// headEvents is a pubsub channel that publishes an event every time the head changes.
@@ -61,6 +66,13 @@ type DefaultStore struct {
 
 	// Tracks tipsets by height/parentset for use by expected consensus.
 	tipIndex *TipIndex
+	// loadedBuckets holds the parents and height keys whose tipsets have all
+	// been read from the persisted tip index into tipIndex. Tipsets put
+	// later are added to tipIndex too, so these buckets are served from
+	// memory.
+	loadedBuckets map[string]struct{}
+	// Protects loadedBuckets.
+	loadedMu sync.Mutex
 
 	// TODO block cache should go here
 }
@@ -73,35 +85,92 @@ func NewDefaultStore(ds repo.Datastore, stateStore *hamt.CborIpldStore, genesisC
 	bs := bstore.NewBlockstore(ds)
 	priv := hamt.CborIpldStore{Blocks: bserv.New(bs, offline.Exchange(bs))}
 	return &DefaultStore{
-		privateStore: &priv,
-		stateStore:   stateStore,
-		headEvents:   pubsub.New(128),
-		ds:           ds,
-		tipIndex:     NewTipIndex(),
-		genesis:      genesisCid,
+		privateStore:  &priv,
+		blocks:        bs,
+		stateStore:    stateStore,
+		headEvents:    pubsub.New(128),
+		ds:            ds,
+		tipIndex:      NewTipIndex(),
+		loadedBuckets: make(map[string]struct{}),
+		genesis:       genesisCid,
 	}
 }
 
-// Load rebuilds the DefaultStore's caches by traversing backwards from the
-// most recent best head as stored in its datastore.  Because Load uses a
-// content addressed datastore it guarantees that parent blocks are correctly
-// resolved from the datastore.  Furthermore Load ensures that all tipsets
-// references correctly have the same parent height, weight and parent set.
-// However, Load DOES NOT validate state transitions, it assumes that the
-// tipset were only Put to the DefaultStore after checking for valid transitions.
+// Load rebuilds the DefaultStore's caches from the most recent best head as
+// stored in its datastore. The tip index is persisted as tipsets are put, so
+// Load only reads the tipsets of the last eagerLoadDepth heights into memory;
+// older ones are read from the datastore when they are first looked up.
 //
-// Furthermore Load trusts that the DefaultStore's backing datastore correctly
-// preserves the cids of the heaviest tipset under the "headKey" datastore key.
-// If the headKey cids are tampered with and invalid blocks added to the datastore
-// then Load could be tricked into loading an invalid chain. Load will error if the
-// head does not link back to the expected genesis block, or the Store's
-// datastore does not store a link in the chain.  In case of error the caller
-// should not consider the chain useable and propagate the error.
+// Load trusts that the DefaultStore's backing datastore correctly preserves
+// the cids of the heaviest tipset under the "headKey" datastore key, and the
+// persisted tip index, which only holds tipsets that were Put to the
+// DefaultStore after checking for valid transitions. Load will error if the
+// genesis block is not indexed, or the Store's datastore does not store a
+// tipset of the recent chain. In case of error the caller should not
+// consider the chain useable and propagate the error.
+//
+// A datastore written before the tip index was persisted is loaded by
+// walking the whole chain back to genesis, see loadLegacy, until the tip
+// index is marked migrated, so that an interrupted migration is resumed.
 func (store *DefaultStore) Load(ctx context.Context) error {
 	tipCids, err := store.loadHead()
 	if err != nil {
 		return err
 	}
+
+	if !store.isTipIndexMigrated() {
+		return store.loadLegacy(ctx, tipCids)
+	}
+
+	headTsas, err := store.GetTipSetAndState(ctx, tipCids.String())
+	if err != nil {
+		return errors.Wrap(err, "failed to load head TipSet")
+	}
+
+	ts := headTsas.TipSet
+	for i := 0; i < eagerLoadDepth; i++ {
+		if store.isCheckpoint(ts.String()) {
+			// The ancestors of a checkpoint are not indexed.
+			break
+		}
+		pKey, err := ts.Parents()
+		if err != nil {
+			return err
+		}
+		if pKey.Empty() {
+			// ts is the genesis tipset.
+			loadCid := ts.ToSlice()[0].Cid()
+			if len(ts) != 1 || !loadCid.Equals(store.genesis) {
+				return errors.Errorf("expected genesis cid: %s, loaded genesis tipset: %s", store.genesis, ts.String())
+			}
+			break
+		}
+
+		tsas, err := store.GetTipSetAndState(ctx, pKey.String())
+		if err != nil {
+			return errors.Wrapf(err, "failed to load TipSet %s", pKey.String())
+		}
+		ts = tsas.TipSet
+	}
+
+	if !store.hasTipIndexRecord(types.NewSortedCidSet(store.genesis).String()) {
+		return errors.Errorf("expected genesis cid %s is not indexed", store.genesis)
+	}
+
+	// Set actual head.
+	return store.SetHead(ctx, headTsas.TipSet)
+}
+
+// loadLegacy loads a datastore which does not persist the tip index by
+// traversing backwards from the head to genesis, indexing every tipset on
+// the way. Because it uses a content addressed datastore it guarantees that
+// parent blocks are correctly resolved from the datastore. Furthermore it
+// ensures that all tipsets references correctly have the same parent height,
+// weight and parent set. However, it DOES NOT validate state transitions.
+// Setting the head once genesis is reached marks the tip index migrated.
+func (store *DefaultStore) loadLegacy(ctx context.Context, tipCids types.SortedCidSet) error {
+	logStore.Infof("tip index is not persisted, rebuilding it from the whole chain")
+
 	headTs := types.TipSet{}
 	// traverse starting from head to begin loading the chain
 	for it := tipCids.Iter(); !it.Complete(); it.Next() {
@@ -116,7 +185,7 @@ func (store *DefaultStore) Load(ctx context.Context) error {
 	}
 
 	var genesii types.TipSet
-	err = store.walkChain(ctx, headTs.ToSlice(), func(tips []*types.Block) (cont bool, err error) {
+	err := store.walkChain(ctx, headTs.ToSlice(), func(tips []*types.Block) (cont bool, err error) {
 		ts, err := types.NewTipSet(tips...)
 		if err != nil {
 			return false, err
@@ -132,8 +201,6 @@ func (store *DefaultStore) Load(ctx context.Context) error {
 		if err != nil {
 			return false, err
 		}
-		// TODO: we should probably warm up the block cache with the
-		// most recent tipsets traversed here.
 		genesii = ts
 		return true, nil
 	})
@@ -171,6 +238,8 @@ func (store *DefaultStore) loadHead() (types.SortedCidSet, error) {
 	return cids, nil
 }
 
+// loadStateRoot reads the state root of a tipset as stored before the tip
+// index was persisted.
 func (store *DefaultStore) loadStateRoot(ts types.TipSet) (cid.Cid, error) {
 	h, err := ts.Height()
 	if err != nil {
@@ -207,42 +276,122 @@ func (store *DefaultStore) PutTipSetAndState(ctx context.Context, tsas *TipSetAn
 		}
 	}
 
+	// Persist the tip index entry before indexing it in memory, so that
+	// the persisted index is never behind.
+	if err := store.writeTipIndexRecord(tsas, false); err != nil {
+		return errors.Wrap(err, "failed to persist tip index")
+	}
+
 	// Update tipindex.
-	err := store.tipIndex.Put(tsas)
-	if err != nil {
-		return err
+	return store.tipIndex.Put(tsas)
+}
+
+// PutCheckpoint persists a trusted tipset and its state, which were not
+// validated, and the blocks of the ancestors of the tipset needed to validate
+// its children. The ancestors are not indexed.
+func (store *DefaultStore) PutCheckpoint(ctx context.Context, tsas *TipSetAndState, ancestors []types.TipSet) error {
+	for _, ts := range ancestors {
+		for _, blk := range ts {
+			if err := store.putBlk(ctx, blk); err != nil {
+				return err
+			}
+		}
 	}
-	// Persist the state mapping.
-	if err = store.writeTipSetAndState(tsas); err != nil {
-		return err
+	for _, blk := range tsas.TipSet {
+		if err := store.putBlk(ctx, blk); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	if err := store.writeTipIndexRecord(tsas, true); err != nil {
+		return errors.Wrap(err, "failed to persist tip index")
+	}
+	return store.tipIndex.Put(tsas)
 }
 
 // GetTipSetAndState returns the tipset and state of the tipset whose block
-// cids correspond to the input string.
+// cids correspond to the input string. Tipsets which are not in the in-memory
+// tip index are read from the persisted one.
 func (store *DefaultStore) GetTipSetAndState(ctx context.Context, tsKey string) (*TipSetAndState, error) {
-	return store.tipIndex.Get(tsKey)
+	tsas, err := store.tipIndex.Get(tsKey)
+	if err != ErrNotFound {
+		return tsas, err
+	}
+
+	tsas, err = store.loadTipIndexRecord(ctx, tsKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.tipIndex.Put(tsas); err != nil {
+		return nil, err
+	}
+	return tsas, nil
 }
 
 // HasTipSetAndState returns true iff the default store's tipindex is indexing
 // the tipset referenced in the input key.
 func (store *DefaultStore) HasTipSetAndState(ctx context.Context, tsKey string) bool {
-	return store.tipIndex.Has(tsKey)
+	return store.tipIndex.Has(tsKey) || store.hasTipIndexRecord(tsKey)
 }
 
 // GetTipSetAndStatesByParentsAndHeight returns the the tipsets and states tracked by
 // the default store's tipIndex that have the parent set corresponding to the
-// input key.
+// input key. The persisted tip index is only queried the first time a parent
+// set and height is looked up.
 func (store *DefaultStore) GetTipSetAndStatesByParentsAndHeight(ctx context.Context, pTsKey string, h uint64) ([]*TipSetAndState, error) {
+	if err := store.loadBucket(ctx, pTsKey, h); err != nil {
+		return nil, err
+	}
 	return store.tipIndex.GetByParentsAndHeight(pTsKey, h)
 }
 
+// loadBucket reads the persisted tipsets with the input parents and height
+// which are missing from the in-memory tip index, unless they were already
+// read.
+func (store *DefaultStore) loadBucket(ctx context.Context, pTsKey string, h uint64) error {
+	if store.isBucketLoaded(pTsKey, h) {
+		return nil
+	}
+
+	tsKeys, err := store.loadTipSetKeysByParentsAndHeight(pTsKey, h)
+	if err != nil {
+		return err
+	}
+	for _, tsKey := range tsKeys {
+		if store.tipIndex.Has(tsKey) {
+			continue
+		}
+		if _, err := store.GetTipSetAndState(ctx, tsKey); err != nil {
+			return err
+		}
+	}
+
+	store.loadedMu.Lock()
+	defer store.loadedMu.Unlock()
+	store.loadedBuckets[makeKey(pTsKey, h)] = struct{}{}
+	return nil
+}
+
+// isBucketLoaded returns true if the in-memory tip index holds all tipsets
+// with the input parents and height.
+func (store *DefaultStore) isBucketLoaded(pTsKey string, h uint64) bool {
+	store.loadedMu.Lock()
+	defer store.loadedMu.Unlock()
+	_, ok := store.loadedBuckets[makeKey(pTsKey, h)]
+	return ok
+}
+
 // HasTipSetAndStatesWithParentsAndHeight returns true if the default store's tipindex
 // contains any tipset indexed by the provided parent ID.
 func (store *DefaultStore) HasTipSetAndStatesWithParentsAndHeight(ctx context.Context, pTsKey string, h uint64) bool {
-	return store.tipIndex.HasByParentsAndHeight(pTsKey, h)
+	if store.tipIndex.HasByParentsAndHeight(pTsKey, h) {
+		return true
+	}
+	if store.isBucketLoaded(pTsKey, h) {
+		return false
+	}
+	tsKeys, err := store.loadTipSetKeysByParentsAndHeight(pTsKey, h)
+	return err == nil && len(tsKeys) > 0
 }
 
 // GetBlocks retrieves the blocks referenced in the input cid set.
@@ -323,6 +472,14 @@ func (store *DefaultStore) setHeadPersistent(ctx context.Context, ts types.TipSe
 		return errors.Wrap(errInner, "failed to write new Head to datastore")
 	}
 
+	// The tip index holds the chain of any head that is set, see Load.
+	if !store.migrated {
+		if err := store.ds.Put(tipIndexMigratedKey, []byte{}); err != nil {
+			return errors.Wrap(err, "failed to mark tip index migrated")
+		}
+		store.migrated = true
+	}
+
 	store.head = ts
 
 	return nil
@@ -339,23 +496,6 @@ func (store *DefaultStore) writeHead(ctx context.Context, cids types.SortedCidSe
 	return store.ds.Put(headKey, val)
 }
 
-// writeTipSetAndState writes the tipset key and the state root id to the
-// datastore.
-func (store *DefaultStore) writeTipSetAndState(tsas *TipSetAndState) error {
-	val, err := json.Marshal(tsas.TipSetStateRoot)
-	if err != nil {
-		return err
-	}
-
-	// datastore keeps tsKey:stateRoot (k,v) pairs.
-	h, err := tsas.TipSet.Height()
-	if err != nil {
-		return err
-	}
-	key := datastore.NewKey(makeKey(tsas.TipSet.String(), h))
-	return store.ds.Put(key, val)
-}
-
 // Head returns the current head.
 func (store *DefaultStore) Head() types.TipSet {
 	store.mu.RLock()
@@ -2,6 +2,7 @@ package chain
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -11,6 +12,7 @@ import (
 	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
 	"gx/ipfs/QmRXf2uUSdGSunRJsM9wXSUNVwLUGCY3So5fAs7h2CBJVf/go-hamt-ipld"
 	bstore "gx/ipfs/QmS2aqUZLJp8kF1ihE5rvDGE5LvmKDPnx32w9Z1BW9xLV5/go-ipfs-blockstore"
+	"gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore"
 
 	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/proofs"
@@ -486,3 +488,215 @@ func TestLoadAndReboot(t *testing.T) {
 	assert.True(rebootChain.HasBlock(ctx, link2blk3.Cid()))
 	assert.True(rebootChain.HasBlock(ctx, genesis.Cid()))
 }
+
+// Tipsets which are not in the in-memory tip index are read from the
+// persisted one.
+func TestLazyLoadPersistedTipIndex(t *testing.T) {
+	ctx := context.Background()
+	initStoreTest(ctx, require.New(t))
+	assert := assert.New(t)
+	require := require.New(t)
+
+	r := repo.NewInMemoryRepo()
+	ds := r.Datastore()
+	chain := NewDefaultStore(ds, hamt.NewCborStore(), genCid)
+	requirePutTestChain(require, chain)
+	chain.Stop()
+
+	// A new store on the same datastore has an empty in-memory index.
+	lazyChain := NewDefaultStore(ds, hamt.NewCborStore(), genCid)
+	assert.False(lazyChain.tipIndex.Has(link2.String()))
+
+	assert.True(lazyChain.HasTipSetAndState(ctx, link2.String()))
+	got2 := requireGetTsas(ctx, require, lazyChain, link2.String())
+	assert.Equal(link2, got2.TipSet)
+	assert.Equal(link2State, got2.TipSetStateRoot)
+	assert.True(lazyChain.tipIndex.Has(link2.String()))
+
+	assert.True(lazyChain.HasTipSetAndStatesWithParentsAndHeight(ctx, link3.String(), uint64(6)))
+	got4 := requireGetTsasByParentAndHeight(ctx, require, lazyChain, link3.String(), uint64(6))
+	require.Equal(1, len(got4))
+	assert.Equal(link4, got4[0].TipSet)
+
+	t.Log("a parents and height bucket is read from memory once loaded")
+	assert.True(lazyChain.isBucketLoaded(link3.String(), uint64(6)))
+	require.NoError(ds.Delete(tipIndexParentsKey.ChildString(makeKey(link3.String(), uint64(6))).ChildString(link4.String())))
+	got4 = requireGetTsasByParentAndHeight(ctx, require, lazyChain, link3.String(), uint64(6))
+	require.Equal(1, len(got4))
+	assert.Equal(link4, got4[0].TipSet)
+
+	_, err := lazyChain.GetTipSetAndState(ctx, "{ unknown }")
+	assert.Equal(ErrNotFound, err)
+	assert.False(lazyChain.HasTipSetAndStatesWithParentsAndHeight(ctx, link3.String(), uint64(7)))
+}
+
+// A datastore written before the tip index was persisted is loaded by
+// walking the chain, which persists the index.
+func TestLoadLegacyTipIndex(t *testing.T) {
+	ctx := context.Background()
+	initStoreTest(ctx, require.New(t))
+	assert := assert.New(t)
+	require := require.New(t)
+
+	r := repo.NewInMemoryRepo()
+	ds := r.Datastore()
+	chain := NewDefaultStore(ds, hamt.NewCborStore(), genCid)
+	requirePutTestChain(require, chain)
+	assertSetHead(assert, chain, link4)
+	chain.Stop()
+
+	// Rewrite the index the way it was stored before it was persisted.
+	for _, tsas := range []*TipSetAndState{
+		{TipSet: genTS, TipSetStateRoot: genStateRoot},
+		{TipSet: link1, TipSetStateRoot: link1State},
+		{TipSet: link2, TipSetStateRoot: link2State},
+		{TipSet: link3, TipSetStateRoot: link3State},
+		{TipSet: link4, TipSetStateRoot: link4State},
+	} {
+		h, err := tsas.TipSet.Height()
+		require.NoError(err)
+		val, err := json.Marshal(tsas.TipSetStateRoot)
+		require.NoError(err)
+		require.NoError(ds.Put(datastore.NewKey(makeKey(tsas.TipSet.String(), h)), val))
+		require.NoError(ds.Delete(tipIndexTipSetsKey.ChildString(tsas.TipSet.String())))
+	}
+	require.NoError(ds.Delete(tipIndexMigratedKey))
+
+	rebootChain := NewDefaultStore(ds, hamt.NewCborStore(), genCid)
+	require.NoError(rebootChain.Load(ctx))
+	assert.Equal(link4, rebootChain.Head())
+	assert.True(rebootChain.hasTipIndexRecord(link2.String()))
+
+	got3 := requireGetTsas(ctx, require, rebootChain, link3.String())
+	assert.Equal(link3State, got3.TipSetStateRoot)
+
+	t.Log("the migrated index is loaded without walking the chain")
+	require.NoError(ds.Delete(datastore.NewKey(makeKey(link2.String(), uint64(2)))))
+	rebootChain.Stop()
+	rebootChain = NewDefaultStore(ds, hamt.NewCborStore(), genCid)
+	require.NoError(rebootChain.Load(ctx))
+	assert.Equal(link4, rebootChain.Head())
+}
+
+// A migration of the tip index interrupted before reaching genesis is resumed
+// on the next Load.
+func TestLoadInterruptedTipIndexMigration(t *testing.T) {
+	ctx := context.Background()
+	initStoreTest(ctx, require.New(t))
+	assert := assert.New(t)
+	require := require.New(t)
+
+	r := repo.NewInMemoryRepo()
+	ds := r.Datastore()
+	chain := NewDefaultStore(ds, hamt.NewCborStore(), genCid)
+	requirePutTestChain(require, chain)
+	assertSetHead(assert, chain, link4)
+	chain.Stop()
+
+	// Rewrite the index the way it was stored before it was persisted, but
+	// for the tipsets near the head that the interrupted migration indexed.
+	for _, tsas := range []*TipSetAndState{
+		{TipSet: genTS, TipSetStateRoot: genStateRoot},
+		{TipSet: link1, TipSetStateRoot: link1State},
+		{TipSet: link2, TipSetStateRoot: link2State},
+		{TipSet: link3, TipSetStateRoot: link3State},
+		{TipSet: link4, TipSetStateRoot: link4State},
+	} {
+		h, err := tsas.TipSet.Height()
+		require.NoError(err)
+		val, err := json.Marshal(tsas.TipSetStateRoot)
+		require.NoError(err)
+		require.NoError(ds.Put(datastore.NewKey(makeKey(tsas.TipSet.String(), h)), val))
+		if h < 3 {
+			require.NoError(ds.Delete(tipIndexTipSetsKey.ChildString(tsas.TipSet.String())))
+		}
+	}
+	require.NoError(ds.Delete(tipIndexMigratedKey))
+
+	rebootChain := NewDefaultStore(ds, hamt.NewCborStore(), genCid)
+	require.NoError(rebootChain.Load(ctx))
+	assert.Equal(link4, rebootChain.Head())
+	assert.True(rebootChain.hasTipIndexRecord(genTS.String()))
+	assert.True(rebootChain.isTipIndexMigrated())
+}
+
+// Load fails on a datastore of a chain with another genesis block.
+func TestLoadWrongGenesis(t *testing.T) {
+	ctx := context.Background()
+	initStoreTest(ctx, require.New(t))
+	assert := assert.New(t)
+	require := require.New(t)
+
+	r := repo.NewInMemoryRepo()
+	ds := r.Datastore()
+	chain := NewDefaultStore(ds, hamt.NewCborStore(), genCid)
+	requirePutTestChain(require, chain)
+	assertSetHead(assert, chain, link4)
+	chain.Stop()
+
+	rebootChain := NewDefaultStore(ds, hamt.NewCborStore(), link1.ToSlice()[0].Cid())
+	assert.Error(rebootChain.Load(ctx))
+}
+
+// Forks whose tipsets are all below the pruning height are removed along with
+// the blocks which are not on the chain of the head.
+func TestPruneForks(t *testing.T) {
+	ctx := context.Background()
+	initStoreTest(ctx, require.New(t))
+	assert := assert.New(t)
+	require := require.New(t)
+
+	chain := NewDefaultStore(repo.NewInMemoryRepo().Datastore(), hamt.NewCborStore(), genCid)
+	requirePutTestChain(require, chain)
+	assertSetHead(assert, chain, link4)
+
+	forkBlk := RequireMkFakeChild(require,
+		FakeChildParams{Parent: link1, GenesisCid: genCid, StateRoot: link1State, Nonce: uint64(7)})
+	forkTs := testhelpers.RequireNewTipSet(require, forkBlk)
+	forkChildBlk := RequireMkFakeChild(require,
+		FakeChildParams{Parent: forkTs, GenesisCid: genCid, StateRoot: link1State, Nonce: uint64(8)})
+	forkChildTs := testhelpers.RequireNewTipSet(require, forkChildBlk)
+	subsetTs := testhelpers.RequireNewTipSet(require, link2blk1)
+	forkState := cidGetter()
+	RequirePutTsas(ctx, require, chain, &TipSetAndState{TipSet: forkTs, TipSetStateRoot: forkState})
+	RequirePutTsas(ctx, require, chain, &TipSetAndState{TipSet: forkChildTs, TipSetStateRoot: forkState})
+	RequirePutTsas(ctx, require, chain, &TipSetAndState{TipSet: subsetTs, TipSetStateRoot: forkState})
+
+	roots, err := chain.StateRootsSince(2)
+	require.NoError(err)
+	assert.Len(roots, 6)
+
+	t.Log("a fork with a tipset at the pruning height is kept")
+	pruned, err := chain.PruneForks(ctx, 3)
+	require.NoError(err)
+	assert.Equal(1, pruned)
+	assert.True(chain.HasTipSetAndState(ctx, forkTs.String()))
+	assert.True(chain.HasTipSetAndState(ctx, forkChildTs.String()))
+
+	pruned, err = chain.PruneForks(ctx, 4)
+	require.NoError(err)
+	assert.Equal(2, pruned)
+
+	assert.False(chain.HasTipSetAndState(ctx, forkTs.String()))
+	assert.False(chain.HasTipSetAndState(ctx, forkChildTs.String()))
+	assert.False(chain.HasBlock(ctx, forkChildBlk.Cid()))
+	assert.False(chain.HasTipSetAndState(ctx, subsetTs.String()))
+	assert.False(chain.HasBlock(ctx, forkBlk.Cid()))
+	assert.True(chain.HasBlock(ctx, link2blk1.Cid()))
+	got := requireGetTsasByParentAndHeight(ctx, require, chain, link1.String(), uint64(2))
+	assert.Equal(1, len(got))
+	assert.Equal(link2, got[0].TipSet)
+
+	roots, err = chain.StateRootsSince(2)
+	require.NoError(err)
+	assert.Len(roots, 3)
+
+	blks, err := chain.IndexedBlocks()
+	require.NoError(err)
+	assert.Len(blks, 9)
+
+	t.Log("pruning again finds nothing more to remove")
+	pruned, err = chain.PruneForks(ctx, 4)
+	require.NoError(err)
+	assert.Equal(0, pruned)
+}
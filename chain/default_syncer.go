@@ -7,10 +7,12 @@ import (
 
 	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
 	"gx/ipfs/QmRXf2uUSdGSunRJsM9wXSUNVwLUGCY3So5fAs7h2CBJVf/go-hamt-ipld"
+	bstore "gx/ipfs/QmS2aqUZLJp8kF1ihE5rvDGE5LvmKDPnx32w9Z1BW9xLV5/go-ipfs-blockstore"
 	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
 	logging "gx/ipfs/QmcuXC5cxs79ro2cUuHs4HQ2bkDLJUYokwL8aivcX6HW3C/go-log"
 
 	"github.com/filecoin-project/go-filecoin/actor/builtin"
+	"github.com/filecoin-project/go-filecoin/config"
 	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/state"
 	"github.com/filecoin-project/go-filecoin/types"
@@ -19,6 +21,11 @@ import (
 // The amount of time the syncer will wait while fetching the blocks of a
 // tipset over the network.
 var blkWaitTime = time.Second // TODO set this parameter in an informed way too
+
+// syncBatchSize is the number of tipsets the syncer asks its fetcher for at
+// once.
+const syncBatchSize = 50
+
 var (
 	// ErrChainHasBadTipSet is returned when the syncer traverses a chain with a cached bad tipset.
 	ErrChainHasBadTipSet = errors.New("input chain contains a cached bad tipset")
@@ -30,6 +37,16 @@ var (
 
 var logSyncer = logging.Logger("chain.syncer")
 
+// TipSetFetcher fetches a tipset and its ancestors from peers of the network.
+type TipSetFetcher interface {
+	// FetchTipSets returns up to count tipsets, going back from and
+	// including the tipset with the key tsKey.
+	FetchTipSets(ctx context.Context, tsKey types.SortedCidSet, count int) ([]types.TipSet, error)
+	// BestHeight returns the height of the highest head reported by the
+	// peers, zero if it knows of none.
+	BestHeight() uint64
+}
+
 // DefaultSyncer updates its chain.Store according to the methods of its
 // consensus.Protocol.  It uses a bad tipset cache and a limit on new
 // blocks to traverse during chain collection.  The DefaultSyncer can query the
@@ -57,54 +74,78 @@ type DefaultSyncer struct {
 	cstOnline *hamt.CborIpldStore
 	// cstOffline is the node's shared offline storage.
 	cstOffline *hamt.CborIpldStore
-	// badTipSetCache is used to filter out collections of invalid blocks.
-	badTipSets *badTipSetCache
+	// badTipSets is used to filter out collections of invalid blocks.
+	badTipSets *BadTipSetCache
 	consensus  consensus.Protocol
 	chainStore Store
+	// fetcher fetches batches of tipsets from peers, if set. Blocks it
+	// does not provide are fetched over bitswap through cstOnline.
+	fetcher TipSetFetcher
+	// tracker records the progress of syncs for Status.
+	tracker syncTracker
+	// reorgs records the switches of the head to other branches.
+	reorgs reorgLog
+	// checkpoints are trusted tipsets every synced chain must contain.
+	checkpoints []*checkpoint
+	// locker, if set, is the GC locker of the blockstore state is written
+	// to. Its pin lock is held from writing state until the state is
+	// referenced from the chain store.
+	locker bstore.GCLocker
 }
 
 var _ Syncer = (*DefaultSyncer)(nil)
 
-// NewDefaultSyncer constructs a DefaultSyncer ready for use.
-func NewDefaultSyncer(online, offline *hamt.CborIpldStore, c consensus.Protocol, s Store) Syncer {
+// NewDefaultSyncer constructs a DefaultSyncer ready for use. Invalid tipsets
+// are recorded in the bad tipset cache. The fetcher may be nil, in which case
+// blocks are only fetched over bitswap. The GC locker l of the blockstore,
+// if it is garbage collected, is pinned while the syncer writes state.
+// Chains which do not contain the checkpoints are refused.
+func NewDefaultSyncer(online, offline *hamt.CborIpldStore, c consensus.Protocol, s Store, bad *BadTipSetCache, f TipSetFetcher, l bstore.GCLocker, checkpoints ...config.Checkpoint) Syncer {
 	return &DefaultSyncer{
-		cstOnline:  online,
-		cstOffline: offline,
-		badTipSets: &badTipSetCache{
-			bad: make(map[string]struct{}),
-		},
-		consensus:  c,
-		chainStore: s,
+		cstOnline:   online,
+		cstOffline:  offline,
+		badTipSets:  bad,
+		consensus:   c,
+		chainStore:  s,
+		fetcher:     f,
+		checkpoints: newCheckpoints(checkpoints),
+		locker:      l,
+	}
+}
+
+// pin takes the pin lock of the blockstore, if it is garbage collected, and
+// returns a function releasing it.
+func (syncer *DefaultSyncer) pin() func() {
+	if syncer.locker == nil {
+		return func() {}
 	}
+	return syncer.locker.PinLock().Unlock
 }
 
 // getBlksMaybeFromNet resolves cids of blocks.  It gets blocks from local
 // storage if they are available there, and otherwise resolves blocks over
 // the network.  This function will timeout if blocks are unavailable.
 // This method is all or nothing, it will error if any of the blocks cannot be
-// resolved.
+// resolved. A block which cannot be fetched before the timeout, while ctx is
+// not done, is attributed to the peer the chain came from as FaultTimeout.
 // WARNING -- this will take one second to error out if blocks are not found.
 // TODO the timeout factor blkWaitTime and maybe the whole timeout mechanism
 // could use some actual thought, this was just a simple first pass.
-func (syncer *DefaultSyncer) getBlksMaybeFromNet(ctx context.Context, blkCids []cid.Cid) ([]*types.Block, error) {
+func (syncer *DefaultSyncer) getBlksMaybeFromNet(parent context.Context, blkCids []cid.Cid) ([]*types.Block, error) {
 	var blks []*types.Block
-	ctx, cancel := context.WithTimeout(ctx, blkWaitTime)
+	ctx, cancel := context.WithTimeout(parent, blkWaitTime)
 	defer cancel()
 	for _, blkCid := range blkCids {
-		// try the chain store
-		blk, err := syncer.chainStore.GetBlock(ctx, blkCid)
-		if err == nil {
-			blks = append(blks, blk)
-			continue
-		}
-		// try the node's local offline storage
-		err = syncer.cstOffline.Get(ctx, blkCid, &blk)
+		blk, err := syncer.getBlkLocally(ctx, blkCid)
 		if err == nil {
 			blks = append(blks, blk)
 			continue
 		}
 		// try the network
 		if err = syncer.cstOnline.Get(ctx, blkCid, &blk); err != nil {
+			if parent.Err() == nil {
+				return nil, withFault(FaultTimeout, errors.Wrapf(err, "failed to fetch block %s", blkCid))
+			}
 			return nil, err
 		}
 		blks = append(blks, blk)
@@ -112,18 +153,93 @@ func (syncer *DefaultSyncer) getBlksMaybeFromNet(ctx context.Context, blkCids []
 	return blks, nil
 }
 
+// getBlksLocally resolves cids of blocks from local storage. It errors if any
+// of the blocks is not available there.
+func (syncer *DefaultSyncer) getBlksLocally(ctx context.Context, blkCids []cid.Cid) ([]*types.Block, error) {
+	var blks []*types.Block
+	for _, blkCid := range blkCids {
+		blk, err := syncer.getBlkLocally(ctx, blkCid)
+		if err != nil {
+			return nil, err
+		}
+		blks = append(blks, blk)
+	}
+	return blks, nil
+}
+
+// getBlkLocally resolves the cid of a block from the chain store or the
+// node's local offline storage.
+func (syncer *DefaultSyncer) getBlkLocally(ctx context.Context, blkCid cid.Cid) (*types.Block, error) {
+	// try the chain store
+	blk, err := syncer.chainStore.GetBlock(ctx, blkCid)
+	if err == nil {
+		return blk, nil
+	}
+	// try the node's local offline storage
+	if err = syncer.cstOffline.Get(ctx, blkCid, &blk); err != nil {
+		return nil, err
+	}
+	return blk, nil
+}
+
+// getBlks resolves the cids of the blocks of a tipset. Blocks which are not
+// available locally are taken from the tipsets already fetched during this
+// chain collection, or else fetched along with a batch of their ancestors
+// from peers by the fetcher. getBlks falls back to bitswap if the fetcher
+// fails.
+func (syncer *DefaultSyncer) getBlks(ctx context.Context, blkCids []cid.Cid, fetched map[string]types.TipSet) ([]*types.Block, error) {
+	tsKey := types.NewSortedCidSet(blkCids...)
+	if ts, ok := fetched[tsKey.String()]; ok {
+		return ts.ToSlice(), nil
+	}
+	if syncer.fetcher == nil {
+		return syncer.getBlksMaybeFromNet(ctx, blkCids)
+	}
+	if blks, err := syncer.getBlksLocally(ctx, blkCids); err == nil {
+		return blks, nil
+	}
+
+	tss, err := syncer.fetcher.FetchTipSets(ctx, tsKey, syncBatchSize)
+	if err != nil {
+		logSyncer.Infof("failed to fetch tipset %s from peers, falling back to bitswap: %s", tsKey.String(), err)
+		return syncer.getBlksMaybeFromNet(ctx, blkCids)
+	}
+	for _, ts := range tss {
+		// Keep fetched blocks in the node's offline storage, so that they
+		// can be served over bitswap like blocks fetched through it.
+		for _, blk := range ts {
+			if _, err := syncer.cstOffline.Put(ctx, blk); err != nil {
+				return nil, err
+			}
+		}
+		fetched[ts.String()] = ts
+	}
+	if ts, ok := fetched[tsKey.String()]; ok {
+		return ts.ToSlice(), nil
+	}
+	return syncer.getBlksMaybeFromNet(ctx, blkCids)
+}
+
 // collectChain resolves the cids of the head tipset and its ancestors to blocks
 // until it resolves blocks contained in the Store. collectChain may resolve cids
 // from the Store, the node's local offline cborstore, or the syncer's online
 // cbor store that is networked under the hood. collectChain errors if any
 // set of cids in the chain resolves to blocks that do not form a tipset, if
-// the chain is too long, or if any tipset has already been recorded as the
-// head of an invalid chain.
+// the chain is too long, if it does not contain a checkpoint or a checkpoint
+// cannot be resolved, or if any tipset has already been recorded as the head
+// of an invalid chain.
+//
+// If collectChain reaches a checkpoint whose parent is not in the Store, it
+// puts the checkpoint into the Store along with its state instead of going
+// further, so that the chain is validated from there.
 //
 // collectChain is the entrypoint to the code that interacts with the network.
 // It does NOT add tipsets to the store.
-func (syncer *DefaultSyncer) collectChain(ctx context.Context, blkCids []cid.Cid) ([]types.TipSet, types.TipSet, error) {
+func (syncer *DefaultSyncer) collectChain(ctx context.Context, blkCids []cid.Cid, state *SyncState) ([]types.TipSet, types.TipSet, error) {
 	var chain []types.TipSet
+	fetched := make(map[string]types.TipSet)
+	syncer.resolveCheckpoints(ctx, fetched)
+	passed := make(map[*checkpoint]bool)
 	defer logSyncer.Info("chain synced")
 	for {
 		var blks []*types.Block
@@ -133,19 +249,21 @@ func (syncer *DefaultSyncer) collectChain(ctx context.Context, blkCids []cid.Cid
 		logSyncer.Debugf("CollectChain next link: %s", tsKey)
 
 		if syncer.badTipSets.Has(tsKey) {
-			return nil, nil, ErrChainHasBadTipSet
+			return nil, nil, withFault(FaultInvalidBlock, ErrChainHasBadTipSet)
 		}
 
-		blks, err := syncer.getBlksMaybeFromNet(ctx, blkCids)
+		blks, err := syncer.getBlks(ctx, blkCids, fetched)
 		if err != nil {
 			return nil, nil, err
 		}
 
 		ts, err := syncer.consensus.NewValidTipSet(ctx, blks)
 		if err != nil {
-			syncer.badTipSets.Add(tsKey)
-			syncer.badTipSets.AddChain(chain)
-			return nil, nil, err
+			if !consensus.IsInvalid(err) {
+				return nil, nil, err
+			}
+			syncer.markBad(ctx, tsKey, chain, err)
+			return nil, nil, withFault(FaultInvalidBlock, err)
 		}
 
 		height, _ := ts.Height()
@@ -153,17 +271,47 @@ func (syncer *DefaultSyncer) collectChain(ctx context.Context, blkCids []cid.Cid
 			logSyncer.Infof("syncing the chain, currently at block height %d", height)
 		}
 
-		// Finish traversal if the tipset made is tracked in the store.
+		// Finish traversal if the tipset made is tracked in the store. The
+		// chain below it was checked when it was stored.
 		if syncer.chainStore.HasTipSetAndState(ctx, tsKey) {
+			if err := syncer.checkUnresolvedCheckpoints(ctx, chain, passed, fetched); err != nil {
+				if err == ErrChainMissesCheckpoint {
+					syncer.markBad(ctx, chain[0].String(), chain[1:], err)
+					return nil, nil, withFault(FaultInvalidBlock, err)
+				}
+				return nil, nil, err
+			}
 			return chain, ts, nil
 		}
 
-		// Update values to traverse next tipset
-		chain = append([]types.TipSet{ts}, chain...)
+		cp, err := syncer.checkCheckpoints(ts, height, passed)
+		if err != nil {
+			syncer.markBad(ctx, tsKey, chain, err)
+			return nil, nil, withFault(FaultInvalidBlock, err)
+		}
+
 		parentCidSet, err := ts.Parents()
 		if err != nil {
 			return nil, nil, err
 		}
+
+		// Start from a checkpoint rather than validating the chain below
+		// it, unless that chain is already in the store.
+		if cp != nil && !syncer.chainStore.HasTipSetAndState(ctx, parentCidSet.String()) {
+			if err := syncer.putCheckpoint(ctx, cp, ts, fetched); err != nil {
+				return nil, nil, err
+			}
+			return chain, ts, nil
+		}
+		syncer.tracker.update(state, func(s *SyncState) {
+			if s.Fetched == 0 {
+				s.TargetHeight = height
+			}
+			s.Fetched++
+		})
+
+		// Update values to traverse next tipset
+		chain = append([]types.TipSet{ts}, chain...)
 		blkCids = parentCidSet.ToSlice()
 	}
 }
@@ -185,6 +333,30 @@ func (syncer *DefaultSyncer) tipSetState(ctx context.Context, tsKey string) (sta
 	return st, nil
 }
 
+// putValidTipSet runs the state transition of tipset next from its parent
+// state st, to validate next and compute its state, and adds next and its
+// state to the chain store. The blockstore is pinned meanwhile, so that the
+// state written is not collected before the chain store references it.
+func (syncer *DefaultSyncer) putValidTipSet(ctx context.Context, next types.TipSet, ancestors []types.TipSet, st state.Tree) error {
+	defer syncer.pin()()
+
+	st, err := syncer.consensus.RunStateTransition(ctx, next, ancestors, st)
+	if err != nil {
+		if consensus.IsInvalid(err) {
+			return withFault(FaultInvalidMessage, err)
+		}
+		return err
+	}
+	root, err := st.Flush(ctx)
+	if err != nil {
+		return err
+	}
+	return syncer.chainStore.PutTipSetAndState(ctx, &TipSetAndState{
+		TipSet:          next,
+		TipSetStateRoot: root,
+	})
+}
+
 // syncOne syncs a single tipset with the chain store. syncOne calculates the
 // parent state of the tipset and calls into consensus to run a state transition
 // in order to validate the tipset.  In the case the input tipset is valid,
@@ -212,21 +384,7 @@ func (syncer *DefaultSyncer) syncOne(ctx context.Context, parent, next types.Tip
 		return err
 	}
 
-	// Run a state transition to validate the tipset and compute
-	// a new state to add to the store.
-	st, err = syncer.consensus.RunStateTransition(ctx, next, ancestors, st)
-	if err != nil {
-		return err
-	}
-	root, err := st.Flush(ctx)
-	if err != nil {
-		return err
-	}
-	err = syncer.chainStore.PutTipSetAndState(ctx, &TipSetAndState{
-		TipSet:          next,
-		TipSetStateRoot: root,
-	})
-	if err != nil {
+	if err := syncer.putValidTipSet(ctx, next, ancestors, st); err != nil {
 		return err
 	}
 	logSyncer.Debugf("Successfully updated store with %s", next.String())
@@ -255,9 +413,11 @@ func (syncer *DefaultSyncer) syncOne(ctx context.Context, parent, next types.Tip
 	}
 
 	if heavier {
+		oldHead := syncer.chainStore.Head()
 		if err = syncer.chainStore.SetHead(ctx, next); err != nil {
 			return err
 		}
+		syncer.recordReorg(ctx, oldHead, next)
 	}
 
 	return nil
@@ -313,11 +473,25 @@ func (syncer *DefaultSyncer) widen(ctx context.Context, ts types.TipSet) (types.
 	return wts, nil
 }
 
+// markBad records the tipset with key tsKey as rejected with err, along with
+// the chain collected on top of it. They are only persisted if err means
+// they are invalid by consensus, rather than e.g. that they conflict with a
+// checkpoint of this node.
+func (syncer *DefaultSyncer) markBad(ctx context.Context, tsKey string, chain []types.TipSet, err error) {
+	peer := syncPeer(ctx)
+	persist := consensus.IsInvalid(err)
+	syncer.badTipSets.Add(tsKey, err.Error(), peer, persist)
+	syncer.badTipSets.AddChain(chain, tsKey, peer, persist)
+}
+
 // HandleNewBlocks extends the Syncer's chain store by the given blocks if they
 // represent a valid extension. It limits the length of new chains it will
 // attempt to validate and caches invalid blocks it has encountered to
-// help prevent DOS.
-func (syncer *DefaultSyncer) HandleNewBlocks(ctx context.Context, blkCids []cid.Cid) error {
+// help prevent DOS. The blocks of a new chain are validated in parallel, while
+// the state transitions of its tipsets are run one after another and the head
+// is updated under the syncer's lock. FaultOf tells whether an error it
+// returns is the fault of the peer the blocks came from.
+func (syncer *DefaultSyncer) HandleNewBlocks(ctx context.Context, blkCids []cid.Cid) (err error) {
 	// ********** WARNING **********
 	//
 	// This concurrency model is flawed.  The mutex is held during a possibly
@@ -334,17 +508,38 @@ func (syncer *DefaultSyncer) HandleNewBlocks(ctx context.Context, blkCids []cid.
 		return nil
 	}
 
+	state := syncer.tracker.start(types.NewSortedCidSet(blkCids...), syncPeer(ctx))
+	defer func() {
+		syncer.tracker.finish(state, err)
+	}()
+
 	// Walk the chain given by the input blocks back to a known tipset in
 	// the store. This is the only code that may go to the network to
 	// resolve cids to blocks.
-	chain, parent, err := syncer.collectChain(ctx, blkCids)
+	chain, parent, err := syncer.collectChain(ctx, blkCids, state)
 	if err != nil {
 		return err
 	}
 
+	// Validate the blocks of the chain in parallel ahead of the state
+	// transitions, which are run one tipset after another below.
+	vctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	validations := syncer.preValidate(vctx, parent, chain)
+
 	// Try adding the tipsets of the chain to the store, checking for new
 	// heaviest tipsets.
 	for i, ts := range chain {
+		if err := validations[i].wait(); err != nil {
+			// Blocks which could not be validated, e.g. because the
+			// sync was cancelled or the verifier failed, are not
+			// known to be bad.
+			if !consensus.IsInvalid(err) {
+				return err
+			}
+			syncer.markBad(ctx, ts.String(), chain[i+1:], err)
+			return withFault(FaultInvalidBlock, err)
+		}
 		// TODO: this "i==0" leaks EC specifics into syncer abstraction
 		// for the sake of efficiency, consider plugging up this leak.
 		if i == 0 {
@@ -356,14 +551,44 @@ func (syncer *DefaultSyncer) HandleNewBlocks(ctx context.Context, blkCids []cid.
 				logSyncer.Debug("attempt to sync after widen")
 				err = syncer.syncOne(ctx, parent, wts)
 				if err != nil {
+					if consensus.IsInvalid(err) {
+						syncer.markBad(ctx, wts.String(), nil, err)
+					}
 					return err
 				}
 			}
 		}
 		if err = syncer.syncOne(ctx, parent, ts); err != nil {
+			// A tipset failing its state transition is bad, and so are
+			// the tipsets built on it.
+			if consensus.IsInvalid(err) {
+				syncer.markBad(ctx, ts.String(), chain[i+1:], err)
+			}
 			return err
 		}
+		syncer.tracker.update(state, func(s *SyncState) {
+			s.Validated++
+		})
 		parent = ts
 	}
 	return nil
 }
+
+// Status returns the state of the active syncs and the outcome of recent ones.
+// The syncer is syncing while a sync is active or while the head of its
+// store is below the best head its fetcher learned of from peers.
+func (syncer *DefaultSyncer) Status() *SyncStatus {
+	status := syncer.tracker.status()
+	head := syncer.chainStore.Head()
+	status.Head = head.String()
+	if len(head) > 0 {
+		status.HeadHeight, _ = head.Height()
+	}
+	if syncer.fetcher != nil {
+		status.BestHeight = syncer.fetcher.BestHeight()
+	}
+	if status.HeadHeight < status.BestHeight {
+		status.Syncing = true
+	}
+	return status
+}
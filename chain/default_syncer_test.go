@@ -7,9 +7,11 @@ import (
 	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
 	"gx/ipfs/QmRXf2uUSdGSunRJsM9wXSUNVwLUGCY3So5fAs7h2CBJVf/go-hamt-ipld"
 	bstore "gx/ipfs/QmS2aqUZLJp8kF1ihE5rvDGE5LvmKDPnx32w9Z1BW9xLV5/go-ipfs-blockstore"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
 
 	"github.com/filecoin-project/go-filecoin/actor/builtin"
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/config"
 	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/gengen/util"
 	"github.com/filecoin-project/go-filecoin/proofs"
@@ -191,6 +193,12 @@ func initSyncTestWithPowerTable(require *require.Assertions, powerTable consensu
 	return sync, chain, cst, con
 }
 
+func requireBadTipSetCache(require *require.Assertions, ds repo.Datastore) *BadTipSetCache {
+	bad, err := NewBadTipSetCache(ds)
+	require.NoError(err)
+	return bad
+}
+
 func initSyncTest(require *require.Assertions, con consensus.Protocol, genFunc func(cst *hamt.CborIpldStore, bs bstore.Blockstore) (*types.Block, error), cst *hamt.CborIpldStore, bs bstore.Blockstore, r repo.Repo) (Syncer, Store, *hamt.CborIpldStore, repo.Repo) {
 	ctx := context.Background()
 
@@ -204,7 +212,7 @@ func initSyncTest(require *require.Assertions, con consensus.Protocol, genFunc f
 	chain := NewDefaultStore(chainDS, cst, calcGenBlk.Cid())
 
 	// chain.Syncer
-	syncer := NewDefaultSyncer(cst, cst, con, chain) // note we use same cst for on and offline for tests
+	syncer := NewDefaultSyncer(cst, cst, con, chain, requireBadTipSetCache(require, chainDS), nil, nil) // note we use same cst for on and offline for tests
 
 	// Initialize stores to contain genesis block and state
 	calcGenTS := testhelpers.RequireNewTipSet(require, calcGenBlk)
@@ -413,6 +421,232 @@ func TestSyncChainHead(t *testing.T) {
 	assertHead(assert, chain, link4)
 }
 
+// fakeFetcher serves the tipsets of a chain, from the head down, and counts
+// the requests it gets.
+type fakeFetcher struct {
+	tss   []types.TipSet
+	calls int
+	best  uint64
+}
+
+func (f *fakeFetcher) BestHeight() uint64 {
+	return f.best
+}
+
+func (f *fakeFetcher) FetchTipSets(ctx context.Context, tsKey types.SortedCidSet, count int) ([]types.TipSet, error) {
+	f.calls++
+	for i, ts := range f.tss {
+		if ts.ToSortedCidSet().Equals(tsKey) {
+			end := i + count
+			if end > len(f.tss) {
+				end = len(f.tss)
+			}
+			return f.tss[i:end], nil
+		}
+	}
+	return nil, errors.New("tipset not found")
+}
+
+// Syncer fetches a whole chain it does not have in one batch from its fetcher.
+func TestSyncChainFromFetcher(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	pt := testhelpers.NewTestPowerTableView(1, 1)
+	_, chain, cst, con := initSyncTestWithPowerTable(require, pt)
+	ctx := context.Background()
+
+	fetcher := &fakeFetcher{tss: []types.TipSet{link4, link3, link2, link1}}
+	syncer := NewDefaultSyncer(cst, cst, con, chain, requireBadTipSetCache(require, repo.NewInMemoryRepo().ChainDatastore()), fetcher, nil)
+
+	err := syncer.HandleNewBlocks(ctx, link4.ToSortedCidSet().ToSlice())
+	assert.NoError(err)
+	assertTsAdded(assert, chain, link4)
+	assertTsAdded(assert, chain, link3)
+	assertTsAdded(assert, chain, link2)
+	assertTsAdded(assert, chain, link1)
+	assertHead(assert, chain, link4)
+	assert.Equal(1, fetcher.calls)
+}
+
+// Syncer records the progress and outcome of its syncs.
+func TestSyncStatus(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	syncer, chain, cst, _ := initSyncTestDefault(require)
+	ctx := WithSyncPeer(context.Background(), "peer")
+
+	status := syncer.Status()
+	assert.False(status.Syncing)
+	assert.Empty(status.Recent)
+
+	_ = requirePutBlocks(require, cst, link1.ToSlice()...)
+	cids2 := requirePutBlocks(require, cst, link2.ToSlice()...)
+	require.NoError(syncer.HandleNewBlocks(ctx, cids2))
+
+	status = syncer.Status()
+	assert.False(status.Syncing)
+	assert.Empty(status.Active)
+	assert.Equal(link2.String(), status.Head)
+	assert.Equal(uint64(2), status.HeadHeight)
+	require.Len(status.Recent, 1)
+	assert.Equal(link2.String(), status.Recent[0].Target)
+	assert.Equal(uint64(2), status.Recent[0].TargetHeight)
+	assert.Equal("peer", status.Recent[0].Peer)
+	assert.Equal(2, status.Recent[0].Fetched)
+	assert.Equal(2, status.Recent[0].Validated)
+	assert.Empty(status.Recent[0].Error)
+
+	t.Log("failed syncs record their error")
+	assertHead(assert, chain, link2)
+	err := syncer.HandleNewBlocks(context.Background(), []cid.Cid{types.SomeCid()})
+	require.Error(err)
+	status = syncer.Status()
+	require.Len(status.Recent, 2)
+	assert.Empty(status.Recent[0].Peer)
+	assert.Equal(err.Error(), status.Recent[0].Error)
+}
+
+// Syncer is syncing while its head is below the best head reported by peers.
+func TestSyncStatusBestHeight(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	pt := testhelpers.NewTestPowerTableView(1, 1)
+	_, chain, cst, con := initSyncTestWithPowerTable(require, pt)
+	ctx := context.Background()
+
+	best, err := link4.Height()
+	require.NoError(err)
+	fetcher := &fakeFetcher{tss: []types.TipSet{link4, link3, link2, link1}, best: best}
+	syncer := NewDefaultSyncer(cst, cst, con, chain, requireBadTipSetCache(require, repo.NewInMemoryRepo().ChainDatastore()), fetcher, nil)
+
+	status := syncer.Status()
+	assert.True(status.Syncing)
+	assert.Empty(status.Active)
+	assert.Equal(best, status.BestHeight)
+
+	require.NoError(syncer.HandleNewBlocks(ctx, link4.ToSortedCidSet().ToSlice()))
+	status = syncer.Status()
+	assert.False(status.Syncing)
+	assert.Equal(best, status.HeadHeight)
+}
+
+// initCheckpointSyncTest creates a chain store for tests creating a syncer
+// with checkpoints, and returns its consensus and repo.
+func initCheckpointSyncTest(require *require.Assertions) (Store, *hamt.CborIpldStore, consensus.Protocol, repo.Repo) {
+	r := repo.NewInMemoryRepo()
+	bs := bstore.NewBlockstore(r.Datastore())
+	cst := hamt.NewCborStore()
+	verifier := proofs.NewFakeVerifier(true, nil)
+	con := consensus.NewExpected(cst, bs, testhelpers.NewTestProcessor(), &testhelpers.TestView{}, genCid, verifier)
+	requireSetTestChain(require, con, false)
+	_, chain, cst, _ := initSyncTest(require, con, consensus.InitGenesis, cst, bs, r)
+	return chain, cst, con, r
+}
+
+// Syncer starts from a checkpoint instead of validating the chain below it.
+func TestSyncFromCheckpoint(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	chain, cst, con, r := initCheckpointSyncTest(require)
+	ctx := context.Background()
+
+	cp := config.Checkpoint{TipSet: link2.ToSortedCidSet(), StateRoot: link2State}
+	syncer := NewDefaultSyncer(cst, cst, con, chain, requireBadTipSetCache(require, r.ChainDatastore()), nil, nil, cp)
+
+	_ = requirePutBlocks(require, cst, link1.ToSlice()...)
+	_ = requirePutBlocks(require, cst, link2.ToSlice()...)
+	_ = requirePutBlocks(require, cst, link3.ToSlice()...)
+	cids4 := requirePutBlocks(require, cst, link4.ToSlice()...)
+
+	err := syncer.HandleNewBlocks(ctx, cids4)
+	assert.NoError(err)
+	assertTsAdded(assert, chain, link2)
+	assertTsAdded(assert, chain, link3)
+	assertTsAdded(assert, chain, link4)
+	assertHead(assert, chain, link4)
+	assert.False(chain.HasTipSetAndState(ctx, link1.String()))
+
+	t.Log("the chain is loaded down to the checkpoint")
+	loadSyncerFromRepo(require, r)
+}
+
+// Syncer refuses chains which do not contain a checkpoint.
+func TestSyncRefusesChainWithoutCheckpoint(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	chain, cst, con, r := initCheckpointSyncTest(require)
+	ctx := context.Background()
+
+	forkBlk := RequireMkFakeChildWithCon(require,
+		FakeChildParams{Parent: link1, GenesisCid: genCid, StateRoot: link1State, Nonce: uint64(5), Consensus: con, MinerAddr: minerAddress})
+	_ = requirePutBlocks(require, cst, forkBlk)
+	cp := config.Checkpoint{TipSet: types.NewSortedCidSet(forkBlk.Cid()), StateRoot: link1State}
+	syncer := NewDefaultSyncer(cst, cst, con, chain, requireBadTipSetCache(require, r.ChainDatastore()), nil, nil, cp)
+
+	_ = requirePutBlocks(require, cst, link1.ToSlice()...)
+	_ = requirePutBlocks(require, cst, link2.ToSlice()...)
+	_ = requirePutBlocks(require, cst, link3.ToSlice()...)
+	cids4 := requirePutBlocks(require, cst, link4.ToSlice()...)
+
+	err := syncer.HandleNewBlocks(ctx, cids4)
+	assert.Equal(ErrChainMissesCheckpoint, errors.Cause(err))
+	assert.Equal(FaultInvalidBlock, FaultOf(err))
+	assertNoAdd(assert, chain, cids4)
+	assertNoAdd(assert, chain, link1.ToSortedCidSet().ToSlice())
+}
+
+// Syncer does not check the tipsets it already stored against checkpoints.
+func TestSyncStoredTipSetBelowCheckpoint(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	chain, cst, con, r := initCheckpointSyncTest(require)
+	ctx := context.Background()
+
+	_ = requirePutBlocks(require, cst, link1.ToSlice()...)
+	cids2 := requirePutBlocks(require, cst, link2.ToSlice()...)
+	_ = requirePutBlocks(require, cst, link3.ToSlice()...)
+	cids4 := requirePutBlocks(require, cst, link4.ToSlice()...)
+
+	bad := requireBadTipSetCache(require, r.ChainDatastore())
+	syncer := NewDefaultSyncer(cst, cst, con, chain, bad, nil, nil)
+	require.NoError(syncer.HandleNewBlocks(ctx, cids2))
+
+	cp := config.Checkpoint{TipSet: link3.ToSortedCidSet(), StateRoot: link3State}
+	syncer = NewDefaultSyncer(cst, cst, con, chain, bad, nil, nil, cp)
+	collected, parent, err := syncer.(*DefaultSyncer).collectChain(ctx, cids2, nil)
+	require.NoError(err)
+	assert.Empty(collected)
+	assert.Equal(link2, parent)
+	assert.False(bad.Has(link2.String()))
+
+	assert.NoError(syncer.HandleNewBlocks(ctx, cids4))
+	assertTsAdded(assert, chain, link4)
+	assertHead(assert, chain, link4)
+}
+
+// Syncer refuses chains which it cannot check against a checkpoint it cannot
+// resolve, without blaming the peer.
+func TestSyncWithUnresolvedCheckpoint(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	chain, cst, con, r := initCheckpointSyncTest(require)
+	ctx := context.Background()
+
+	cp := config.Checkpoint{TipSet: types.NewSortedCidSet(types.SomeCid()), StateRoot: link1State}
+	syncer := NewDefaultSyncer(cst, cst, con, chain, requireBadTipSetCache(require, r.ChainDatastore()), nil, nil, cp)
+
+	_ = requirePutBlocks(require, cst, link1.ToSlice()...)
+	_ = requirePutBlocks(require, cst, link2.ToSlice()...)
+	_ = requirePutBlocks(require, cst, link3.ToSlice()...)
+	cids4 := requirePutBlocks(require, cst, link4.ToSlice()...)
+
+	err := syncer.HandleNewBlocks(ctx, cids4)
+	assert.Equal(ErrUnresolvedCheckpoint, errors.Cause(err))
+	assert.Equal(FaultNone, FaultOf(err))
+	assertNoAdd(assert, chain, cids4)
+	assertNoAdd(assert, chain, link1.ToSortedCidSet().ToSlice())
+}
+
 // Syncer determines the heavier fork.
 func TestSyncIgnoreLightFork(t *testing.T) {
 	assert := assert.New(t)
@@ -509,7 +743,170 @@ func TestBlocksNotATipSet(t *testing.T) {
 	badCids := []cid.Cid{link1blk1.Cid(), link2blk1.Cid()}
 	err := syncer.HandleNewBlocks(ctx, badCids)
 	assert.Error(err)
+	assert.Equal(FaultInvalidBlock, FaultOf(err))
 	assertNoAdd(assert, chain, badCids)
+
+	// The bad tipset is remembered and still attributed to the peer.
+	err = syncer.HandleNewBlocks(ctx, badCids)
+	assert.Equal(ErrChainHasBadTipSet, errors.Cause(err))
+	assert.Equal(FaultInvalidBlock, FaultOf(err))
+}
+
+// failingValidator is a consensus.Protocol failing the validation of a block.
+type failingValidator struct {
+	consensus.Protocol
+	bad cid.Cid
+}
+
+func (v *failingValidator) ValidateBlock(ctx context.Context, blk *types.Block, parent types.TipSet) error {
+	if blk.Cid().Equals(v.bad) {
+		return consensus.NewInvalidError(errors.New("invalid block"))
+	}
+	return v.Protocol.ValidateBlock(ctx, blk, parent)
+}
+
+// failingTransition is a consensus.Protocol whose state transition of a
+// tipset fails as invalid.
+type failingTransition struct {
+	consensus.Protocol
+	bad string
+}
+
+func (v *failingTransition) RunStateTransition(ctx context.Context, ts types.TipSet, ancestors []types.TipSet, pSt state.Tree) (state.Tree, error) {
+	if ts.String() == v.bad {
+		return nil, consensus.NewInvalidError(errors.New("invalid message"))
+	}
+	return v.Protocol.RunStateTransition(ctx, ts, ancestors, pSt)
+}
+
+// stalledValidator is a consensus.Protocol whose validation of a block only
+// returns once its context is done.
+type stalledValidator struct {
+	consensus.Protocol
+	stalled cid.Cid
+	started chan struct{}
+}
+
+func (v *stalledValidator) ValidateBlock(ctx context.Context, blk *types.Block, parent types.TipSet) error {
+	if blk.Cid().Equals(v.stalled) {
+		close(v.started)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return v.Protocol.ValidateBlock(ctx, blk, parent)
+}
+
+// Syncer adds the tipsets of a chain below a block failing validation, and
+// refuses the tipset of the block and the tipsets above it.
+func TestSyncStopsAtInvalidBlock(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	pt := testhelpers.NewTestPowerTableView(1, 1)
+	_, chain, cst, con := initSyncTestWithPowerTable(require, pt)
+	ctx := context.Background()
+
+	bad := requireBadTipSetCache(require, repo.NewInMemoryRepo().ChainDatastore())
+	syncer := NewDefaultSyncer(cst, cst, &failingValidator{Protocol: con, bad: link3blk1.Cid()}, chain, bad, nil, nil)
+
+	_ = requirePutBlocks(require, cst, link1.ToSlice()...)
+	_ = requirePutBlocks(require, cst, link2.ToSlice()...)
+	_ = requirePutBlocks(require, cst, link3.ToSlice()...)
+	cids4 := requirePutBlocks(require, cst, link4.ToSlice()...)
+
+	err := syncer.HandleNewBlocks(ctx, cids4)
+	assert.EqualError(err, "invalid block")
+	assert.Equal(FaultInvalidBlock, FaultOf(err))
+	assertTsAdded(assert, chain, link1)
+	assertTsAdded(assert, chain, link2)
+	assertHead(assert, chain, link2)
+	assertNoAdd(assert, chain, link3.ToSortedCidSet().ToSlice())
+	assertNoAdd(assert, chain, cids4)
+	assert.True(bad.Has(link3.String()))
+	assert.True(bad.Has(link4.String()))
+}
+
+// Syncer refuses a tipset failing its state transition and the tipsets above
+// it, and remembers them as bad.
+func TestSyncStopsAtInvalidStateTransition(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	pt := testhelpers.NewTestPowerTableView(1, 1)
+	_, chain, cst, con := initSyncTestWithPowerTable(require, pt)
+	ctx := context.Background()
+
+	bad := requireBadTipSetCache(require, repo.NewInMemoryRepo().ChainDatastore())
+	syncer := NewDefaultSyncer(cst, cst, &failingTransition{Protocol: con, bad: link3.String()}, chain, bad, nil, nil)
+
+	_ = requirePutBlocks(require, cst, link1.ToSlice()...)
+	_ = requirePutBlocks(require, cst, link2.ToSlice()...)
+	_ = requirePutBlocks(require, cst, link3.ToSlice()...)
+	cids4 := requirePutBlocks(require, cst, link4.ToSlice()...)
+
+	err := syncer.HandleNewBlocks(ctx, cids4)
+	assert.EqualError(err, "invalid message")
+	assert.Equal(FaultInvalidMessage, FaultOf(err))
+	assertHead(assert, chain, link2)
+	assertNoAdd(assert, chain, cids4)
+	assert.True(bad.Has(link3.String()))
+	assert.True(bad.Has(link4.String()))
+
+	err = syncer.HandleNewBlocks(ctx, cids4)
+	assert.Equal(ErrChainHasBadTipSet, errors.Cause(err))
+}
+
+// Syncer does not take the blocks it could not validate because the sync was
+// cancelled for bad ones.
+func TestSyncCancelledDuringValidation(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	pt := testhelpers.NewTestPowerTableView(1, 1)
+	_, chain, cst, con := initSyncTestWithPowerTable(require, pt)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bad := requireBadTipSetCache(require, repo.NewInMemoryRepo().ChainDatastore())
+	validator := &stalledValidator{Protocol: con, stalled: link3blk1.Cid(), started: make(chan struct{})}
+	syncer := NewDefaultSyncer(cst, cst, validator, chain, bad, nil, nil)
+
+	_ = requirePutBlocks(require, cst, link1.ToSlice()...)
+	_ = requirePutBlocks(require, cst, link2.ToSlice()...)
+	_ = requirePutBlocks(require, cst, link3.ToSlice()...)
+	cids4 := requirePutBlocks(require, cst, link4.ToSlice()...)
+
+	go func() {
+		<-validator.started
+		cancel()
+	}()
+	err := syncer.HandleNewBlocks(ctx, cids4)
+	assert.Error(err)
+	assert.Equal(FaultNone, FaultOf(err))
+	assertNoAdd(assert, chain, cids4)
+	assert.Empty(bad.List())
+}
+
+// Syncer remembers bad tipsets across restarts, with why they were rejected.
+func TestBadTipSetsPersisted(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	syncer, _, cst, r := initSyncTestDefault(require)
+	ctx := WithSyncPeer(context.Background(), "peer")
+
+	_ = requirePutBlocks(require, cst, link1.ToSlice()...)
+	_ = requirePutBlocks(require, cst, link2.ToSlice()...)
+	badCids := []cid.Cid{link1blk1.Cid(), link2blk1.Cid()}
+	err := syncer.HandleNewBlocks(ctx, badCids)
+	require.Error(err)
+
+	bad := requireBadTipSetCache(require, r.ChainDatastore()).List()
+	require.Len(bad, 1)
+	assert.Equal(types.NewSortedCidSet(badCids...).String(), bad[0].Key)
+	assert.Equal(err.Error(), bad[0].Reason)
+	assert.Equal("peer", bad[0].Peer)
+	assert.False(bad[0].FirstSeen.IsZero())
+
+	syncer, _ = loadSyncerFromRepo(require, r)
+	err = syncer.HandleNewBlocks(ctx, badCids)
+	assert.Equal(ErrChainHasBadTipSet, errors.Cause(err))
 }
 
 /* particularly tricky edge cases relating to subtle Expected Consensus requirements */
@@ -567,6 +964,62 @@ func TestLoadFork(t *testing.T) {
 	assert.NoError(err)
 }
 
+func TestReorgRecorded(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	syncer, chain, cst, _ := initSyncTestDefault(require)
+	ctx := context.Background()
+
+	_ = requirePutBlocks(require, cst, link1.ToSlice()...)
+	cids2 := requirePutBlocks(require, cst, link2.ToSlice()...)
+	require.NoError(syncer.HandleNewBlocks(ctx, cids2))
+
+	// Building on the head is not a reorg.
+	ds := syncer.(*DefaultSyncer)
+	assert.Empty(ds.Reorgs())
+
+	// Switch to a heavier fork off link1.
+	forklink1blk1 := RequireMkFakeChild(require,
+		FakeChildParams{Parent: link1, GenesisCid: genCid, StateRoot: genStateRoot, Nonce: uint64(10)})
+	forklink1blk2 := RequireMkFakeChild(require,
+		FakeChildParams{Parent: link1, GenesisCid: genCid, StateRoot: genStateRoot, Nonce: uint64(11)})
+	forklink1 := testhelpers.RequireNewTipSet(require, forklink1blk1, forklink1blk2)
+	forklink2 := testhelpers.RequireNewTipSet(require, RequireMkFakeChild(require,
+		FakeChildParams{Parent: forklink1, GenesisCid: genCid, StateRoot: genStateRoot}))
+	forklink3 := testhelpers.RequireNewTipSet(require, RequireMkFakeChild(require,
+		FakeChildParams{Parent: forklink2, GenesisCid: genCid, StateRoot: genStateRoot}))
+
+	_ = requirePutBlocks(require, cst, forklink1.ToSlice()...)
+	_ = requirePutBlocks(require, cst, forklink2.ToSlice()...)
+	forkHead := requirePutBlocks(require, cst, forklink3.ToSlice()...)
+	require.NoError(syncer.HandleNewBlocks(ctx, forkHead))
+	requireHead(require, chain, forklink3)
+
+	reorgs := ds.Reorgs()
+	require.Len(reorgs, 1)
+	r := reorgs[0]
+	assert.Equal(link2.String(), r.OldHead)
+	assert.Equal(forklink3.String(), r.NewHead)
+	assert.Equal(link1.String(), r.CommonAncestor)
+	link1Height, err := link1.Height()
+	require.NoError(err)
+	link2Height, err := link2.Height()
+	require.NoError(err)
+	assert.Equal(link1Height, r.CommonAncestorHeight)
+	assert.Equal(link2Height, r.OldHeadHeight)
+	assert.Equal(link2Height-link1Height, r.Depth)
+	assert.True(r.NewHeadWeight > r.OldHeadWeight)
+
+	// The abandoned tipset is listed as a fork, with its weight.
+	forks, err := ds.Forks(ctx)
+	require.NoError(err)
+	require.Len(forks, 1)
+	assert.Equal(link2.String(), forks[0].TipSet)
+	assert.Equal(link1.String(), forks[0].Parent)
+	assert.Equal(link2Height, forks[0].Height)
+	assert.Equal(r.OldHeadWeight, forks[0].Weight)
+}
+
 // Syncer must track state of subsets of parent tipsets tracked in the store
 // when they are the ancestor in a chain.  This is in order to maintain the
 // invariant that the aggregate state of the  parents of the base of a collected chain
@@ -812,7 +1265,7 @@ func TestTipSetWeightDeep(t *testing.T) {
 	// Now sync the chain with consensus using a MarketView.
 	verifier = proofs.NewFakeVerifier(true, nil)
 	con = consensus.NewExpected(cst, bs, testhelpers.NewTestProcessor(), &consensus.MarketView{}, calcGenBlk.Cid(), verifier)
-	syncer := NewDefaultSyncer(cst, cst, con, chain)
+	syncer := NewDefaultSyncer(cst, cst, con, chain, requireBadTipSetCache(require, r.ChainDatastore()), nil, nil)
 	baseTS := chain.Head() // this is the last block of the bootstrapping chain creating miners
 	require.Equal(1, len(baseTS))
 	bootstrapStateRoot := baseTS.ToSlice()[0].StateRoot
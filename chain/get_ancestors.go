@@ -62,11 +62,21 @@ func GetRecentAncestors(ctx context.Context, base types.TipSet, chainReader Read
 	// This is needed because CollectTipSetsOfHeightAtLeast necessarily reads out
 	// the first tipset of extraRandomnessAncestors from the channel so historyCh can't
 	// be reused.
-	tsas, err := chainReader.GetTipSetAndState(ctx, firstExtraRandomnessAncestorsCids.String())
+	// The tipset is assembled from its blocks rather than read from the
+	// tip index, as the ancestors of a checkpoint are not indexed.
+	var blks []*types.Block
+	for it := firstExtraRandomnessAncestorsCids.Iter(); !it.Complete(); it.Next() {
+		blk, err := chainReader.GetBlock(ctx, it.Value())
+		if err != nil {
+			return nil, err
+		}
+		blks = append(blks, blk)
+	}
+	firstExtraRandomnessAncestor, err := types.NewTipSet(blks...)
 	if err != nil {
 		return nil, err
 	}
-	historyCh = chainReader.BlockHistory(ctx, tsas.TipSet)
+	historyCh = chainReader.BlockHistory(ctx, firstExtraRandomnessAncestor)
 	extraRandomnessAncestors, err := CollectAtMostNTipSets(ctx, historyCh, lookback)
 	if err != nil {
 		return nil, err
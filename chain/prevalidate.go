@@ -0,0 +1,121 @@
+package chain
+
+import (
+	"context"
+	"runtime"
+	"sync"
+
+	"github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// validationWorkers is the number of blocks the syncer validates in parallel.
+var validationWorkers = runtime.NumCPU()
+
+// tipSetValidation is the outcome of validating the blocks of a tipset,
+// which is known once all of them are validated.
+type tipSetValidation struct {
+	wg sync.WaitGroup
+	mu sync.Mutex
+	// invalid is the first error a block was found invalid with.
+	invalid error
+	// err is the first error a block could not be validated with.
+	err error
+}
+
+// fail records err, which validating a block of the tipset failed with,
+// unless an earlier error of the same kind was recorded.
+func (v *tipSetValidation) fail(err error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if consensus.IsInvalid(err) {
+		if v.invalid == nil {
+			v.invalid = err
+		}
+	} else if v.err == nil {
+		v.err = err
+	}
+}
+
+// abort records that a block of the tipset was not validated because
+// validation was stopped with err. The tipset is not known to be invalid.
+func (v *tipSetValidation) abort(err error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.err == nil {
+		v.err = err
+	}
+}
+
+// wait waits until all blocks of the tipset are validated. It returns the
+// first error a block was found invalid with, and otherwise the first error
+// a block could not be validated with, for which consensus.IsInvalid is
+// false.
+func (v *tipSetValidation) wait() error {
+	v.wg.Wait()
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.invalid != nil {
+		return v.invalid
+	}
+	return v.err
+}
+
+type validationJob struct {
+	blk    *types.Block
+	parent types.TipSet
+	v      *tipSetValidation
+}
+
+// preValidate starts validating the blocks of the chain, built on parent, in
+// parallel with consensus.Protocol.ValidateBlock, oldest tipsets first. It
+// returns the validation of each tipset of the chain, so that state
+// transitions can be run on the validated tipsets while the ones above them
+// are still being validated. Validation stops when ctx is done, the blocks
+// left are then aborted rather than failed.
+func (syncer *DefaultSyncer) preValidate(ctx context.Context, parent types.TipSet, chain []types.TipSet) []*tipSetValidation {
+	validations := make([]*tipSetValidation, len(chain))
+	var nBlks int
+	for i, ts := range chain {
+		validations[i] = &tipSetValidation{}
+		validations[i].wg.Add(len(ts))
+		nBlks += len(ts)
+	}
+
+	jobs := make(chan validationJob)
+	workers := validationWorkers
+	if nBlks < workers {
+		workers = nBlks
+	}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range jobs {
+				if err := syncer.consensus.ValidateBlock(ctx, job.blk, job.parent); err != nil {
+					job.v.fail(err)
+				}
+				job.v.wg.Done()
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for i, ts := range chain {
+			for _, blk := range ts.ToSlice() {
+				job := validationJob{blk: blk, parent: parent, v: validations[i]}
+				if ctx.Err() == nil {
+					select {
+					case jobs <- job:
+						continue
+					case <-ctx.Done():
+					}
+				}
+				job.v.abort(ctx.Err())
+				job.v.wg.Done()
+			}
+			parent = ts
+		}
+	}()
+
+	return validations
+}
@@ -0,0 +1,214 @@
+package chain
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// maxReorgs is the number of reorgs a syncer reports.
+const maxReorgs = 100
+
+// Reorg describes a switch of the head to a heavier tipset on another branch
+// of the chain.
+type Reorg struct {
+	// Time is the time of the switch.
+	Time time.Time `json:"time"`
+	// OldHead is the key of the head switched from.
+	OldHead       string `json:"oldHead"`
+	OldHeadHeight uint64 `json:"oldHeadHeight"`
+	OldHeadWeight uint64 `json:"oldHeadWeight"`
+	// NewHead is the key of the head switched to.
+	NewHead       string `json:"newHead"`
+	NewHeadHeight uint64 `json:"newHeadHeight"`
+	NewHeadWeight uint64 `json:"newHeadWeight"`
+	// CommonAncestor is the key of the latest tipset both heads descend
+	// from.
+	CommonAncestor       string `json:"commonAncestor"`
+	CommonAncestorHeight uint64 `json:"commonAncestorHeight"`
+	// Depth is the number of rounds of the old head's branch above the
+	// common ancestor.
+	Depth uint64 `json:"depth"`
+}
+
+// ForkTipSet describes a tipset of the store which is not on the chain of
+// the head.
+type ForkTipSet struct {
+	TipSet string `json:"tipset"`
+	Parent string `json:"parent"`
+	Height uint64 `json:"height"`
+	// Weight is the weight of the tipset, or zero if its parent state is
+	// not in the store.
+	Weight uint64 `json:"weight"`
+}
+
+// reorgLog records the most recent reorgs. It is safe for concurrent access.
+type reorgLog struct {
+	mu     sync.Mutex
+	reorgs []Reorg
+}
+
+// add records r as the latest reorg.
+func (l *reorgLog) add(r Reorg) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.reorgs = append([]Reorg{r}, l.reorgs...)
+	if len(l.reorgs) > maxReorgs {
+		l.reorgs = l.reorgs[:maxReorgs]
+	}
+}
+
+// list returns a copy of the recorded reorgs, latest first.
+func (l *reorgLog) list() []Reorg {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Reorg{}, l.reorgs...)
+}
+
+// recordReorg records the switch of the head from oldHead to newHead if
+// newHead is not built on oldHead. Failures are logged rather than returned
+// as the head is already switched.
+func (syncer *DefaultSyncer) recordReorg(ctx context.Context, oldHead, newHead types.TipSet) {
+	ancestor, err := syncer.commonAncestor(ctx, oldHead, newHead)
+	if err != nil {
+		logSyncer.Warningf("failed to find the common ancestor of %s and %s: %s", oldHead.String(), newHead.String(), err)
+		return
+	}
+	// The head is not switched to another branch if the new head is built
+	// on it, or widens it with blocks of the same parents.
+	if ancestor.Equals(oldHead) || isSubset(oldHead, newHead) {
+		return
+	}
+
+	r := Reorg{
+		Time:           time.Now(),
+		OldHead:        oldHead.String(),
+		NewHead:        newHead.String(),
+		CommonAncestor: ancestor.String(),
+	}
+	r.OldHeadHeight, _ = oldHead.Height()
+	r.NewHeadHeight, _ = newHead.Height()
+	r.CommonAncestorHeight, _ = ancestor.Height()
+	r.Depth = r.OldHeadHeight - r.CommonAncestorHeight
+	if r.OldHeadWeight, err = syncer.weight(ctx, oldHead); err != nil {
+		logSyncer.Warningf("failed to compute the weight of %s: %s", oldHead.String(), err)
+	}
+	if r.NewHeadWeight, err = syncer.weight(ctx, newHead); err != nil {
+		logSyncer.Warningf("failed to compute the weight of %s: %s", newHead.String(), err)
+	}
+
+	logSyncer.Infof("reorg of depth %d from %s at height %d with weight %d to %s at height %d with weight %d, common ancestor %s at height %d",
+		r.Depth, r.OldHead, r.OldHeadHeight, r.OldHeadWeight, r.NewHead, r.NewHeadHeight, r.NewHeadWeight, r.CommonAncestor, r.CommonAncestorHeight)
+	syncer.reorgs.add(r)
+}
+
+// commonAncestor returns the latest tipset of the store both a and b are
+// or descend from.
+func (syncer *DefaultSyncer) commonAncestor(ctx context.Context, a, b types.TipSet) (types.TipSet, error) {
+	for !a.Equals(b) {
+		ha, err := a.Height()
+		if err != nil {
+			return nil, err
+		}
+		hb, err := b.Height()
+		if err != nil {
+			return nil, err
+		}
+		if ha >= hb {
+			a, err = syncer.parentTipSet(ctx, a)
+		} else {
+			b, err = syncer.parentTipSet(ctx, b)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// parentTipSet returns the parent of ts from the store.
+func (syncer *DefaultSyncer) parentTipSet(ctx context.Context, ts types.TipSet) (types.TipSet, error) {
+	pSet, err := ts.Parents()
+	if err != nil {
+		return nil, err
+	}
+	if pSet.Empty() {
+		return nil, errors.New("reached genesis")
+	}
+	tsas, err := syncer.chainStore.GetTipSetAndState(ctx, pSet.String())
+	if err != nil {
+		return nil, err
+	}
+	return tsas.TipSet, nil
+}
+
+// weight returns the weight of ts as computed by consensus from the state of
+// its parent.
+func (syncer *DefaultSyncer) weight(ctx context.Context, ts types.TipSet) (uint64, error) {
+	pSet, err := ts.Parents()
+	if err != nil {
+		return 0, err
+	}
+	if pSet.Empty() {
+		// The weight of genesis does not depend on a state.
+		return syncer.consensus.Weight(ctx, ts, nil)
+	}
+	pSt, err := syncer.tipSetState(ctx, pSet.String())
+	if err != nil {
+		return 0, err
+	}
+	return syncer.consensus.Weight(ctx, ts, pSt)
+}
+
+// isSubset returns true if all blocks of a are in b.
+func isSubset(a, b types.TipSet) bool {
+	bSet := b.ToSortedCidSet()
+	for _, blk := range a.ToSlice() {
+		if !bSet.Has(blk.Cid()) {
+			return false
+		}
+	}
+	return true
+}
+
+// Reorgs returns the most recent switches of the head to another branch of
+// the chain, latest first.
+func (syncer *DefaultSyncer) Reorgs() []Reorg {
+	return syncer.reorgs.list()
+}
+
+// Forks returns the tipsets of the store which are not on the chain of the
+// head, highest first, with their weights.
+func (syncer *DefaultSyncer) Forks(ctx context.Context) ([]ForkTipSet, error) {
+	tsass, err := syncer.chainStore.ForkTipSets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	forks := []ForkTipSet{}
+	for _, tsas := range tsass {
+		pSet, err := tsas.TipSet.Parents()
+		if err != nil {
+			return nil, err
+		}
+		fork := ForkTipSet{
+			TipSet: tsas.TipSet.String(),
+			Parent: pSet.String(),
+		}
+		if fork.Height, err = tsas.TipSet.Height(); err != nil {
+			return nil, err
+		}
+		if syncer.chainStore.HasTipSetAndState(ctx, pSet.String()) {
+			if fork.Weight, err = syncer.weight(ctx, tsas.TipSet); err != nil {
+				return nil, errors.Wrapf(err, "failed to compute the weight of %s", fork.TipSet)
+			}
+		}
+		forks = append(forks, fork)
+	}
+	return forks, nil
+}
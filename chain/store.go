@@ -58,6 +58,10 @@ type Store interface {
 	// PutTipSet adds a tipset to the store.  This persists blocks to disk and
 	// updates the tips index.
 	PutTipSetAndState(ctx context.Context, tsas *TipSetAndState) error
+	// PutCheckpoint adds a trusted tipset and its state to the store
+	// without its ancestors being in the store. Only the blocks of the
+	// ancestors needed to validate its children are persisted.
+	PutCheckpoint(ctx context.Context, tsas *TipSetAndState, ancestors []types.TipSet) error
 	// HasTipSet indicates whether the tipset is in the store.
 	HasTipSetAndState(ctx context.Context, tsKey string) bool
 	// GetTipSetsByParentsAndHeight returns all tipsets with the given parent set and the given height
@@ -73,4 +77,7 @@ type Store interface {
 
 	// SetHead sets the internally tracked  head to the provided tipset.
 	SetHead(ctx context.Context, s types.TipSet) error
+	// ForkTipSets returns the tipsets in the store which are not on the
+	// chain of the head, highest first.
+	ForkTipSets(ctx context.Context) ([]*TipSetAndState, error)
 }
@@ -0,0 +1,65 @@
+package chain
+
+// Fault describes what the peer a chain came from did wrong, when syncing
+// that chain failed. Blocks failing to be validated because of a local error
+// are not a fault, as this cannot be told apart from trouble of the node
+// itself. Blocks failing to be fetched may be, so their fault, FaultTimeout,
+// is penalized lightly.
+type Fault int
+
+const (
+	// FaultNone means the sync did not fail because of the chain it was
+	// given.
+	FaultNone = Fault(iota)
+	// FaultInvalidBlock means the chain contained blocks which failed
+	// validation, a known bad tipset, or missed a checkpoint.
+	FaultInvalidBlock
+	// FaultInvalidMessage means a tipset of the chain failed its state
+	// transition, e.g. because its messages failed to apply.
+	FaultInvalidMessage
+	// FaultTimeout means blocks of the chain could not be fetched in time.
+	FaultTimeout
+)
+
+// String returns a description of the fault.
+func (f Fault) String() string {
+	switch f {
+	case FaultInvalidBlock:
+		return "invalid block"
+	case FaultInvalidMessage:
+		return "invalid message"
+	case FaultTimeout:
+		return "timeout"
+	default:
+		return "none"
+	}
+}
+
+// faultError is an error syncing a chain attributed to the chain's peer.
+type faultError struct {
+	fault Fault
+	err   error
+}
+
+func (e *faultError) Error() string {
+	return e.err.Error()
+}
+
+// Cause returns the underlying error, see errors.Cause.
+func (e *faultError) Cause() error {
+	return e.err
+}
+
+// withFault attributes err to the peer the chain being synced came from.
+func withFault(fault Fault, err error) error {
+	return &faultError{fault: fault, err: err}
+}
+
+// FaultOf returns the fault of the peer a chain came from, given the error
+// returned by HandleNewBlocks for that chain.
+func FaultOf(err error) Fault {
+	if fe, ok := err.(*faultError); ok {
+		return fe.fault
+	}
+	return FaultNone
+}
@@ -0,0 +1,130 @@
+package chain
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// maxRecentSyncs is the number of finished syncs a syncer reports.
+const maxRecentSyncs = 10
+
+// SyncState describes a sync of the chain store to a new head.
+type SyncState struct {
+	// Target is the key of the tipset the sync was asked to reach.
+	Target string `json:"target"`
+	// TargetHeight is the height of the target tipset, known once its
+	// blocks are fetched.
+	TargetHeight uint64 `json:"targetHeight"`
+	// Peer is the peer the target came from, if it came from the network.
+	Peer string `json:"peer,omitempty"`
+	// Fetched is the number of new tipsets collected so far.
+	Fetched int `json:"fetched"`
+	// Validated is the number of fetched tipsets validated so far.
+	Validated int `json:"validated"`
+	// Started is the time the sync started.
+	Started time.Time `json:"started"`
+	// Finished is the time the sync finished, zero while it is active.
+	Finished time.Time `json:"finished"`
+	// Error is the error the sync failed with, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// SyncStatus describes the syncs of a syncer.
+type SyncStatus struct {
+	// Syncing is true iff the syncer is syncing the chain with the network,
+	// that is, a sync is active or the head is below the best known head.
+	Syncing bool `json:"syncing"`
+	// Head is the key of the head of the chain store.
+	Head string `json:"head"`
+	// HeadHeight is the height of the head of the chain store.
+	HeadHeight uint64 `json:"headHeight"`
+	// BestHeight is the height of the highest head reported by peers.
+	BestHeight uint64 `json:"bestHeight"`
+	// Active are the syncs in progress.
+	Active []SyncState `json:"active"`
+	// Recent are the most recently finished syncs, latest first.
+	Recent []SyncState `json:"recent"`
+}
+
+type syncPeerKey struct{}
+
+// WithSyncPeer returns a context telling the syncer that the blocks it
+// handles with it came from peer p.
+func WithSyncPeer(ctx context.Context, p string) context.Context {
+	return context.WithValue(ctx, syncPeerKey{}, p)
+}
+
+// syncPeer returns the peer set on ctx by WithSyncPeer, if any.
+func syncPeer(ctx context.Context) string {
+	p, _ := ctx.Value(syncPeerKey{}).(string)
+	return p
+}
+
+// syncTracker records the state of active syncs and the outcome of recent
+// ones. It is safe for concurrent access.
+type syncTracker struct {
+	mu     sync.Mutex
+	active []*SyncState
+	recent []SyncState
+}
+
+// start records a new active sync to the target tipset.
+func (t *syncTracker) start(target types.SortedCidSet, peer string) *SyncState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := &SyncState{
+		Target:  target.String(),
+		Peer:    peer,
+		Started: time.Now(),
+	}
+	t.active = append(t.active, s)
+	return s
+}
+
+// update applies fn to the active sync s.
+func (t *syncTracker) update(s *SyncState, fn func(s *SyncState)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fn(s)
+}
+
+// finish moves the active sync s to the recent syncs, recording err.
+func (t *syncTracker) finish(s *SyncState, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s.Finished = time.Now()
+	if err != nil {
+		s.Error = err.Error()
+	}
+	for i, a := range t.active {
+		if a == s {
+			t.active = append(t.active[:i], t.active[i+1:]...)
+			break
+		}
+	}
+	t.recent = append([]SyncState{*s}, t.recent...)
+	if len(t.recent) > maxRecentSyncs {
+		t.recent = t.recent[:maxRecentSyncs]
+	}
+}
+
+// status returns a copy of the active and recent syncs.
+func (t *syncTracker) status() *SyncStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	status := &SyncStatus{
+		Syncing: len(t.active) > 0,
+		Active:  []SyncState{},
+		Recent:  append([]SyncState{}, t.recent...),
+	}
+	for _, s := range t.active {
+		status.Active = append(status.Active, *s)
+	}
+	return status
+}
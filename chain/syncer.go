@@ -19,4 +19,12 @@ import (
 // after too many blocks.
 type Syncer interface {
 	HandleNewBlocks(ctx context.Context, blkCids []cid.Cid) error
+	// Status returns the state of the syncs of the syncer.
+	Status() *SyncStatus
+	// Reorgs returns the most recent switches of the head to another
+	// branch of the chain, latest first.
+	Reorgs() []Reorg
+	// Forks returns the known tipsets which are not on the chain of the
+	// head, with their weights.
+	Forks(ctx context.Context) ([]ForkTipSet, error)
 }
@@ -146,7 +146,8 @@ func RequirePutTsas(ctx context.Context, require *require.Assertions, chain Stor
 	require.NoError(err)
 }
 
-// MakeProofAndWinningTicket generates a proof and ticket that will pass validateMining.
+// MakeProofAndWinningTicket generates a proof and ticket that will pass block
+// validation.
 func MakeProofAndWinningTicket(minerAddr address.Address, minerPower uint64, totalPower uint64) (proofs.PoStProof, types.Signature, error) {
 	var postProof proofs.PoStProof
 	var ticket types.Signature
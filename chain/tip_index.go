@@ -75,6 +75,33 @@ func (ti *TipIndex) Put(tsas *TipSetAndState) error {
 	return nil
 }
 
+// Delete removes the tipset with the input ID from both of TipIndex's
+// internal indexes. Deleting a tipset which is not indexed is a no-op.
+func (ti *TipIndex) Delete(tsKey string) error {
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+	tsas, ok := ti.tsasByID[tsKey]
+	if !ok {
+		return nil
+	}
+	delete(ti.tsasByID, tsKey)
+
+	pSet, err := tsas.TipSet.Parents()
+	if err != nil {
+		return err
+	}
+	h, err := tsas.TipSet.Height()
+	if err != nil {
+		return err
+	}
+	key := makeKey(pSet.String(), h)
+	delete(ti.tsasByParentsAndHeight[key], tsKey)
+	if len(ti.tsasByParentsAndHeight[key]) == 0 {
+		delete(ti.tsasByParentsAndHeight, key)
+	}
+	return nil
+}
+
 // Get returns the tipset given by the input ID and its state.
 func (ti *TipIndex) Get(tsKey string) (*TipSetAndState, error) {
 	ti.mu.Lock()
@@ -0,0 +1,441 @@
+package chain
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"sort"
+	"strings"
+
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	bstore "gx/ipfs/QmS2aqUZLJp8kF1ihE5rvDGE5LvmKDPnx32w9Z1BW9xLV5/go-ipfs-blockstore"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+	"gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore"
+	dsq "gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore/query"
+
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// eagerLoadDepth is the number of tipsets below the head that Load reads into
+// the in-memory tip index. Older tipsets are read when first looked up.
+const eagerLoadDepth = 200
+
+// The tip index is persisted in the chain datastore as a record per tipset,
+// keyed by the tipset key, and an empty entry per tipset under the key of its
+// parents and height, so that the tipsets of a parents and height bucket can
+// be listed with a prefix query. The migrated entry marks that the index holds
+// the chain of the persisted head, which a datastore written before the index
+// was persisted only does once loadLegacy has completed.
+var (
+	tipIndexKey         = datastore.NewKey("/chain/tipindex")
+	tipIndexTipSetsKey  = tipIndexKey.ChildString("tipsets")
+	tipIndexParentsKey  = tipIndexKey.ChildString("parents")
+	tipIndexMigratedKey = tipIndexKey.ChildString("migrated")
+)
+
+// tipIndexRecord is the persisted form of a TipSetAndState.
+type tipIndexRecord struct {
+	Blocks          types.SortedCidSet
+	TipSetStateRoot cid.Cid
+	Height          uint64
+	// Checkpoint is true if the tipset and its state are trusted rather
+	// than validated, in which case its ancestors are not indexed.
+	Checkpoint bool
+}
+
+// writeTipIndexRecord persists the tip index entry of tsas.
+func (store *DefaultStore) writeTipIndexRecord(tsas *TipSetAndState, checkpoint bool) error {
+	pSet, err := tsas.TipSet.Parents()
+	if err != nil {
+		return err
+	}
+	h, err := tsas.TipSet.Height()
+	if err != nil {
+		return err
+	}
+
+	val, err := json.Marshal(&tipIndexRecord{
+		Blocks:          tsas.TipSet.ToSortedCidSet(),
+		TipSetStateRoot: tsas.TipSetStateRoot,
+		Height:          h,
+		Checkpoint:      checkpoint,
+	})
+	if err != nil {
+		return err
+	}
+
+	tsKey := tsas.TipSet.String()
+	if err := store.ds.Put(tipIndexTipSetsKey.ChildString(tsKey), val); err != nil {
+		return err
+	}
+	return store.ds.Put(tipIndexParentsKey.ChildString(makeKey(pSet.String(), h)).ChildString(tsKey), []byte{})
+}
+
+// isTipIndexMigrated returns true if the persisted tip index holds the chain
+// of the persisted head.
+func (store *DefaultStore) isTipIndexMigrated() bool {
+	has, err := store.ds.Has(tipIndexMigratedKey)
+	return err == nil && has
+}
+
+// hasTipIndexRecord returns true if the tipset with the input key is in the
+// persisted tip index.
+func (store *DefaultStore) hasTipIndexRecord(tsKey string) bool {
+	has, err := store.ds.Has(tipIndexTipSetsKey.ChildString(tsKey))
+	return err == nil && has
+}
+
+// readTipIndexRecord reads the persisted record of the tipset with the input
+// key. It returns ErrNotFound if it is not indexed.
+func (store *DefaultStore) readTipIndexRecord(tsKey string) (*tipIndexRecord, error) {
+	val, err := store.ds.Get(tipIndexTipSetsKey.ChildString(tsKey))
+	if err == datastore.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read tip index of %s", tsKey)
+	}
+
+	var record tipIndexRecord
+	if err := json.Unmarshal(val, &record); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode tip index of %s", tsKey)
+	}
+	return &record, nil
+}
+
+// isCheckpoint returns true if the tipset with the input key is indexed as a
+// checkpoint.
+func (store *DefaultStore) isCheckpoint(tsKey string) bool {
+	record, err := store.readTipIndexRecord(tsKey)
+	return err == nil && record.Checkpoint
+}
+
+// loadTipIndexRecord reads the tipset with the input key and its state from
+// the persisted tip index. It returns ErrNotFound if it is not indexed.
+func (store *DefaultStore) loadTipIndexRecord(ctx context.Context, tsKey string) (*TipSetAndState, error) {
+	record, err := store.readTipIndexRecord(tsKey)
+	if err != nil {
+		return nil, err
+	}
+
+	blks, err := store.GetBlocks(ctx, record.Blocks)
+	if err != nil {
+		return nil, err
+	}
+	ts, err := types.NewTipSet(blks...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TipSetAndState{
+		TipSet:          ts,
+		TipSetStateRoot: record.TipSetStateRoot,
+	}, nil
+}
+
+// loadTipSetKeysByParentsAndHeight lists the keys of the persisted tipsets
+// with the input parents and height.
+func (store *DefaultStore) loadTipSetKeysByParentsAndHeight(pKey string, h uint64) ([]string, error) {
+	prefix := tipIndexParentsKey.ChildString(makeKey(pKey, h)).String() + "/"
+	res, err := store.ds.Query(dsq.Query{Prefix: prefix, KeysOnly: true})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query tip index")
+	}
+
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query tip index")
+	}
+
+	var tsKeys []string
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Key, prefix) {
+			continue
+		}
+		tsKeys = append(tsKeys, datastore.NewKey(e.Key).BaseNamespace())
+	}
+	return tsKeys, nil
+}
+
+// loadTipIndexRecords reads the records of all persisted tipsets, keyed by
+// tipset key.
+func (store *DefaultStore) loadTipIndexRecords() (map[string]*tipIndexRecord, error) {
+	prefix := tipIndexTipSetsKey.String() + "/"
+	res, err := store.ds.Query(dsq.Query{Prefix: prefix})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query tip index")
+	}
+
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query tip index")
+	}
+
+	records := make(map[string]*tipIndexRecord)
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Key, prefix) {
+			continue
+		}
+		var record tipIndexRecord
+		if err := json.Unmarshal(e.Value, &record); err != nil {
+			return nil, errors.Wrapf(err, "failed to decode tip index entry %s", e.Key)
+		}
+		records[datastore.NewKey(e.Key).BaseNamespace()] = &record
+	}
+	return records, nil
+}
+
+// StateRootsSince returns the state roots of all indexed tipsets at height h
+// or above, on the chain of the head as well as on forks.
+func (store *DefaultStore) StateRootsSince(h uint64) ([]cid.Cid, error) {
+	records, err := store.loadTipIndexRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	var roots []cid.Cid
+	for _, record := range records {
+		if record.Height >= h {
+			roots = append(roots, record.TipSetStateRoot)
+		}
+	}
+	return roots, nil
+}
+
+// CheckpointStateRoots returns the state roots of all indexed checkpoints.
+func (store *DefaultStore) CheckpointStateRoots() ([]cid.Cid, error) {
+	records, err := store.loadTipIndexRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	var roots []cid.Cid
+	for _, record := range records {
+		if record.Checkpoint {
+			roots = append(roots, record.TipSetStateRoot)
+		}
+	}
+	return roots, nil
+}
+
+// IndexedBlocks returns the cids of the blocks of all indexed tipsets.
+func (store *DefaultStore) IndexedBlocks() ([]cid.Cid, error) {
+	records, err := store.loadTipIndexRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	blks := cid.NewSet()
+	for _, record := range records {
+		for it := record.Blocks.Iter(); !it.Complete(); it.Next() {
+			blks.Add(it.Value())
+		}
+	}
+	return blks.Keys(), nil
+}
+
+// PruneForks removes the fork tipsets whose descendants are all below height
+// h from the tip index, along with their blocks which are not part of a kept
+// tipset. Forks are pruned from their tips down, so that only the tipsets
+// without a descendant on another chain are removed: the tipsets of the chain
+// of the head always have one. The genesis tipset and checkpoints are never
+// removed. It returns the number of tipsets removed.
+func (store *DefaultStore) PruneForks(ctx context.Context, h uint64) (int, error) {
+	records, err := store.loadTipIndexRecords()
+	if err != nil {
+		return 0, err
+	}
+	parents, err := store.loadTipIndexParents()
+	if err != nil {
+		return 0, err
+	}
+
+	head := store.Head()
+	if len(head) == 0 {
+		return 0, errors.New("cannot prune forks without a head")
+	}
+	headKey := head.String()
+	genesisKey := types.NewSortedCidSet(store.genesis).String()
+	prunable := func(tsKey string) bool {
+		record, ok := records[tsKey]
+		return ok && record.Height < h && !record.Checkpoint && tsKey != genesisKey && tsKey != headKey
+	}
+
+	children := make(map[string]int)
+	for tsKey := range records {
+		children[parents[tsKey]]++
+	}
+	var tips []string
+	for tsKey := range records {
+		if children[tsKey] == 0 && prunable(tsKey) {
+			tips = append(tips, tsKey)
+		}
+	}
+	stale := make(map[string]*tipIndexRecord)
+	for len(tips) > 0 {
+		tsKey := tips[0]
+		tips = tips[1:]
+		stale[tsKey] = records[tsKey]
+
+		// The parent becomes a tip of the fork once it has no other child.
+		pKey := parents[tsKey]
+		children[pKey]--
+		if children[pKey] == 0 && prunable(pKey) {
+			tips = append(tips, pKey)
+		}
+	}
+	if len(stale) == 0 {
+		return 0, nil
+	}
+
+	kept := cid.NewSet()
+	for tsKey, record := range records {
+		if _, ok := stale[tsKey]; ok {
+			continue
+		}
+		for it := record.Blocks.Iter(); !it.Complete(); it.Next() {
+			kept.Add(it.Value())
+		}
+	}
+
+	for tsKey, record := range stale {
+		if err := store.deleteTipIndexRecord(ctx, tsKey, record); err != nil {
+			return 0, err
+		}
+		if err := store.tipIndex.Delete(tsKey); err != nil {
+			return 0, err
+		}
+		for it := record.Blocks.Iter(); !it.Complete(); it.Next() {
+			if kept.Has(it.Value()) {
+				continue
+			}
+			if err := store.blocks.DeleteBlock(it.Value()); err != nil && err != bstore.ErrNotFound {
+				return 0, errors.Wrapf(err, "failed to delete block %s", it.Value())
+			}
+		}
+	}
+	return len(stale), nil
+}
+
+// loadTipIndexParents reads the parents key of all persisted tipsets, keyed by
+// tipset key, from the entries indexing them by parents and height.
+func (store *DefaultStore) loadTipIndexParents() (map[string]string, error) {
+	prefix := tipIndexParentsKey.String() + "/"
+	res, err := store.ds.Query(dsq.Query{Prefix: prefix, KeysOnly: true})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query tip index")
+	}
+
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query tip index")
+	}
+
+	parents := make(map[string]string)
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Key, prefix) {
+			continue
+		}
+		k := datastore.NewKey(e.Key)
+		// The bucket is named by makeKey.
+		bucket := strings.TrimPrefix(k.Parent().BaseNamespace(), "p-")
+		i := strings.LastIndex(bucket, " h-")
+		if i < 0 {
+			return nil, errors.Errorf("malformed tip index entry %s", e.Key)
+		}
+		parents[k.BaseNamespace()] = bucket[:i]
+	}
+	return parents, nil
+}
+
+// ForkTipSets returns the indexed tipsets which are not on the chain of the
+// head, highest first. The chain of the head is followed down to a
+// checkpoint, below which nothing is indexed but genesis.
+func (store *DefaultStore) ForkTipSets(ctx context.Context) ([]*TipSetAndState, error) {
+	records, err := store.loadTipIndexRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	genesisKey := types.NewSortedCidSet(store.genesis).String()
+	forks := make(map[string]*tipIndexRecord)
+	lowest := uint64(math.MaxUint64)
+	for tsKey, record := range records {
+		if tsKey == genesisKey {
+			continue
+		}
+		forks[tsKey] = record
+		if record.Height < lowest {
+			lowest = record.Height
+		}
+	}
+	if len(forks) == 0 {
+		return nil, nil
+	}
+
+	head := store.Head()
+	if len(head) == 0 {
+		return nil, errors.New("cannot list forks without a head")
+	}
+	err = store.walkChain(ctx, head.ToSlice(), func(tips []*types.Block) (bool, error) {
+		ts, err := types.NewTipSet(tips...)
+		if err != nil {
+			return false, err
+		}
+		delete(forks, ts.String())
+		return uint64(tips[0].Height) > lowest && !store.isCheckpoint(ts.String()), nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to walk the chain of the head")
+	}
+
+	var out []*TipSetAndState
+	for _, record := range forks {
+		blks, err := store.GetBlocks(ctx, record.Blocks)
+		if err != nil {
+			return nil, err
+		}
+		ts, err := types.NewTipSet(blks...)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, &TipSetAndState{
+			TipSet:          ts,
+			TipSetStateRoot: record.TipSetStateRoot,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		hi, _ := out[i].TipSet.Height()
+		hj, _ := out[j].TipSet.Height()
+		if hi != hj {
+			return hi > hj
+		}
+		return out[i].TipSet.String() < out[j].TipSet.String()
+	})
+	return out, nil
+}
+
+// deleteTipIndexRecord removes the tipset with the input key and record from
+// the persisted tip index.
+func (store *DefaultStore) deleteTipIndexRecord(ctx context.Context, tsKey string, record *tipIndexRecord) error {
+	blks, err := store.GetBlocks(ctx, record.Blocks)
+	if err != nil {
+		return err
+	}
+	ts, err := types.NewTipSet(blks...)
+	if err != nil {
+		return err
+	}
+	pSet, err := ts.Parents()
+	if err != nil {
+		return err
+	}
+
+	if err := store.ds.Delete(tipIndexParentsKey.ChildString(makeKey(pSet.String(), record.Height)).ChildString(tsKey)); err != nil {
+		return errors.Wrapf(err, "failed to delete tip index of %s", tsKey)
+	}
+	if err := store.ds.Delete(tipIndexTipSetsKey.ChildString(tsKey)); err != nil {
+		return errors.Wrapf(err, "failed to delete tip index of %s", tsKey)
+	}
+	return nil
+}
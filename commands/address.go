@@ -9,6 +9,7 @@ import (
 
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/wallet"
 )
 
 var walletCmd = &cmds.Command{
@@ -16,10 +17,17 @@ var walletCmd = &cmds.Command{
 		Tagline: "Manage your filecoin wallets",
 	},
 	Subcommands: map[string]*cmds.Command{
-		"addrs":   addrsCmd,
-		"balance": balanceCmd,
-		"import":  walletImportCmd,
-		"export":  walletExportCmd,
+		"addrs":    addrsCmd,
+		"balance":  balanceCmd,
+		"init":     walletInitCmd,
+		"import":   walletImportCmd,
+		"export":   walletExportCmd,
+		"multisig": walletMultisigCmd,
+		"restore":  walletRestoreCmd,
+		"encrypt":  walletEncryptCmd,
+		"unlock":   walletUnlockCmd,
+		"lock":     walletLockCmd,
+		"sign":     walletSignCmd,
 	},
 }
 
@@ -44,8 +52,12 @@ type AddressLsResult struct {
 }
 
 var addrsNewCmd = &cmds.Command{
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("type", "Type of the key of the new address: secp256k1 or bls").WithDefault(wallet.SECP256K1),
+	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
-		addr, err := GetAPI(env).Address().Addrs().New(req.Context)
+		keyType, _ := req.Options["type"].(string)
+		addr, err := GetAPI(env).Address().Addrs().New(req.Context, keyType)
 		if err != nil {
 			return err
 		}
@@ -137,6 +149,127 @@ var balanceCmd = &cmds.Command{
 	},
 }
 
+// WalletInitResult is the result of running the wallet init command.
+type WalletInitResult struct {
+	// Mnemonic is set if the mnemonic was generated by the command.
+	Mnemonic  string
+	Addresses []string
+}
+
+var walletInitCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Initialize the hierarchical deterministic wallet",
+		ShortDescription: `
+Initializes the HD wallet, from which new secp256k1 addresses are then
+derived. A new mnemonic is generated and printed along with the first address;
+write it down, it is the only backup of the keys. With --mnemonic, the wallet
+is initialized from an existing mnemonic instead, read from stdin or from the
+given file, like wallet restore does.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("mnemonic", false, false, "File containing the mnemonic to initialize the wallet from, with --mnemonic").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("mnemonic", "Initialize the wallet from an existing mnemonic"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		if fromMnemonic, _ := req.Options["mnemonic"].(bool); fromMnemonic {
+			mnemonic, err := readSecret(req)
+			if err != nil {
+				return err
+			}
+
+			res, err := initHD(req, env, mnemonic)
+			if err != nil {
+				return err
+			}
+			return re.Emit(res)
+		}
+
+		mnemonic, err := wallet.NewMnemonic()
+		if err != nil {
+			return err
+		}
+
+		res, err := initHD(req, env, mnemonic)
+		if err != nil {
+			return err
+		}
+		res.Mnemonic = mnemonic
+		return re.Emit(res)
+	},
+	Type:     &WalletInitResult{},
+	Encoders: walletInitEncoderMap,
+}
+
+var walletRestoreCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Initialize the hierarchical deterministic wallet from a mnemonic",
+		ShortDescription: `
+Initializes the HD wallet from the seed of a mnemonic, read from stdin or from
+the given file, and restores the addresses of the mnemonic which have an actor
+on chain. The mnemonic is not taken as argument, so that it does not end up in
+the shell history.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("mnemonic", true, false, "File containing the mnemonic to restore the wallet from").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		mnemonic, err := readSecret(req)
+		if err != nil {
+			return err
+		}
+
+		res, err := initHD(req, env, mnemonic)
+		if err != nil {
+			return err
+		}
+		return re.Emit(res)
+	},
+	Type:     &WalletInitResult{},
+	Encoders: walletInitEncoderMap,
+}
+
+// initHD initializes the HD wallet with the seed of mnemonic and returns the
+// restored addresses, or a new one if none were restored.
+func initHD(req *cmds.Request, env cmds.Environment, mnemonic string) (*WalletInitResult, error) {
+	addrs, err := GetAPI(env).Address().InitHD(req.Context, mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		addr, err := GetAPI(env).Address().Addrs().New(req.Context, wallet.SECP256K1)
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, addr)
+	}
+
+	var res WalletInitResult
+	for _, addr := range addrs {
+		res.Addresses = append(res.Addresses, addr.String())
+	}
+	return &res, nil
+}
+
+var walletInitEncoderMap = cmds.EncoderMap{
+	cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res *WalletInitResult) error {
+		if res.Mnemonic != "" {
+			if _, err := fmt.Fprintf(w, "mnemonic: %s\n", res.Mnemonic); err != nil {
+				return err
+			}
+		}
+		for _, addr := range res.Addresses {
+			if _, err := fmt.Fprintln(w, addr); err != nil {
+				return err
+			}
+		}
+		return nil
+	}),
+}
+
 var walletImportCmd = &cmds.Command{
 	Arguments: []cmdkit.Argument{
 		cmdkit.FileArg("walletFile", true, false, "File containing wallet data to import").EnableStdin(),
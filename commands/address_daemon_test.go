@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -30,6 +31,86 @@ func TestAddrsNewAndList(t *testing.T) {
 	}
 }
 
+func TestAddrsNewBLS(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	d := th.NewDaemon(t).Start()
+	defer d.ShutdownSuccess()
+
+	addrStr := d.RunSuccess("address", "new", "--type=bls").ReadStdoutTrimNewlines()
+	addr, err := address.NewFromString(addrStr)
+	assert.NoError(err)
+	assert.True(addr.IsBLS())
+
+	list := d.RunSuccess("wallet", "addrs", "ls").ReadStdout()
+	assert.Contains(list, addrStr)
+
+	d.RunFail("unknown key type", "address", "new", "--type=rsa")
+}
+
+func TestWalletInitHD(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	d := th.NewDaemon(t).Start()
+	defer d.ShutdownSuccess()
+
+	out := d.RunSuccess("wallet", "init").ReadStdout()
+	assert.Contains(out, "mnemonic: ")
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	assert.Len(lines, 2)
+	mnemonic := strings.TrimPrefix(lines[0], "mnemonic: ")
+	first := lines[1]
+
+	d.RunFail("already initialized", "wallet", "init")
+
+	second := d.RunSuccess("address", "new").ReadStdoutTrimNewlines()
+	assert.NotEqual(first, second)
+
+	t.Log("the mnemonic derives the same addresses on another node")
+	d2 := th.NewDaemon(t).Start()
+	defer d2.ShutdownSuccess()
+
+	out = d2.RunWithStdin(strings.NewReader(mnemonic+"\n"), "wallet", "restore").ReadStdoutTrimNewlines()
+	assert.Equal(first, out)
+	assert.Equal(second, d2.RunSuccess("address", "new").ReadStdoutTrimNewlines())
+
+	t.Log("init takes an existing mnemonic with --mnemonic")
+	d3 := th.NewDaemon(t).Start()
+	defer d3.ShutdownSuccess()
+
+	out = d3.RunWithStdin(strings.NewReader(mnemonic+"\n"), "wallet", "init", "--mnemonic").ReadStdoutTrimNewlines()
+	assert.Equal(first, out)
+}
+
+func TestWalletEncryptLockUnlock(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	d := th.NewDaemon(t).Start()
+	defer d.ShutdownSuccess()
+
+	addr := d.CreateWalletAddr()
+
+	d.RunWithStdin(strings.NewReader("secret\n"), "wallet", "unlock").AssertFail("wallet is not encrypted")
+
+	out := d.RunWithStdin(strings.NewReader("secret\n"), "wallet", "encrypt")
+	assert.Equal(0, out.Code)
+	d.RunSuccess("wallet", "export", addr)
+
+	d.RunSuccess("wallet", "lock")
+	d.RunFail("wallet is locked", "wallet", "export", addr)
+	d.RunWithStdin(strings.NewReader("wrong\n"), "wallet", "unlock").AssertFail("wrong passphrase")
+
+	d.RunWithStdin(strings.NewReader("secret\n"), "wallet", "unlock").AssertSuccess()
+	d.RunSuccess("wallet", "export", addr)
+
+	d.RunWithStdin(strings.NewReader("secret\n"), "wallet", "unlock", "--timeout=100ms").AssertSuccess()
+	time.Sleep(500 * time.Millisecond)
+	d.RunFail("wallet is locked", "wallet", "export", addr)
+}
+
 func TestWalletBalance(t *testing.T) {
 	t.Parallel()
 	assert := assert.New(t)
@@ -6,11 +6,15 @@ import (
 	"io"
 	"strconv"
 	"strings"
+	"time"
 
 	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
 	"gx/ipfs/Qma6uuSyjkecGhMFFLfzyJDPyoDtNJSHJNweDccZhaWkgU/go-ipfs-cmds"
 	"gx/ipfs/Qmde5VP1qUkyQXKCfmEUA7bP64V2HAptbJ7phuPp7jXWwg/go-ipfs-cmdkit"
 
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/proofs"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
@@ -19,8 +23,13 @@ var chainCmd = &cmds.Command{
 		Tagline: "Inspect the filecoin blockchain",
 	},
 	Subcommands: map[string]*cmds.Command{
-		"head": chainHeadCmd,
-		"ls":   chainLsCmd,
+		"bad":      chainBadCmd,
+		"forks":    chainForksCmd,
+		"head":     chainHeadCmd,
+		"ls":       chainLsCmd,
+		"reorgs":   chainReorgsCmd,
+		"sync":     chainSyncCmd,
+		"verifier": chainVerifierCmd,
 	},
 }
 
@@ -102,3 +111,221 @@ var chainLsCmd = &cmds.Command{
 		}),
 	},
 }
+
+var chainSyncCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Inspect the syncing of the chain with the network",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"status": chainSyncStatusCmd,
+	},
+}
+
+var chainSyncStatusCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show whether the chain is being synced",
+		ShortDescription: `
+Shows the head of the chain, the syncs in progress and the most recent finished
+syncs. A node is caught up when no sync is in progress and its head is at least
+as high as the best head it has heard of from its peers.
+`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		return re.Emit(GetPorcelainAPI(env).ChainSyncStatus())
+	},
+	Type: chain.SyncStatus{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, status *chain.SyncStatus) error {
+			state := "caught up"
+			if status.Syncing {
+				state = "syncing"
+			}
+			if _, err := fmt.Fprintf(w, "%s\nhead: %s\nheight: %d\nbest peer height: %d\n", state, status.Head, status.HeadHeight, status.BestHeight); err != nil {
+				return err
+			}
+			for _, s := range status.Active {
+				if _, err := fmt.Fprintf(w, "active: target %s at height %d from %q, %d tipsets fetched, %d validated\n", s.Target, s.TargetHeight, s.Peer, s.Fetched, s.Validated); err != nil {
+					return err
+				}
+			}
+			for _, s := range status.Recent {
+				outcome := "ok"
+				if s.Error != "" {
+					outcome = "failed: " + s.Error
+				}
+				if _, err := fmt.Fprintf(w, "recent: target %s at height %d from %q, %d tipsets fetched, %d validated, %s\n", s.Target, s.TargetHeight, s.Peer, s.Fetched, s.Validated, outcome); err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
+	},
+}
+
+var chainBadCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Inspect the tipsets rejected by the syncer",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"ls": chainBadLsCmd,
+		"rm": chainBadRmCmd,
+	},
+}
+
+var chainBadLsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the tipsets rejected by the syncer",
+		ShortDescription: `
+Lists the tipsets the syncer rejected and will not try to sync again, in the
+order they were first seen, with the peer they came from and the reason they
+were rejected.
+`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		return re.Emit(GetPorcelainAPI(env).ChainBadTipSets())
+	},
+	Type: []chain.BadTipSet{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, bad *[]chain.BadTipSet) error {
+			for _, bts := range *bad {
+				if _, err := fmt.Fprintf(w, "%s\t%s\t%q\t%s\n", bts.Key, bts.FirstSeen.Format(time.RFC3339), bts.Peer, bts.Reason); err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
+	},
+}
+
+var chainBadRmCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Forget that a tipset was rejected by the syncer",
+		ShortDescription: `
+Removes the tipset made of the given blocks from the tipsets rejected by the
+syncer, so that it is validated again the next time it is seen. With --all,
+all rejected tipsets are removed.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("block", false, true, "CID of a block of the tipset"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("all", "Remove all rejected tipsets"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		if all, _ := req.Options["all"].(bool); all {
+			if err := GetPorcelainAPI(env).ChainClearBadTipSets(); err != nil {
+				return err
+			}
+			return re.Emit("Removed all bad tipsets")
+		}
+		if len(req.Arguments) == 0 {
+			return errors.New("expected the blocks of a tipset, or --all")
+		}
+
+		var key types.SortedCidSet
+		for _, arg := range req.Arguments {
+			c, err := cid.Decode(arg)
+			if err != nil {
+				return errors.Wrapf(err, "invalid block cid %s", arg)
+			}
+			key.Add(c)
+		}
+		if err := GetPorcelainAPI(env).ChainRemoveBadTipSet(key); err != nil {
+			return err
+		}
+		return re.Emit(fmt.Sprintf("Removed bad tipset %s", key.String()))
+	},
+	Encoders: stringEncoderMap,
+}
+
+var chainForksCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the known tipsets which are not on the chain of the head",
+		ShortDescription: `
+Lists the tipsets the node validated which are not on the chain of its head,
+highest first, with their height, weight and parent. Comparing their weights
+with the weight of the head helps debugging fork choice.
+`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		forks, err := GetPorcelainAPI(env).ChainForks(req.Context)
+		if err != nil {
+			return err
+		}
+		return re.Emit(forks)
+	},
+	Type: []chain.ForkTipSet{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, forks *[]chain.ForkTipSet) error {
+			for _, f := range *forks {
+				if _, err := fmt.Fprintf(w, "%s\theight %d\tweight %d\tparent %s\n", f.TipSet, f.Height, f.Weight, f.Parent); err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
+	},
+}
+
+var chainReorgsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the recent switches of the head to another branch",
+		ShortDescription: `
+Lists the most recent reorgs, latest first: the old and new heads with their
+heights and weights, their common ancestor and the depth of the reorg, which
+is the number of rounds of the old branch above the common ancestor.
+`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		return re.Emit(GetPorcelainAPI(env).ChainReorgs())
+	},
+	Type: []chain.Reorg{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, reorgs *[]chain.Reorg) error {
+			for _, r := range *reorgs {
+				if _, err := fmt.Fprintf(w, "%s depth %d: %s (height %d, weight %d) -> %s (height %d, weight %d), common ancestor %s (height %d)\n",
+					r.Time.Format(time.RFC3339), r.Depth, r.OldHead, r.OldHeadHeight, r.OldHeadWeight, r.NewHead, r.NewHeadHeight, r.NewHeadWeight, r.CommonAncestor, r.CommonAncestorHeight); err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
+	},
+}
+
+var chainVerifierCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the metrics of the proof verifier",
+		ShortDescription: `
+Shows, for each kind of proof, how many verifications the chain requested, how
+many were answered from the cache of recent results, how many proofs were
+checked and the total and longest time a check took.
+`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		stats, err := GetPorcelainAPI(env).ChainVerifierStats()
+		if err != nil {
+			return err
+		}
+		return re.Emit(stats)
+	},
+	Type: proofs.VerificationStats{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, stats *proofs.VerificationStats) error {
+			for _, op := range []struct {
+				name  string
+				stats proofs.OperationStats
+			}{
+				{"seal", stats.Seal},
+				{"seal batch", stats.SealBatch},
+				{"post", stats.PoSt},
+			} {
+				if _, err := fmt.Fprintf(w, "%s: %d calls, %d cache hits, %d verified, total %s, max %s\n", op.name, op.stats.Calls, op.stats.CacheHits, op.stats.Verified, op.stats.TotalTime, op.stats.MaxTime); err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
+	},
+}
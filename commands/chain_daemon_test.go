@@ -8,6 +8,7 @@ import (
 
 	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
 
+	"github.com/filecoin-project/go-filecoin/chain"
 	"github.com/filecoin-project/go-filecoin/fixtures"
 	th "github.com/filecoin-project/go-filecoin/testhelpers"
 	"github.com/filecoin-project/go-filecoin/types"
@@ -110,4 +111,65 @@ func TestChainDaemon(t *testing.T) {
 		assert.Contains(chainLsResult, "1")
 		assert.Contains(chainLsResult, "0")
 	})
+
+	t.Run("chain bad ls lists no tipsets and rm fails for unknown tipsets", func(t *testing.T) {
+		t.Parallel()
+		assert := assert.New(t)
+
+		daemon := th.NewDaemon(t).Start()
+		defer daemon.ShutdownSuccess()
+
+		assert.Empty(daemon.RunSuccess("chain", "bad", "ls").ReadStdoutTrimNewlines())
+		daemon.RunFail("not found", "chain", "bad", "rm", types.SomeCid().String())
+		assert.Contains(daemon.RunSuccess("chain", "bad", "rm", "--all").ReadStdoutTrimNewlines(), "Removed all bad tipsets")
+	})
+
+	t.Run("chain forks and reorgs are empty on a single chain", func(t *testing.T) {
+		t.Parallel()
+		assert := assert.New(t)
+
+		daemon := th.NewDaemon(t, th.WithMiner(fixtures.TestMiners[0])).Start()
+		defer daemon.ShutdownSuccess()
+
+		daemon.RunSuccess("mining", "once")
+
+		assert.Empty(daemon.RunSuccess("chain", "forks").ReadStdoutTrimNewlines())
+		assert.Empty(daemon.RunSuccess("chain", "reorgs").ReadStdoutTrimNewlines())
+	})
+
+	t.Run("chain sync status shows the outcome of recent syncs", func(t *testing.T) {
+		t.Parallel()
+		assert := assert.New(t)
+		require := require.New(t)
+
+		daemon := th.NewDaemon(t, th.WithMiner(fixtures.TestMiners[0])).Start()
+		defer daemon.ShutdownSuccess()
+
+		daemon.RunSuccess("mining", "once")
+
+		var status chain.SyncStatus
+		statusJSON := daemon.RunSuccess("chain", "sync", "status", "--enc", "json").ReadStdoutTrimNewlines()
+		require.NoError(json.Unmarshal([]byte(statusJSON), &status))
+
+		assert.False(status.Syncing)
+		assert.Equal(uint64(1), status.HeadHeight)
+		require.Len(status.Recent, 1)
+		assert.Equal(status.Head, status.Recent[0].Target)
+		assert.Equal(1, status.Recent[0].Validated)
+		assert.Empty(status.Recent[0].Error)
+
+		assert.Contains(daemon.RunSuccess("chain", "sync", "status").ReadStdoutTrimNewlines(), "caught up")
+	})
+
+	t.Run("chain verifier shows the metrics of the proof verifier", func(t *testing.T) {
+		t.Parallel()
+		assert := assert.New(t)
+
+		daemon := th.NewDaemon(t).Start()
+		defer daemon.ShutdownSuccess()
+
+		out := daemon.RunSuccess("chain", "verifier").ReadStdoutTrimNewlines()
+		assert.Contains(out, "seal: 0 calls")
+		assert.Contains(out, "post: 0 calls")
+	})
 }
@@ -23,6 +23,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/mining"
 	"github.com/filecoin-project/go-filecoin/node"
 	"github.com/filecoin-project/go-filecoin/repo"
+	"github.com/filecoin-project/go-filecoin/wallet"
 )
 
 // exposed here, to be available during testing
@@ -39,6 +40,7 @@ var daemonCmd = &cmds.Command{
 		cmdkit.BoolOption(ELStdout),
 		cmdkit.BoolOption(IsRelay, "advertise and allow filecoin network traffic to be relayed through this node"),
 		cmdkit.StringOption(BlockTime, "time a node waits before trying to mine the next block").WithDefault(mining.DefaultBlockTime.String()),
+		cmdkit.StringOption(WalletPassphraseFile, "path of a file containing the passphrase to unlock an encrypted wallet with, FIL_WALLET_PASSPHRASE is used if not set"),
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		return daemonRun(req, re, env)
@@ -102,6 +104,10 @@ func daemonRun(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment)
 		return err
 	}
 
+	if err := unlockWallet(req, fcn.Wallet); err != nil {
+		return err
+	}
+
 	if fcn.OfflineMode {
 		re.Emit("Filecoin node running in offline mode (libp2p is disabled)\n") // nolint: errcheck
 	} else {
@@ -118,6 +124,33 @@ func daemonRun(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment)
 	return runAPIAndWait(req.Context, fcn, rep.Config(), req)
 }
 
+// unlockWallet unlocks an encrypted wallet with the passphrase given in a
+// file or, with lower precedence, in the FIL_WALLET_PASSPHRASE env var. A
+// passphrase given for a wallet which is not encrypted yet is ignored, so the
+// daemon can be started the same way before and after running wallet encrypt.
+func unlockWallet(req *cmds.Request, w *wallet.Wallet) error {
+	var passphrase []byte
+	if envPassphrase := os.Getenv("FIL_WALLET_PASSPHRASE"); envPassphrase != "" {
+		passphrase = []byte(envPassphrase)
+	}
+
+	if path, ok := req.Options[WalletPassphraseFile].(string); ok && path != "" {
+		var err error
+		if passphrase, err = readPassphraseFile(path); err != nil {
+			return err
+		}
+	}
+
+	if passphrase == nil {
+		return nil
+	}
+
+	if err := wallet.Unlock(w, passphrase, 0); err != nil && err != wallet.ErrNotEncrypted {
+		return errors.Wrap(err, "failed to unlock wallet")
+	}
+	return nil
+}
+
 func getRepo(req *cmds.Request) (repo.Repo, error) {
 	return repo.OpenFSRepo(getRepoDir(req))
 }
@@ -21,6 +21,9 @@ var (
 	// ErrInvalidPledge indicates that provided pledge was invalid.
 	ErrInvalidPledge = fmt.Errorf("invalid pledge")
 
+	// ErrInvalidThreshold indicates that the provided threshold was invalid.
+	ErrInvalidThreshold = fmt.Errorf("invalid threshold")
+
 	// ErrInvalidBlockHeight indicates that the provided block height was invalid.
 	ErrInvalidBlockHeight = fmt.Errorf("invalid block height")
 
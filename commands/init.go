@@ -4,12 +4,17 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
 	cmds "gx/ipfs/Qma6uuSyjkecGhMFFLfzyJDPyoDtNJSHJNweDccZhaWkgU/go-ipfs-cmds"
 	cmdkit "gx/ipfs/Qmde5VP1qUkyQXKCfmEUA7bP64V2HAptbJ7phuPp7jXWwg/go-ipfs-cmdkit"
 
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/api"
+	"github.com/filecoin-project/go-filecoin/config"
+	"github.com/filecoin-project/go-filecoin/types"
 )
 
 var initCmd = &cmds.Command{
@@ -21,10 +26,12 @@ var initCmd = &cmds.Command{
 		cmdkit.StringOption(PeerKeyFile, "path of file containing key to use for new node's libp2p identity"),
 		cmdkit.StringOption(WithMiner, "when set, creates a custom genesis block with a pre generated miner account, requires running the daemon using dev mode (--dev)"),
 		cmdkit.StringOption(DefaultAddress, "when set, sets the daemons's default address to the provided address"),
+		cmdkit.StringOption(Checkpoint, "when set, adds a trusted checkpoint the chain is synced from, formatted as <block cid>[,<block cid>...]:<state root cid>"),
 		cmdkit.UintOption(AutoSealIntervalSeconds, "when set to a number > 0, configures the daemon to check for and seal any staged sectors on an interval.").WithDefault(uint(120)),
 		cmdkit.BoolOption(DevnetTest, "when set, populates config bootstrap addrs with the dns multiaddrs of the test devnet and other test devnet specific bootstrap parameters."),
 		cmdkit.BoolOption(DevnetNightly, "when set, populates config bootstrap addrs with the dns multiaddrs of the nightly devnet and other nightly devnet specific bootstrap parameters"),
 		cmdkit.BoolOption(DevnetUser, "when set, populates config bootstrap addrs with the dns multiaddrs of the user devnet and other user devnet specific bootstrap parameters"),
+		cmdkit.StringOption(WalletPassphraseFile, "when set, encrypts the keys of the wallet with the passphrase in the file at this path, e.g. /dev/stdin"),
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		repoDir := getRepoDir(req)
@@ -57,8 +64,7 @@ var initCmd = &cmds.Command{
 			}
 		}
 
-		return GetAPI(env).Daemon().Init(
-			req.Context,
+		initOpts := []api.DaemonInitOpt{
 			api.RepoDir(repoDir),
 			api.GenesisFile(genesisFile),
 			api.PeerKeyFile(peerKeyFile),
@@ -68,13 +74,56 @@ var initCmd = &cmds.Command{
 			api.DevnetUser(devnetUser),
 			api.AutoSealIntervalSeconds(autoSealIntervalSeconds),
 			api.DefaultAddress(defaultAddress),
-		)
+		}
+
+		if path, ok := req.Options[WalletPassphraseFile].(string); ok && path != "" {
+			passphrase, err := readPassphraseFile(path)
+			if err != nil {
+				return err
+			}
+			initOpts = append(initOpts, api.WalletPassphrase(passphrase))
+		}
+
+		if s, ok := req.Options[Checkpoint].(string); ok {
+			cp, err := parseCheckpoint(s)
+			if err != nil {
+				return err
+			}
+			initOpts = append(initOpts, api.Checkpoint(cp))
+		}
+
+		return GetAPI(env).Daemon().Init(req.Context, initOpts...)
 	},
 	Encoders: cmds.EncoderMap{
 		cmds.Text: cmds.MakeEncoder(initTextEncoder),
 	},
 }
 
+// parseCheckpoint parses a checkpoint formatted as
+// <block cid>[,<block cid>...]:<state root cid>.
+func parseCheckpoint(s string) (config.Checkpoint, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return config.Checkpoint{}, fmt.Errorf("invalid checkpoint %q, expected <block cid>[,<block cid>...]:<state root cid>", s)
+	}
+
+	var tipSet types.SortedCidSet
+	for _, c := range strings.Split(parts[0], ",") {
+		blkCid, err := cid.Decode(c)
+		if err != nil {
+			return config.Checkpoint{}, errors.Wrapf(err, "invalid checkpoint block cid %q", c)
+		}
+		tipSet.Add(blkCid)
+	}
+
+	stateRoot, err := cid.Decode(parts[1])
+	if err != nil {
+		return config.Checkpoint{}, errors.Wrapf(err, "invalid checkpoint state root %q", parts[1])
+	}
+
+	return config.Checkpoint{TipSet: tipSet, StateRoot: stateRoot}, nil
+}
+
 func initTextEncoder(req *cmds.Request, w io.Writer, val interface{}) error {
 	_, err := fmt.Fprintf(w, val.(string))
 	return err
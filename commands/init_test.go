@@ -8,6 +8,8 @@ import (
 	"testing"
 
 	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -31,3 +33,23 @@ func TestDownloadGenesis(t *testing.T) {
 
 	td.ShutdownSuccess()
 }
+
+func TestParseCheckpoint(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+
+	newCid := types.NewCidForTestGetter()
+	blk1, blk2, root := newCid(), newCid(), newCid()
+
+	cp, err := parseCheckpoint(fmt.Sprintf("%s,%s:%s", blk1, blk2, root))
+	require.NoError(err)
+	assert.True(types.NewSortedCidSet(blk1, blk2).Equals(cp.TipSet))
+	assert.Equal(root, cp.StateRoot)
+
+	_, err = parseCheckpoint(blk1.String())
+	assert.Error(err)
+
+	_, err = parseCheckpoint(fmt.Sprintf("%s:notacid", blk1))
+	assert.Error(err)
+}
@@ -0,0 +1,97 @@
+package commands
+
+import (
+	"bytes"
+	"io/ioutil"
+	"time"
+
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+	"gx/ipfs/Qma6uuSyjkecGhMFFLfzyJDPyoDtNJSHJNweDccZhaWkgU/go-ipfs-cmds"
+	"gx/ipfs/Qmde5VP1qUkyQXKCfmEUA7bP64V2HAptbJ7phuPp7jXWwg/go-ipfs-cmdkit"
+)
+
+var walletEncryptCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Encrypt the keys of the wallet with a passphrase",
+		ShortDescription: `
+Encrypts the keys of a wallet created without a passphrase; init encrypts them
+when given --wallet-passphrase-file. Once encrypted, the wallet must be
+unlocked with the passphrase to sign messages. The wallet is unlocked right
+after encrypting it. The passphrase is read from stdin or from the given file.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("passphrase", true, false, "File containing the passphrase to encrypt the wallet with").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		passphrase, err := readSecret(req)
+		if err != nil {
+			return err
+		}
+		if err := GetPorcelainAPI(env).WalletEncrypt([]byte(passphrase)); err != nil {
+			return err
+		}
+		return re.Emit("Wallet encrypted")
+	},
+	Encoders: stringEncoderMap,
+}
+
+var walletUnlockCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Unlock an encrypted wallet",
+		ShortDescription: `
+Unlocks the wallet until it is locked again or, if --timeout is given, until
+the timeout has passed. The passphrase is read from stdin or from the given
+file.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("passphrase", true, false, "File containing the passphrase of the wallet").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("timeout", "duration after which the wallet locks itself again, e.g. 10m"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		var timeout time.Duration
+		if t, ok := req.Options["timeout"].(string); ok && t != "" {
+			var err error
+			timeout, err = time.ParseDuration(t)
+			if err != nil {
+				return errors.Wrap(err, "invalid timeout")
+			}
+		}
+
+		passphrase, err := readSecret(req)
+		if err != nil {
+			return err
+		}
+		if err := GetPorcelainAPI(env).WalletUnlock([]byte(passphrase), timeout); err != nil {
+			return err
+		}
+		return re.Emit("Wallet unlocked")
+	},
+	Encoders: stringEncoderMap,
+}
+
+var walletLockCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Lock an encrypted wallet",
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		if err := GetPorcelainAPI(env).WalletLock(); err != nil {
+			return err
+		}
+		return re.Emit("Wallet locked")
+	},
+	Encoders: stringEncoderMap,
+}
+
+// readPassphraseFile returns the passphrase in the file at path, without its
+// trailing newline.
+func readPassphraseFile(path string) ([]byte, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read wallet passphrase file")
+	}
+	return bytes.TrimRight(content, "\r\n"), nil
+}
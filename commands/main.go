@@ -68,6 +68,9 @@ const (
 	// GenesisFile is the path of file containing archive of genesis block DAG data
 	GenesisFile = "genesisfile"
 
+	// Checkpoint is a trusted tipset and its state root to sync the chain from
+	Checkpoint = "checkpoint"
+
 	// DevnetTest populates config bootstrap addrs with the dns multiaddrs of the test devnet and other test devnet specific bootstrap parameters
 	DevnetTest = "devnet-test"
 
@@ -80,6 +83,10 @@ const (
 	// IsRelay when set causes the the daemon to provide libp2p relay
 	// services allowing other filecoin nodes behind NATs to talk directly.
 	IsRelay = "is-relay"
+
+	// WalletPassphraseFile is the path of a file containing the passphrase to
+	// encrypt the wallet with on init, or to unlock an encrypted wallet with
+	WalletPassphraseFile = "wallet-passphrase-file"
 )
 
 // command object for the local cli
@@ -123,6 +130,7 @@ MESSAGE COMMANDS
 
 TOOL COMMANDS
   go-filecoin log                    - Interact with the daemon event log output.
+  go-filecoin repo gc                - Remove chain and state data which is no longer needed
   go-filecoin version                - Show go-filecoin version information
 `,
 	},
@@ -164,6 +172,7 @@ var rootSubcmdsDaemon = map[string]*cmds.Command{
 	"mpool":            mpoolCmd,
 	"paych":            paymentChannelCmd,
 	"ping":             pingCmd,
+	"repo":             repoCmd,
 	"retrieval-client": retrievalClientCmd,
 	"show":             showCmd,
 	"swarm":            swarmCmd,
@@ -295,6 +304,10 @@ func requiresDaemon(req *cmds.Request) bool {
 		return false
 	}
 
+	if req.Command == walletSignCmd {
+		return false
+	}
+
 	return true
 }
 
@@ -24,8 +24,10 @@ var msgCmd = &cmds.Command{
 		Tagline: "Manage messages",
 	},
 	Subcommands: map[string]*cmds.Command{
-		"send": msgSendCmd,
-		"wait": msgWaitCmd,
+		"send":   msgSendCmd,
+		"create": msgCreateCmd,
+		"submit": msgSubmitCmd,
+		"wait":   msgWaitCmd,
 	},
 }
 
@@ -131,6 +133,105 @@ var msgSendCmd = &cmds.Command{
 	},
 }
 
+var msgCreateCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Create an unsigned message for signing offline",
+		ShortDescription: `
+Creates a message with the next nonce of its sender without signing it, so
+that it can be signed by wallet sign on a machine holding the key, and then
+published with message submit. The nonce only accounts for messages already
+submitted, so submit each message before creating the next one.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("target", true, false, "Address of the actor to send the message to"),
+		cmdkit.StringArg("method", false, false, "The method to invoke on the target actor"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.IntOption("value", "Value to send with message, in AttoFIL"),
+		cmdkit.StringOption("from", "Address to send message from"),
+		encodingOption,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		target, err := address.NewFromString(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+
+		var method string
+		if len(req.Arguments) > 1 {
+			method = req.Arguments[1]
+		}
+
+		val, _ := req.Options["value"].(int)
+
+		var fromAddr address.Address
+		if o, ok := req.Options["from"].(string); ok && o != "" {
+			fromAddr, err = address.NewFromString(o)
+			if err != nil {
+				return errors.Wrap(err, "invalid from address")
+			}
+		} else {
+			fromAddr, err = GetPorcelainAPI(env).GetAndMaybeSetDefaultSenderAddress()
+			if err != nil {
+				return err
+			}
+		}
+
+		msg, err := GetPorcelainAPI(env).MessageCreate(
+			req.Context,
+			fromAddr,
+			target,
+			types.NewAttoFILFromFIL(uint64(val)),
+			method,
+		)
+		if err != nil {
+			return err
+		}
+
+		return re.Emit(msg)
+	},
+	Type: &types.Message{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, msg *types.Message) error {
+			return writeMessage(req, w, msg)
+		}),
+	},
+}
+
+var msgSubmitCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Publish a message signed offline",
+		ShortDescription: `
+Validates a message signed by wallet sign, adds it to the message pool and
+publishes it to the network. The message is read as JSON or CBOR from the
+file argument or stdin. Outputs the cid of the message.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("message", true, false, "File containing the signed message").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		var smsg types.SignedMessage
+		if err := readMessage(req, &smsg); err != nil {
+			return err
+		}
+
+		c, err := GetPorcelainAPI(env).MessageSubmit(req.Context, &smsg)
+		if err != nil {
+			return err
+		}
+
+		return re.Emit(c)
+	},
+	Type: cid.Cid{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, c cid.Cid) error {
+			return PrintString(w, c)
+		}),
+	},
+}
+
 // WaitResult is the result of a message wait call.
 type WaitResult struct {
 	Message   *types.SignedMessage
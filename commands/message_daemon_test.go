@@ -2,6 +2,7 @@ package commands
 
 import (
 	"encoding/json"
+	"os"
 	"strconv"
 	"strings"
 	"sync"
@@ -53,6 +54,43 @@ func TestMessageSend(t *testing.T) {
 	)
 }
 
+func TestMessageCreateSignSubmit(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	d := th.NewDaemon(
+		t,
+		th.WithMiner(fixtures.TestMiners[0]),
+		th.KeyFile(fixtures.KeyFilePaths()[0]),
+	).Start()
+	defer d.ShutdownSuccess()
+
+	t.Log("the key of the sender is only in the repo of a stopped node")
+	cold := th.NewDaemon(t, th.KeyFile(fixtures.KeyFilePaths()[1])).Start().Stop()
+	defer os.RemoveAll(cold.RepoDir()) // nolint: errcheck
+
+	d.RunSuccess("mining", "once")
+
+	unsigned := d.RunSuccess("message", "create",
+		"--from", fixtures.TestAddresses[1],
+		"--value=10", fixtures.TestAddresses[0],
+	).ReadStdout()
+	assert.Contains(unsigned, `"nonce": "0"`)
+
+	t.Log("[failure] submitting the unsigned message")
+	d.RunWithStdin(strings.NewReader(unsigned), "message", "submit").AssertFail("invalid signature")
+
+	signed := cold.RunWithStdin(strings.NewReader(unsigned), "wallet", "sign",
+		"--price", "0", "--limit", "300",
+	).AssertSuccess().ReadStdout()
+
+	msgCid := d.RunWithStdin(strings.NewReader(signed), "message", "submit").AssertSuccess().ReadStdoutTrimNewlines()
+
+	d.RunSuccess("mining", "once")
+	wait := d.RunSuccess("message", "wait", "--message=false", "--receipt=true", msgCid).ReadStdout()
+	assert.Contains(wait, `"exitCode": 0`)
+}
+
 func TestMessageWait(t *testing.T) {
 	t.Parallel()
 
@@ -7,6 +7,8 @@ import (
 	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
 	"gx/ipfs/Qma6uuSyjkecGhMFFLfzyJDPyoDtNJSHJNweDccZhaWkgU/go-ipfs-cmds"
 	cmdkit "gx/ipfs/Qmde5VP1qUkyQXKCfmEUA7bP64V2HAptbJ7phuPp7jXWwg/go-ipfs-cmdkit"
+
+	"github.com/filecoin-project/go-filecoin/proofs/sectorbuilder"
 )
 
 var miningCmd = &cmds.Command{
@@ -14,9 +16,10 @@ var miningCmd = &cmds.Command{
 		Tagline: "Manage all mining operations for a node",
 	},
 	Subcommands: map[string]*cmds.Command{
-		"once":  miningOnceCmd,
-		"start": miningStartCmd,
-		"stop":  miningStopCmd,
+		"once":    miningOnceCmd,
+		"sealing": miningSealingCmd,
+		"start":   miningStartCmd,
+		"stop":    miningStopCmd,
 	},
 }
 
@@ -58,6 +61,75 @@ var miningStopCmd = &cmds.Command{
 	Encoders: stringEncoderMap,
 }
 
+var miningSealingCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage the sealing of staged sectors",
+		ShortDescription: `
+Staged sectors are queued for sealing when they are full, when the auto-seal
+interval elapses or when a seal is requested. Queued sectors are sealed in order
+of their earliest deal start deadline, never more than mining.maxConcurrentSeals
+at once. Pausing sealing lets in-progress seals finish but starts no new ones.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"pause":  miningSealingPauseCmd,
+		"resume": miningSealingResumeCmd,
+		"status": miningSealingStatusCmd,
+	},
+}
+
+var miningSealingPauseCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Stop starting new seals",
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		if err := GetAPI(env).Mining().PauseSealing(req.Context); err != nil {
+			return err
+		}
+		return re.Emit("Paused sealing")
+	},
+	Type:     "",
+	Encoders: stringEncoderMap,
+}
+
+var miningSealingResumeCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Resume sealing queued sectors",
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		if err := GetAPI(env).Mining().ResumeSealing(req.Context); err != nil {
+			return err
+		}
+		return re.Emit("Resumed sealing")
+	},
+	Type:     "",
+	Encoders: stringEncoderMap,
+}
+
+var miningSealingStatusCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show queued and in-progress seals",
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		status, err := GetAPI(env).Mining().SealingStatus(req.Context)
+		if err != nil {
+			return err
+		}
+		return re.Emit(status)
+	},
+	Type: sectorbuilder.SealSchedulerStatus{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, status *sectorbuilder.SealSchedulerStatus) error {
+			fmt.Fprintf(w, "paused:               %t\n", status.Paused)             // nolint: errcheck
+			fmt.Fprintf(w, "max concurrent seals: %d\n", status.MaxConcurrentSeals) // nolint: errcheck
+			fmt.Fprintf(w, "sealing:              %v\n", status.Sealing)            // nolint: errcheck
+			fmt.Fprintf(w, "queued:               %v\n", status.Queued)             // nolint: errcheck
+			fmt.Fprintf(w, "staged:               %v\n", status.Staged)             // nolint: errcheck
+			return nil
+		}),
+	},
+}
+
 var stringEncoderMap = cmds.EncoderMap{
 	cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, t string) error {
 		fmt.Fprintln(w, t) // nolint: errcheck
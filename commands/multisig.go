@@ -0,0 +1,253 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"gx/ipfs/Qma6uuSyjkecGhMFFLfzyJDPyoDtNJSHJNweDccZhaWkgU/go-ipfs-cmds"
+	"gx/ipfs/Qmde5VP1qUkyQXKCfmEUA7bP64V2HAptbJ7phuPp7jXWwg/go-ipfs-cmdkit"
+
+	"github.com/filecoin-project/go-filecoin/actor/builtin/multisig"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+var walletMultisigCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage multisig wallets",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"approve": multisigApproveCmd,
+		"create":  multisigCreateCmd,
+		"ls":      multisigLsCmd,
+		"propose": multisigProposeCmd,
+	},
+}
+
+var multisigCreateCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Create a multisig wallet",
+		ShortDescription: `Issues a new message to the network to create a wallet controlled by the given
+signers, threshold of whom must approve each transaction. Then waits for the
+message to be mined to get the wallet's address.`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("threshold", true, false, "Number of signers who must approve each transaction"),
+		cmdkit.StringArg("signers", true, true, "Addresses of the wallet's signers"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("from", "Address to send from"),
+		cmdkit.StringOption("value", "Amount in FIL to fund the wallet with"),
+		priceOption,
+		limitOption,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		fromAddr, err := optionalAddr(req.Options["from"])
+		if err != nil {
+			return err
+		}
+
+		threshold, err := strconv.ParseUint(req.Arguments[0], 10, 64)
+		if err != nil {
+			return ErrInvalidThreshold
+		}
+
+		var signers []address.Address
+		for _, arg := range req.Arguments[1:] {
+			signer, err := address.NewFromString(arg)
+			if err != nil {
+				return err
+			}
+			signers = append(signers, signer)
+		}
+
+		value := types.NewZeroAttoFIL()
+		if o, ok := req.Options["value"].(string); ok {
+			if value, ok = types.NewAttoFILFromFILString(o); !ok {
+				return ErrInvalidAmount
+			}
+		}
+
+		gasPrice, gasLimit, _, err := parseGasOptions(req)
+		if err != nil {
+			return err
+		}
+
+		wallet, err := GetPorcelainAPI(env).MultisigCreate(req.Context, fromAddr, gasPrice, gasLimit, signers, threshold, value)
+		if err != nil {
+			return err
+		}
+
+		return re.Emit(&addressResult{wallet.String()})
+	},
+	Type: &addressResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, a *addressResult) error {
+			_, err := fmt.Fprintln(w, a.Address)
+			return err
+		}),
+	},
+}
+
+var multisigProposeCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Propose a transaction from a multisig wallet",
+		ShortDescription: `Issues a new message to the network proposing that the wallet send value to
+target, calling method if one is given. The proposal counts as the sender's
+approval. Then waits for the message to be mined to get the transaction's id.`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("wallet", true, false, "Address of the multisig wallet"),
+		cmdkit.StringArg("target", true, false, "Address of the actor the wallet should send to"),
+		cmdkit.StringArg("value", true, false, "Amount in FIL the wallet should send"),
+		cmdkit.StringArg("method", false, false, "The method to invoke on the target actor"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("from", "Address to send from"),
+		priceOption,
+		limitOption,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		fromAddr, err := optionalAddr(req.Options["from"])
+		if err != nil {
+			return err
+		}
+
+		wallet, err := address.NewFromString(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+
+		target, err := address.NewFromString(req.Arguments[1])
+		if err != nil {
+			return err
+		}
+
+		value, ok := types.NewAttoFILFromFILString(req.Arguments[2])
+		if !ok {
+			return ErrInvalidAmount
+		}
+
+		method := ""
+		if len(req.Arguments) > 3 {
+			method = req.Arguments[3]
+		}
+
+		gasPrice, gasLimit, _, err := parseGasOptions(req)
+		if err != nil {
+			return err
+		}
+
+		txID, err := GetPorcelainAPI(env).MultisigPropose(req.Context, fromAddr, gasPrice, gasLimit, wallet, target, value, method)
+		if err != nil {
+			return err
+		}
+
+		return re.Emit(txID)
+	},
+	Type: uint64(0),
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, txID uint64) error {
+			_, err := fmt.Fprintln(w, txID)
+			return err
+		}),
+	},
+}
+
+var multisigApproveCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Approve a pending transaction of a multisig wallet",
+		ShortDescription: `Issues a new message to the network approving the transaction, and waits for
+it to be mined. The transaction is sent once enough signers approve it. If the
+threshold was lowered after you approved a transaction, approving it again
+sends it.`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("wallet", true, false, "Address of the multisig wallet"),
+		cmdkit.StringArg("txid", true, false, "Id of the transaction to approve"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("from", "Address to send from"),
+		priceOption,
+		limitOption,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		fromAddr, err := optionalAddr(req.Options["from"])
+		if err != nil {
+			return err
+		}
+
+		wallet, err := address.NewFromString(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+
+		txID, err := strconv.ParseUint(req.Arguments[1], 10, 64)
+		if err != nil {
+			return err
+		}
+
+		gasPrice, gasLimit, _, err := parseGasOptions(req)
+		if err != nil {
+			return err
+		}
+
+		return GetPorcelainAPI(env).MultisigApprove(req.Context, fromAddr, gasPrice, gasLimit, wallet, txID)
+	},
+}
+
+var multisigLsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the signers and pending transactions of a multisig wallet",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("wallet", true, false, "Address of the multisig wallet"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		wallet, err := address.NewFromString(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+
+		state, err := GetPorcelainAPI(env).MultisigLs(req.Context, wallet)
+		if err != nil {
+			return err
+		}
+
+		return re.Emit(state)
+	},
+	Type: &multisig.State{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, state *multisig.State) error {
+			if _, err := fmt.Fprintf(w, "threshold: %d\n", state.Threshold); err != nil {
+				return err
+			}
+			for _, signer := range state.Signers {
+				if _, err := fmt.Fprintf(w, "signer: %s\n", signer); err != nil {
+					return err
+				}
+			}
+
+			var txIDs []string
+			for txID := range state.Pending {
+				txIDs = append(txIDs, txID)
+			}
+			sort.Slice(txIDs, func(i, j int) bool {
+				a, _ := strconv.ParseUint(txIDs[i], 10, 64)
+				b, _ := strconv.ParseUint(txIDs[j], 10, 64)
+				return a < b
+			})
+
+			for _, txID := range txIDs {
+				tx := state.Pending[txID]
+				_, err := fmt.Fprintf(w, "%s: send %s FIL to %s calling %q (%d/%d approvals)\n", txID, tx.Value, tx.To, tx.Method, len(tx.Approvals), state.Threshold)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
+	},
+}
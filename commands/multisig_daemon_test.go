@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/fixtures"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+)
+
+func TestWalletMultisig(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+
+	d := th.NewDaemon(
+		t,
+		th.WithMiner(fixtures.TestMiners[0]),
+		th.KeyFile(fixtures.KeyFilePaths()[0]),
+		th.KeyFile(fixtures.KeyFilePaths()[1]),
+	).Start()
+	defer d.ShutdownSuccess()
+
+	signer0, signer1 := fixtures.TestAddresses[0], fixtures.TestAddresses[1]
+	target := fixtures.TestAddresses[2]
+
+	create := runMultisigAndMine(d, "create", "--from", signer0, "--price", "0", "--limit", "1000", "--value", "100", "2", signer0, signer1)
+	wallet, err := address.NewFromString(strings.Trim(create.ReadStdout(), "\n"))
+	require.NoError(err)
+
+	propose := runMultisigAndMine(d, "propose", "--from", signer0, "--price", "0", "--limit", "1000", wallet.String(), target, "10")
+	txID := strings.Trim(propose.ReadStdout(), "\n")
+	assert.Equal("0", txID)
+
+	ls := d.RunSuccess("wallet", "multisig", "ls", wallet.String()).ReadStdout()
+	assert.Contains(ls, "threshold: 2")
+	assert.Contains(ls, "signer: "+signer1)
+	assert.Contains(ls, "0: send 10 FIL to "+target)
+	assert.Contains(ls, "(1/2 approvals)")
+
+	runMultisigAndMine(d, "approve", "--from", signer1, "--price", "0", "--limit", "1000", wallet.String(), txID)
+
+	ls = d.RunSuccess("wallet", "multisig", "ls", wallet.String()).ReadStdout()
+	assert.NotContains(ls, "approvals")
+
+	balance := d.RunSuccess("wallet", "balance", wallet.String()).ReadStdout()
+	assert.Equal("90", strings.Trim(balance, "\n"))
+}
+
+// runMultisigAndMine runs a wallet multisig subcommand, which waits for its
+// message to be mined, and mines a block once the message is in the pool.
+func runMultisigAndMine(d *th.TestDaemon, args ...string) *th.Output {
+	var out *th.Output
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		out = d.RunSuccess(append([]string{"wallet", "multisig"}, args...)...)
+		wg.Done()
+	}()
+
+	d.RunSuccess("mpool", "ls", "--wait-for-count=1")
+	d.RunSuccess("mining", "once")
+
+	wg.Wait()
+	return out
+}
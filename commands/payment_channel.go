@@ -1,18 +1,22 @@
 package commands
 
 import (
+	"encoding/hex"
 	"fmt"
 	"io"
+	"math/big"
 	"strconv"
 
 	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
 	cbor "gx/ipfs/QmRoARq3nkUb13HSKZGepCZSWe5GrVPwx7xURJGZ7KWv9V/go-ipld-cbor"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
 	"gx/ipfs/Qma6uuSyjkecGhMFFLfzyJDPyoDtNJSHJNweDccZhaWkgU/go-ipfs-cmds"
 	"gx/ipfs/Qmde5VP1qUkyQXKCfmEUA7bP64V2HAptbJ7phuPp7jXWwg/go-ipfs-cmdkit"
 	"gx/ipfs/QmekxXDhCxCJRNuzmHreuaT3BsuJcsjcXWNrtV9C8DRHtd/go-multibase"
 
 	"github.com/filecoin-project/go-filecoin/actor/builtin/paymentbroker"
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/porcelain"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
@@ -21,13 +25,15 @@ var paymentChannelCmd = &cmds.Command{
 		Tagline: "Payment channel operations",
 	},
 	Subcommands: map[string]*cmds.Command{
-		"close":   closeCmd,
-		"create":  createChannelCmd,
-		"extend":  extendCmd,
-		"ls":      lsCmd,
-		"reclaim": reclaimCmd,
-		"redeem":  redeemCmd,
-		"voucher": voucherCmd,
+		"add-funds": addFundsCmd,
+		"close":     closeCmd,
+		"create":    createChannelCmd,
+		"extend":    extendCmd,
+		"ls":        lsCmd,
+		"reclaim":   reclaimCmd,
+		"redeem":    redeemCmd,
+		"status":    statusCmd,
+		"voucher":   voucherCmd,
 	},
 }
 
@@ -169,10 +175,63 @@ var lsCmd = &cmds.Command{
 	},
 }
 
+var statusCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the status of a payment channel",
+		ShortDescription: `Combines the state of a payment channel on chain with the vouchers this node has
+issued or received on it.`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("channel", true, false, "Id of the channel"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("payer", "Address of the payer of the channel (defaults to the default sender address)"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		payerAddr, err := optionalAddr(req.Options["payer"])
+		if err != nil {
+			return err
+		}
+		if payerAddr.Empty() {
+			payerAddr, err = GetPorcelainAPI(env).GetAndMaybeSetDefaultSenderAddress()
+			if err != nil {
+				return err
+			}
+		}
+
+		channel, ok := types.NewChannelIDFromString(req.Arguments[0], 10)
+		if !ok {
+			return fmt.Errorf("invalid channel id")
+		}
+
+		status, err := GetPorcelainAPI(env).PaychStatus(req.Context, payerAddr, channel)
+		if err != nil {
+			return err
+		}
+
+		return re.Emit(status)
+	},
+	Type: &porcelain.PaychStatusReturn{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, status *porcelain.PaychStatusReturn) error {
+			_, err := fmt.Fprintf(w, `target: %s
+amount locked: %s
+amount issued: %s
+amount redeemed: %s
+eol: %s (%d blocks left)
+`, status.Target, status.AmountLocked, status.AmountIssued, status.AmountRedeemed, status.Eol, status.BlocksToEol)
+			return err
+		}),
+	},
+}
+
 var voucherCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
-		Tagline:          "Create a new voucher from a payment channel",
-		ShortDescription: `Generate a new signed payment voucher for the target of a payment channel.`,
+		Tagline: "Create a new voucher from a payment channel",
+		ShortDescription: `Generate a new signed payment voucher for the target of a payment channel.
+With --condition-miner, --condition-sector and --condition-commd the voucher can
+only be redeemed while the sector of the miner is committed with the given commD
+and the miner's last PoSt is current.`,
 	},
 	Arguments: []cmdkit.Argument{
 		cmdkit.StringArg("channel", true, false, "Channel id of channel from which to create voucher"),
@@ -181,6 +240,10 @@ var voucherCmd = &cmds.Command{
 	Options: []cmdkit.Option{
 		cmdkit.StringOption("from", "Address for which to retrieve channels"),
 		cmdkit.StringOption("validat", "Smallest block height at which target can redeem"),
+		cmdkit.Uint64Option("lane", "Lane of the channel in which to create the voucher").WithDefault(uint64(0)),
+		cmdkit.StringOption("condition-miner", "Address of the miner whose sector must be proven to redeem the voucher"),
+		cmdkit.Uint64Option("condition-sector", "Id of the sector which must be proven to redeem the voucher"),
+		cmdkit.StringOption("condition-commd", "Hex encoded commD of the sector which must be proven to redeem the voucher"),
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		fromAddr, err := optionalAddr(req.Options["from"])
@@ -193,6 +256,11 @@ var voucherCmd = &cmds.Command{
 			return err
 		}
 
+		condition, err := optionalSectorProvenCondition(req)
+		if err != nil {
+			return err
+		}
+
 		channel, ok := types.NewChannelIDFromString(req.Arguments[0], 10)
 		if !ok {
 			return fmt.Errorf("invalid channel id")
@@ -203,7 +271,9 @@ var voucherCmd = &cmds.Command{
 			return ErrInvalidAmount
 		}
 
-		voucher, err := GetAPI(env).Paych().Voucher(req.Context, fromAddr, channel, amount, validAt)
+		lane, _ := req.Options["lane"].(uint64)
+
+		voucher, err := GetAPI(env).Paych().Voucher(req.Context, fromAddr, channel, lane, amount, validAt, condition)
 		if err != nil {
 			return err
 		}
@@ -218,6 +288,31 @@ var voucherCmd = &cmds.Command{
 	},
 }
 
+// optionalSectorProvenCondition returns the condition set by the condition
+// options of voucherCmd, or nil if they are not set.
+func optionalSectorProvenCondition(req *cmds.Request) (*paymentbroker.Condition, error) {
+	minerOpt, hasMiner := req.Options["condition-miner"]
+	sectorID, hasSector := req.Options["condition-sector"].(uint64)
+	commDOpt, hasCommD := req.Options["condition-commd"].(string)
+	if !hasMiner && !hasSector && !hasCommD {
+		return nil, nil
+	}
+	if !hasMiner || !hasSector || !hasCommD {
+		return nil, errors.New("condition-miner, condition-sector and condition-commd must be given together")
+	}
+
+	minerAddr, err := address.NewFromString(minerOpt.(string))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid condition miner address")
+	}
+	commD, err := hex.DecodeString(commDOpt)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid condition commD")
+	}
+
+	return paymentbroker.NewCondition(minerAddr, "verifySectorProven", sectorID, commD)
+}
+
 type redeemResult struct {
 	Cid     cid.Cid
 	GasUsed types.GasUnits
@@ -260,12 +355,17 @@ var redeemCmd = &cmds.Command{
 				return err
 			}
 
+			condition, err := paymentbroker.EncodeCondition(voucher.Condition)
+			if err != nil {
+				return err
+			}
+
 			usedGas, err := GetPorcelainAPI(env).MessagePreview(
 				req.Context,
 				fromAddr,
 				address.PaymentBrokerAddress,
 				"redeem",
-				voucher.Payer, &voucher.Channel, &voucher.Amount, &voucher.ValidAt, []byte(voucher.Signature),
+				voucher.Payer, &voucher.Channel, big.NewInt(0).SetUint64(voucher.Lane), &voucher.Amount, &voucher.ValidAt, condition, []byte(voucher.Signature),
 			)
 			if err != nil {
 				return err
@@ -420,12 +520,17 @@ var closeCmd = &cmds.Command{
 				return err
 			}
 
+			condition, err := paymentbroker.EncodeCondition(voucher.Condition)
+			if err != nil {
+				return err
+			}
+
 			usedGas, err := GetPorcelainAPI(env).MessagePreview(
 				req.Context,
 				fromAddr,
 				address.PaymentBrokerAddress,
 				"close",
-				voucher.Payer, &voucher.Channel, &voucher.Amount, &voucher.ValidAt, []byte(voucher.Signature),
+				voucher.Payer, &voucher.Channel, big.NewInt(0).SetUint64(voucher.Lane), &voucher.Amount, &voucher.ValidAt, condition, []byte(voucher.Signature),
 			)
 			if err != nil {
 				return err
@@ -549,3 +654,86 @@ var extendCmd = &cmds.Command{
 		}),
 	},
 }
+
+type addFundsResult struct {
+	Cid     cid.Cid
+	GasUsed types.GasUnits
+	Preview bool
+}
+
+var addFundsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Add funds to a given channel without changing its lifetime",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("channel", true, false, "Id of channel to add funds to"),
+		cmdkit.StringArg("amount", true, false, "Amount in FIL to add to the channel"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("from", "Address of the channel creator"),
+		priceOption,
+		limitOption,
+		previewOption,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		fromAddr, err := optionalAddr(req.Options["from"])
+		if err != nil {
+			return err
+		}
+
+		channel, ok := types.NewChannelIDFromString(req.Arguments[0], 10)
+		if !ok {
+			return fmt.Errorf("invalid channel id")
+		}
+
+		amount, ok := types.NewAttoFILFromFILString(req.Arguments[1])
+		if !ok {
+			return ErrInvalidAmount
+		}
+
+		gasPrice, gasLimit, preview, err := parseGasOptions(req)
+		if err != nil {
+			return err
+		}
+
+		if preview {
+			usedGas, err := GetPorcelainAPI(env).MessagePreview(
+				req.Context,
+				fromAddr,
+				address.PaymentBrokerAddress,
+				"addFunds",
+				channel,
+			)
+			if err != nil {
+				return err
+			}
+			return re.Emit(&addFundsResult{
+				Cid:     cid.Cid{},
+				GasUsed: usedGas,
+				Preview: true,
+			})
+		}
+
+		c, err := GetAPI(env).Paych().AddFunds(req.Context, fromAddr, gasPrice, gasLimit, channel, amount)
+		if err != nil {
+			return err
+		}
+
+		return re.Emit(&addFundsResult{
+			Cid:     c,
+			GasUsed: types.NewGasUnits(0),
+			Preview: false,
+		})
+	},
+	Type: &addFundsResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res *addFundsResult) error {
+			if res.Preview {
+				output := strconv.FormatUint(uint64(res.GasUsed), 10)
+				_, err := w.Write([]byte(output))
+				return err
+			}
+			return PrintString(w, res.Cid)
+		}),
+	},
+}
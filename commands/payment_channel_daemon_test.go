@@ -17,6 +17,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/actor/builtin/paymentbroker"
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/fixtures"
+	"github.com/filecoin-project/go-filecoin/porcelain"
 	th "github.com/filecoin-project/go-filecoin/testhelpers"
 	"github.com/filecoin-project/go-filecoin/types"
 )
@@ -145,6 +146,37 @@ func TestPaymentChannelVoucherSuccess(t *testing.T) {
 	})
 }
 
+func TestPaymentChannelStatus(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	payer, err := address.NewFromString(fixtures.TestAddresses[0])
+	require.NoError(err)
+	target, err := address.NewFromString(fixtures.TestAddresses[1])
+	require.NoError(err)
+
+	eol := types.NewBlockHeight(20)
+	amt := types.NewAttoFILFromFIL(10000)
+
+	daemonTestWithPaymentChannel(t, &payer, &target, amt, eol, func(d *th.TestDaemon, channelID *types.ChannelID) {
+		assert := assert.New(t)
+
+		mustCreateVoucher(t, d, channelID, types.NewAttoFILFromFIL(100), &payer)
+
+		// a second lane may not promise funds already promised in the first
+		d.RunFail(porcelain.ErrDoubleIssuance.Error(),
+			"paych", "voucher", channelID.String(), "9950", "--from", payer.String(), "--lane", "1",
+		)
+
+		status := d.RunSuccess("paych", "status", channelID.String(), "--payer", payer.String()).ReadStdout()
+		assert.Contains(status, "target: "+target.String())
+		assert.Contains(status, "amount locked: 10000")
+		assert.Contains(status, "amount issued: 100")
+		assert.Contains(status, "amount redeemed: 0")
+		assert.Contains(status, "eol: 20")
+	})
+}
+
 func TestPaymentChannelRedeemSuccess(t *testing.T) {
 	t.Parallel()
 	require := require.New(t)
@@ -336,6 +368,30 @@ func TestPaymentChannelExtendSuccess(t *testing.T) {
 	})
 }
 
+func TestPaymentChannelAddFundsSuccess(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	payer, err := address.NewFromString(fixtures.TestAddresses[0])
+	require.NoError(err)
+	target, err := address.NewFromString(fixtures.TestAddresses[1])
+	require.NoError(err)
+
+	eol := types.NewBlockHeight(5)
+	amt := types.NewAttoFILFromFIL(2000)
+
+	daemonTestWithPaymentChannel(t, &payer, &target, amt, eol, func(d *th.TestDaemon, channelID *types.ChannelID) {
+		assert := assert.New(t)
+
+		addedAmt := types.NewAttoFILFromFIL(500)
+
+		mustAddFunds(t, d, channelID, addedAmt, &payer)
+
+		lsStr := listChannelsAsStrs(d, &payer)[0]
+		assert.Equal(fmt.Sprintf("%v: target: %s, amt: %s, amt redeemed: 0, eol: %s", channelID.String(), target.String(), addedAmt.Add(amt), eol), lsStr)
+	})
+}
+
 func daemonTestWithPaymentChannel(t *testing.T, payerAddress *address.Address, targetAddress *address.Address, fundsToLock *types.AttoFIL, eol *types.BlockHeight, f func(*th.TestDaemon, *types.ChannelID)) {
 	assert := assert.New(t)
 
@@ -436,6 +492,36 @@ func mustExtendChannel(t *testing.T, d *th.TestDaemon, channelID *types.ChannelI
 	wg.Wait()
 }
 
+func mustAddFunds(t *testing.T, d *th.TestDaemon, channelID *types.ChannelID, amount *types.AttoFIL, payerAddress *address.Address) {
+	require := require.New(t)
+
+	args := []string{"paych", "add-funds"}
+	args = append(args, "--from", payerAddress.String(), "--price", "0", "--limit", "300")
+	args = append(args, channelID.String(), amount.String())
+
+	addFundsCmd := d.RunSuccess(args...)
+	messageCid, err := cid.Parse(strings.Trim(addFundsCmd.ReadStdout(), "\n"))
+	require.NoError(err)
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		_ = d.RunSuccess("message", "wait",
+			"--return=false",
+			"--message=false",
+			"--receipt=false",
+			messageCid.String(),
+		)
+
+		wg.Done()
+	}()
+
+	d.RunSuccess("mining once")
+
+	wg.Wait()
+}
+
 func mustRedeemVoucher(t *testing.T, d *th.TestDaemon, voucher string, targetAddress *address.Address) {
 	require := require.New(t)
 
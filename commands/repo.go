@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+
+	cmds "gx/ipfs/Qma6uuSyjkecGhMFFLfzyJDPyoDtNJSHJNweDccZhaWkgU/go-ipfs-cmds"
+	cmdkit "gx/ipfs/Qmde5VP1qUkyQXKCfmEUA7bP64V2HAptbJ7phuPp7jXWwg/go-ipfs-cmdkit"
+
+	"github.com/filecoin-project/go-filecoin/gc"
+)
+
+var repoCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage the repo",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"gc": repoGCCmd,
+	},
+}
+
+var repoGCCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Remove chain and state data which is no longer needed",
+		ShortDescription: `
+Removes the state of tipsets older than the gc.retention config value, except
+for the genesis state, and the fork tipsets older than that from the repo.
+Garbage is also collected in the background every gc.period.
+`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		res, err := GetPorcelainAPI(env).RepoGC(req.Context)
+		if err != nil {
+			return err
+		}
+		return re.Emit(res)
+	},
+	Type: gc.Result{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res *gc.Result) error {
+			_, err := fmt.Fprintf(w, "removed %d blocks and %d fork tipsets\n", res.BlocksRemoved, res.TipSetsPruned)
+			return err
+		}),
+	},
+}
@@ -0,0 +1,25 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+)
+
+func TestRepoGC(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	d := th.NewDaemon(t).Start()
+	defer d.ShutdownSuccess()
+
+	out := d.RunSuccess("repo", "gc")
+	assert.Contains(out.ReadStdout(), "fork tipsets")
+
+	// The genesis state is retained, so the chain is still readable.
+	d.RunSuccess("chain", "ls")
+	d.RunSuccess("wallet", "balance", address.NetworkAddress.String())
+}
@@ -0,0 +1,135 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+	"gx/ipfs/Qma6uuSyjkecGhMFFLfzyJDPyoDtNJSHJNweDccZhaWkgU/go-ipfs-cmds"
+	"gx/ipfs/Qmde5VP1qUkyQXKCfmEUA7bP64V2HAptbJ7phuPp7jXWwg/go-ipfs-cmdkit"
+
+	"github.com/filecoin-project/go-filecoin/node"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+var encodingOption = cmdkit.StringOption("encoding", "Encoding of the output message: json or cbor").WithDefault("json")
+
+var walletSignCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Sign a message created by `message create`",
+		ShortDescription: `
+Signs an unsigned message, as output by message create, with the key of its
+sender and outputs the signed message, to be handed to message submit. The
+message is read as JSON or CBOR from the file argument or stdin.
+
+This command runs without a daemon, so that it can be used on an offline
+machine holding the keys. It opens the wallet of the repo directly, so no
+daemon may be running on that repo.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("message", true, false, "File containing the unsigned message").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		priceOption,
+		limitOption,
+		encodingOption,
+		cmdkit.StringOption(WalletPassphraseFile, "path of a file containing the passphrase to unlock an encrypted wallet with, FIL_WALLET_PASSPHRASE is used if not set"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		var msg types.Message
+		if err := readMessage(req, &msg); err != nil {
+			return err
+		}
+
+		gasPrice, gasLimit, _, err := parseGasOptions(req)
+		if err != nil {
+			return err
+		}
+
+		rep, err := getRepo(req)
+		if err != nil {
+			return err
+		}
+		defer rep.Close() // nolint: errcheck
+
+		w, err := node.OpenWallet(rep)
+		if err != nil {
+			return err
+		}
+		if err := unlockWallet(req, w); err != nil {
+			return err
+		}
+
+		smsg, err := types.NewSignedMessage(msg, w, gasPrice, gasLimit)
+		if err != nil {
+			return errors.Wrap(err, "failed to sign message")
+		}
+
+		return re.Emit(smsg)
+	},
+	Type: &types.SignedMessage{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, smsg *types.SignedMessage) error {
+			return writeMessage(req, w, smsg)
+		}),
+	},
+}
+
+// encodedMessage is a message which can be exchanged as JSON or CBOR.
+type encodedMessage interface {
+	Marshal() ([]byte, error)
+	Unmarshal([]byte) error
+}
+
+// readMessage decodes the message in the file argument of req, which is
+// read as JSON if it looks like a JSON object and as CBOR otherwise.
+func readMessage(req *cmds.Request, msg encodedMessage) error {
+	f, err := req.Files.NextFile()
+	if err != nil {
+		return errors.Wrap(err, "failed to open message")
+	}
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return errors.Wrap(err, "failed to read message")
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, msg); err != nil {
+			return errors.Wrap(err, "failed to decode JSON message")
+		}
+		return nil
+	}
+
+	if err := msg.Unmarshal(data); err != nil {
+		return errors.Wrap(err, "failed to decode CBOR message")
+	}
+	return nil
+}
+
+// writeMessage encodes msg as set by the encoding option of req.
+func writeMessage(req *cmds.Request, w io.Writer, msg encodedMessage) error {
+	encoding, _ := req.Options["encoding"].(string)
+	switch encoding {
+	case "", "json":
+		out, err := appendJSON(msg, nil)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+	case "cbor":
+		out, err := msg.Marshal()
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+	default:
+		return fmt.Errorf("unknown encoding %q", encoding)
+	}
+}
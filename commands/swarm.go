@@ -41,13 +41,17 @@ var swarmPeersCmd = &cmds.Command{
 		cmdkit.BoolOption("verbose", "v", "Display all extra information"),
 		cmdkit.BoolOption("streams", "Also list information about open streams for each peer"),
 		cmdkit.BoolOption("latency", "Also list information about latency to each peer"),
+		cmdkit.BoolOption("scores", "Also list the reputation score of each peer and the banned peers"),
+		cmdkit.BoolOption("chain", "Also list information about the chain of each peer"),
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		verbose, _ := req.Options["verbose"].(bool)
 		latency, _ := req.Options["latency"].(bool)
 		streams, _ := req.Options["streams"].(bool)
+		scores, _ := req.Options["scores"].(bool)
+		chain, _ := req.Options["chain"].(bool)
 
-		out, err := GetAPI(env).Swarm().Peers(req.Context, verbose, latency, streams)
+		out, err := GetAPI(env).Swarm().Peers(req.Context, verbose, latency, streams, scores, chain)
 		if err != nil {
 			return err
 		}
@@ -67,6 +71,12 @@ var swarmPeersCmd = &cmds.Command{
 				if info.Latency != "" {
 					fmt.Fprintf(w, " %s", info.Latency) // nolint: errcheck
 				}
+				if info.Score != "" {
+					fmt.Fprintf(w, " score %s", info.Score) // nolint: errcheck
+				}
+				if c := info.Chain; c != nil {
+					fmt.Fprintf(w, " height %d weight %d network %d version %q", c.HeadHeight, c.HeadWeight, c.NetworkVersion, c.SoftwareVersion) // nolint: errcheck
+				}
 				fmt.Fprintln(w) // nolint: errcheck
 
 				for _, s := range info.Streams {
@@ -77,6 +87,9 @@ var swarmPeersCmd = &cmds.Command{
 					fmt.Fprintf(w, "  %s\n", s.Protocol) // nolint: errcheck
 				}
 			}
+			for _, p := range ci.Banned {
+				fmt.Fprintf(w, "/%s/%s banned\n", pipfs, p) // nolint: errcheck
+			}
 
 			return nil
 		}),
@@ -1,9 +1,12 @@
 package commands
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	th "github.com/filecoin-project/go-filecoin/testhelpers"
 )
@@ -47,3 +50,41 @@ func TestSwarmFindPeer(t *testing.T) {
 
 	assert.Contains(d2Addr, findpeerOutput)
 }
+
+func TestSwarmPeersScores(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	d1 := th.NewDaemon(t).Start()
+	defer d1.ShutdownSuccess()
+
+	d2 := th.NewDaemon(t).Start()
+	defer d2.ShutdownSuccess()
+
+	d1.ConnectSuccess(d2)
+
+	peersOutput := d1.RunSuccess("swarm", "peers", "--scores").ReadStdoutTrimNewlines()
+	assert.Contains(peersOutput, d2.GetID())
+	assert.Contains(peersOutput, "score 0.0")
+}
+
+func TestSwarmPeersChain(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	d1 := th.NewDaemon(t).Start()
+	defer d1.ShutdownSuccess()
+
+	d2 := th.NewDaemon(t).Start()
+	defer d2.ShutdownSuccess()
+
+	d1.ConnectSuccess(d2)
+
+	// The chain info of a peer is known once the hello handshake completed.
+	var peersOutput string
+	require.NoError(t, th.WaitForIt(20, 100*time.Millisecond, func() (bool, error) {
+		peersOutput = d1.RunSuccess("swarm", "peers", "--chain").ReadStdoutTrimNewlines()
+		return strings.Contains(peersOutput, "height 0"), nil
+	}))
+	assert.Contains(peersOutput, d2.GetID())
+}
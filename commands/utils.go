@@ -3,6 +3,11 @@ package commands
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
+	"strings"
+
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+	"gx/ipfs/Qma6uuSyjkecGhMFFLfzyJDPyoDtNJSHJNweDccZhaWkgU/go-ipfs-cmds"
 
 	"github.com/filecoin-project/go-filecoin/types"
 )
@@ -29,3 +34,24 @@ func optionalBlockHeight(o interface{}) (ret *types.BlockHeight, err error) {
 	}
 	return validAt, nil
 }
+
+// readSecret returns the content of the file argument of req, which is stdin
+// unless a file is given, without its trailing newline. Secrets such as
+// mnemonics and passphrases are read this way rather than taken as string
+// arguments, which end up in the shell history and the process list.
+func readSecret(req *cmds.Request) (string, error) {
+	if req.Files == nil {
+		return "", errors.New("expected a file or stdin")
+	}
+	f, err := req.Files.NextFile()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to open file")
+	}
+	defer f.Close() // nolint: errcheck
+
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read file")
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
@@ -9,6 +9,7 @@ import (
 	"regexp"
 	"strings"
 
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
 	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
 
 	"github.com/filecoin-project/go-filecoin/address"
@@ -24,6 +25,10 @@ type Config struct {
 	Mining    *MiningConfig    `json:"mining"`
 	Wallet    *WalletConfig    `json:"wallet"`
 	Heartbeat *HeartbeatConfig `json:"heartbeat"`
+	Proofs    *ProofsConfig    `json:"proofs"`
+	GC        *GCConfig        `json:"gc"`
+	Sync      *SyncConfig      `json:"sync"`
+	Paych     *PaychConfig     `json:"paych"`
 }
 
 // APIConfig holds all configuration options related to the api.
@@ -61,6 +66,7 @@ type DatastoreConfig struct {
 // being set matches the name given in this map.
 var Validators = map[string]func(string, string) error{
 	"heartbeat.nickname": validateLettersOnly,
+	"proofs.backend":     validateProofsBackend,
 }
 
 func newDefaultDatastoreConfig() *DatastoreConfig {
@@ -102,6 +108,7 @@ func newDefaultBootstrapConfig() *BootstrapConfig {
 type MiningConfig struct {
 	MinerAddress            address.Address `json:"minerAddress"`
 	AutoSealIntervalSeconds uint            `json:"autoSealIntervalSeconds"`
+	MaxConcurrentSeals      uint            `json:"maxConcurrentSeals"`
 	StoragePrice            *types.AttoFIL  `json:"storagePrice"`
 }
 
@@ -109,6 +116,7 @@ func newDefaultMiningConfig() *MiningConfig {
 	return &MiningConfig{
 		MinerAddress:            address.Address{},
 		AutoSealIntervalSeconds: 120,
+		MaxConcurrentSeals:      1,
 		StoragePrice:            types.NewZeroAttoFIL(),
 	}
 }
@@ -116,6 +124,9 @@ func newDefaultMiningConfig() *MiningConfig {
 // WalletConfig holds all configuration options related to the wallet.
 type WalletConfig struct {
 	DefaultAddress address.Address `json:"defaultAddress,omitempty"`
+	// RemoteSigner is the socket path of an external signer process holding
+	// keys of the wallet, if any.
+	RemoteSigner string `json:"remoteSigner,omitempty"`
 }
 
 func newDefaultWalletConfig() *WalletConfig {
@@ -147,6 +158,83 @@ func newDefaultHeartbeatConfig() *HeartbeatConfig {
 	}
 }
 
+// RustProofsBackend and GoProofsBackend are the values of ProofsConfig.Backend.
+const (
+	// RustProofsBackend verifies and generates proofs with rust-proofs.
+	RustProofsBackend = "rust"
+	// GoProofsBackend uses pure-Go, hash-based stand-ins for proofs. They
+	// prove nothing and must only be used on development networks.
+	GoProofsBackend = "go"
+)
+
+// ProofsConfig holds all configuration options related to proofs.
+type ProofsConfig struct {
+	// Backend selects the implementation used to generate and verify seal
+	// proofs and PoSts. Every node on a network must use the same backend.
+	Backend string `json:"backend"`
+}
+
+func newDefaultProofsConfig() *ProofsConfig {
+	return &ProofsConfig{
+		Backend: RustProofsBackend,
+	}
+}
+
+// GCConfig holds all configuration options related to garbage collection of
+// chain and state data.
+type GCConfig struct {
+	// Retention is the number of heights below the head whose full state
+	// is kept.
+	Retention uint64 `json:"retention"`
+	// Period represents how frequently garbage is collected in the
+	// background, background collection is disabled if it is empty.
+	// Golang duration units are accepted.
+	Period string `json:"period"`
+}
+
+func newDefaultGCConfig() *GCConfig {
+	return &GCConfig{
+		Retention: 1000,
+		Period:    "1h",
+	}
+}
+
+// SyncConfig holds all configuration options related to syncing the chain.
+type SyncConfig struct {
+	// Checkpoints are trusted tipsets. Chains which do not contain them are
+	// refused, and a node without their ancestors syncs from the latest one
+	// by fetching its state instead of validating the chain below it.
+	Checkpoints []Checkpoint `json:"checkpoints"`
+}
+
+// Checkpoint is a trusted tipset and the root of the state resulting from it.
+type Checkpoint struct {
+	TipSet    types.SortedCidSet `json:"tipset"`
+	StateRoot cid.Cid            `json:"stateRoot"`
+}
+
+func newDefaultSyncConfig() *SyncConfig {
+	return &SyncConfig{
+		Checkpoints: []Checkpoint{},
+	}
+}
+
+// PaychConfig holds all configuration options related to payment channels.
+type PaychConfig struct {
+	// SettleGasPrice is the gas price of the messages closing and
+	// reclaiming payment channels around their Eol.
+	SettleGasPrice *types.AttoFIL `json:"settleGasPrice"`
+	// SettleGasLimit is the gas limit of those messages.
+	SettleGasLimit uint64 `json:"settleGasLimit"`
+}
+
+func newDefaultPaychConfig() *PaychConfig {
+	return &PaychConfig{
+		SettleGasPrice: types.NewZeroAttoFIL(),
+		SettleGasLimit: 300,
+	}
+}
+
 // NewDefaultConfig returns a config object with all the fields filled out to
 // their default values
 func NewDefaultConfig() *Config {
@@ -158,6 +246,10 @@ func NewDefaultConfig() *Config {
 		Mining:    newDefaultMiningConfig(),
 		Wallet:    newDefaultWalletConfig(),
 		Heartbeat: newDefaultHeartbeatConfig(),
+		Proofs:    newDefaultProofsConfig(),
+		GC:        newDefaultGCConfig(),
+		Sync:      newDefaultSyncConfig(),
+		Paych:     newDefaultPaychConfig(),
 	}
 }
 
@@ -290,3 +382,15 @@ func validateLettersOnly(key string, value string) error {
 	}
 	return nil
 }
+
+// validateProofsBackend validates that a given value names a proofs backend.
+func validateProofsBackend(key string, value string) error {
+	var backend string
+	if err := json.Unmarshal([]byte(value), &backend); err != nil {
+		return err
+	}
+	if backend != RustProofsBackend && backend != GoProofsBackend {
+		return errors.Errorf(`"%s" must be "%s" or "%s"`, key, RustProofsBackend, GoProofsBackend)
+	}
+	return nil
+}
@@ -66,6 +66,7 @@ func TestWriteFile(t *testing.T) {
 	"mining": {
 		"minerAddress": "",
 		"autoSealIntervalSeconds": 120,
+		"maxConcurrentSeals": 1,
 		"storagePrice": "0"
 	},
 	"wallet": {
@@ -76,6 +77,16 @@ func TestWriteFile(t *testing.T) {
 		"beatPeriod": "3s",
 		"reconnectPeriod": "10s",
 		"nickname": ""
+	},
+	"proofs": {
+		"backend": "rust"
+	},
+	"gc": {
+		"retention": 1000,
+		"period": "1h"
+	},
+	"sync": {
+		"checkpoints": []
 	}
 }`,
 		string(content),
@@ -97,6 +108,16 @@ func TestSetRejectsInvalidNicks(t *testing.T) {
 	assert.Error(err)
 }
 
+func TestSetRejectsUnknownProofsBackend(t *testing.T) {
+	assert := assert.New(t)
+	cfg := NewDefaultConfig()
+
+	assert.NoError(cfg.Set("proofs.backend", `"go"`))
+	assert.Equal(GoProofsBackend, cfg.Proofs.Backend)
+	assert.Error(cfg.Set("proofs.backend", `"java"`))
+	assert.Equal(GoProofsBackend, cfg.Proofs.Backend)
+}
+
 func TestConfigRoundtrip(t *testing.T) {
 	assert := assert.New(t)
 
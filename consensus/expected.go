@@ -22,6 +22,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/state"
 	"github.com/filecoin-project/go-filecoin/types"
 	"github.com/filecoin-project/go-filecoin/vm"
+	vmerrors "github.com/filecoin-project/go-filecoin/vm/errors"
 )
 
 var (
@@ -118,7 +119,11 @@ func (c *Expected) NewValidTipSet(ctx context.Context, blks []*types.Block) (typ
 			return nil, err
 		}
 	}
-	return types.NewTipSet(blks...)
+	ts, err := types.NewTipSet(blks...)
+	if err != nil {
+		return nil, NewInvalidError(err)
+	}
+	return ts, nil
 }
 
 // ValidateBlockStructure verifies that this block, on its own, is structurally and
@@ -131,12 +136,32 @@ func (c *Expected) validateBlockStructure(ctx context.Context, b *types.Block) e
 	ctx = log.Start(ctx, "Expected.validateBlockStructure")
 	log.LogKV(ctx, "ValidateBlockStructure", b.Cid().String())
 	if !b.StateRoot.Defined() {
-		return fmt.Errorf("block has nil StateRoot")
+		return NewInvalidError(fmt.Errorf("block has nil StateRoot"))
 	}
 
 	return nil
 }
 
+// ValidateBlock checks the structure of the block, the signatures of its
+// messages, and that its proof is valid for the challenge of its parent
+// tipset and its ticket is computed from its proof.
+func (c *Expected) ValidateBlock(ctx context.Context, blk *types.Block, parent types.TipSet) error {
+	if err := c.validateBlockStructure(ctx, blk); err != nil {
+		return err
+	}
+
+	for _, msg := range blk.Messages {
+		if !msg.IsBLSAggregated() && !msg.VerifySignature() {
+			return NewInvalidError(errors.Errorf("invalid signature on message from %s", msg.From))
+		}
+	}
+	if !types.VerifyBLSAggregate(blk.Messages, blk.BLSAggregateSignature) {
+		return NewInvalidError(errors.New("invalid aggregate BLS signature over block messages"))
+	}
+
+	return c.validateProof(blk, parent)
+}
+
 // Weight returns the EC weight of this TipSet in uint64 encoded fixed point
 // representation.
 func (c *Expected) Weight(ctx context.Context, ts types.TipSet, pSt state.Tree) (uint64, error) {
@@ -229,10 +254,11 @@ func (c *Expected) IsHeavier(ctx context.Context, a, b types.TipSet, aSt, bSt st
 
 // RunStateTransition is the chain transition function that goes from a
 // starting state and a tipset to a new state.  It errors if the tipset was not
-// mined according to the EC rules, or if running the messages in the tipset
-// results in an error.
+// mined with winning tickets, or if running the messages in the tipset
+// results in an error. The proofs and tickets of the blocks are checked by
+// ValidateBlock beforehand.
 func (c *Expected) RunStateTransition(ctx context.Context, ts types.TipSet, ancestors []types.TipSet, pSt state.Tree) (state.Tree, error) {
-	err := c.validateMining(ctx, pSt, ts, ancestors[0])
+	err := c.validateMining(ctx, pSt, ts)
 	if err != nil {
 		return nil, err
 	}
@@ -250,6 +276,8 @@ func (c *Expected) RunStateTransition(ctx context.Context, ts types.TipSet, ance
 		}
 	}
 
+	c.verifySeals(ctx, pSt, ts)
+
 	vms := vm.NewStorageMap(c.bstore)
 	st, err := c.runMessages(ctx, pSt, vms, ts, ancestors)
 	if err != nil {
@@ -262,51 +290,98 @@ func (c *Expected) RunStateTransition(ctx context.Context, ts types.TipSet, ance
 	return st, nil
 }
 
-// validateMining checks validity of the block ticket, proof, and miner address.
+// verifySeals verifies the seal proofs committed by messages in the tipset in
+// a single batch, if the verifier supports batches. The results are
+// discarded: the verifier is expected to cache them (see
+// proofs.CachingVerifier), so that the miner actor finds them in the cache
+// when the messages are processed. Failures are left for the actor to report.
+func (c *Expected) verifySeals(ctx context.Context, st state.Tree, ts types.TipSet) {
+	bv, ok := c.verifier.(proofs.SealBatchVerifier)
+	if !ok {
+		return
+	}
+
+	var reqs []proofs.VerifySealRequest
+	for _, blk := range ts.ToSlice() {
+		for _, msg := range blk.Messages {
+			if msg.Method != "commitSector" {
+				continue
+			}
+			act, err := st.GetActor(ctx, msg.To)
+			if err != nil || !act.Code.Equals(types.MinerActorCodeCid) {
+				continue
+			}
+			req, err := miner.CommitSectorVerificationRequest(&msg.Message)
+			if err != nil {
+				continue
+			}
+			reqs = append(reqs, req)
+		}
+	}
+
+	if len(reqs) == 0 {
+		return
+	}
+
+	if _, err := bv.VerifySeals(reqs); err != nil {
+		log.Warningf("failed to verify %d seal proofs in tipset %s: %s", len(reqs), ts.String(), err)
+	}
+}
+
+// validateProof checks the block proof and ticket.
 //    Returns an error if:
-//    	* any tipset's block was mined by an invalid miner address.
 //      * the block proof is invalid for the challenge
 //      * the block ticket is incorrectly computed
-//      * the block ticket fails the power check, i.e. is not a winning ticket
 //    Returns nil if all the above checks pass.
 // See https://github.com/filecoin-project/specs/blob/master/mining.md#chain-validation
-func (c *Expected) validateMining(ctx context.Context, st state.Tree, ts types.TipSet, parentTs types.TipSet) error {
-	for _, blk := range ts.ToSlice() {
-		parentHeight, err := parentTs.Height()
-		if err != nil {
-			return errors.Wrap(err, "failed to get parentHeight")
-		}
+func (c *Expected) validateProof(blk *types.Block, parentTs types.TipSet) error {
+	parentHeight, err := parentTs.Height()
+	if err != nil {
+		return errors.Wrap(err, "failed to get parentHeight")
+	}
 
-		nullBlockCount := uint64(blk.Height) - parentHeight - 1
-		challengeSeed, err := CreateChallengeSeed(parentTs, nullBlockCount)
-		if err != nil {
-			return errors.Wrap(err, "couldn't create challengeSeed")
-		}
+	nullBlockCount := uint64(blk.Height) - parentHeight - 1
+	challengeSeed, err := CreateChallengeSeed(parentTs, nullBlockCount)
+	if err != nil {
+		return errors.Wrap(err, "couldn't create challengeSeed")
+	}
 
-		isValid, err := proofs.IsPoStValidWithVerifier(c.verifier, []proofs.CommR{}, challengeSeed, []uint64{}, blk.Proof)
-		if err != nil {
-			return errors.Wrap(err, "could not test the proof's validity")
-		}
-		if !isValid {
-			return errors.New("invalid proof")
-		}
+	// TODO: pass the miner's commitments once block proofs are generated
+	// over its sectors (see createProof in the mining package).
+	isValid, err := proofs.IsPoStValidWithVerifier(c.verifier, []proofs.CommR{}, challengeSeed, []uint64{}, blk.Proof)
+	if err != nil {
+		return errors.Wrap(err, "could not test the proof's validity")
+	}
+	if !isValid {
+		return NewInvalidError(errors.New("invalid proof"))
+	}
 
-		computedTicket := CreateTicket(blk.Proof, blk.Miner)
+	computedTicket := CreateTicket(blk.Proof, blk.Miner)
 
-		if !bytes.Equal(blk.Ticket, computedTicket) {
-			return errors.New("ticket incorrectly computed")
-		}
+	if !bytes.Equal(blk.Ticket, computedTicket) {
+		return NewInvalidError(errors.New("ticket incorrectly computed"))
+	}
+
+	// TODO: Also need to validate BlockSig
 
-		// TODO: Also need to validate BlockSig
+	return nil
+}
 
-		// See https://github.com/filecoin-project/specs/blob/master/mining.md#ticket-checking
+// validateMining checks that the tickets of the tipset's blocks are winning.
+//    Returns an error if:
+//    	* any tipset's block was mined by an invalid miner address.
+//      * the block ticket fails the power check, i.e. is not a winning ticket
+//    Returns nil if all the above checks pass.
+// See https://github.com/filecoin-project/specs/blob/master/mining.md#ticket-checking
+func (c *Expected) validateMining(ctx context.Context, st state.Tree, ts types.TipSet) error {
+	for _, blk := range ts.ToSlice() {
 		result, err := IsWinningTicket(ctx, c.bstore, c.PwrTableView, st, blk.Ticket, blk.Miner)
 		if err != nil {
 			return errors.Wrap(err, "can't check for winning ticket")
 		}
 
 		if !result {
-			return errors.New("not a winning ticket")
+			return NewInvalidError(errors.New("not a winning ticket"))
 		}
 	}
 	return nil
@@ -399,11 +474,14 @@ func (c *Expected) runMessages(ctx context.Context, st state.Tree, vms vm.Storag
 
 		receipts, err := c.processor.ProcessBlock(ctx, cpySt, vms, blk, ancestors)
 		if err != nil {
+			if isInvalidBlockError(err) {
+				err = NewInvalidError(err)
+			}
 			return nil, errors.Wrap(err, "error validating block state")
 		}
 		// TODO: check that receipts actually match
 		if len(receipts) != len(blk.MessageReceipts) {
-			return nil, fmt.Errorf("found invalid message receipts: %v %v", receipts, blk.MessageReceipts)
+			return nil, NewInvalidError(fmt.Errorf("found invalid message receipts: %v %v", receipts, blk.MessageReceipts))
 		}
 
 		outCid, err := cpySt.Flush(ctx)
@@ -411,7 +489,7 @@ func (c *Expected) runMessages(ctx context.Context, st state.Tree, vms vm.Storag
 			return nil, errors.Wrap(err, "error validating block state")
 		}
 		if !outCid.Equals(blk.StateRoot) {
-			return nil, ErrStateRootMismatch
+			return nil, NewInvalidError(ErrStateRootMismatch)
 		}
 	}
 	if len(ts) == 1 { // block validation state == aggregate parent state
@@ -427,3 +505,10 @@ func (c *Expected) runMessages(ctx context.Context, st state.Tree, vms vm.Storag
 	}
 	return st, nil
 }
+
+// isInvalidBlockError returns true if err, returned by ProcessBlock, means
+// that the block has a message which should not have been included in it,
+// rather than that the node faulted processing it.
+func isInvalidBlockError(err error) bool {
+	return err == errInvalidBLSAggregate || vmerrors.IsApplyErrorPermanent(err) || vmerrors.IsApplyErrorTemporary(err)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/actor"
 	"github.com/filecoin-project/go-filecoin/actor/builtin"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/account"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/multisig"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/paymentbroker"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/storagemarket"
 	"github.com/filecoin-project/go-filecoin/address"
@@ -183,5 +184,15 @@ func SetupDefaultActors(ctx context.Context, st state.Tree, storageMap vm.Storag
 
 	pbAct.Balance = types.NewAttoFILFromFIL(0)
 
-	return st.SetActor(ctx, address.PaymentBrokerAddress, pbAct)
+	if err := st.SetActor(ctx, address.PaymentBrokerAddress, pbAct); err != nil {
+		return err
+	}
+
+	msAct := multisig.NewActor()
+	err = (&multisig.Actor{}).InitializeState(storageMap.NewStorage(address.MultisigAddress, msAct), nil)
+	if err != nil {
+		return err
+	}
+
+	return st.SetActor(ctx, address.MultisigAddress, msAct)
 }
@@ -0,0 +1,44 @@
+package consensus
+
+// InvalidError is an error validating blocks which is caused by the contents
+// of the blocks, so that any node would reject them, as opposed to a failure
+// of the node validating them such as a storage error or a cancelled context.
+type InvalidError struct {
+	err error
+}
+
+// NewInvalidError marks err as caused by the contents of the blocks being
+// validated.
+func NewInvalidError(err error) error {
+	return &InvalidError{err: err}
+}
+
+func (e *InvalidError) Error() string {
+	return e.err.Error()
+}
+
+// Cause returns the underlying error, see errors.Cause.
+func (e *InvalidError) Cause() error {
+	return e.err
+}
+
+type causer interface {
+	Cause() error
+}
+
+// IsInvalid returns true if err, returned by the methods of a Protocol
+// validating blocks, means that the blocks are invalid rather than that they
+// could not be validated.
+func IsInvalid(err error) bool {
+	for err != nil {
+		if _, ok := err.(*InvalidError); ok {
+			return true
+		}
+		c, ok := err.(causer)
+		if !ok {
+			return false
+		}
+		err = c.Cause()
+	}
+	return false
+}
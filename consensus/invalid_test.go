@@ -0,0 +1,22 @@
+package consensus_test
+
+import (
+	"testing"
+
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+
+	"github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	err := consensus.NewInvalidError(consensus.ErrStateRootMismatch)
+	assert.True(consensus.IsInvalid(err))
+	assert.True(consensus.IsInvalid(errors.Wrap(err, "error validating block state")))
+	assert.Equal(consensus.ErrStateRootMismatch, errors.Cause(err))
+
+	assert.False(consensus.IsInvalid(errors.New("could not test the proof's validity")))
+	assert.False(consensus.IsInvalid(nil))
+}
@@ -5,9 +5,12 @@ import (
 	"math/big"
 	"time"
 
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+
 	"github.com/filecoin-project/go-filecoin/actor"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/account"
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/exec"
 	"github.com/filecoin-project/go-filecoin/state"
 	"github.com/filecoin-project/go-filecoin/types"
 	"github.com/filecoin-project/go-filecoin/vm"
@@ -52,6 +55,9 @@ type ProcessTipSetResponse struct {
 type DefaultProcessor struct {
 	signedMessageValidator SignedMessageValidator
 	blockRewarder          BlockRewarder
+	// builtinActors, if set, execute messages instead of the builtin
+	// actors of the state trees messages are applied to.
+	builtinActors map[cid.Cid]exec.ExecutableActor
 }
 
 var _ Processor = (*DefaultProcessor)(nil)
@@ -72,6 +78,17 @@ func NewConfiguredProcessor(validator SignedMessageValidator, rewarder BlockRewa
 	}
 }
 
+// NewProcessorWithActors creates a processor with custom validation and
+// rewards which executes messages with the provided builtin actors, e.g. to
+// check proofs with a particular verifier (see builtin.ActorsWithVerifier).
+func NewProcessorWithActors(validator SignedMessageValidator, rewarder BlockRewarder, builtinActors map[cid.Cid]exec.ExecutableActor) *DefaultProcessor {
+	return &DefaultProcessor{
+		signedMessageValidator: validator,
+		blockRewarder:          rewarder,
+		builtinActors:          builtinActors,
+	}
+}
+
 // ProcessBlock is the entrypoint for validating the state transitions
 // of the messages in a block. When we receive a new block from the
 // network ProcessBlock applies the block's messages to the beginning
@@ -107,6 +124,12 @@ func (p *DefaultProcessor) ProcessBlock(ctx context.Context, st state.Tree, vms
 		log.Infof("[TIMER] DefaultProcessor.ProcessBlock BlkCID: %s - elapsed time: %s", blk.Cid(), time.Since(processBlkTimer).Round(time.Millisecond))
 	}()
 
+	// The signatures of aggregated BLS messages are only verified here, the
+	// message validator accepts them without.
+	if !types.VerifyBLSAggregate(blk.Messages, blk.BLSAggregateSignature) {
+		return emptyResults, errInvalidBLSAggregate
+	}
+
 	bh := types.NewBlockHeight(uint64(blk.Height))
 	res, faultErr := p.ApplyMessagesAndPayRewards(ctx, st, vms, blk.Messages, blk.Miner, bh, ancestors)
 	if faultErr != nil {
@@ -267,6 +290,9 @@ func (p *DefaultProcessor) ApplyMessage(ctx context.Context, st state.Tree, vms
 		log.Infof("[TIMER] DefaultProcessor.ApplyMessage CID: %s - elapsed time: %s", msgCid.String(), time.Since(applyMsgTimer).Round(time.Millisecond))
 	}()
 
+	if p.builtinActors != nil {
+		st = state.WithBuiltinActors(st, p.builtinActors)
+	}
 	cachedStateTree := state.NewCachedStateTree(st)
 
 	r, err := p.attemptApplyMessage(ctx, cachedStateTree, vms, msg, bh, gasTracker, ancestors)
@@ -333,6 +359,10 @@ var (
 	errSelfSend = errors.NewRevertError("cannot send to self")
 )
 
+// errInvalidBLSAggregate is returned by ProcessBlock when the aggregate BLS
+// signature of a block does not match its messages.
+var errInvalidBLSAggregate = errors.NewRevertError("invalid aggregate BLS signature over block messages")
+
 // CallQueryMethod calls a method on an actor in the given state tree. It does
 // not make any changes to the state/blockchain and is useful for interrogating
 // actor state. Block height bh is optional; some methods will ignore it.
@@ -560,7 +590,9 @@ var _ SignedMessageValidator = (*DefaultMessageValidator)(nil)
 
 // Validate validates that the given message is ready to be processed.
 func (nmv *DefaultMessageValidator) Validate(ctx context.Context, msg *types.SignedMessage, fromActor *actor.Actor) error {
-	if !msg.VerifySignature() {
+	// The signature of an aggregated BLS message is part of the aggregate
+	// signature of its block, which ProcessBlock verifies.
+	if !msg.IsBLSAggregated() && !msg.VerifySignature() {
 		return errInvalidSignature
 	}
 
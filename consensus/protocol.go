@@ -21,6 +21,9 @@ import (
 // the system and the implementation level. The method set is not necessarily
 // the most theoretically obvious or pleasing and should not be considered
 // finalized.
+//
+// The methods validating blocks tell the blocks being invalid from failing
+// to validate them: IsInvalid returns true only for the errors of the former.
 type Protocol interface {
 	// NewValidTipSet returns a TipSet wrapping the input blks if they form a valid tipset.
 	// Here valid means that blocks are not obviously malformed, and that all blocks have
@@ -28,12 +31,19 @@ type Protocol interface {
 	// check if a tipset constitutes a valid state transition or that its
 	// blocks were mined according to protocol rules (RunStateTransition does these checks).
 	NewValidTipSet(ctx context.Context, blks []*types.Block) (types.TipSet, error)
+	// ValidateBlock checks everything about blk that does not depend on the
+	// state of its parent: its structure, the signatures of its messages and
+	// its proof and ticket given its parent tipset. It is safe to call
+	// concurrently, so that the blocks of a chain can be validated in
+	// parallel before their state transitions are run one after another.
+	ValidateBlock(ctx context.Context, blk *types.Block, parent types.TipSet) error
 	// Weight returns the weight given to the input ts by this consensus protocol.
 	Weight(ctx context.Context, ts types.TipSet, pSt state.Tree) (uint64, error)
 	// IsHeaver returns 1 if tipset a is heavier than tipset b and -1 if
 	// tipset b is heavier than tipset a.
 	IsHeavier(ctx context.Context, a, b types.TipSet, aSt, bSt state.Tree) (bool, error)
 	// RunStateTransition returns the state resulting from applying the input ts to the parent
-	// state pSt.  It returns an error if the transition is invalid.
+	// state pSt.  It returns an error if the transition is invalid. The blocks of ts must
+	// have been checked with ValidateBlock.
 	RunStateTransition(ctx context.Context, ts types.TipSet, ancestors []types.TipSet, pSt state.Tree) (state.Tree, error)
 }
@@ -70,6 +70,7 @@ type VMContext interface {
 	Message() *types.Message
 	Storage() Storage
 	Send(to address.Address, method string, value *types.AttoFIL, params []interface{}) ([][]byte, uint8, error)
+	Query(to address.Address, method string, params []interface{}) ([][]byte, uint8, error)
 	AddressForNewActor() (address.Address, error)
 	BlockHeight() *types.BlockHeight
 	IsFromAccountActor() bool
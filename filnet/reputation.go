@@ -0,0 +1,194 @@
+package filnet
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	ma "gx/ipfs/QmNTCey11oxhb1AxDnQBRHtdhap6Ctud872NjAYPYYXPuc/go-multiaddr"
+	inet "gx/ipfs/QmNgLg1NTw37iWbYPKcyK85YJ9Whs1MkPtJwhfqbNYAyKg/go-libp2p-net"
+	peer "gx/ipfs/QmY5Grm8pJdiSSVsYxx4uNRgweY72EmYwuSDbRnbFok3iY/go-libp2p-peer"
+	host "gx/ipfs/QmaoXrM4Z41PD48JY36YqQGKQpLGjyLA2cKcLsES7YddAq/go-libp2p-host"
+)
+
+// Penalties subtracted from the score of a peer for its misbehaviour.
+const (
+	// PenaltyInvalidBlock is the penalty for sending blocks which fail
+	// validation, or a chain containing a known bad tipset.
+	PenaltyInvalidBlock = 50
+	// PenaltyInvalidMessage is the penalty for sending blocks whose
+	// messages fail to apply.
+	PenaltyInvalidMessage = 25
+	// PenaltyTimeout is the penalty for announcing blocks which could not be
+	// fetched in time. It is small, as the node itself may be to blame.
+	PenaltyTimeout = 10
+)
+
+// BanThreshold is the score below which a peer is disconnected and banned.
+const BanThreshold = -100
+
+// BanDuration is the time a peer stays banned for.
+const BanDuration = time.Hour
+
+// ScoreHalfLife is the time it takes the score of a peer to decay halfway
+// back to zero.
+const ScoreHalfLife = 10 * time.Minute
+
+// PeerScore is the reputation of a peer.
+type PeerScore struct {
+	Peer   peer.ID
+	Score  float64
+	Banned bool
+}
+
+type peerScore struct {
+	score   float64
+	updated time.Time
+}
+
+// Reputation scores peers by their misbehaviour, and disconnects and bans
+// the peers whose score falls below BanThreshold. Scores decay back to zero
+// over time, so that peers recover from occasional faults. Banned peers are
+// disconnected as soon as they connect again, until their ban expires.
+type Reputation struct {
+	host host.Host
+
+	mu     sync.Mutex
+	scores map[peer.ID]*peerScore
+	banned map[peer.ID]time.Time
+
+	// now returns the current time, it is replaced in tests.
+	now func() time.Time
+}
+
+// NewReputation returns a Reputation banning peers from the given host.
+func NewReputation(h host.Host) *Reputation {
+	r := &Reputation{
+		host:   h,
+		scores: make(map[peer.ID]*peerScore),
+		banned: make(map[peer.ID]time.Time),
+		now:    time.Now,
+	}
+	h.Network().Notify((*reputationNotify)(r))
+	return r
+}
+
+// Penalize subtracts penalty from the score of peer p for the given reason,
+// and bans p if its score falls below BanThreshold.
+func (r *Reputation) Penalize(p peer.ID, penalty float64, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	s, ok := r.scores[p]
+	if !ok {
+		s = &peerScore{updated: now}
+		r.scores[p] = s
+	}
+	s.decay(now)
+	s.score -= penalty
+	log.Infof("penalized peer %s by %.0f for %s, its score is %.1f", p.Pretty(), penalty, reason, s.score)
+
+	if s.score < BanThreshold {
+		log.Warningf("banning peer %s for %s", p.Pretty(), BanDuration)
+		r.banned[p] = now.Add(BanDuration)
+		delete(r.scores, p)
+		go r.disconnect(p)
+	}
+}
+
+// IsBanned returns true if peer p is banned.
+func (r *Reputation) IsBanned(p peer.ID) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.isBanned(p, r.now())
+}
+
+// Score returns the current score of peer p.
+func (r *Reputation) Score(p peer.ID) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.scores[p]
+	if !ok {
+		return 0
+	}
+	s.decay(r.now())
+	return s.score
+}
+
+// Scores returns the scores of the peers which were penalized or are
+// banned, lowest first.
+func (r *Reputation) Scores() []PeerScore {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	var scores []PeerScore
+	for p, s := range r.scores {
+		s.decay(now)
+		if s.score == 0 {
+			// The peer recovered, it is no longer tracked.
+			delete(r.scores, p)
+			continue
+		}
+		scores = append(scores, PeerScore{Peer: p, Score: s.score})
+	}
+	for p := range r.banned {
+		if r.isBanned(p, now) {
+			scores = append(scores, PeerScore{Peer: p, Score: BanThreshold, Banned: true})
+		}
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		return scores[i].Score < scores[j].Score
+	})
+	return scores
+}
+
+// decay decays the score to now. Scores within one of zero are rounded to
+// zero. Precondition: the caller holds the lock of the Reputation.
+func (s *peerScore) decay(now time.Time) {
+	s.score *= math.Pow(0.5, float64(now.Sub(s.updated))/float64(ScoreHalfLife))
+	s.updated = now
+	if s.score > -1 {
+		s.score = 0
+	}
+}
+
+// isBanned returns true if peer p is banned at now, forgetting expired bans.
+// Precondition: the caller holds r.mu.
+func (r *Reputation) isBanned(p peer.ID, now time.Time) bool {
+	until, ok := r.banned[p]
+	if !ok {
+		return false
+	}
+	if now.After(until) {
+		delete(r.banned, p)
+		return false
+	}
+	return true
+}
+
+func (r *Reputation) disconnect(p peer.ID) {
+	if err := r.host.Network().ClosePeer(p); err != nil {
+		log.Warningf("failed to disconnect banned peer %s: %s", p.Pretty(), err)
+	}
+}
+
+// Connection notifications, which disconnect banned peers
+
+type reputationNotify Reputation
+
+func (rn *reputationNotify) Connected(n inet.Network, c inet.Conn) {
+	r := (*Reputation)(rn)
+	if p := c.RemotePeer(); r.IsBanned(p) {
+		go r.disconnect(p)
+	}
+}
+
+func (rn *reputationNotify) Listen(n inet.Network, a ma.Multiaddr)      {}
+func (rn *reputationNotify) ListenClose(n inet.Network, a ma.Multiaddr) {}
+func (rn *reputationNotify) Disconnected(n inet.Network, c inet.Conn)   {}
+func (rn *reputationNotify) OpenedStream(n inet.Network, s inet.Stream) {}
+func (rn *reputationNotify) ClosedStream(n inet.Network, s inet.Stream) {}
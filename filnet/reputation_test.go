@@ -0,0 +1,92 @@
+package filnet
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gx/ipfs/QmY5Grm8pJdiSSVsYxx4uNRgweY72EmYwuSDbRnbFok3iY/go-libp2p-peer"
+	"gx/ipfs/QmYxivS34F2M2n44WQQnRHGAKS8aoRUxwGpi9wk4Cdn4Jf/go-libp2p/p2p/net/mock"
+	"gx/ipfs/QmaoXrM4Z41PD48JY36YqQGKQpLGjyLA2cKcLsES7YddAq/go-libp2p-host"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReputationBansPeerBelowThreshold(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn, err := mocknet.WithNPeers(ctx, 2)
+	require.NoError(err)
+	require.NoError(mn.LinkAll())
+	require.NoError(mn.ConnectAllButSelf())
+
+	a, b := mn.Hosts()[0], mn.Hosts()[1]
+	r := NewReputation(a)
+
+	r.Penalize(b.ID(), PenaltyInvalidBlock, "invalid block")
+	assert.Equal(float64(-PenaltyInvalidBlock), r.Score(b.ID()))
+	assert.False(r.IsBanned(b.ID()))
+
+	r.Penalize(b.ID(), PenaltyInvalidBlock, "invalid block")
+	r.Penalize(b.ID(), PenaltyInvalidBlock, "invalid block")
+	assert.True(r.IsBanned(b.ID()))
+	assert.Equal([]PeerScore{{Peer: b.ID(), Score: BanThreshold, Banned: true}}, r.Scores())
+
+	assert.True(disconnected(a, b.ID()))
+
+	// The banned peer is disconnected again when it reconnects.
+	_, err = mn.ConnectPeers(b.ID(), a.ID())
+	require.NoError(err)
+	assert.True(disconnected(a, b.ID()))
+}
+
+func TestReputationDecay(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn, err := mocknet.WithNPeers(ctx, 2)
+	require.NoError(err)
+
+	a, b := mn.Hosts()[0], mn.Hosts()[1]
+	r := NewReputation(a)
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	r.Penalize(b.ID(), 80, "test")
+	now = now.Add(ScoreHalfLife)
+	assert.Equal(-40.0, r.Score(b.ID()))
+
+	// Penalties add up to the decayed score.
+	r.Penalize(b.ID(), 40, "test")
+	assert.Equal(-80.0, r.Score(b.ID()))
+	assert.False(r.IsBanned(b.ID()))
+
+	r.Penalize(b.ID(), 40, "test")
+	assert.True(r.IsBanned(b.ID()))
+
+	// Bans expire.
+	now = now.Add(BanDuration + time.Second)
+	assert.False(r.IsBanned(b.ID()))
+	assert.Empty(r.Scores())
+}
+
+// disconnected waits for host h to have no connection to peer p.
+func disconnected(h host.Host, p peer.ID) bool {
+	for i := 0; i < 10; i++ {
+		if len(h.Network().ConnsToPeer(p)) == 0 {
+			return true
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return false
+}
@@ -0,0 +1,279 @@
+package gc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	cbor "gx/ipfs/QmRoARq3nkUb13HSKZGepCZSWe5GrVPwx7xURJGZ7KWv9V/go-ipld-cbor"
+	bstore "gx/ipfs/QmS2aqUZLJp8kF1ihE5rvDGE5LvmKDPnx32w9Z1BW9xLV5/go-ipfs-blockstore"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+	logging "gx/ipfs/QmcuXC5cxs79ro2cUuHs4HQ2bkDLJUYokwL8aivcX6HW3C/go-log"
+
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/repo"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+var log = logging.Logger("gc")
+
+// chainStore is the subset of the chain store the collector reads retained
+// state roots from and prunes forks of.
+type chainStore interface {
+	Head() types.TipSet
+	GenesisCid() cid.Cid
+	GetTipSetAndState(ctx context.Context, tsKey string) (*chain.TipSetAndState, error)
+	StateRootsSince(h uint64) ([]cid.Cid, error)
+	CheckpointStateRoots() ([]cid.Cid, error)
+	IndexedBlocks() ([]cid.Cid, error)
+	PruneForks(ctx context.Context, h uint64) (int, error)
+}
+
+// valueLogCollector is implemented by datastores, such as badger, which only
+// reclaim the disk space of deleted entries when asked to.
+type valueLogCollector interface {
+	CollectGarbage() error
+}
+
+// Result describes what a garbage collection removed.
+type Result struct {
+	// BlocksRemoved is the number of blocks deleted from the blockstore.
+	BlocksRemoved int `json:"blocksRemoved"`
+	// TipSetsPruned is the number of fork tipsets deleted from the chain store.
+	TipSetsPruned int `json:"tipSetsPruned"`
+}
+
+// Collector removes chain and state data the node no longer needs. It prunes
+// the forks whose tipsets are all below the last Retention heights from the
+// chain store, then keeps the full state of every remaining tipset of these
+// heights, on the chain of the head as well as on forks, and the states of
+// the genesis tipset and of the checkpoints the chain was synced from. Blocks of the blockstore which are neither reachable from these
+// state roots nor blocks of an indexed tipset, which peers may fetch to
+// sync, are deleted.
+//
+// Only dag-cbor blocks are collected, so that data such as imported client
+// files, which shares the blockstore, is never removed. Writers of state
+// must hold a pin lock of the blockstore until the state they write is
+// referenced from the chain store, see bstore.GCLocker. The GC lock is only
+// held while forks are pruned and while blocks are swept: live blocks are
+// marked without it, then the roots added meanwhile are marked under it. To
+// stop a Collector cancel the context passed in Start() or call Stop().
+type Collector struct {
+	// Retention is the number of heights below the head whose state is kept.
+	Retention uint64
+	// Period is the interval at which Start collects garbage.
+	Period time.Duration
+
+	chain chainStore
+	bs    bstore.GCBlockstore
+	ds    repo.Datastore
+
+	// mu ensures at most one collection runs at any time.
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+// NewCollector returns a Collector removing state from bs and forks from
+// chainStore. ds is the datastore backing bs; if it supports it, it is asked
+// to reclaim the space of the removed blocks.
+func NewCollector(chainStore chainStore, bs bstore.GCBlockstore, ds repo.Datastore, retention uint64, period time.Duration) *Collector {
+	return &Collector{
+		Retention: retention,
+		Period:    period,
+		chain:     chainStore,
+		bs:        bs,
+		ds:        ds,
+	}
+}
+
+// Start collects garbage every Period. Cancel `ctx` or call Stop() to stop it.
+func (c *Collector) Start(ctx context.Context) {
+	ctx, c.cancel = context.WithCancel(ctx)
+	ticker := time.NewTicker(c.Period)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				res, err := c.Collect(ctx)
+				if err != nil {
+					log.Errorf("garbage collection failed: %s", err)
+					continue
+				}
+				log.Infof("garbage collection removed %d blocks and %d tipsets", res.BlocksRemoved, res.TipSetsPruned)
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic collection.
+func (c *Collector) Stop() {
+	if c.cancel != nil {
+		c.cancel()
+	}
+}
+
+// Collect runs one garbage collection.
+func (c *Collector) Collect(ctx context.Context) (*Result, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	head := c.chain.Head()
+	if len(head) == 0 {
+		return nil, errors.New("cannot collect garbage without a head")
+	}
+	h, err := head.Height()
+	if err != nil {
+		return nil, err
+	}
+	var minHeight uint64
+	if h > c.Retention {
+		minHeight = h - c.Retention
+	}
+
+	// Holding the GC lock keeps the syncer from adding tipsets while forks
+	// are pruned.
+	unlocker := c.bs.GCLock()
+	pruned, err := c.chain.PruneForks(ctx, minHeight)
+	unlocker.Unlock()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to prune forks")
+	}
+
+	live := cid.NewSet()
+	if err := c.markRetained(ctx, minHeight, live); err != nil {
+		return nil, err
+	}
+
+	// Tipsets added since marking are referenced from the chain store by
+	// now, as writers of state hold a pin lock until they are. Their state
+	// is marked before sweeping, mostly visiting blocks already marked.
+	unlocker = c.bs.GCLock()
+	defer unlocker.Unlock()
+
+	if err := c.markRetained(ctx, minHeight, live); err != nil {
+		return nil, err
+	}
+	removed, err := c.sweep(ctx, live)
+	if err != nil {
+		return nil, err
+	}
+
+	if vlc, ok := c.ds.(valueLogCollector); ok && removed > 0 {
+		if err := vlc.CollectGarbage(); err != nil {
+			return nil, errors.Wrap(err, "failed to reclaim datastore space")
+		}
+	}
+
+	return &Result{BlocksRemoved: removed, TipSetsPruned: pruned}, nil
+}
+
+// markRetained adds the indexed blocks and the blocks of the states retained
+// for minHeight to live.
+func (c *Collector) markRetained(ctx context.Context, minHeight uint64, live *cid.Set) error {
+	blks, err := c.chain.IndexedBlocks()
+	if err != nil {
+		return errors.Wrap(err, "failed to read indexed blocks")
+	}
+	for _, blk := range blks {
+		// The links of blocks are not followed, the state of old blocks is
+		// not retained.
+		live.Add(blk)
+	}
+
+	roots, err := c.retainedStateRoots(ctx, minHeight)
+	if err != nil {
+		return err
+	}
+	for _, root := range roots {
+		if err := c.mark(root, live); err != nil {
+			return errors.Wrapf(err, "failed to mark state %s", root)
+		}
+	}
+	return nil
+}
+
+// retainedStateRoots returns the state roots of the tipsets at minHeight and
+// above, of the checkpoints, and of the genesis tipset.
+func (c *Collector) retainedStateRoots(ctx context.Context, minHeight uint64) ([]cid.Cid, error) {
+	roots, err := c.chain.StateRootsSince(minHeight)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read retained state roots")
+	}
+
+	checkpoints, err := c.chain.CheckpointStateRoots()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read checkpoint state roots")
+	}
+	roots = append(roots, checkpoints...)
+
+	genesis, err := c.chain.GetTipSetAndState(ctx, types.NewSortedCidSet(c.chain.GenesisCid()).String())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read genesis state root")
+	}
+	return append(roots, genesis.TipSetStateRoot), nil
+}
+
+// mark adds the cids of all blocks reachable from root to live. Links to
+// blocks which are not in the blockstore, such as the cids of builtin actor
+// code, are not followed.
+func (c *Collector) mark(root cid.Cid, live *cid.Set) error {
+	if !live.Visit(root) {
+		return nil
+	}
+	if root.Type() != cid.DagCBOR {
+		return nil
+	}
+
+	blk, err := c.bs.Get(root)
+	if err == bstore.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	nd, err := cbor.DecodeBlock(blk)
+	if err != nil {
+		return err
+	}
+	for _, link := range nd.Links() {
+		if err := c.mark(link.Cid, live); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sweep deletes the dag-cbor blocks which are not live and returns how many
+// it deleted.
+func (c *Collector) sweep(ctx context.Context, live *cid.Set) (int, error) {
+	keys, err := c.bs.AllKeysChan(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to list blocks")
+	}
+
+	// Deleting is left until the listing is done, as not every datastore
+	// supports deleting while iterating.
+	var dead []cid.Cid
+	for k := range keys {
+		if k.Type() == cid.DagCBOR && !live.Has(k) {
+			dead = append(dead, k)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, k := range dead {
+		if err := c.bs.DeleteBlock(k); err != nil {
+			return 0, errors.Wrapf(err, "failed to delete block %s", k)
+		}
+	}
+	return len(dead), nil
+}
@@ -0,0 +1,204 @@
+package gc
+
+import (
+	"context"
+	"testing"
+
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	cbor "gx/ipfs/QmRoARq3nkUb13HSKZGepCZSWe5GrVPwx7xURJGZ7KWv9V/go-ipld-cbor"
+	bstore "gx/ipfs/QmS2aqUZLJp8kF1ihE5rvDGE5LvmKDPnx32w9Z1BW9xLV5/go-ipfs-blockstore"
+	blocks "gx/ipfs/QmWoXtvgC8inqFkAATB7cp2Dax7XBi9VDvSg9RCCZufmRk/go-block-format"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/repo"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// fakeChain serves state roots by height and records the height forks are
+// pruned below.
+type fakeChain struct {
+	head            types.TipSet
+	genesis         *types.Block
+	genesisRoot     cid.Cid
+	roots           map[uint64]cid.Cid
+	checkpointRoots []cid.Cid
+	prunedBelow     uint64
+	// onRootsRead, if set, is called once after state roots are first read.
+	onRootsRead func()
+}
+
+func (c *fakeChain) Head() types.TipSet {
+	return c.head
+}
+
+func (c *fakeChain) GenesisCid() cid.Cid {
+	return c.genesis.Cid()
+}
+
+func (c *fakeChain) GetTipSetAndState(ctx context.Context, tsKey string) (*chain.TipSetAndState, error) {
+	if tsKey != types.NewSortedCidSet(c.genesis.Cid()).String() {
+		return nil, chain.ErrNotFound
+	}
+	ts, err := types.NewTipSet(c.genesis)
+	if err != nil {
+		return nil, err
+	}
+	return &chain.TipSetAndState{
+		TipSet:          ts,
+		TipSetStateRoot: c.genesisRoot,
+	}, nil
+}
+
+func (c *fakeChain) StateRootsSince(h uint64) ([]cid.Cid, error) {
+	var roots []cid.Cid
+	for height, root := range c.roots {
+		if height >= h {
+			roots = append(roots, root)
+		}
+	}
+	if c.onRootsRead != nil {
+		onRootsRead := c.onRootsRead
+		c.onRootsRead = nil
+		onRootsRead()
+	}
+	return roots, nil
+}
+
+func (c *fakeChain) CheckpointStateRoots() ([]cid.Cid, error) {
+	return c.checkpointRoots, nil
+}
+
+func (c *fakeChain) IndexedBlocks() ([]cid.Cid, error) {
+	return []cid.Cid{c.head.ToSlice()[0].Cid()}, nil
+}
+
+func (c *fakeChain) PruneForks(ctx context.Context, h uint64) (int, error) {
+	c.prunedBelow = h
+	return 1, nil
+}
+
+func putObject(require *require.Assertions, bs bstore.Blockstore, obj interface{}) cid.Cid {
+	nd, err := cbor.WrapObject(obj, types.DefaultHashFunction, -1)
+	require.NoError(err)
+	require.NoError(bs.Put(nd))
+	return nd.Cid()
+}
+
+func TestCollect(t *testing.T) {
+	ctx := context.Background()
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ds := repo.NewInMemoryRepo().Datastore()
+	bs := bstore.NewGCBlockstore(bstore.NewBlockstore(ds), bstore.NewGCLocker())
+
+	shared := putObject(require, bs, map[string]string{"chunk": "shared"})
+	recentRoot := putObject(require, bs, map[string]interface{}{"chunk": shared})
+	oldChunk := putObject(require, bs, map[string]string{"chunk": "old"})
+	oldRoot := putObject(require, bs, map[string]interface{}{"chunks": []cid.Cid{shared, oldChunk}})
+	genesisChunk := putObject(require, bs, map[string]string{"chunk": "genesis"})
+	genesisRoot := putObject(require, bs, map[string]interface{}{"chunk": genesisChunk})
+	checkpointChunk := putObject(require, bs, map[string]string{"chunk": "checkpoint"})
+	checkpointRoot := putObject(require, bs, map[string]interface{}{"chunk": checkpointChunk})
+	orphan := putObject(require, bs, map[string]string{"chunk": "orphan"})
+
+	raw := blocks.NewBlock([]byte("client data"))
+	require.NoError(bs.Put(raw))
+
+	headBlk := &types.Block{Height: 10, StateRoot: recentRoot}
+	require.NoError(bs.Put(headBlk.ToNode()))
+
+	fc := &fakeChain{
+		head:            types.RequireNewTipSet(require, headBlk),
+		genesis:         &types.Block{},
+		genesisRoot:     genesisRoot,
+		roots:           map[uint64]cid.Cid{9: recentRoot, 5: oldRoot},
+		checkpointRoots: []cid.Cid{checkpointRoot},
+	}
+
+	collector := NewCollector(fc, bs, ds, 2, 0)
+	res, err := collector.Collect(ctx)
+	require.NoError(err)
+	assert.Equal(3, res.BlocksRemoved)
+	assert.Equal(1, res.TipSetsPruned)
+	assert.Equal(uint64(8), fc.prunedBelow)
+
+	for _, c := range []cid.Cid{shared, recentRoot, genesisChunk, genesisRoot, checkpointChunk, checkpointRoot, raw.Cid(), headBlk.Cid()} {
+		has, err := bs.Has(c)
+		require.NoError(err)
+		assert.True(has, "%s should be kept", c)
+	}
+	for _, c := range []cid.Cid{oldChunk, oldRoot, orphan} {
+		has, err := bs.Has(c)
+		require.NoError(err)
+		assert.False(has, "%s should be removed", c)
+	}
+
+	t.Log("a second collection finds nothing more to remove")
+	res, err = collector.Collect(ctx)
+	require.NoError(err)
+	assert.Equal(0, res.BlocksRemoved)
+}
+
+func TestCollectRetainsEverythingOnShortChains(t *testing.T) {
+	ctx := context.Background()
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ds := repo.NewInMemoryRepo().Datastore()
+	bs := bstore.NewGCBlockstore(bstore.NewBlockstore(ds), bstore.NewGCLocker())
+
+	root := putObject(require, bs, map[string]string{"chunk": "state"})
+	headBlk := &types.Block{Height: 3, StateRoot: root}
+	fc := &fakeChain{
+		head:        types.RequireNewTipSet(require, headBlk),
+		genesis:     &types.Block{},
+		genesisRoot: root,
+		roots:       map[uint64]cid.Cid{0: root},
+	}
+
+	res, err := NewCollector(fc, bs, ds, 10, 0).Collect(ctx)
+	require.NoError(err)
+	assert.Equal(0, res.BlocksRemoved)
+	assert.Equal(uint64(0), fc.prunedBelow)
+}
+
+func TestCollectRetainsStateAddedWhileMarking(t *testing.T) {
+	ctx := context.Background()
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ds := repo.NewInMemoryRepo().Datastore()
+	bs := bstore.NewGCBlockstore(bstore.NewBlockstore(ds), bstore.NewGCLocker())
+
+	root := putObject(require, bs, map[string]string{"chunk": "state"})
+	headBlk := &types.Block{Height: 10, StateRoot: root}
+	fc := &fakeChain{
+		head:        types.RequireNewTipSet(require, headBlk),
+		genesis:     &types.Block{},
+		genesisRoot: root,
+		roots:       map[uint64]cid.Cid{10: root},
+	}
+
+	// A writer stores a new state while live blocks are marked, which it
+	// could not do if the GC lock was held.
+	var newChunk, newRoot cid.Cid
+	fc.onRootsRead = func() {
+		defer bs.PinLock().Unlock()
+		newChunk = putObject(require, bs, map[string]string{"chunk": "new"})
+		newRoot = putObject(require, bs, map[string]interface{}{"chunk": newChunk})
+		fc.roots[11] = newRoot
+	}
+
+	res, err := NewCollector(fc, bs, ds, 2, 0).Collect(ctx)
+	require.NoError(err)
+	assert.Equal(0, res.BlocksRemoved)
+	for _, c := range []cid.Cid{newChunk, newRoot} {
+		has, err := bs.Has(c)
+		require.NoError(err)
+		assert.True(has, "%s should be kept", c)
+	}
+}
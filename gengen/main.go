@@ -48,6 +48,14 @@ $ cat setup.json
 			"owner": 1,
 			"power": 1000
 		}
+	],
+	"vestingAccounts": [
+		{
+			"owner": 2,
+			"value": "1000",
+			"startHeight": 0,
+			"endHeight": 10000
+		}
 	]
 }
 $ cat setup.json | gengen > genesis.car
@@ -120,6 +128,9 @@ func main() {
 	for _, m := range info.Miners {
 		fmt.Fprintf(os.Stderr, "created miner %s, owned by %d, power = %d\n", m.Address, m.Owner, m.Power) // nolint: errcheck
 	}
+	for _, v := range info.VestingAccounts {
+		fmt.Fprintf(os.Stderr, "created vesting account %s, owned by %d\n", v.Address, v.Owner) // nolint: errcheck
+	}
 }
 
 func readConfig(filePath string) (*gengen.GenesisCfg, error) {
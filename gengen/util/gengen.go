@@ -11,6 +11,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/actor"
 	"github.com/filecoin-project/go-filecoin/actor/builtin"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/account"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/vesting"
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/crypto"
@@ -47,6 +48,23 @@ type Miner struct {
 	Power uint64
 }
 
+// VestingAccount is a vesting account actor to create in genesis. Its value
+// is released to its owner linearly between StartHeight and EndHeight.
+type VestingAccount struct {
+	// Owner is the name of the key that may spend the vested funds
+	// It must be a name of a key from the configs 'Keys' list
+	Owner int
+
+	// Value is the string value of whole filecoin the account holds
+	Value string
+
+	// StartHeight is the block height from which funds start to unlock
+	StartHeight uint64
+
+	// EndHeight is the block height at which all funds are unlocked
+	EndHeight uint64
+}
+
 // GenesisCfg is
 type GenesisCfg struct {
 	// Keys is an array of names of keys. A random key will be generated
@@ -59,6 +77,10 @@ type GenesisCfg struct {
 
 	// Miners is a list of miners that should be set up at the start of the network
 	Miners []Miner
+
+	// VestingAccounts is a list of vesting accounts that should be set up at
+	// the start of the network
+	VestingAccounts []VestingAccount
 }
 
 // RenderedGenInfo contains information about a genesis block creation
@@ -69,6 +91,9 @@ type RenderedGenInfo struct {
 	// Miners is the list of addresses of miners created
 	Miners []RenderedMinerInfo
 
+	// VestingAccounts is the list of vesting accounts created
+	VestingAccounts []RenderedVestingInfo
+
 	// GenesisCid is the cid of the created genesis block
 	GenesisCid cid.Cid
 }
@@ -85,6 +110,15 @@ type RenderedMinerInfo struct {
 	Power uint64
 }
 
+// RenderedVestingInfo contains info about a created vesting account
+type RenderedVestingInfo struct {
+	// Owner is the key name of the owner of this account
+	Owner int
+
+	// Address is the address of the vesting account actor
+	Address address.Address
+}
+
 // GenGen takes the genesis configuration and creates a genesis block that
 // matches the description. It writes all chunks to the dagservice, and returns
 // the final genesis block.
@@ -108,6 +142,11 @@ func GenGen(ctx context.Context, cfg *GenesisCfg, cst *hamt.CborIpldStore, bs bl
 		return nil, err
 	}
 
+	vestingAccounts, err := setupVesting(st, storageMap, keys, cfg.VestingAccounts)
+	if err != nil {
+		return nil, err
+	}
+
 	miners, err := setupMiners(st, storageMap, keys, cfg.Miners, pnrg)
 	if err != nil {
 		return nil, err
@@ -128,6 +167,12 @@ func GenGen(ctx context.Context, cfg *GenesisCfg, cst *hamt.CborIpldStore, bs bl
 	if err := cst.Blocks.AddBlock(types.PaymentBrokerActorCodeObj); err != nil {
 		return nil, err
 	}
+	if err := cst.Blocks.AddBlock(types.MultisigActorCodeObj); err != nil {
+		return nil, err
+	}
+	if err := cst.Blocks.AddBlock(types.VestingActorCodeObj); err != nil {
+		return nil, err
+	}
 
 	stateRoot, err := st.Flush(ctx)
 	if err != nil {
@@ -149,9 +194,10 @@ func GenGen(ctx context.Context, cfg *GenesisCfg, cst *hamt.CborIpldStore, bs bl
 	}
 
 	return &RenderedGenInfo{
-		Keys:       keys,
-		GenesisCid: c,
-		Miners:     miners,
+		Keys:            keys,
+		GenesisCid:      c,
+		Miners:          miners,
+		VestingAccounts: vestingAccounts,
 	}, nil
 }
 
@@ -209,6 +255,51 @@ func setupPrealloc(st state.Tree, keys []*types.KeyInfo, prealloc []string) erro
 	return st.SetActor(context.Background(), address.NetworkAddress, netact)
 }
 
+func setupVesting(st state.Tree, sm vm.StorageMap, keys []*types.KeyInfo, accounts []VestingAccount) ([]RenderedVestingInfo, error) {
+	var vinfos []RenderedVestingInfo
+	ctx := context.Background()
+
+	for i, v := range accounts {
+		if v.Owner < 0 || v.Owner >= len(keys) {
+			return nil, fmt.Errorf("vesting account %d has no owner key %d", i, v.Owner)
+		}
+		owner, err := keys[v.Owner].Address()
+		if err != nil {
+			return nil, err
+		}
+
+		valint, err := strconv.ParseUint(v.Value, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		value := types.NewAttoFILFromFIL(valint)
+
+		// derive the address deterministically from the owner and position
+		addr := address.NewMainnet(address.Hash([]byte(fmt.Sprintf("vesting-%s-%d", owner, i))))
+
+		act := vesting.NewActor(value)
+		initState := &vesting.State{
+			Owner:       owner,
+			Total:       value,
+			StartHeight: types.NewBlockHeight(v.StartHeight),
+			EndHeight:   types.NewBlockHeight(v.EndHeight),
+		}
+		if err := (&vesting.Actor{}).InitializeState(sm.NewStorage(addr, act), initState); err != nil {
+			return nil, err
+		}
+		if err := st.SetActor(ctx, addr, act); err != nil {
+			return nil, err
+		}
+
+		vinfos = append(vinfos, RenderedVestingInfo{
+			Owner:   v.Owner,
+			Address: addr,
+		})
+	}
+
+	return vinfos, nil
+}
+
 func setupMiners(st state.Tree, sm vm.StorageMap, keys []*types.KeyInfo, miners []Miner, pnrg io.Reader) ([]RenderedMinerInfo, error) {
 	var minfos []RenderedMinerInfo
 	ctx := context.Background()
@@ -30,6 +30,14 @@ var testConfig = &GenesisCfg{
 			Power: 10,
 		},
 	},
+	VestingAccounts: []VestingAccount{
+		{
+			Owner:       2,
+			Value:       "1000",
+			StartHeight: 0,
+			EndHeight:   100,
+		},
+	},
 }
 
 func TestGenGenLoading(t *testing.T) {
@@ -49,6 +57,7 @@ func TestGenGenLoading(t *testing.T) {
 	stdout := o.ReadStdout()
 	assert.Contains(stdout, `"MinerActor"`)
 	assert.Contains(stdout, `"StoragemarketActor"`)
+	assert.Contains(stdout, `"VestingActor"`)
 }
 
 func TestGenGenDeterministicBetweenBuilds(t *testing.T) {
@@ -73,3 +82,29 @@ func TestGenGenDeterministicBetweenBuilds(t *testing.T) {
 		}
 	}
 }
+
+func TestGenGenRejectsUnknownVestingOwner(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, owner := range []int{-1, 4} {
+		cfg := *testConfig
+		cfg.VestingAccounts = []VestingAccount{
+			{
+				Owner:       owner,
+				Value:       "1000",
+				StartHeight: 0,
+				EndHeight:   100,
+			},
+		}
+
+		mds := ds.NewMapDatastore()
+		bstore := blockstore.NewBlockstore(mds)
+		cst := &hamt.CborIpldStore{Blocks: bserv.New(bstore, offline.Exchange(bstore))}
+
+		_, err := GenGen(context.Background(), &cfg, cst, bstore, 0)
+		assert.Error(err)
+		if err != nil {
+			assert.Contains(err.Error(), "has no owner key")
+		}
+	}
+}
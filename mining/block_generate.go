@@ -16,7 +16,10 @@ import (
 	"github.com/filecoin-project/go-filecoin/vm"
 )
 
-// Generate returns a new block created from the messages in the pool.
+// Generate returns a new block created from the messages in the pool. The
+// state it writes is not referenced by the chain yet, so the caller must hold
+// the pin lock of a garbage collected blockstore while it runs. The syncer
+// writes the state again when the block is added to the chain.
 func (w *DefaultWorker) Generate(ctx context.Context,
 	baseTipSet types.TipSet,
 	ticket types.Signature,
@@ -79,16 +82,22 @@ func (w *DefaultWorker) Generate(ctx context.Context,
 		receipts = append(receipts, r.Receipt)
 	}
 
+	blkMessages, blsAggregate, err := types.AggregateBLSSignatures(res.SuccessfulMessages)
+	if err != nil {
+		return nil, errors.Wrap(err, "generate aggregate BLS signatures")
+	}
+
 	next := &types.Block{
-		Miner:           w.minerAddr,
-		Height:          types.Uint64(blockHeight),
-		Messages:        res.SuccessfulMessages,
-		MessageReceipts: receipts,
-		Parents:         baseTipSet.ToSortedCidSet(),
-		ParentWeight:    types.Uint64(weight),
-		Proof:           proof,
-		StateRoot:       newStateTreeCid,
-		Ticket:          ticket,
+		Miner:                 w.minerAddr,
+		Height:                types.Uint64(blockHeight),
+		Messages:              blkMessages,
+		BLSAggregateSignature: blsAggregate,
+		MessageReceipts:       receipts,
+		Parents:               baseTipSet.ToSortedCidSet(),
+		ParentWeight:          types.Uint64(weight),
+		Proof:                 proof,
+		StateRoot:             newStateTreeCid,
+		Ticket:                ticket,
 	}
 
 	// TODO: Should we really be pruning the message pool here at all? Maybe this should happen elsewhere.
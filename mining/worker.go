@@ -170,7 +170,11 @@ func (w *DefaultWorker) Mine(ctx context.Context, base types.TipSet, nullBlkCoun
 	}
 
 	if weHaveAWinner {
+		// Keep the state from being collected while Generate writes it. The
+		// syncer writes it again, pinned, when it adds the block.
+		unpin := w.pin()
 		next, err := w.Generate(ctx, base, ticket, proof, uint64(nullBlkCount))
+		unpin()
 		if err == nil {
 			log.SetTag(ctx, "block", next)
 		}
@@ -182,6 +186,16 @@ func (w *DefaultWorker) Mine(ctx context.Context, base types.TipSet, nullBlkCoun
 	return false
 }
 
+// pin takes the pin lock of the blockstore, if it is garbage collected, and
+// returns a function releasing it.
+func (w *DefaultWorker) pin() func() {
+	gcbs, ok := w.blockstore.(blockstore.GCBlockstore)
+	if !ok {
+		return func() {}
+	}
+	return gcbs.PinLock().Unlock
+}
+
 // TODO: Actually use the results of the PoST once it is implemented.
 // Currently createProof just passes the challenge seed through.
 func createProof(challengeSeed proofs.PoStChallengeSeed, createPoST DoSomeWorkFunc) <-chan proofs.PoStChallengeSeed {
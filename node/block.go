@@ -2,17 +2,80 @@ package node
 
 import (
 	"context"
+	"sync"
 
 	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
 	"gx/ipfs/QmVRxA4J3UPQpw74dLrQ6NJkfysCA1H4GU28gVpXQt9zMU/go-libp2p-pubsub"
 	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+	"gx/ipfs/QmY5Grm8pJdiSSVsYxx4uNRgweY72EmYwuSDbRnbFok3iY/go-libp2p-peer"
 
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/filnet"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
 // BlockTopic is the pubsub topic identifier on which new blocks are announced.
 const BlockTopic = "/fil/blocks"
 
+// maxBlockSources bounds the number of block messages whose delivering peer
+// is remembered until they are processed.
+const maxBlockSources = 1024
+
+// blockSources remembers the peers which delivered block messages, from
+// their validation by pubsub until they are processed. The origin of a
+// message, its From field, is chosen by its publisher and may name any peer,
+// so misbehaviour is attributed to the peer which delivered the message.
+type blockSources struct {
+	mu    sync.Mutex
+	peers map[string]peer.ID
+}
+
+func newBlockSources() *blockSources {
+	return &blockSources{peers: make(map[string]peer.ID)}
+}
+
+// add records that the message with the input id was delivered by peer p.
+// Pubsub delivers a message with a given id once, later copies are dropped
+// as already seen.
+func (bs *blockSources) add(msgID string, p peer.ID) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if len(bs.peers) >= maxBlockSources {
+		// Forget any message, its processing was likely dropped.
+		for id := range bs.peers {
+			delete(bs.peers, id)
+			break
+		}
+	}
+	bs.peers[msgID] = p
+}
+
+// take returns and forgets the peer which delivered the message with the
+// input id.
+func (bs *blockSources) take(msgID string) (peer.ID, bool) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	p, ok := bs.peers[msgID]
+	delete(bs.peers, msgID)
+	return p, ok
+}
+
+// pubSubMsgID returns the id pubsub identifies a message with.
+func pubSubMsgID(msg *pubsub.Message) string {
+	return string(msg.Message.GetFrom()) + string(msg.GetSeqno())
+}
+
+// validateBlockMessage is the pubsub validator of the block topic. It drops
+// messages delivered by banned peers and records the delivering peer of the
+// others for processBlock.
+func (node *Node) validateBlockMessage(ctx context.Context, p peer.ID, msg *pubsub.Message) bool {
+	if node.Reputation.IsBanned(p) {
+		return false
+	}
+	node.blockSources.add(pubSubMsgID(msg), p)
+	return true
+}
+
 // AddNewBlock receives a newly mined block and stores, validates and propagates it to the network.
 func (node *Node) AddNewBlock(ctx context.Context, b *types.Block) (err error) {
 	// Put block in storage wired to an exchange so this node and other
@@ -34,6 +97,10 @@ func (node *Node) AddNewBlock(ctx context.Context, b *types.Block) (err error) {
 }
 
 func (node *Node) processBlock(ctx context.Context, pubSubMsg *pubsub.Message) (err error) {
+	// Blame the peer which delivered the block, not the origin the message
+	// claims.
+	from, known := node.blockSources.take(pubSubMsgID(pubSubMsg))
+
 	// ignore messages from ourself
 	if pubSubMsg.GetFrom() == node.Host().ID() {
 		return nil
@@ -47,10 +114,33 @@ func (node *Node) processBlock(ctx context.Context, pubSubMsg *pubsub.Message) (
 	log.Infof("Received new block from network cid: %s", blk.Cid().String())
 	log.Debugf("Received new block from network: %s", blk)
 
+	if known {
+		if node.Reputation.IsBanned(from) {
+			return nil
+		}
+		ctx = chain.WithSyncPeer(ctx, from.Pretty())
+	}
+
 	err = node.Syncer.HandleNewBlocks(ctx, []cid.Cid{blk.Cid()})
 	if err != nil {
+		if known {
+			node.penalizeSyncPeer(from, err)
+		}
 		return errors.Wrap(err, "processing block from network")
 	}
 
 	return nil
 }
+
+// penalizeSyncPeer penalizes peer p if err, returned by the syncer for a
+// chain p sent, is its fault.
+func (node *Node) penalizeSyncPeer(p peer.ID, err error) {
+	switch fault := chain.FaultOf(err); fault {
+	case chain.FaultInvalidBlock:
+		node.Reputation.Penalize(p, filnet.PenaltyInvalidBlock, fault.String())
+	case chain.FaultInvalidMessage:
+		node.Reputation.Penalize(p, filnet.PenaltyInvalidMessage, fault.String())
+	case chain.FaultTimeout:
+		node.Reputation.Penalize(p, filnet.PenaltyTimeout, fault.String())
+	}
+}
@@ -0,0 +1,78 @@
+package node
+
+import (
+	"context"
+	"testing"
+
+	"gx/ipfs/QmVRxA4J3UPQpw74dLrQ6NJkfysCA1H4GU28gVpXQt9zMU/go-libp2p-pubsub"
+	pb "gx/ipfs/QmVRxA4J3UPQpw74dLrQ6NJkfysCA1H4GU28gVpXQt9zMU/go-libp2p-pubsub/pb"
+
+	"github.com/filecoin-project/go-filecoin/filnet"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	"github.com/filecoin-project/go-filecoin/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessBlockPenalizesDeliveringPeer(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+
+	nd := MakeOfflineNode(t)
+
+	// A block failing validation as it lacks a state root.
+	blk := &types.Block{
+		Parents: types.NewSortedCidSet(nd.ChainReader.GenesisCid()),
+		Height:  types.Uint64(1),
+	}
+	_, err := nd.cborStore.Put(ctx, blk)
+	require.NoError(err)
+
+	// The message claims to come from an honest peer, and is delivered by
+	// another.
+	honest := th.RequireRandomPeerID()
+	deliverer := th.RequireRandomPeerID()
+	msg := &pubsub.Message{Message: &pb.Message{
+		From:  []byte(honest),
+		Data:  blk.ToNode().RawData(),
+		Seqno: []byte{1},
+	}}
+
+	require.True(nd.validateBlockMessage(ctx, deliverer, msg))
+	assert.Error(nd.processBlock(ctx, msg))
+	assert.Equal(0.0, nd.Reputation.Score(honest))
+	// Scores decay from the time of the penalty.
+	assert.InDelta(float64(-filnet.PenaltyInvalidBlock), nd.Reputation.Score(deliverer), 1)
+}
+
+func TestProcessBlockPenalizesPeerNotServingAncestors(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+
+	nd := MakeOfflineNode(t)
+
+	// A block whose parent cannot be fetched.
+	blk := &types.Block{
+		Parents:   types.NewSortedCidSet(types.NewCidForTestGetter()()),
+		Height:    types.Uint64(1),
+		StateRoot: types.SomeCid(),
+	}
+	_, err := nd.cborStore.Put(ctx, blk)
+	require.NoError(err)
+
+	deliverer := th.RequireRandomPeerID()
+	msg := &pubsub.Message{Message: &pb.Message{
+		From:  []byte(deliverer),
+		Data:  blk.ToNode().RawData(),
+		Seqno: []byte{1},
+	}}
+
+	require.True(nd.validateBlockMessage(ctx, deliverer, msg))
+	assert.Error(nd.processBlock(ctx, msg))
+	assert.InDelta(float64(-filnet.PenaltyTimeout), nd.Reputation.Score(deliverer), 1)
+}
@@ -24,6 +24,7 @@ type InitCfg struct {
 	PeerKey                 ci.PrivKey
 	DefaultWalletAddress    address.Address
 	AutoSealIntervalSeconds uint
+	WalletPassphrase        []byte
 }
 
 // InitOpt is an init option function
@@ -51,6 +52,13 @@ func AutoSealIntervalSecondsOpt(autoSealIntervalSeconds uint) InitOpt {
 	}
 }
 
+// WalletPassphraseOpt encrypts the keys of the wallet with the given passphrase.
+func WalletPassphraseOpt(passphrase []byte) InitOpt {
+	return func(c *InitCfg) {
+		c.WalletPassphrase = passphrase
+	}
+}
+
 // Init initializes a filecoin node in the given repo.
 func Init(ctx context.Context, r repo.Repo, gen consensus.GenesisInitFunc, opts ...InitOpt) error {
 	cfg := new(InitCfg)
@@ -97,6 +105,12 @@ func Init(ctx context.Context, r repo.Repo, gen consensus.GenesisInitFunc, opts
 		newConfig.Wallet.DefaultAddress = addr
 	}
 
+	if cfg.WalletPassphrase != nil {
+		if err := encryptWallet(r, cfg.WalletPassphrase); err != nil {
+			return errors.Wrap(err, "failed to encrypt wallet")
+		}
+	}
+
 	if err := r.ReplaceConfig(newConfig); err != nil {
 		return errors.Wrap(err, "failed to update config with new values")
 	}
@@ -135,3 +149,12 @@ func newAddress(r repo.Repo) (address.Address, error) {
 
 	return addr, err
 }
+
+// encryptWallet encrypts the keys of the default wallet with passphrase.
+func encryptWallet(r repo.Repo, passphrase []byte) error {
+	backend, err := wallet.NewDSBackend(r.WalletDatastore())
+	if err != nil {
+		return errors.Wrap(err, "failed to set up wallet backend")
+	}
+	return backend.Encrypt(passphrase)
+}
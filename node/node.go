@@ -44,10 +44,13 @@ import (
 	"github.com/filecoin-project/go-filecoin/config"
 	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/core"
+	"github.com/filecoin-project/go-filecoin/exec"
 	"github.com/filecoin-project/go-filecoin/filnet"
+	"github.com/filecoin-project/go-filecoin/gc"
 	"github.com/filecoin-project/go-filecoin/lookup"
 	"github.com/filecoin-project/go-filecoin/metrics"
 	"github.com/filecoin-project/go-filecoin/mining"
+	"github.com/filecoin-project/go-filecoin/paych"
 	"github.com/filecoin-project/go-filecoin/plumbing"
 	"github.com/filecoin-project/go-filecoin/plumbing/cfg"
 	"github.com/filecoin-project/go-filecoin/plumbing/chn"
@@ -57,6 +60,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/porcelain"
 	"github.com/filecoin-project/go-filecoin/proofs"
 	"github.com/filecoin-project/go-filecoin/proofs/sectorbuilder"
+	"github.com/filecoin-project/go-filecoin/protocol/blocksync"
 	"github.com/filecoin-project/go-filecoin/protocol/hello"
 	"github.com/filecoin-project/go-filecoin/protocol/retrieval"
 	"github.com/filecoin-project/go-filecoin/protocol/storage"
@@ -90,6 +94,11 @@ type Node struct {
 	Syncer      chain.Syncer
 	PowerTable  consensus.PowerTableView
 
+	// builtinActors execute the messages of blocks, if set, instead of the
+	// default builtin actors, so that they check proofs with the node's
+	// verifier.
+	builtinActors map[cid.Cid]exec.ExecutableActor
+
 	PorcelainAPI *porcelain.API
 
 	// HeavyTipSetCh is a subscription to the heaviest tipset topic on the chain.
@@ -121,14 +130,27 @@ type Node struct {
 	RetrievalClient *retrieval.Client
 	RetrievalMiner  *retrieval.Miner
 
+	// PaychSettler closes and reclaims payment channels around their Eol.
+	PaychSettler *paych.Settler
+
 	// Network Fields
 	PubSub       *pubsub.PubSub
 	BlockSub     *pubsub.Subscription
 	MessageSub   *pubsub.Subscription
 	Ping         *ping.PingService
 	HelloSvc     *hello.Handler
+	BlockSyncSvc *blocksync.Handler
 	Bootstrapper *filnet.Bootstrapper
 	OnlineStore  *hamt.CborIpldStore
+	// Reputation scores peers by the chains they send, and bans the
+	// misbehaving ones.
+	Reputation *filnet.Reputation
+	// blockSources remembers the peers which delivered block messages
+	// until they are processed.
+	blockSources *blockSources
+	// syncFetcher fetches tipsets for the syncer from the peers learned
+	// of through the hello handshake.
+	syncFetcher *blocksync.Fetcher
 
 	// Data Storage Fields
 
@@ -142,8 +164,13 @@ type Node struct {
 	// Exchange is the interface for fetching data from other nodes.
 	Exchange exchange.Interface
 
-	// Blockstore is the un-networked blocks interface
-	Blockstore bstore.Blockstore
+	// Blockstore is the un-networked blocks interface. Code writing state
+	// to it must hold a pin lock until the state is referenced from the
+	// chain store, so that it is not garbage collected meanwhile.
+	Blockstore bstore.GCBlockstore
+
+	// GC removes chain and state data which is no longer needed.
+	GC *gc.Collector
 
 	// Blockservice is a higher level interface for fetching data
 	blockservice bserv.BlockService
@@ -320,7 +347,7 @@ func (nc *Config) Build(ctx context.Context) (*Node, error) {
 		nc.Repo = repo.NewInMemoryRepo()
 	}
 
-	bs := bstore.NewBlockstore(nc.Repo.Datastore())
+	bs := bstore.NewGCBlockstore(bstore.NewBlockstore(nc.Repo.Datastore()), bstore.NewGCLocker())
 
 	validator := blankValidator{}
 
@@ -368,45 +395,71 @@ func (nc *Config) Build(ctx context.Context) (*Node, error) {
 		return nil, err
 	}
 
-	var chainStore chain.Store = chain.NewDefaultStore(nc.Repo.ChainDatastore(), &cstOffline, genCid)
+	defaultStore := chain.NewDefaultStore(nc.Repo.ChainDatastore(), &cstOffline, genCid)
+	var chainStore chain.Store = defaultStore
 	powerTable := &consensus.MarketView{}
 
-	var processor consensus.Processor
-	if nc.Rewarder == nil {
-		processor = consensus.NewDefaultProcessor()
-	} else {
-		processor = consensus.NewConfiguredProcessor(consensus.NewDefaultMessageValidator(), nc.Rewarder)
+	rewarder := nc.Rewarder
+	if rewarder == nil {
+		rewarder = consensus.NewDefaultBlockRewarder()
 	}
 
-	var nodeConsensus consensus.Protocol
-	if nc.Verifier == nil {
-		nodeConsensus = consensus.NewExpected(&cstOffline, bs, processor, powerTable, genCid, &proofs.RustVerifier{})
-	} else {
-		nodeConsensus = consensus.NewExpected(&cstOffline, bs, processor, powerTable, genCid, nc.Verifier)
+	verifier := nc.Verifier
+	var builtinActors map[cid.Cid]exec.ExecutableActor
+	var cachingVerifier *proofs.CachingVerifier
+	if verifier == nil {
+		var backend proofs.Verifier = &proofs.RustVerifier{}
+		if nc.Repo.Config().Proofs.Backend == config.GoProofsBackend {
+			backend = &proofs.GoVerifier{}
+		}
+		// share the cache with the miner actor, so that proofs checked while
+		// validating one fork are not checked again on another
+		cachingVerifier = proofs.NewCachingVerifier(backend, proofs.DefaultVerifierCacheSize)
+		verifier = cachingVerifier
+		builtinActors = builtin.ActorsWithVerifier(verifier)
 	}
+	processor := consensus.NewProcessorWithActors(consensus.NewDefaultMessageValidator(), rewarder, builtinActors)
+	nodeConsensus := consensus.NewExpected(&cstOffline, bs, processor, powerTable, genCid, verifier)
 
 	// only the syncer gets the storage which is online connected
-	chainSyncer := chain.NewDefaultSyncer(&cstOnline, &cstOffline, nodeConsensus, chainStore)
+	blockSyncFetcher := blocksync.NewFetcher(peerHost)
+	badTipSets, err := chain.NewBadTipSetCache(nc.Repo.ChainDatastore())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load bad tipsets")
+	}
+	chainSyncer := chain.NewDefaultSyncer(&cstOnline, &cstOffline, nodeConsensus, chainStore, badTipSets, blockSyncFetcher, bs, nc.Repo.Config().Sync.Checkpoints...)
 	chainReader, ok := chainStore.(chain.ReadStore)
 	if !ok {
 		return nil, errors.New("failed to cast chain.Store to chain.ReadStore")
 	}
 	msgPool := core.NewMessagePool()
 
+	gcCfg := nc.Repo.Config().GC
+	var gcPeriod time.Duration
+	if gcCfg.Period != "" {
+		gcPeriod, err = time.ParseDuration(gcCfg.Period)
+		if err != nil {
+			return nil, errors.Wrapf(err, "couldn't parse gc period %s", gcCfg.Period)
+		}
+	}
+	collector := gc.NewCollector(defaultStore, bs, nc.Repo.Datastore(), gcCfg.Retention, gcPeriod)
+
 	// Set up libp2p pubsub
 	fsub, err := pubsub.NewFloodSub(ctx, peerHost)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to set up pubsub")
 	}
-	backend, err := wallet.NewDSBackend(nc.Repo.WalletDatastore())
+	fcWallet, err := OpenWallet(nc.Repo)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to set up wallet backend")
+		return nil, err
 	}
-	fcWallet := wallet.New(backend)
 
 	PorcelainAPI := porcelain.New(plumbing.New(&plumbing.APIDeps{
+		BadTipSets:   badTipSets,
 		Chain:        chn.New(chainReader),
 		Config:       cfg.NewConfig(nc.Repo),
+		GC:           collector,
+		Syncer:       chainSyncer,
 		MessagePool:  msgPool,
 		MsgPreviewer: msg.NewPreviewer(fcWallet, chainReader, &cstOffline, bs),
 		MsgQueryer:   msg.NewQueryer(nc.Repo, fcWallet, chainReader, &cstOffline, bs),
@@ -414,17 +467,20 @@ func (nc *Config) Build(ctx context.Context) (*Node, error) {
 		MsgWaiter:    msg.NewWaiter(chainReader, bs, &cstOffline),
 		Network:      ntwk.NewNetwork(peerHost),
 		SigGetter:    mthdsig.NewGetter(chainReader),
+		Verifier:     cachingVerifier,
 		Wallet:       fcWallet,
-	}))
+	}), porcelain.NewPaychLedger(nc.Repo.DealsDatastore()))
 
 	nd := &Node{
 		blockservice: bservice,
 		Blockstore:   bs,
+		GC:           collector,
 		cborStore:    &cstOffline,
 		OnlineStore:  &cstOnline,
 		Consensus:    nodeConsensus,
 		ChainReader:  chainReader,
 		Syncer:       chainSyncer,
+		syncFetcher:  blockSyncFetcher,
 		PowerTable:   powerTable,
 		PorcelainAPI: PorcelainAPI,
 		Exchange:     bswap,
@@ -456,6 +512,10 @@ func (nc *Config) Build(ctx context.Context) (*Node, error) {
 	minPeerThreshold := nd.Repo.Config().Bootstrap.MinPeerThreshold
 	nd.Bootstrapper = filnet.NewBootstrapper(bpi, nd.Host(), nd.Host().Network(), nd.Router, minPeerThreshold, period)
 
+	nd.Reputation = filnet.NewReputation(nd.Host())
+	nd.blockSources = newBlockSources()
+	nd.builtinActors = builtinActors
+
 	// On-chain lookup service
 	defaultAddressGetter := func() (address.Address, error) {
 		return nd.PorcelainAPI.GetAndMaybeSetDefaultSenderAddress()
@@ -465,6 +525,27 @@ func (nc *Config) Build(ctx context.Context) (*Node, error) {
 	return nd, nil
 }
 
+// OpenWallet sets up the wallet of the repo r with all its backends.
+func OpenWallet(r repo.Repo) (*wallet.Wallet, error) {
+	backend, err := wallet.NewDSBackend(r.WalletDatastore())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to set up wallet backend")
+	}
+	hdBackend, err := wallet.NewHDBackend(backend)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to set up hd wallet backend")
+	}
+	backends := []wallet.Backend{backend, hdBackend}
+	if path := r.Config().Wallet.RemoteSigner; path != "" {
+		remoteBackend, err := wallet.NewRemoteBackend(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to set up remote signer wallet backend")
+		}
+		backends = append(backends, remoteBackend)
+	}
+	return wallet.New(backends...), nil
+}
+
 // Start boots up the node.
 func (node *Node) Start(ctx context.Context) error {
 	if err := node.ChainReader.Load(ctx); err != nil {
@@ -481,16 +562,24 @@ func (node *Node) Start(ctx context.Context) error {
 
 	// Start up 'hello' handshake service
 	syncCallBack := func(pid libp2ppeer.ID, cids []cid.Cid, height uint64) {
-		// TODO it is possible the syncer interface should be modified to
-		// make use of the additional context not used here (from addr + height).
-		// To keep things simple for now this info is not used.
-		err := node.Syncer.HandleNewBlocks(context.Background(), cids)
+		if node.Reputation.IsBanned(pid) {
+			return
+		}
+		// The peer and the height of its head are used by the syncer's
+		// fetcher to choose which peers to fetch tipsets from.
+		node.syncFetcher.AddPeer(pid, height)
+
+		err := node.Syncer.HandleNewBlocks(chain.WithSyncPeer(context.Background(), pid.Pretty()), cids)
 		if err != nil {
 			log.Infof("error handling blocks: %s", types.NewSortedCidSet(cids...).String())
+			node.penalizeSyncPeer(pid, err)
 		}
 	}
 	node.HelloSvc = hello.New(node.Host(), node.ChainReader.GenesisCid(), syncCallBack, node.ChainReader.Head)
 
+	// Serve the chain to peers syncing it.
+	node.BlockSyncSvc = blocksync.New(node.Host(), node.ChainReader)
+
 	cni := storage.NewClientNodeImpl(dag.NewDAGService(node.BlockService()), node.Host(), node.GetBlockTime())
 	var err error
 	node.StorageMinerClient, err = storage.NewClient(cni, node.PorcelainAPI, node.Repo.DealsDatastore())
@@ -501,7 +590,15 @@ func (node *Node) Start(ctx context.Context) error {
 	node.RetrievalClient = retrieval.NewClient(node)
 	node.RetrievalMiner = retrieval.NewMiner(node)
 
-	// subscribe to block notifications
+	paychCfg := node.Repo.Config().Paych
+	node.PaychSettler = paych.NewSettler(node.PorcelainAPI, *paychCfg.SettleGasPrice, types.NewGasUnits(paychCfg.SettleGasLimit))
+	node.PaychSettler.Start(context.Background())
+
+	// subscribe to block notifications, validated to learn the peers
+	// delivering them
+	if err := node.PubSub.RegisterTopicValidator(BlockTopic, node.validateBlockMessage); err != nil {
+		return errors.Wrap(err, "failed to register the blocks topic validator")
+	}
 	blkSub, err := node.PubSub.Subscribe(BlockTopic)
 	if err != nil {
 		return errors.Wrap(err, "failed to subscribe to blocks topic")
@@ -529,6 +626,10 @@ func (node *Node) Start(ctx context.Context) error {
 		node.Bootstrapper.Start(context.Background())
 	}
 
+	if node.GC.Period > 0 {
+		node.GC.Start(context.Background())
+	}
+
 	mag := func() address.Address {
 		addr, err := node.MiningAddress()
 		// the only error MiningAddress() returns is ErrNoMinerAddress.
@@ -540,8 +641,11 @@ func (node *Node) Start(ctx context.Context) error {
 
 		return addr
 	}
+	sg := func() bool {
+		return node.Syncer.Status().Syncing
+	}
 	// start the primary heartbeat service
-	hbs := metrics.NewHeartbeatService(node.Host(), node.Repo.Config().Heartbeat, node.ChainReader.Head, metrics.WithMinerAddressGetter(mag))
+	hbs := metrics.NewHeartbeatService(node.Host(), node.Repo.Config().Heartbeat, node.ChainReader.Head, metrics.WithMinerAddressGetter(mag), metrics.WithSyncingGetter(sg))
 	go hbs.Start(ctx)
 
 	// check if we want to connect to an alert service. An alerting service is a heartbeat
@@ -552,7 +656,7 @@ func (node *Node) Start(ctx context.Context) error {
 			BeatPeriod:      "10s",
 			ReconnectPeriod: "10s",
 			Nickname:        node.Repo.Config().Heartbeat.Nickname,
-		}, node.ChainReader.Head, metrics.WithMinerAddressGetter(mag))
+		}, node.ChainReader.Head, metrics.WithMinerAddressGetter(mag), metrics.WithSyncingGetter(sg))
 		go ahbs.Start(ctx)
 	}
 	return nil
@@ -643,6 +747,9 @@ func (node *Node) handleNewHeaviestTipSet(ctx context.Context, head types.TipSet
 			if node.StorageMiner != nil {
 				node.StorageMiner.OnNewHeaviestTipSet(newHead)
 			}
+			if node.PaychSettler != nil {
+				node.PaychSettler.OnNewHeaviestTipSet(newHead)
+			}
 			node.HeaviestTipSetHandled()
 		case <-ctx.Done():
 			return
@@ -658,6 +765,9 @@ func (node *Node) cancelSubscriptions() {
 	if node.BlockSub != nil {
 		node.BlockSub.Cancel()
 		node.BlockSub = nil
+		if err := node.PubSub.UnregisterTopicValidator(BlockTopic); err != nil {
+			log.Warningf("failed to unregister the blocks topic validator: %s", err)
+		}
 	}
 
 	if node.MessageSub != nil {
@@ -672,6 +782,10 @@ func (node *Node) Stop(ctx context.Context) {
 	node.StopMining(ctx)
 
 	node.cancelSubscriptions()
+	if node.PaychSettler != nil {
+		node.PaychSettler.Stop()
+	}
+	node.GC.Stop()
 	node.ChainReader.Stop()
 
 	if node.SectorBuilder() != nil {
@@ -794,7 +908,7 @@ func (node *Node) StartMining(ctx context.Context) error {
 		getAncestors := func(ctx context.Context, ts types.TipSet, newBlockHeight *types.BlockHeight) ([]types.TipSet, error) {
 			return chain.GetRecentAncestors(ctx, ts, node.ChainReader, newBlockHeight, consensus.AncestorRoundsNeeded, consensus.LookBackParameter)
 		}
-		processor := consensus.NewDefaultProcessor()
+		processor := consensus.NewProcessorWithActors(consensus.NewDefaultMessageValidator(), consensus.NewDefaultBlockRewarder(), node.builtinActors)
 		worker := mining.NewDefaultWorker(node.MsgPool, getState, getWeight, getAncestors, processor, node.PowerTable, node.Blockstore, node.CborStore(), minerAddr, blockTime)
 		node.MiningScheduler = mining.NewScheduler(worker, mineDelay, node.ChainReader.Head)
 	}
@@ -861,8 +975,8 @@ func (node *Node) StartMining(ctx context.Context) error {
 		}
 	}()
 
-	// schedules sealing of staged piece-data
-	if node.Repo.Config().Mining.AutoSealIntervalSeconds > 0 {
+	// schedules sealing of staged piece-data if the seal scheduler does not
+	if node.SealScheduler() == nil && node.Repo.Config().Mining.AutoSealIntervalSeconds > 0 {
 		go func() {
 			for {
 				select {
@@ -877,8 +991,6 @@ func (node *Node) StartMining(ctx context.Context) error {
 				}
 			}
 		}()
-	} else {
-		log.Debug("auto-seal is disabled")
 	}
 	node.setIsMining(true)
 
@@ -919,26 +1031,51 @@ func initSectorBuilderForNode(ctx context.Context, node *Node, sectorStoreType p
 		return nil, errors.Wrapf(err, "failed to get last used sector id for miner w/address %s", minerAddr.String())
 	}
 
-	// TODO: Where should we store the RustSectorBuilder metadata? Currently, we
-	// configure the RustSectorBuilder to store its metadata in the staging
+	// TODO: Where should we store the sector builder metadata? Currently, we
+	// configure the sector builder to store its metadata in the staging
 	// directory.
 
-	cfg := sectorbuilder.RustSectorBuilderConfig{
-		BlockService:     node.blockservice,
-		LastUsedSectorID: lastUsedSectorID,
-		MetadataDir:      node.Repo.StagingDir(),
-		MinerAddr:        minerAddr,
-		SealedSectorDir:  node.Repo.SealedDir(),
-		SectorStoreType:  sectorStoreType,
-		StagedSectorDir:  node.Repo.StagingDir(),
+	var sb sectorbuilder.SectorBuilder
+	if node.Repo.Config().Proofs.Backend == config.GoProofsBackend {
+		sb, err = sectorbuilder.NewGoSectorBuilder(sectorbuilder.GoSectorBuilderConfig{
+			BlockService:     node.blockservice,
+			LastUsedSectorID: lastUsedSectorID,
+			MetadataDir:      node.Repo.StagingDir(),
+			MinerAddr:        minerAddr,
+			SealedSectorDir:  node.Repo.SealedDir(),
+			SectorStoreType:  sectorStoreType,
+			StagedSectorDir:  node.Repo.StagingDir(),
+		})
+	} else {
+		sb, err = sectorbuilder.NewRustSectorBuilder(sectorbuilder.RustSectorBuilderConfig{
+			BlockService:     node.blockservice,
+			LastUsedSectorID: lastUsedSectorID,
+			MetadataDir:      node.Repo.StagingDir(),
+			MinerAddr:        minerAddr,
+			SealedSectorDir:  node.Repo.SealedDir(),
+			SectorStoreType:  sectorStoreType,
+			StagedSectorDir:  node.Repo.StagingDir(),
+		})
 	}
-
-	sb, err := sectorbuilder.NewRustSectorBuilder(cfg)
 	if err != nil {
 		return nil, errors.Wrap(err, fmt.Sprintf("failed to initialize sector builder for miner %s", minerAddr.String()))
 	}
 
-	return sb, nil
+	// the scheduler decides when staged sectors are sealed
+	miningCfg := node.Repo.Config().Mining
+	if miningCfg.AutoSealIntervalSeconds == 0 {
+		log.Debug("auto-seal is disabled")
+	}
+
+	scheduler, err := sectorbuilder.NewSealScheduler(sb, sectorbuilder.SealSchedulerConfig{
+		MaxConcurrentSeals: int(miningCfg.MaxConcurrentSeals),
+		AutoSealInterval:   time.Duration(miningCfg.AutoSealIntervalSeconds) * time.Second,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialize seal scheduler")
+	}
+
+	return scheduler, nil
 }
 
 func initStorageMinerForNode(ctx context.Context, node *Node) (*storage.Miner, error) {
@@ -999,11 +1136,7 @@ func (node *Node) CreateMiner(ctx context.Context, accountAddr address.Address,
 	if err != nil {
 		return nil, err
 	}
-	info, err := backend.GetKeyInfo(accountAddr)
-	if err != nil {
-		return nil, err
-	}
-	pubkey, err := info.PublicKey()
+	pubkey, err := wallet.PublicKey(backend, accountAddr)
 	if err != nil {
 		return nil, err
 	}
@@ -1110,6 +1243,14 @@ func (node *Node) SectorBuilder() sectorbuilder.SectorBuilder {
 	return node.sectorBuilder
 }
 
+// SealScheduler returns the scheduler which decides when the node's staged
+// sectors are sealed, or nil if the node has no sector builder or its sector
+// builder cannot seal single sectors.
+func (node *Node) SealScheduler() *sectorbuilder.SealScheduler {
+	scheduler, _ := node.sectorBuilder.(*sectorbuilder.SealScheduler)
+	return scheduler
+}
+
 // BlockService returns the nodes blockservice.
 func (node *Node) BlockService() bserv.BlockService {
 	return node.blockservice
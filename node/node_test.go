@@ -170,7 +170,7 @@ func TestNodeStartMining(t *testing.T) {
 		Network:      ntwk.NewNetwork(minerNode.Host()),
 		Wallet:       wallet.New(walletBackend),
 	})
-	porcelainAPI := porcelain.New(plumbingAPI)
+	porcelainAPI := porcelain.New(plumbingAPI, porcelain.NewPaychLedger(minerNode.Repo.DealsDatastore()))
 
 	seed.GiveKey(t, minerNode, 0)
 	mineraddr, minerOwnerAddr := seed.GiveMiner(t, minerNode, 0)
@@ -335,6 +335,25 @@ func TestUpdateMessagePool(t *testing.T) {
 	node.Stop(ctx)
 }
 
+func TestInitWalletPassphrase(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	assert := assert.New(t)
+	require := require.New(t)
+	r := repo.NewInMemoryRepo()
+	require.NoError(Init(ctx, r, consensus.InitGenesis, WalletPassphraseOpt([]byte("secret"))))
+
+	backend, err := wallet.NewDSBackend(r.WalletDatastore())
+	require.NoError(err)
+	assert.True(backend.IsLocked())
+	assert.True(backend.HasAddress(r.Config().Wallet.DefaultAddress))
+
+	assert.Error(backend.Unlock([]byte("wrong"), 0))
+	require.NoError(backend.Unlock([]byte("secret"), 0))
+	_, err = backend.GetKeyInfo(r.Config().Wallet.DefaultAddress)
+	assert.NoError(err)
+}
+
 func TestOptionWithError(t *testing.T) {
 	t.Parallel()
 	ctx := context.Background()
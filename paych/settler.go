@@ -0,0 +1,363 @@
+package paych
+
+import (
+	"context"
+	"math/big"
+	"sync"
+
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	cbor "gx/ipfs/QmRoARq3nkUb13HSKZGepCZSWe5GrVPwx7xURJGZ7KWv9V/go-ipld-cbor"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+	logging "gx/ipfs/QmcuXC5cxs79ro2cUuHs4HQ2bkDLJUYokwL8aivcX6HW3C/go-log"
+
+	"github.com/filecoin-project/go-filecoin/actor/builtin/paymentbroker"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/exec"
+	"github.com/filecoin-project/go-filecoin/porcelain"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+var log = logging.Logger("paych")
+
+// CloseWindow is the number of blocks before a channel's Eol at which the
+// settler closes it as the payee.
+const CloseWindow = 10
+
+// settlerPorcelain is the subset of the porcelain API that the Settler uses.
+type settlerPorcelain interface {
+	MessageQuery(ctx context.Context, optFrom, to address.Address, method string, params ...interface{}) ([][]byte, *exec.FunctionSignature, error)
+	MessageSend(ctx context.Context, from, to address.Address, value *types.AttoFIL, gasPrice types.AttoFIL, gasLimit types.GasUnits, method string, params ...interface{}) (cid.Cid, error)
+	MessageWait(ctx context.Context, msgCid cid.Cid, cb func(*types.Block, *types.SignedMessage, *types.MessageReceipt) error) error
+	PaychBestVouchers(ctx context.Context, payer address.Address, channel *types.ChannelID) ([]*paymentbroker.PaymentVoucher, error)
+	PaychLedgerEntries() ([]*porcelain.PaychLedgerEntry, error)
+	PaychRecordSettled(payer address.Address, channel *types.ChannelID) error
+	WalletAddresses() []address.Address
+}
+
+// Settler watches the payment channels the node's wallet takes part in and
+// settles them around their Eol, so that funds are neither lost nor left
+// locked. As the payee of a channel it redeems the best voucher received in
+// each lane and closes the channel once it is within CloseWindow blocks of
+// its Eol.
+// As the payer it reclaims the remaining funds once the Eol has passed.
+//
+// Channels are settled in the background, on the latest head the settler was
+// notified of. A channel is recorded as settled in the ledger once the
+// message settling it has been applied successfully.
+type Settler struct {
+	api      settlerPorcelain
+	gasPrice types.AttoFIL
+	gasLimit types.GasUnits
+
+	// heads holds the latest head which has not been settled yet.
+	heads  chan types.TipSet
+	cancel context.CancelFunc
+
+	// pending records the channels the settler has sent a message for
+	// which has not been applied yet, so that it sends one at a time.
+	pendingLk sync.Mutex
+	pending   map[string]bool
+}
+
+// NewSettler returns a new Settler sending messages with the provided gas
+// price and limit.
+func NewSettler(api settlerPorcelain, gasPrice types.AttoFIL, gasLimit types.GasUnits) *Settler {
+	return &Settler{
+		api:      api,
+		gasPrice: gasPrice,
+		gasLimit: gasLimit,
+		heads:    make(chan types.TipSet, 1),
+		pending:  map[string]bool{},
+	}
+}
+
+// Start starts settling channels on the heads the settler is notified of.
+func (s *Settler) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ts := <-s.heads:
+				s.settle(ctx, ts)
+			}
+		}
+	}()
+}
+
+// Stop stops settling channels.
+func (s *Settler) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
+// OnNewHeaviestTipSet notifies the settler of a new head. It does not block:
+// a head which the settler has not started settling yet is replaced.
+func (s *Settler) OnNewHeaviestTipSet(ts types.TipSet) {
+	select {
+	case <-s.heads:
+	default:
+	}
+	s.heads <- ts
+}
+
+// settle settles the channels which are due at the height of ts.
+func (s *Settler) settle(ctx context.Context, ts types.TipSet) {
+	h, err := ts.Height()
+	if err != nil {
+		log.Errorf("failed to get height of new head: %s", err)
+		return
+	}
+	height := types.NewBlockHeight(h)
+
+	ours := map[address.Address]bool{}
+	for _, addr := range s.api.WalletAddresses() {
+		ours[addr] = true
+	}
+
+	entries, err := s.api.PaychLedgerEntries()
+	if err != nil {
+		log.Errorf("failed to read payment channel ledger: %s", err)
+		return
+	}
+	settled := map[string]bool{}
+	for _, entry := range entries {
+		if entry.Settled {
+			settled[settledKey(entry.Payer, entry.Channel)] = true
+		}
+	}
+
+	// the channels of each payer are queried once per head
+	channels := map[address.Address]map[string]*paymentbroker.PaymentChannel{}
+	channelsOf := func(payer address.Address) (map[string]*paymentbroker.PaymentChannel, error) {
+		if cs, ok := channels[payer]; ok {
+			return cs, nil
+		}
+		cs, err := s.channels(ctx, payer)
+		if err != nil {
+			return nil, err
+		}
+		channels[payer] = cs
+		return cs, nil
+	}
+
+	if err := s.closeAsPayee(ctx, height, ours, entries, settled, channelsOf); err != nil {
+		log.Errorf("failed to close payment channels: %s", err)
+	}
+
+	for addr := range ours {
+		if err := s.reclaimAsPayer(ctx, height, addr, settled, channelsOf); err != nil {
+			log.Errorf("failed to reclaim payment channels of %s: %s", addr, err)
+		}
+	}
+}
+
+// closeAsPayee closes the channels to one of our addresses on which we have
+// received vouchers and which are about to expire.
+func (s *Settler) closeAsPayee(ctx context.Context, height *types.BlockHeight, ours map[address.Address]bool, entries []*porcelain.PaychLedgerEntry, settled map[string]bool, channelsOf channelsFunc) error {
+	for _, entry := range entries {
+		if len(entry.Received) == 0 || s.isDone(entry.Payer, entry.Channel, settled) {
+			continue
+		}
+
+		channels, err := channelsOf(entry.Payer)
+		if err != nil {
+			return err
+		}
+
+		channel, ok := channels[entry.Channel.KeyString()]
+		if !ok || !ours[channel.Target] {
+			continue
+		}
+
+		if height.GreaterEqual(channel.Eol) || height.Add(types.NewBlockHeight(CloseWindow)).LessThan(channel.Eol) {
+			continue
+		}
+
+		vouchers, err := s.api.PaychBestVouchers(ctx, entry.Payer, entry.Channel)
+		if err != nil {
+			return err
+		}
+		if len(vouchers) == 0 {
+			continue
+		}
+
+		// closing redeems a single lane and returns the rest of the channel
+		// to the payer, so the other lanes are redeemed first
+		if err := s.closeLanes(ctx, channel.Target, vouchers); err != nil {
+			log.Errorf("failed to close payment channel %s of %s: %s", entry.Channel, entry.Payer, err)
+		}
+	}
+
+	return nil
+}
+
+// reclaimAsPayer reclaims the funds left in the expired channels of payer.
+func (s *Settler) reclaimAsPayer(ctx context.Context, height *types.BlockHeight, payer address.Address, settled map[string]bool, channelsOf channelsFunc) error {
+	channels, err := channelsOf(payer)
+	if err != nil {
+		return err
+	}
+
+	for key, channel := range channels {
+		chid, ok := types.NewChannelIDFromString(key, 10)
+		if !ok {
+			return errors.Errorf("invalid channel id %s", key)
+		}
+
+		if s.isDone(payer, chid, settled) || height.LessThan(channel.Eol) {
+			continue
+		}
+		if channel.Amount.LessEqual(channel.AmountRedeemed) {
+			continue
+		}
+
+		if err := s.reclaim(ctx, payer, chid); err != nil {
+			log.Errorf("failed to reclaim payment channel %s of %s: %s", chid, payer, err)
+		}
+	}
+
+	return nil
+}
+
+// closeLanes redeems the vouchers of all lanes but the last, then closes the
+// channel with the voucher of the last lane. The messages are sent in order
+// from target, so they are applied in that order.
+func (s *Settler) closeLanes(ctx context.Context, target address.Address, vouchers []*paymentbroker.PaymentVoucher) (err error) {
+	last := vouchers[len(vouchers)-1]
+
+	ctx = log.Start(ctx, "Settler.closeLanes")
+	log.SetTag(ctx, "payer", last.Payer.String())
+	log.SetTag(ctx, "channel", last.Channel.String())
+	log.SetTag(ctx, "lanes", len(vouchers))
+	defer func() {
+		log.FinishWithErr(ctx, err)
+	}()
+
+	for _, voucher := range vouchers[:len(vouchers)-1] {
+		if _, err := s.sendVoucher(ctx, target, "redeem", voucher); err != nil {
+			return errors.Wrapf(err, "failed to redeem lane %d", voucher.Lane)
+		}
+	}
+
+	msgCid, err := s.sendVoucher(ctx, target, "close", last)
+	if err != nil {
+		return err
+	}
+	log.SetTag(ctx, "message", msgCid.String())
+
+	s.waitSettled(ctx, last.Payer, &last.Channel, msgCid)
+	return nil
+}
+
+// sendVoucher sends a message from target calling method, redeem or close,
+// with voucher.
+func (s *Settler) sendVoucher(ctx context.Context, target address.Address, method string, voucher *paymentbroker.PaymentVoucher) (cid.Cid, error) {
+	condition, err := paymentbroker.EncodeCondition(voucher.Condition)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	return s.api.MessageSend(
+		ctx,
+		target,
+		address.PaymentBrokerAddress,
+		types.NewAttoFILFromFIL(0),
+		s.gasPrice,
+		s.gasLimit,
+		method,
+		voucher.Payer, &voucher.Channel, big.NewInt(0).SetUint64(voucher.Lane), &voucher.Amount, &voucher.ValidAt, condition, []byte(voucher.Signature),
+	)
+}
+
+func (s *Settler) reclaim(ctx context.Context, payer address.Address, chid *types.ChannelID) (err error) {
+	ctx = log.Start(ctx, "Settler.reclaim")
+	log.SetTag(ctx, "payer", payer.String())
+	log.SetTag(ctx, "channel", chid.String())
+	defer func() {
+		log.FinishWithErr(ctx, err)
+	}()
+
+	msgCid, err := s.api.MessageSend(
+		ctx,
+		payer,
+		address.PaymentBrokerAddress,
+		types.NewAttoFILFromFIL(0),
+		s.gasPrice,
+		s.gasLimit,
+		"reclaim",
+		chid,
+	)
+	if err != nil {
+		return err
+	}
+	log.SetTag(ctx, "message", msgCid.String())
+
+	s.waitSettled(ctx, payer, chid, msgCid)
+	return nil
+}
+
+// waitSettled marks a channel pending until the message settling it has been
+// applied, and records it as settled in the ledger if the message succeeded.
+// If it failed, the channel is settled again on a later head.
+func (s *Settler) waitSettled(ctx context.Context, payer address.Address, chid *types.ChannelID, msgCid cid.Cid) {
+	key := settledKey(payer, chid)
+	s.pendingLk.Lock()
+	s.pending[key] = true
+	s.pendingLk.Unlock()
+
+	go func() {
+		defer func() {
+			s.pendingLk.Lock()
+			delete(s.pending, key)
+			s.pendingLk.Unlock()
+		}()
+
+		err := s.api.MessageWait(ctx, msgCid, func(blk *types.Block, smsg *types.SignedMessage, receipt *types.MessageReceipt) error {
+			if receipt.ExitCode != 0 {
+				return errors.Errorf("message %s failed with exit code %d", msgCid, receipt.ExitCode)
+			}
+			return s.api.PaychRecordSettled(payer, chid)
+		})
+		if err != nil {
+			log.Errorf("failed to settle payment channel %s of %s: %s", chid, payer, err)
+		}
+	}()
+}
+
+// channels returns the channels of payer on chain, by channel id.
+func (s *Settler) channels(ctx context.Context, payer address.Address) (map[string]*paymentbroker.PaymentChannel, error) {
+	values, _, err := s.api.MessageQuery(ctx, address.Address{}, address.PaymentBrokerAddress, "ls", payer)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not query payment channels")
+	}
+
+	var channels map[string]*paymentbroker.PaymentChannel
+	if err := cbor.DecodeInto(values[0], &channels); err != nil {
+		return nil, errors.Wrap(err, "could not decode payment channels")
+	}
+	return channels, nil
+}
+
+// channelsFunc returns the channels of payer on chain, by channel id.
+type channelsFunc func(payer address.Address) (map[string]*paymentbroker.PaymentChannel, error)
+
+// isDone returns whether a channel has been settled or a message settling it
+// is pending.
+func (s *Settler) isDone(payer address.Address, chid *types.ChannelID, settled map[string]bool) bool {
+	key := settledKey(payer, chid)
+	if settled[key] {
+		return true
+	}
+
+	s.pendingLk.Lock()
+	defer s.pendingLk.Unlock()
+	return s.pending[key]
+}
+
+func settledKey(payer address.Address, chid *types.ChannelID) string {
+	return payer.String() + "/" + chid.String()
+}
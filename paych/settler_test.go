@@ -0,0 +1,304 @@
+package paych
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/paymentbroker"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/exec"
+	"github.com/filecoin-project/go-filecoin/porcelain"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+type sentMessage struct {
+	from   address.Address
+	method string
+	params []interface{}
+}
+
+type settlerTestPorcelain struct {
+	wallet   []address.Address
+	channels map[address.Address]map[string]*paymentbroker.PaymentChannel
+	entries  []*porcelain.PaychLedgerEntry
+	best     []*paymentbroker.PaymentVoucher
+
+	// applied receives the exit codes of the sent messages, which are
+	// waited for until one is received.
+	applied chan uint8
+
+	lk   sync.Mutex
+	sent []sentMessage
+}
+
+func newSettlerTestPorcelain() *settlerTestPorcelain {
+	return &settlerTestPorcelain{applied: make(chan uint8)}
+}
+
+func (stp *settlerTestPorcelain) MessageQuery(ctx context.Context, optFrom, to address.Address, method string, params ...interface{}) ([][]byte, *exec.FunctionSignature, error) {
+	channels, ok := stp.channels[params[0].(address.Address)]
+	if !ok {
+		channels = map[string]*paymentbroker.PaymentChannel{}
+	}
+	channelsBytes, err := actor.MarshalStorage(channels)
+	if err != nil {
+		panic(err)
+	}
+	return [][]byte{channelsBytes}, nil, nil
+}
+
+func (stp *settlerTestPorcelain) MessageSend(ctx context.Context, from, to address.Address, value *types.AttoFIL, gasPrice types.AttoFIL, gasLimit types.GasUnits, method string, params ...interface{}) (cid.Cid, error) {
+	stp.lk.Lock()
+	defer stp.lk.Unlock()
+	stp.sent = append(stp.sent, sentMessage{from: from, method: method, params: params})
+	return types.NewCidForTestGetter()(), nil
+}
+
+func (stp *settlerTestPorcelain) MessageWait(ctx context.Context, msgCid cid.Cid, cb func(*types.Block, *types.SignedMessage, *types.MessageReceipt) error) error {
+	select {
+	case exitCode := <-stp.applied:
+		return cb(&types.Block{}, &types.SignedMessage{}, &types.MessageReceipt{ExitCode: exitCode})
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (stp *settlerTestPorcelain) PaychBestVouchers(ctx context.Context, payer address.Address, channel *types.ChannelID) ([]*paymentbroker.PaymentVoucher, error) {
+	return stp.best, nil
+}
+
+func (stp *settlerTestPorcelain) PaychLedgerEntries() ([]*porcelain.PaychLedgerEntry, error) {
+	stp.lk.Lock()
+	defer stp.lk.Unlock()
+	return stp.entries, nil
+}
+
+func (stp *settlerTestPorcelain) PaychRecordSettled(payer address.Address, channel *types.ChannelID) error {
+	stp.lk.Lock()
+	defer stp.lk.Unlock()
+	for _, entry := range stp.entries {
+		if entry.Payer == payer && entry.Channel.Equal(channel) {
+			entry.Settled = true
+			return nil
+		}
+	}
+	stp.entries = append(stp.entries, &porcelain.PaychLedgerEntry{Payer: payer, Channel: channel, Settled: true})
+	return nil
+}
+
+func (stp *settlerTestPorcelain) WalletAddresses() []address.Address {
+	return stp.wallet
+}
+
+func (stp *settlerTestPorcelain) sentMessages() []sentMessage {
+	stp.lk.Lock()
+	defer stp.lk.Unlock()
+	return append([]sentMessage{}, stp.sent...)
+}
+
+func requireTipSetAt(t *testing.T, height uint64) types.TipSet {
+	ts, err := types.NewTipSet(&types.Block{Height: types.Uint64(height)})
+	require.NoError(t, err)
+	return ts
+}
+
+// requireSettled waits until the message settling a channel has been handled.
+func requireSettled(t *testing.T, settler *Settler, payer address.Address, chid *types.ChannelID) {
+	for i := 0; i < 100; i++ {
+		if !settler.isDone(payer, chid, nil) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Fail(t, "channel is still pending")
+}
+
+func TestSettlerReclaimsAsPayer(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	addresses := address.NewForTestGetter()
+	payer, target := addresses(), addresses()
+	api := newSettlerTestPorcelain()
+	api.wallet = []address.Address{payer}
+	api.channels = map[address.Address]map[string]*paymentbroker.PaymentChannel{
+		payer: {
+			"1": {Target: target, Amount: types.NewAttoFILFromFIL(100), AmountRedeemed: types.NewAttoFILFromFIL(10), Eol: types.NewBlockHeight(20)},
+			"2": {Target: target, Amount: types.NewAttoFILFromFIL(100), AmountRedeemed: types.NewAttoFILFromFIL(100), Eol: types.NewBlockHeight(20)},
+			"3": {Target: target, Amount: types.NewAttoFILFromFIL(100), AmountRedeemed: types.NewAttoFILFromFIL(0), Eol: types.NewBlockHeight(30)},
+		},
+	}
+	settler := NewSettler(api, types.NewGasPrice(1), types.NewGasUnits(300))
+
+	settler.settle(ctx, requireTipSetAt(t, 19))
+	assert.Len(api.sentMessages(), 0)
+
+	settler.settle(ctx, requireTipSetAt(t, 20))
+	sent := api.sentMessages()
+	assert.Len(sent, 1)
+	assert.Equal(payer, sent[0].from)
+	assert.Equal("reclaim", sent[0].method)
+	assert.Equal(types.NewChannelID(1), sent[0].params[0])
+
+	// the channel is not reclaimed again while the message is pending
+	settler.settle(ctx, requireTipSetAt(t, 21))
+	assert.Len(api.sentMessages(), 1)
+
+	// each channel is reclaimed only once its message has been applied
+	api.applied <- 0
+	requireSettled(t, settler, payer, types.NewChannelID(1))
+	settler.settle(ctx, requireTipSetAt(t, 22))
+	assert.Len(api.sentMessages(), 1)
+	entries, err := api.PaychLedgerEntries()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.True(entries[0].Settled)
+}
+
+func TestSettlerRetriesFailedMessages(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	addresses := address.NewForTestGetter()
+	payer, target := addresses(), addresses()
+	api := newSettlerTestPorcelain()
+	api.wallet = []address.Address{payer}
+	api.channels = map[address.Address]map[string]*paymentbroker.PaymentChannel{
+		payer: {
+			"1": {Target: target, Amount: types.NewAttoFILFromFIL(100), AmountRedeemed: types.NewAttoFILFromFIL(10), Eol: types.NewBlockHeight(20)},
+		},
+	}
+	settler := NewSettler(api, types.NewGasPrice(1), types.NewGasUnits(300))
+
+	settler.settle(ctx, requireTipSetAt(t, 20))
+	assert.Len(api.sentMessages(), 1)
+
+	api.applied <- 1
+	requireSettled(t, settler, payer, types.NewChannelID(1))
+	settler.settle(ctx, requireTipSetAt(t, 21))
+	assert.Len(api.sentMessages(), 2)
+
+	entries, err := api.PaychLedgerEntries()
+	require.NoError(t, err)
+	assert.Len(entries, 0)
+}
+
+func TestSettlerClosesAsPayee(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	addresses := address.NewForTestGetter()
+	payer, target := addresses(), addresses()
+	chid := types.NewChannelID(1)
+	voucher := &paymentbroker.PaymentVoucher{
+		Channel: *chid,
+		Payer:   payer,
+		Target:  target,
+		Amount:  *types.NewAttoFILFromFIL(50),
+	}
+	api := newSettlerTestPorcelain()
+	api.wallet = []address.Address{target}
+	api.channels = map[address.Address]map[string]*paymentbroker.PaymentChannel{
+		payer: {
+			"1": {Target: target, Amount: types.NewAttoFILFromFIL(100), AmountRedeemed: types.NewAttoFILFromFIL(0), Eol: types.NewBlockHeight(100)},
+		},
+	}
+	api.entries = []*porcelain.PaychLedgerEntry{
+		{Payer: payer, Channel: chid, Received: []*paymentbroker.PaymentVoucher{voucher}},
+	}
+	api.best = []*paymentbroker.PaymentVoucher{voucher}
+	settler := NewSettler(api, types.NewGasPrice(1), types.NewGasUnits(300))
+
+	settler.settle(ctx, requireTipSetAt(t, 100-CloseWindow-1))
+	assert.Len(api.sentMessages(), 0)
+
+	settler.settle(ctx, requireTipSetAt(t, 100-CloseWindow))
+	sent := api.sentMessages()
+	assert.Len(sent, 1)
+	assert.Equal(target, sent[0].from)
+	assert.Equal("close", sent[0].method)
+	assert.Equal(payer, sent[0].params[0])
+
+	api.applied <- 0
+	requireSettled(t, settler, payer, chid)
+	settler.settle(ctx, requireTipSetAt(t, 100-CloseWindow+1))
+	assert.Len(api.sentMessages(), 1)
+	assert.True(api.entries[0].Settled)
+}
+
+func TestSettlerRedeemsEachLaneBeforeClosing(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	addresses := address.NewForTestGetter()
+	payer, target := addresses(), addresses()
+	chid := types.NewChannelID(1)
+	var vouchers []*paymentbroker.PaymentVoucher
+	for lane := uint64(0); lane < 3; lane++ {
+		vouchers = append(vouchers, &paymentbroker.PaymentVoucher{
+			Channel: *chid,
+			Payer:   payer,
+			Target:  target,
+			Lane:    lane,
+			Amount:  *types.NewAttoFILFromFIL(10 * (lane + 1)),
+		})
+	}
+	api := newSettlerTestPorcelain()
+	api.wallet = []address.Address{target}
+	api.channels = map[address.Address]map[string]*paymentbroker.PaymentChannel{
+		payer: {
+			"1": {Target: target, Amount: types.NewAttoFILFromFIL(100), AmountRedeemed: types.NewAttoFILFromFIL(0), Eol: types.NewBlockHeight(100)},
+		},
+	}
+	api.entries = []*porcelain.PaychLedgerEntry{
+		{Payer: payer, Channel: chid, Received: vouchers},
+	}
+	api.best = vouchers
+	settler := NewSettler(api, types.NewGasPrice(1), types.NewGasUnits(300))
+
+	settler.settle(ctx, requireTipSetAt(t, 100-CloseWindow))
+	sent := api.sentMessages()
+	assert.Len(sent, 3)
+	for i, method := range []string{"redeem", "redeem", "close"} {
+		assert.Equal(target, sent[i].from)
+		assert.Equal(method, sent[i].method)
+		assert.Equal(big.NewInt(int64(i)), sent[i].params[2])
+		assert.Equal(&vouchers[i].Amount, sent[i].params[3])
+	}
+
+	api.applied <- 0
+	requireSettled(t, settler, payer, chid)
+	assert.True(api.entries[0].Settled)
+}
+
+func TestSettlerSettlesInBackground(t *testing.T) {
+	assert := assert.New(t)
+
+	addresses := address.NewForTestGetter()
+	payer, target := addresses(), addresses()
+	api := newSettlerTestPorcelain()
+	api.wallet = []address.Address{payer}
+	api.channels = map[address.Address]map[string]*paymentbroker.PaymentChannel{
+		payer: {
+			"1": {Target: target, Amount: types.NewAttoFILFromFIL(100), AmountRedeemed: types.NewAttoFILFromFIL(10), Eol: types.NewBlockHeight(20)},
+		},
+	}
+	settler := NewSettler(api, types.NewGasPrice(1), types.NewGasUnits(300))
+	settler.Start(context.Background())
+	defer settler.Stop()
+
+	settler.OnNewHeaviestTipSet(requireTipSetAt(t, 20))
+	for i := 0; i < 100 && len(api.sentMessages()) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Len(api.sentMessages(), 1)
+}
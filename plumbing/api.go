@@ -2,19 +2,24 @@ package plumbing
 
 import (
 	"context"
+	"time"
 
 	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
 	"gx/ipfs/QmY5Grm8pJdiSSVsYxx4uNRgweY72EmYwuSDbRnbFok3iY/go-libp2p-peer"
 	logging "gx/ipfs/QmcuXC5cxs79ro2cUuHs4HQ2bkDLJUYokwL8aivcX6HW3C/go-log"
 
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/chain"
 	"github.com/filecoin-project/go-filecoin/core"
 	"github.com/filecoin-project/go-filecoin/exec"
+	"github.com/filecoin-project/go-filecoin/gc"
 	"github.com/filecoin-project/go-filecoin/plumbing/cfg"
 	"github.com/filecoin-project/go-filecoin/plumbing/chn"
 	"github.com/filecoin-project/go-filecoin/plumbing/msg"
 	"github.com/filecoin-project/go-filecoin/plumbing/mthdsig"
 	"github.com/filecoin-project/go-filecoin/plumbing/ntwk"
+	"github.com/filecoin-project/go-filecoin/proofs"
 	"github.com/filecoin-project/go-filecoin/types"
 	"github.com/filecoin-project/go-filecoin/wallet"
 )
@@ -27,8 +32,10 @@ import (
 type API struct {
 	logger logging.EventLogger
 
+	badTipSets   *chain.BadTipSetCache
 	chain        *chn.Reader
 	config       *cfg.Config
+	gc           *gc.Collector
 	messagePool  *core.MessagePool
 	msgPreviewer *msg.Previewer
 	msgQueryer   *msg.Queryer
@@ -36,13 +43,17 @@ type API struct {
 	msgWaiter    *msg.Waiter
 	network      *ntwk.Network
 	sigGetter    *mthdsig.Getter
+	syncer       chain.Syncer
+	verifier     *proofs.CachingVerifier
 	wallet       *wallet.Wallet
 }
 
 // APIDeps contains all the API's dependencies
 type APIDeps struct {
+	BadTipSets   *chain.BadTipSetCache
 	Chain        *chn.Reader
 	Config       *cfg.Config
+	GC           *gc.Collector
 	MessagePool  *core.MessagePool
 	MsgPreviewer *msg.Previewer
 	MsgQueryer   *msg.Queryer
@@ -50,6 +61,8 @@ type APIDeps struct {
 	MsgWaiter    *msg.Waiter
 	Network      *ntwk.Network
 	SigGetter    *mthdsig.Getter
+	Syncer       chain.Syncer
+	Verifier     *proofs.CachingVerifier
 	Wallet       *wallet.Wallet
 }
 
@@ -58,8 +71,10 @@ func New(deps *APIDeps) *API {
 	return &API{
 		logger: logging.Logger("porcelain"),
 
+		badTipSets:   deps.BadTipSets,
 		chain:        deps.Chain,
 		config:       deps.Config,
+		gc:           deps.GC,
 		messagePool:  deps.MessagePool,
 		msgPreviewer: deps.MsgPreviewer,
 		msgQueryer:   deps.MsgQueryer,
@@ -67,6 +82,8 @@ func New(deps *APIDeps) *API {
 		msgWaiter:    deps.MsgWaiter,
 		network:      deps.Network,
 		sigGetter:    deps.SigGetter,
+		syncer:       deps.Syncer,
+		verifier:     deps.Verifier,
 		wallet:       deps.Wallet,
 	}
 }
@@ -104,11 +121,62 @@ func (api *API) ChainLs(ctx context.Context) <-chan interface{} {
 	return api.chain.Ls(ctx)
 }
 
+// ChainSyncStatus returns the state of the active syncs of the chain and the
+// outcome of recent ones.
+func (api *API) ChainSyncStatus() *chain.SyncStatus {
+	return api.syncer.Status()
+}
+
+// ChainReorgs returns the most recent switches of the head to another branch
+// of the chain, latest first.
+func (api *API) ChainReorgs() []chain.Reorg {
+	return api.syncer.Reorgs()
+}
+
+// ChainForks returns the known tipsets which are not on the chain of the head,
+// highest first, with their weights.
+func (api *API) ChainForks(ctx context.Context) ([]chain.ForkTipSet, error) {
+	return api.syncer.Forks(ctx)
+}
+
+// ChainVerifierStats returns the metrics of the proof verifier used to
+// validate the chain: the proofs it checked, its cache hits and the time the
+// checks took.
+func (api *API) ChainVerifierStats() (proofs.VerificationStats, error) {
+	if api.verifier == nil {
+		return proofs.VerificationStats{}, errors.New("the proof verifier does not record metrics")
+	}
+	return api.verifier.Stats(), nil
+}
+
+// ChainBadTipSets returns the tipsets the syncer rejected, with the reason
+// they were rejected, in the order they were first seen.
+func (api *API) ChainBadTipSets() []chain.BadTipSet {
+	return api.badTipSets.List()
+}
+
+// ChainRemoveBadTipSet forgets that the tipset with the given key was
+// rejected, so that the syncer validates it again if it sees it.
+func (api *API) ChainRemoveBadTipSet(key types.SortedCidSet) error {
+	return api.badTipSets.Remove(key.String())
+}
+
+// ChainClearBadTipSets forgets all the tipsets the syncer rejected.
+func (api *API) ChainClearBadTipSets() error {
+	return api.badTipSets.Clear()
+}
+
 // BlockGet gets a block by CID
 func (api *API) BlockGet(ctx context.Context, id cid.Cid) (*types.Block, error) {
 	return api.chain.BlockGet(ctx, id)
 }
 
+// RepoGC removes the chain and state data which is no longer needed from the
+// repo.
+func (api *API) RepoGC(ctx context.Context) (*gc.Result, error) {
+	return api.gc.Collect(ctx)
+}
+
 // MessagePoolRemove removes a message from the message pool
 func (api *API) MessagePoolRemove(cid cid.Cid) {
 	api.messagePool.Remove(cid)
@@ -136,6 +204,18 @@ func (api *API) MessageSend(ctx context.Context, from, to address.Address, value
 	return api.msgSender.Send(ctx, from, to, value, gasPrice, gasLimit, method, params...)
 }
 
+// MessageCreate builds an unsigned message with the next nonce of from, for
+// signing offline. See MessageSubmit.
+func (api *API) MessageCreate(ctx context.Context, from, to address.Address, value *types.AttoFIL, method string, params ...interface{}) (*types.Message, error) {
+	return api.msgSender.Create(ctx, from, to, value, method, params...)
+}
+
+// MessageSubmit validates a message signed offline, adds it to the message
+// pool and publishes it to the network.
+func (api *API) MessageSubmit(ctx context.Context, smsg *types.SignedMessage) (cid.Cid, error) {
+	return api.msgSender.Submit(ctx, smsg)
+}
+
 // MessageWait invokes the callback when a message with the given cid appears on chain.
 // It will find the message in both the case that it is already on chain and
 // the case that it appears in a newly mined block. An error is returned if one is
@@ -169,3 +249,18 @@ func (api *API) WalletFind(address address.Address) (wallet.Backend, error) {
 func (api *API) WalletNewAddress() (address.Address, error) {
 	return wallet.NewAddress(api.wallet)
 }
+
+// WalletEncrypt encrypts the wallet keys with a key derived from passphrase
+func (api *API) WalletEncrypt(passphrase []byte) error {
+	return wallet.Encrypt(api.wallet, passphrase)
+}
+
+// WalletUnlock unlocks the wallet keys, for timeout if it is not zero
+func (api *API) WalletUnlock(passphrase []byte, timeout time.Duration) error {
+	return wallet.Unlock(api.wallet, passphrase, timeout)
+}
+
+// WalletLock locks the wallet keys
+func (api *API) WalletLock() error {
+	return wallet.Lock(api.wallet)
+}
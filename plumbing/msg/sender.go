@@ -67,22 +67,81 @@ func (s *Sender) Send(ctx context.Context, from, to address.Address, value *type
 		return cid.Undef, errors.Wrap(err, "failed to sign message")
 	}
 
+	if err := s.push(smsg); err != nil {
+		return cid.Undef, err
+	}
+
+	log.Debugf("MessageSend with message: %s", smsg)
+
+	return smsg.Cid()
+}
+
+// Create builds an unsigned message with the next nonce of from, to be
+// signed elsewhere and handed back to Submit. The nonce only accounts for
+// messages in the pool, so messages created before the previous one is
+// submitted share its nonce.
+func (s *Sender) Create(ctx context.Context, from, to address.Address, value *types.AttoFIL, method string, params ...interface{}) (*types.Message, error) {
+	encodedParams, err := abi.ToEncodedValues(params...)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid params")
+	}
+
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	nonce, err := nextNonce(ctx, s.chainReader, s.msgPool, from)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't get next nonce")
+	}
+
+	return types.NewMessage(from, to, nonce, value, method, encodedParams), nil
+}
+
+// Submit validates a message signed elsewhere, adds it to the message pool
+// and publishes it to the network.
+func (s *Sender) Submit(ctx context.Context, smsg *types.SignedMessage) (cid.Cid, error) {
+	if !smsg.VerifySignature() {
+		return cid.Undef, errors.New("invalid signature")
+	}
+
+	st, err := s.chainReader.LatestState(ctx)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	fromActor, err := st.GetActor(ctx, smsg.From)
+	if err != nil {
+		return cid.Undef, errors.Wrapf(err, "failed to get actor of sender %s", smsg.From)
+	}
+	if smsg.Nonce < fromActor.Nonce {
+		return cid.Undef, errors.Errorf("nonce too low: %d, actor nonce is %d", smsg.Nonce, fromActor.Nonce)
+	}
+
+	if err := s.push(smsg); err != nil {
+		return cid.Undef, err
+	}
+
+	log.Debugf("MessageSubmit with message: %s", smsg)
+
+	return smsg.Cid()
+}
+
+// push adds a signed message to the pool and publishes it.
+func (s *Sender) push(smsg *types.SignedMessage) error {
 	smsgdata, err := smsg.Marshal()
 	if err != nil {
-		return cid.Undef, errors.Wrap(err, "failed to marshal message")
+		return errors.Wrap(err, "failed to marshal message")
 	}
 
 	if _, err := s.msgPool.Add(smsg); err != nil {
-		return cid.Undef, errors.Wrap(err, "failed to add message to the message pool")
+		return errors.Wrap(err, "failed to add message to the message pool")
 	}
 
 	if err = s.publish(Topic, smsgdata); err != nil {
-		return cid.Undef, errors.Wrap(err, "couldnt publish new message to network")
+		return errors.Wrap(err, "couldnt publish new message to network")
 	}
 
-	log.Debugf("MessageSend with message: %s", smsg)
-
-	return smsg.Cid()
+	return nil
 }
 
 // nextNonce returns the next nonce for the given address. It checks
@@ -9,6 +9,7 @@ import (
 
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/core"
 	"github.com/filecoin-project/go-filecoin/repo"
 	"github.com/filecoin-project/go-filecoin/types"
@@ -121,6 +122,80 @@ func TestNextNonce(t *testing.T) {
 	})
 }
 
+func TestCreateAndSubmit(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	ki := types.MustGenerateKeyInfo(2, types.GenerateKeyInfoSeed())
+	coldSigner := types.NewMockSigner(ki)
+	from := coldSigner.Addresses[0]
+	to := address.NewForTestGetter()()
+
+	setup := func(require *require.Assertions, publish PublishFunc) (*Sender, *core.MessagePool) {
+		gif := consensus.MakeGenesisFunc(consensus.ActorAccount(from, types.NewAttoFILFromFIL(100)))
+		d := requireCommonDepsWithGif(require, gif)
+		msgPool := core.NewMessagePool()
+		return NewSender(d.repo, d.wallet, d.chainStore, msgPool, publish), msgPool
+	}
+
+	t.Run("message created and signed elsewhere is submitted", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		publishCalled := false
+		s, msgPool := setup(require, func(string, []byte) error {
+			publishCalled = true
+			return nil
+		})
+
+		msg, err := s.Create(ctx, from, to, types.NewAttoFILFromFIL(2), "")
+		require.NoError(err)
+		assert.Equal(types.Uint64(0), msg.Nonce)
+
+		smsg, err := types.NewSignedMessage(*msg, coldSigner, types.NewGasPrice(0), types.NewGasUnits(0))
+		require.NoError(err)
+
+		c, err := s.Submit(ctx, smsg)
+		require.NoError(err)
+		assert.True(publishCalled)
+		require.Equal(1, len(msgPool.Pending()))
+		pc, err := msgPool.Pending()[0].Cid()
+		require.NoError(err)
+		assert.Equal(c, pc)
+
+		t.Log("the next message gets the next nonce")
+		msg, err = s.Create(ctx, from, to, types.NewAttoFILFromFIL(2), "")
+		require.NoError(err)
+		assert.Equal(types.Uint64(1), msg.Nonce)
+	})
+
+	t.Run("invalid messages are rejected", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		s, msgPool := setup(require, func(string, []byte) error { return nil })
+
+		msg, err := s.Create(ctx, from, to, types.NewAttoFILFromFIL(2), "")
+		require.NoError(err)
+		smsg, err := types.NewSignedMessage(*msg, coldSigner, types.NewGasPrice(0), types.NewGasUnits(0))
+		require.NoError(err)
+
+		tampered := *smsg
+		tampered.Value = types.NewAttoFILFromFIL(3)
+		_, err = s.Submit(ctx, &tampered)
+		assert.Error(err)
+
+		t.Log("the sender must have an actor")
+		noActor := types.NewMessage(coldSigner.Addresses[1], to, 0, types.NewAttoFILFromFIL(2), "", nil)
+		smsg, err = types.NewSignedMessage(*noActor, coldSigner, types.NewGasPrice(0), types.NewGasUnits(0))
+		require.NoError(err)
+		_, err = s.Submit(ctx, smsg)
+		assert.Error(err)
+
+		assert.Equal(0, len(msgPool.Pending()))
+	})
+}
+
 func setupSendTest(require *require.Assertions) (repo.Repo, *wallet.Wallet, *chain.DefaultStore, *core.MessagePool) {
 	d := requireCommonDeps(require)
 	return d.repo, d.wallet, d.chainStore, core.NewMessagePool()
@@ -8,6 +8,8 @@ import (
 	"gx/ipfs/QmY5Grm8pJdiSSVsYxx4uNRgweY72EmYwuSDbRnbFok3iY/go-libp2p-peer"
 
 	minerActor "github.com/filecoin-project/go-filecoin/actor/builtin/miner"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/multisig"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/paymentbroker"
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/plumbing"
 	"github.com/filecoin-project/go-filecoin/types"
@@ -34,11 +36,17 @@ import (
 // take this implementation as a dependency.
 type API struct {
 	*plumbing.API
+
+	paychLedger *PaychLedger
 }
 
-// New returns a new porcelain.API.
-func New(plumbing *plumbing.API) *API {
-	return &API{plumbing}
+// New returns a new porcelain.API. Payment vouchers are recorded in
+// paychLedger.
+func New(plumbing *plumbing.API, paychLedger *PaychLedger) *API {
+	return &API{
+		API:         plumbing,
+		paychLedger: paychLedger,
+	}
 }
 
 // ChainBlockHeight determines the current block height
@@ -46,7 +54,8 @@ func (a *API) ChainBlockHeight(ctx context.Context) (*types.BlockHeight, error)
 	return ChainBlockHeight(ctx, a)
 }
 
-// CreatePayments establishes a payment channel and create multiple payments against it
+// CreatePayments establishes a payment channel and create multiple payments against it.
+// The payments are recorded as issued in the payment channel ledger.
 func (a *API) CreatePayments(ctx context.Context, config CreatePaymentsParams) (*CreatePaymentsReturn, error) {
 	return CreatePayments(ctx, a, config)
 }
@@ -119,6 +128,66 @@ func (a *API) MinerPreviewSetPrice(
 	return MinerPreviewSetPrice(ctx, a, from, miner, price, expiry)
 }
 
+// MultisigCreate creates a multisig wallet. See implementation for details.
+func (a *API) MultisigCreate(ctx context.Context, from address.Address, gasPrice types.AttoFIL, gasLimit types.GasUnits, signers []address.Address, threshold uint64, value *types.AttoFIL) (address.Address, error) {
+	return MultisigCreate(ctx, a, from, gasPrice, gasLimit, signers, threshold, value)
+}
+
+// MultisigPropose proposes a transaction from a multisig wallet. See
+// implementation for details.
+func (a *API) MultisigPropose(ctx context.Context, from address.Address, gasPrice types.AttoFIL, gasLimit types.GasUnits, wallet, to address.Address, value *types.AttoFIL, method string, params ...interface{}) (uint64, error) {
+	return MultisigPropose(ctx, a, from, gasPrice, gasLimit, wallet, to, value, method, params...)
+}
+
+// MultisigApprove approves a pending transaction of a multisig wallet.
+func (a *API) MultisigApprove(ctx context.Context, from address.Address, gasPrice types.AttoFIL, gasLimit types.GasUnits, wallet address.Address, txID uint64) error {
+	return MultisigApprove(ctx, a, from, gasPrice, gasLimit, wallet, txID)
+}
+
+// MultisigLs returns the state of a multisig wallet.
+func (a *API) MultisigLs(ctx context.Context, wallet address.Address) (*multisig.State, error) {
+	return MultisigLs(ctx, a, wallet)
+}
+
+// PaychRecordIssued records a voucher issued by this node. See
+// implementation for details.
+func (a *API) PaychRecordIssued(ctx context.Context, voucher *paymentbroker.PaymentVoucher) error {
+	return PaychRecordIssued(ctx, a, a.paychLedger, voucher)
+}
+
+// PaychRecordReceived records a voucher received by this node.
+func (a *API) PaychRecordReceived(ctx context.Context, voucher *paymentbroker.PaymentVoucher) error {
+	return PaychRecordReceived(ctx, a.paychLedger, voucher)
+}
+
+// PaychRecordSettled records that a channel has been closed or reclaimed by
+// this node.
+func (a *API) PaychRecordSettled(payer address.Address, channel *types.ChannelID) error {
+	return PaychRecordSettled(a.paychLedger, payer, channel)
+}
+
+// PaychBestVoucher returns the received voucher of a channel which would pay
+// the most if redeemed now.
+func (a *API) PaychBestVoucher(ctx context.Context, payer address.Address, channel *types.ChannelID) (*paymentbroker.PaymentVoucher, error) {
+	return PaychBestVoucher(ctx, a, a.paychLedger, payer, channel)
+}
+
+// PaychBestVouchers returns the received voucher of each lane of a channel
+// which would pay the most if redeemed now, ordered by lane.
+func (a *API) PaychBestVouchers(ctx context.Context, payer address.Address, channel *types.ChannelID) ([]*paymentbroker.PaymentVoucher, error) {
+	return PaychBestVouchers(ctx, a, a.paychLedger, payer, channel)
+}
+
+// PaychLedgerEntries returns the vouchers recorded for every channel.
+func (a *API) PaychLedgerEntries() ([]*PaychLedgerEntry, error) {
+	return a.paychLedger.Entries()
+}
+
+// PaychStatus returns the status of a payment channel.
+func (a *API) PaychStatus(ctx context.Context, payer address.Address, channel *types.ChannelID) (*PaychStatusReturn, error) {
+	return PaychStatus(ctx, a, a.paychLedger, payer, channel)
+}
+
 // GetAndMaybeSetDefaultSenderAddress returns a default address from which to
 // send messsages. If none is set it picks the first address in the wallet and
 // sets it as the default in the config.
@@ -61,11 +61,7 @@ func MinerPreviewCreate(
 	if err != nil {
 		return types.NewGasUnits(0), err
 	}
-	info, err := backend.GetKeyInfo(fromAddr)
-	if err != nil {
-		return types.NewGasUnits(0), err
-	}
-	pubkey, err := info.PublicKey()
+	pubkey, err := w.PublicKey(backend, fromAddr)
 	if err != nil {
 		return types.NewGasUnits(0), err
 	}
@@ -0,0 +1,108 @@
+package porcelain
+
+import (
+	"context"
+	"math/big"
+
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	cbor "gx/ipfs/QmRoARq3nkUb13HSKZGepCZSWe5GrVPwx7xURJGZ7KWv9V/go-ipld-cbor"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+
+	"github.com/filecoin-project/go-filecoin/abi"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/multisig"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/exec"
+	"github.com/filecoin-project/go-filecoin/types"
+	vmErrors "github.com/filecoin-project/go-filecoin/vm/errors"
+)
+
+// msSendAPI is the subset of the plumbing.API that the multisig calls which
+// send messages use.
+type msSendAPI interface {
+	MessageSendWithDefaultAddress(ctx context.Context, from, to address.Address, value *types.AttoFIL, gasPrice types.AttoFIL, gasLimit types.GasUnits, method string, params ...interface{}) (cid.Cid, error)
+	MessageWait(ctx context.Context, msgCid cid.Cid, cb func(*types.Block, *types.SignedMessage, *types.MessageReceipt) error) error
+}
+
+// MultisigCreate creates a multisig wallet controlled by signers, funded with
+// value, and waits for it to be mined. threshold signers must approve each
+// transaction from the wallet. Returns the wallet's address.
+func MultisigCreate(ctx context.Context, plumbing msSendAPI, from address.Address, gasPrice types.AttoFIL, gasLimit types.GasUnits, signers []address.Address, threshold uint64, value *types.AttoFIL) (address.Address, error) {
+	ret, err := multisigSendAndWait(ctx, plumbing, from, address.MultisigAddress, value, gasPrice, gasLimit, "create", signers, big.NewInt(0).SetUint64(threshold))
+	if err != nil {
+		return address.Address{}, err
+	}
+
+	return address.NewFromBytes(ret[0])
+}
+
+// MultisigPropose proposes a transaction from a multisig wallet sending value
+// to an actor and calling method with params. It waits for the proposal to be
+// mined and returns the transaction's id. The transaction is sent once
+// enough signers approve it; the proposal counts as the first approval.
+func MultisigPropose(ctx context.Context, plumbing msSendAPI, from address.Address, gasPrice types.AttoFIL, gasLimit types.GasUnits, wallet, to address.Address, value *types.AttoFIL, method string, params ...interface{}) (uint64, error) {
+	encodedParams, err := abi.ToEncodedTypedValues(params...)
+	if err != nil {
+		return 0, errors.Wrap(err, "invalid transaction params")
+	}
+	if encodedParams == nil {
+		encodedParams = []byte{}
+	}
+
+	ret, err := multisigSendAndWait(ctx, plumbing, from, wallet, types.NewZeroAttoFIL(), gasPrice, gasLimit, "propose", to, value, method, encodedParams)
+	if err != nil {
+		return 0, err
+	}
+
+	return big.NewInt(0).SetBytes(ret[0]).Uint64(), nil
+}
+
+// MultisigApprove approves a pending transaction of a multisig wallet and
+// waits for the approval to be mined.
+func MultisigApprove(ctx context.Context, plumbing msSendAPI, from address.Address, gasPrice types.AttoFIL, gasLimit types.GasUnits, wallet address.Address, txID uint64) error {
+	_, err := multisigSendAndWait(ctx, plumbing, from, wallet, types.NewZeroAttoFIL(), gasPrice, gasLimit, "approve", big.NewInt(0).SetUint64(txID))
+	return err
+}
+
+// multisigSendAndWait sends a message to a multisig actor and waits for it to
+// be mined, returning its return values.
+func multisigSendAndWait(ctx context.Context, plumbing msSendAPI, from, to address.Address, value *types.AttoFIL, gasPrice types.AttoFIL, gasLimit types.GasUnits, method string, params ...interface{}) ([]types.Bytes, error) {
+	msgCid, err := plumbing.MessageSendWithDefaultAddress(ctx, from, to, value, gasPrice, gasLimit, method, params...)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't send message")
+	}
+
+	var ret []types.Bytes
+	err = plumbing.MessageWait(ctx, msgCid, func(blk *types.Block, smsg *types.SignedMessage, receipt *types.MessageReceipt) error {
+		if receipt.ExitCode != uint8(0) {
+			return vmErrors.VMExitCodeToError(receipt.ExitCode, multisig.Errors)
+		}
+		ret = receipt.Return
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// mslsAPI is the subset of the plumbing.API that MultisigLs uses.
+type mslsAPI interface {
+	MessageQuery(ctx context.Context, optFrom, to address.Address, method string, params ...interface{}) ([][]byte, *exec.FunctionSignature, error)
+}
+
+// MultisigLs returns the signers, threshold and pending transactions of a
+// multisig wallet.
+func MultisigLs(ctx context.Context, plumbing mslsAPI, wallet address.Address) (*multisig.State, error) {
+	ret, _, err := plumbing.MessageQuery(ctx, address.Address{}, wallet, "getState")
+	if err != nil {
+		return nil, err
+	}
+
+	var state multisig.State
+	if err := cbor.DecodeInto(ret[0], &state); err != nil {
+		return nil, errors.Wrap(err, "could not decode multisig state")
+	}
+
+	return &state, nil
+}
@@ -0,0 +1,124 @@
+package porcelain
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	cbor "gx/ipfs/QmRoARq3nkUb13HSKZGepCZSWe5GrVPwx7xURJGZ7KWv9V/go-ipld-cbor"
+
+	"github.com/filecoin-project/go-filecoin/abi"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/multisig"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/exec"
+	"github.com/filecoin-project/go-filecoin/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// multisigPlumbing records the last message sent and answers MessageWait with
+// a receipt carrying exitCode and ret.
+type multisigPlumbing struct {
+	require *require.Assertions
+
+	to     address.Address
+	method string
+	params []interface{}
+
+	exitCode uint8
+	ret      []types.Bytes
+}
+
+func (mp *multisigPlumbing) MessageSendWithDefaultAddress(ctx context.Context, from, to address.Address, value *types.AttoFIL, gasPrice types.AttoFIL, gasLimit types.GasUnits, method string, params ...interface{}) (cid.Cid, error) {
+	mp.to = to
+	mp.method = method
+	mp.params = params
+	return types.SomeCid(), nil
+}
+
+func (mp *multisigPlumbing) MessageWait(ctx context.Context, msgCid cid.Cid, cb func(*types.Block, *types.SignedMessage, *types.MessageReceipt) error) error {
+	return cb(&types.Block{}, &types.SignedMessage{}, &types.MessageReceipt{ExitCode: mp.exitCode, Return: mp.ret})
+}
+
+func (mp *multisigPlumbing) MessageQuery(ctx context.Context, optFrom, to address.Address, method string, params ...interface{}) ([][]byte, *exec.FunctionSignature, error) {
+	mp.to = to
+	mp.method = method
+
+	st := multisig.State{
+		Signers:   []address.Address{address.TestAddress, address.TestAddress2},
+		Threshold: 2,
+		Pending:   map[string]multisig.Transaction{},
+	}
+	bytes, err := cbor.DumpObject(st)
+	mp.require.NoError(err)
+
+	return [][]byte{bytes}, nil, nil
+}
+
+func TestMultisigCreate(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	wallet := address.NewForTestGetter()()
+	plumbing := &multisigPlumbing{require: require, ret: []types.Bytes{wallet.Bytes()}}
+
+	signers := []address.Address{address.TestAddress, address.TestAddress2}
+	addr, err := MultisigCreate(context.Background(), plumbing, address.Address{}, types.NewGasPrice(0), types.NewGasUnits(0), signers, 2, types.NewAttoFILFromFIL(10))
+	require.NoError(err)
+
+	assert.Equal(wallet, addr)
+	assert.Equal(address.MultisigAddress, plumbing.to)
+	assert.Equal("create", plumbing.method)
+	assert.Equal([]interface{}{signers, big.NewInt(2)}, plumbing.params)
+}
+
+func TestMultisigPropose(t *testing.T) {
+	t.Run("encodes the transaction's params with their types", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		wallet := address.NewForTestGetter()()
+		plumbing := &multisigPlumbing{require: require, ret: []types.Bytes{big.NewInt(7).Bytes()}}
+
+		txID, err := MultisigPropose(context.Background(), plumbing, address.Address{}, types.NewGasPrice(0), types.NewGasUnits(0), wallet, address.TestAddress, types.NewZeroAttoFIL(), "changeThreshold", big.NewInt(1))
+		require.NoError(err)
+
+		assert.Equal(uint64(7), txID)
+		assert.Equal(wallet, plumbing.to)
+		assert.Equal("propose", plumbing.method)
+
+		values, err := abi.DecodeTypedValues(plumbing.params[3].([]byte))
+		require.NoError(err)
+		require.Len(values, 1)
+		assert.Equal(abi.Integer, values[0].Type)
+		assert.Equal(big.NewInt(1), values[0].Val)
+	})
+
+	t.Run("maps exit codes to multisig errors", func(t *testing.T) {
+		require := require.New(t)
+
+		plumbing := &multisigPlumbing{require: require, exitCode: multisig.ErrNotSigner}
+
+		_, err := MultisigPropose(context.Background(), plumbing, address.Address{}, types.NewGasPrice(0), types.NewGasUnits(0), address.TestAddress, address.TestAddress2, types.NewZeroAttoFIL(), "")
+		require.Error(err)
+		require.Contains(err.Error(), multisig.Errors[multisig.ErrNotSigner].Error())
+	})
+}
+
+func TestMultisigLs(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	wallet := address.NewForTestGetter()()
+	plumbing := &multisigPlumbing{require: require}
+
+	st, err := MultisigLs(context.Background(), plumbing, wallet)
+	require.NoError(err)
+
+	assert.Equal(wallet, plumbing.to)
+	assert.Equal("getState", plumbing.method)
+	assert.Equal(uint64(2), st.Threshold)
+	assert.Equal([]address.Address{address.TestAddress, address.TestAddress2}, st.Signers)
+}
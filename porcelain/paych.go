@@ -0,0 +1,329 @@
+package porcelain
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	cbor "gx/ipfs/QmRoARq3nkUb13HSKZGepCZSWe5GrVPwx7xURJGZ7KWv9V/go-ipld-cbor"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+	"gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore"
+	"gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore/query"
+
+	"github.com/filecoin-project/go-filecoin/actor/builtin/paymentbroker"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/exec"
+	"github.com/filecoin-project/go-filecoin/repo"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+const paychDatastorePrefix = "paych"
+
+// ErrDoubleIssuance is returned when recording an issued voucher would
+// promise more than a channel holds: the best vouchers of all lanes together
+// could then not all be redeemed.
+var ErrDoubleIssuance = errors.New("vouchers issued on the channel exceed its funds")
+
+// ErrUnknownPaymentChannel is returned when a channel cannot be found on chain.
+var ErrUnknownPaymentChannel = errors.New("payment channel not found")
+
+func init() {
+	cbor.RegisterCborType(PaychLedgerEntry{})
+}
+
+// PaychLedgerEntry holds the vouchers this node has issued and received on
+// one payment channel. Settled records that a message of this node closing
+// or reclaiming the channel has been applied on chain.
+type PaychLedgerEntry struct {
+	Payer    address.Address
+	Channel  *types.ChannelID
+	Issued   []*paymentbroker.PaymentVoucher
+	Received []*paymentbroker.PaymentVoucher
+	Settled  bool
+}
+
+// PaychLedger persists payment vouchers, so that a node knows which vouchers
+// it has issued and received independently of the deals they were made for.
+type PaychLedger struct {
+	lk sync.Mutex
+	ds repo.Datastore
+}
+
+// NewPaychLedger returns a ledger storing its entries in ds.
+func NewPaychLedger(ds repo.Datastore) *PaychLedger {
+	return &PaychLedger{ds: ds}
+}
+
+// Get returns the ledger entry of a channel. The entry of a channel without
+// recorded vouchers is empty.
+func (l *PaychLedger) Get(payer address.Address, channel *types.ChannelID) (*PaychLedgerEntry, error) {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	return l.get(payer, channel)
+}
+
+// Entries returns the ledger entries of all channels with recorded vouchers.
+func (l *PaychLedger) Entries() ([]*PaychLedgerEntry, error) {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	results, err := l.ds.Query(query.Query{Prefix: "/" + paychDatastorePrefix})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query payment channel ledger")
+	}
+
+	var entries []*PaychLedgerEntry
+	for result := range results.Next() {
+		var entry PaychLedgerEntry
+		if err := cbor.DecodeInto(result.Value, &entry); err != nil {
+			return nil, errors.Wrap(err, "could not unmarshal payment channel ledger entry")
+		}
+		entries = append(entries, &entry)
+	}
+
+	return entries, nil
+}
+
+// update applies f to the entry of a channel and persists the result unless f
+// fails.
+func (l *PaychLedger) update(payer address.Address, channel *types.ChannelID, f func(*PaychLedgerEntry) error) error {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	entry, err := l.get(payer, channel)
+	if err != nil {
+		return err
+	}
+
+	if err := f(entry); err != nil {
+		return err
+	}
+
+	datum, err := cbor.DumpObject(entry)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal payment channel ledger entry")
+	}
+
+	return l.ds.Put(paychKey(payer, channel), datum)
+}
+
+func (l *PaychLedger) get(payer address.Address, channel *types.ChannelID) (*PaychLedgerEntry, error) {
+	entry := &PaychLedgerEntry{Payer: payer, Channel: channel}
+
+	datum, err := l.ds.Get(paychKey(payer, channel))
+	if err == datastore.ErrNotFound {
+		return entry, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read payment channel ledger entry")
+	}
+
+	if err := cbor.DecodeInto(datum, entry); err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal payment channel ledger entry")
+	}
+	return entry, nil
+}
+
+func paychKey(payer address.Address, channel *types.ChannelID) datastore.Key {
+	return datastore.KeyWithNamespaces([]string{paychDatastorePrefix, payer.String(), channel.String()})
+}
+
+// paychPlumbing is the subset of the plumbing.API that the payment channel
+// ledger calls use.
+type paychPlumbing interface {
+	ChainLs(ctx context.Context) <-chan interface{}
+	MessageQuery(ctx context.Context, optFrom, to address.Address, method string, params ...interface{}) ([][]byte, *exec.FunctionSignature, error)
+}
+
+// PaychRecordIssued records a voucher this node has issued. It fails with
+// ErrDoubleIssuance, without recording the voucher, if the best vouchers
+// issued in each lane of the channel would together exceed its funds.
+func PaychRecordIssued(ctx context.Context, plumbing paychPlumbing, ledger *PaychLedger, voucher *paymentbroker.PaymentVoucher) error {
+	channel, err := paychGetChannel(ctx, plumbing, voucher.Payer, &voucher.Channel)
+	if err != nil {
+		return err
+	}
+
+	return ledger.update(voucher.Payer, &voucher.Channel, func(entry *PaychLedgerEntry) error {
+		issued := append(entry.Issued, voucher)
+		if paychAmountIssued(issued).GreaterThan(channel.Amount) {
+			return ErrDoubleIssuance
+		}
+
+		entry.Issued = issued
+		return nil
+	})
+}
+
+// PaychRecordReceived records a voucher this node has received.
+func PaychRecordReceived(ctx context.Context, ledger *PaychLedger, voucher *paymentbroker.PaymentVoucher) error {
+	return ledger.update(voucher.Payer, &voucher.Channel, func(entry *PaychLedgerEntry) error {
+		entry.Received = append(entry.Received, voucher)
+		return nil
+	})
+}
+
+// PaychRecordSettled records that a message of this node closing or
+// reclaiming a channel has been applied on chain.
+func PaychRecordSettled(ledger *PaychLedger, payer address.Address, channel *types.ChannelID) error {
+	return ledger.update(payer, channel, func(entry *PaychLedgerEntry) error {
+		entry.Settled = true
+		return nil
+	})
+}
+
+// PaychBestVoucher returns the received voucher of a channel that would pay
+// the most if redeemed now: the one which is valid at the current block
+// height and exceeds the amount redeemed in its lane by the most. It returns
+// nil if no received voucher would pay anything.
+func PaychBestVoucher(ctx context.Context, plumbing paychPlumbing, ledger *PaychLedger, payer address.Address, channelID *types.ChannelID) (*paymentbroker.PaymentVoucher, error) {
+	channel, err := paychGetChannel(ctx, plumbing, payer, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	vouchers, err := PaychBestVouchers(ctx, plumbing, ledger, payer, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	var best *paymentbroker.PaymentVoucher
+	bestPayout := types.ZeroAttoFIL
+	for _, v := range vouchers {
+		payout := v.Amount.Sub(channel.RedeemedInLane(v.Lane))
+		if payout.GreaterThan(bestPayout) {
+			best, bestPayout = v, payout
+		}
+	}
+
+	return best, nil
+}
+
+// PaychBestVouchers returns, for each lane of a channel, the received voucher
+// which would pay the most if redeemed now, ordered by lane. Lanes in which no
+// received voucher would pay anything are left out.
+func PaychBestVouchers(ctx context.Context, plumbing paychPlumbing, ledger *PaychLedger, payer address.Address, channelID *types.ChannelID) ([]*paymentbroker.PaymentVoucher, error) {
+	channel, err := paychGetChannel(ctx, plumbing, payer, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	height, err := ChainBlockHeight(ctx, plumbing)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := ledger.Get(payer, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	bestByLane := map[uint64]*paymentbroker.PaymentVoucher{}
+	for _, v := range entry.Received {
+		if v.ValidAt.GreaterThan(height) || !v.Amount.GreaterThan(channel.RedeemedInLane(v.Lane)) {
+			continue
+		}
+		if best, ok := bestByLane[v.Lane]; !ok || v.Amount.GreaterThan(&best.Amount) {
+			bestByLane[v.Lane] = v
+		}
+	}
+
+	vouchers := make([]*paymentbroker.PaymentVoucher, 0, len(bestByLane))
+	for _, v := range bestByLane {
+		vouchers = append(vouchers, v)
+	}
+	sort.Slice(vouchers, func(i, j int) bool {
+		return vouchers[i].Lane < vouchers[j].Lane
+	})
+
+	return vouchers, nil
+}
+
+// PaychStatusReturn summarizes a payment channel.
+type PaychStatusReturn struct {
+	Payer   address.Address  `json:"payer"`
+	Target  address.Address  `json:"target"`
+	Channel *types.ChannelID `json:"channel"`
+	// AmountLocked is the amount the payer has put in the channel.
+	AmountLocked *types.AttoFIL `json:"amountLocked"`
+	// AmountIssued is the amount promised by the vouchers this node has
+	// issued or received, i.e. the sum of the best voucher of each lane.
+	AmountIssued *types.AttoFIL `json:"amountIssued"`
+	// AmountRedeemed is the amount the target has redeemed on chain.
+	AmountRedeemed *types.AttoFIL     `json:"amountRedeemed"`
+	Eol            *types.BlockHeight `json:"eol"`
+	// BlocksToEol is the number of blocks until the channel expires, zero if
+	// it has expired.
+	BlocksToEol uint64 `json:"blocksToEol"`
+}
+
+// PaychStatus returns the status of a payment channel, combining its state on
+// chain with the vouchers in the ledger.
+func PaychStatus(ctx context.Context, plumbing paychPlumbing, ledger *PaychLedger, payer address.Address, channelID *types.ChannelID) (*PaychStatusReturn, error) {
+	channel, err := paychGetChannel(ctx, plumbing, payer, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	height, err := ChainBlockHeight(ctx, plumbing)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := ledger.Get(payer, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &PaychStatusReturn{
+		Payer:          payer,
+		Target:         channel.Target,
+		Channel:        channelID,
+		AmountLocked:   channel.Amount,
+		AmountIssued:   paychAmountIssued(append(entry.Issued, entry.Received...)),
+		AmountRedeemed: channel.AmountRedeemed,
+		Eol:            channel.Eol,
+	}
+	if channel.Eol.GreaterThan(height) {
+		status.BlocksToEol = channel.Eol.Sub(height).AsBigInt().Uint64()
+	}
+
+	return status, nil
+}
+
+// paychAmountIssued returns the sum of the greatest voucher amount of each
+// lane, which is the most the vouchers can pay together.
+func paychAmountIssued(vouchers []*paymentbroker.PaymentVoucher) *types.AttoFIL {
+	bestByLane := map[uint64]*types.AttoFIL{}
+	for _, v := range vouchers {
+		if best, ok := bestByLane[v.Lane]; !ok || v.Amount.GreaterThan(best) {
+			amount := v.Amount
+			bestByLane[v.Lane] = &amount
+		}
+	}
+
+	total := types.NewZeroAttoFIL()
+	for _, amount := range bestByLane {
+		total = total.Add(amount)
+	}
+	return total
+}
+
+func paychGetChannel(ctx context.Context, plumbing paychPlumbing, payer address.Address, channelID *types.ChannelID) (*paymentbroker.PaymentChannel, error) {
+	values, _, err := plumbing.MessageQuery(ctx, address.Address{}, address.PaymentBrokerAddress, "ls", payer)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not query payment channels")
+	}
+
+	var channels map[string]*paymentbroker.PaymentChannel
+	if err := cbor.DecodeInto(values[0], &channels); err != nil {
+		return nil, errors.Wrap(err, "could not decode payment channels")
+	}
+
+	channel, ok := channels[channelID.KeyString()]
+	if !ok {
+		return nil, ErrUnknownPaymentChannel
+	}
+	return channel, nil
+}
@@ -0,0 +1,176 @@
+package porcelain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/paymentbroker"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/exec"
+	"github.com/filecoin-project/go-filecoin/repo"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+type paychTestPlumbing struct {
+	height  uint64
+	channel *paymentbroker.PaymentChannel
+	chid    *types.ChannelID
+}
+
+func (ptp *paychTestPlumbing) ChainLs(ctx context.Context) <-chan interface{} {
+	out := make(chan interface{}, 1)
+	ts, err := types.NewTipSet(&types.Block{Height: types.Uint64(ptp.height)})
+	if err != nil {
+		panic(err)
+	}
+	out <- ts
+	close(out)
+	return out
+}
+
+func (ptp *paychTestPlumbing) MessageQuery(ctx context.Context, optFrom, to address.Address, method string, params ...interface{}) ([][]byte, *exec.FunctionSignature, error) {
+	channels := map[string]*paymentbroker.PaymentChannel{ptp.chid.KeyString(): ptp.channel}
+	channelsBytes, err := actor.MarshalStorage(channels)
+	if err != nil {
+		panic(err)
+	}
+	return [][]byte{channelsBytes}, nil, nil
+}
+
+func newPaychTest() (*paychTestPlumbing, *PaychLedger, address.Address) {
+	addresses := address.NewForTestGetter()
+	payer := addresses()
+
+	plumbing := &paychTestPlumbing{
+		height: 10,
+		chid:   types.NewChannelID(3),
+		channel: &paymentbroker.PaymentChannel{
+			Target:         addresses(),
+			Amount:         types.NewAttoFILFromFIL(1000),
+			AmountRedeemed: types.NewAttoFILFromFIL(100),
+			Eol:            types.NewBlockHeight(50),
+			LaneRedeemed:   map[string]*types.AttoFIL{"0": types.NewAttoFILFromFIL(100)},
+		},
+	}
+	ledger := NewPaychLedger(repo.NewInMemoryRepo().DealsDatastore())
+
+	return plumbing, ledger, payer
+}
+
+func testVoucher(payer address.Address, chid *types.ChannelID, lane uint64, amount uint64, validAt uint64) *paymentbroker.PaymentVoucher {
+	return &paymentbroker.PaymentVoucher{
+		Channel: *chid,
+		Lane:    lane,
+		Payer:   payer,
+		Amount:  *types.NewAttoFILFromFIL(amount),
+		ValidAt: *types.NewBlockHeight(validAt),
+	}
+}
+
+func TestPaychRecordIssued(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	plumbing, ledger, payer := newPaychTest()
+
+	require.NoError(PaychRecordIssued(ctx, plumbing, ledger, testVoucher(payer, plumbing.chid, 0, 400, 0)))
+	// vouchers within a lane are cumulative, so this one replaces the first
+	require.NoError(PaychRecordIssued(ctx, plumbing, ledger, testVoucher(payer, plumbing.chid, 0, 700, 0)))
+	require.NoError(PaychRecordIssued(ctx, plumbing, ledger, testVoucher(payer, plumbing.chid, 1, 300, 0)))
+
+	err := PaychRecordIssued(ctx, plumbing, ledger, testVoucher(payer, plumbing.chid, 2, 1, 0))
+	require.Equal(ErrDoubleIssuance, err)
+
+	entry, err := ledger.Get(payer, plumbing.chid)
+	require.NoError(err)
+	require.Len(entry.Issued, 3)
+}
+
+func TestPaychRecordSettled(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+	plumbing, ledger, payer := newPaychTest()
+
+	require.NoError(PaychRecordReceived(ctx, ledger, testVoucher(payer, plumbing.chid, 0, 400, 0)))
+	require.NoError(PaychRecordSettled(ledger, payer, plumbing.chid))
+
+	entries, err := ledger.Entries()
+	require.NoError(err)
+	require.Len(entries, 1)
+	assert.True(entries[0].Settled)
+	assert.Len(entries[0].Received, 1)
+}
+
+func TestPaychBestVoucher(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+	plumbing, ledger, payer := newPaychTest()
+
+	best, err := PaychBestVoucher(ctx, plumbing, ledger, payer, plumbing.chid)
+	require.NoError(err)
+	assert.Nil(best)
+
+	// lane 0 has 100 redeemed already, so this pays 200
+	require.NoError(PaychRecordReceived(ctx, ledger, testVoucher(payer, plumbing.chid, 0, 300, 5)))
+	// pays 250
+	require.NoError(PaychRecordReceived(ctx, ledger, testVoucher(payer, plumbing.chid, 1, 250, 10)))
+	// not yet valid
+	require.NoError(PaychRecordReceived(ctx, ledger, testVoucher(payer, plumbing.chid, 0, 900, 20)))
+
+	best, err = PaychBestVoucher(ctx, plumbing, ledger, payer, plumbing.chid)
+	require.NoError(err)
+	assert.Equal(uint64(1), best.Lane)
+
+	plumbing.height = 20
+	best, err = PaychBestVoucher(ctx, plumbing, ledger, payer, plumbing.chid)
+	require.NoError(err)
+	assert.Equal(*types.NewAttoFILFromFIL(900), best.Amount)
+}
+
+func TestPaychBestVouchers(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+	plumbing, ledger, payer := newPaychTest()
+
+	// lane 0 has 100 redeemed already, so this pays nothing
+	require.NoError(PaychRecordReceived(ctx, ledger, testVoucher(payer, plumbing.chid, 0, 100, 5)))
+	require.NoError(PaychRecordReceived(ctx, ledger, testVoucher(payer, plumbing.chid, 2, 50, 5)))
+	require.NoError(PaychRecordReceived(ctx, ledger, testVoucher(payer, plumbing.chid, 1, 200, 5)))
+	require.NoError(PaychRecordReceived(ctx, ledger, testVoucher(payer, plumbing.chid, 1, 250, 10)))
+
+	vouchers, err := PaychBestVouchers(ctx, plumbing, ledger, payer, plumbing.chid)
+	require.NoError(err)
+	require.Len(vouchers, 2)
+	assert.Equal(uint64(1), vouchers[0].Lane)
+	assert.Equal(*types.NewAttoFILFromFIL(250), vouchers[0].Amount)
+	assert.Equal(uint64(2), vouchers[1].Lane)
+}
+
+func TestPaychStatus(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+	plumbing, ledger, payer := newPaychTest()
+
+	require.NoError(PaychRecordIssued(ctx, plumbing, ledger, testVoucher(payer, plumbing.chid, 0, 200, 0)))
+	require.NoError(PaychRecordIssued(ctx, plumbing, ledger, testVoucher(payer, plumbing.chid, 1, 50, 0)))
+
+	status, err := PaychStatus(ctx, plumbing, ledger, payer, plumbing.chid)
+	require.NoError(err)
+	assert.Equal(plumbing.channel.Target, status.Target)
+	assert.Equal(types.NewAttoFILFromFIL(1000), status.AmountLocked)
+	assert.Equal(types.NewAttoFILFromFIL(250), status.AmountIssued)
+	assert.Equal(types.NewAttoFILFromFIL(100), status.AmountRedeemed)
+	assert.Equal(uint64(40), status.BlocksToEol)
+
+	plumbing.height = 60
+	status, err = PaychStatus(ctx, plumbing, ledger, payer, plumbing.chid)
+	require.NoError(err)
+	assert.Equal(uint64(0), status.BlocksToEol)
+}
@@ -22,6 +22,7 @@ type cpPlumbing interface {
 	MessageWait(ctx context.Context, msgCid cid.Cid, cb func(*types.Block, *types.SignedMessage, *types.MessageReceipt) error) error
 	ChainLs(ctx context.Context) <-chan interface{}
 	SignBytes(data []byte, addr address.Address) (types.Signature, error)
+	PaychRecordIssued(ctx context.Context, voucher *paymentbroker.PaymentVoucher) error
 }
 
 // CreatePaymentsParams structures all the parameters for the CreatePayments command. All values are required.
@@ -53,6 +54,10 @@ type CreatePaymentsParams struct {
 
 	// GasLimit is the maximum amount of gas to be paid creating the payment channel.
 	GasLimit types.GasUnits
+
+	// Condition, if not nil, is a condition the vouchers can only be redeemed
+	// under, see paymentbroker.Condition.
+	Condition *paymentbroker.Condition
 }
 
 // CreatePaymentsReturn collects relevant stats from the create payments process
@@ -73,7 +78,16 @@ type CreatePaymentsReturn struct {
 	Vouchers []*paymentbroker.PaymentVoucher
 }
 
-// CreatePayments establishes a payment channel and create multiple payments against it
+// scheduledPayment is the amount a voucher pays in total and the height from
+// which it is valid.
+type scheduledPayment struct {
+	amount  *types.AttoFIL
+	validAt *types.BlockHeight
+}
+
+// CreatePayments establishes a payment channel and create multiple payments
+// against it. Each voucher is recorded as issued before it is signed, and no
+// more vouchers are signed once recording one fails.
 func CreatePayments(ctx context.Context, plumbing cpPlumbing, config CreatePaymentsParams) (*CreatePaymentsReturn, error) {
 	// validate
 	if config.From.Empty() {
@@ -98,6 +112,30 @@ func CreatePayments(ctx context.Context, plumbing cpPlumbing, config CreatePayme
 		return nil, fmt.Errorf("channel would expire (%s) before last payment is made (%d)", config.ChannelExpiry.String(), lastPayment)
 	}
 
+	// Schedule the payments before creating anything. The amount of each
+	// voucher is roughly value/num payments, exactly
+	// ceil(value*interval/duration), and the last one pays the whole value.
+	intervalAsBigInt := big.NewInt(int64(config.PaymentInterval))
+	// Convert to AttoFIL, because values have to be the same type.
+	durationAsAttoFIL := types.NewAttoFIL(big.NewInt(int64(config.Duration)))
+	valuePerPayment := *config.Value.MulBigInt(intervalAsBigInt).DivCeil(durationAsAttoFIL)
+
+	var payments []scheduledPayment
+	voucherAmount := types.ZeroAttoFIL
+	for i := 0; uint64(i+1)*config.PaymentInterval < config.Duration; i++ {
+		voucherAmount = voucherAmount.Add(&valuePerPayment)
+		if voucherAmount.GreaterThan(&config.Value) {
+			voucherAmount = &config.Value
+		}
+
+		validAt := currentHeight.Add(types.NewBlockHeight(uint64(i+1) * config.PaymentInterval))
+		payments = append(payments, scheduledPayment{amount: voucherAmount, validAt: validAt})
+	}
+	if voucherAmount.LessThan(&config.Value) {
+		validAt := currentHeight.Add(types.NewBlockHeight(config.Duration))
+		payments = append(payments, scheduledPayment{amount: &config.Value, validAt: validAt})
+	}
+
 	response := &CreatePaymentsReturn{
 		CreatePaymentsParams: config,
 	}
@@ -130,32 +168,10 @@ func CreatePayments(ctx context.Context, plumbing cpPlumbing, config CreatePayme
 		return response, err
 	}
 
-	// compute value per payment. Roughly value/num payments. Exactly ceil(value*interval/duration).
-	intervalAsBigInt := big.NewInt(int64(config.PaymentInterval))
-	// Convert to AttoFIL, because values have to be the same type.
-	durationAsAttoFIL := types.NewAttoFIL(big.NewInt(int64(config.Duration)))
-	valuePerPayment := *config.Value.MulBigInt(intervalAsBigInt).DivCeil(durationAsAttoFIL)
-
 	// generate payments
 	response.Vouchers = []*paymentbroker.PaymentVoucher{}
-	voucherAmount := types.ZeroAttoFIL
-	for i := 0; uint64(i+1)*config.PaymentInterval < config.Duration; i++ {
-		voucherAmount = voucherAmount.Add(&valuePerPayment)
-		if voucherAmount.GreaterThan(&config.Value) {
-			voucherAmount = &config.Value
-		}
-
-		validAt := currentHeight.Add(types.NewBlockHeight(uint64(i+1) * config.PaymentInterval))
-		err = createPayment(ctx, plumbing, response, voucherAmount, validAt)
-		if err != nil {
-			return response, err
-		}
-	}
-
-	if voucherAmount.LessThan(&config.Value) {
-		validAt := currentHeight.Add(types.NewBlockHeight(config.Duration))
-		err = createPayment(ctx, plumbing, response, &config.Value, validAt)
-		if err != nil {
+	for _, payment := range payments {
+		if err := createPayment(ctx, plumbing, response, payment.amount, payment.validAt); err != nil {
 			return response, err
 		}
 	}
@@ -169,6 +185,7 @@ func createPayment(ctx context.Context, plumbing cpPlumbing, response *CreatePay
 		address.PaymentBrokerAddress,
 		"voucher",
 		response.Channel,
+		big.NewInt(0),
 		amount,
 		validAt)
 	if err != nil {
@@ -180,7 +197,14 @@ func createPayment(ctx context.Context, plumbing cpPlumbing, response *CreatePay
 		return err
 	}
 
-	sig, err := paymentbroker.SignVoucher(&voucher.Channel, amount, validAt, voucher.Payer, plumbing)
+	voucher.Condition = response.Condition
+
+	// Reserve the amount of the voucher in its lane before signing it.
+	if err := plumbing.PaychRecordIssued(ctx, &voucher); err != nil {
+		return errors.Wrap(err, "could not record issued voucher")
+	}
+
+	sig, err := paymentbroker.SignVoucher(&voucher.Channel, voucher.Lane, amount, validAt, response.Condition, voucher.Payer, plumbing)
 	if err != nil {
 		return err
 	}
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	pkgerrors "gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
 
 	"github.com/filecoin-project/go-filecoin/actor"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/paymentbroker"
@@ -30,6 +31,10 @@ type paymentsTestPlumbing struct {
 	messageSend  func(ctx context.Context, from, to address.Address, value *types.AttoFIL, gasPrice types.AttoFIL, gasLimit types.GasUnits, method string, params ...interface{}) (cid.Cid, error)
 	messageWait  func(ctx context.Context, msgCid cid.Cid, cb func(*types.Block, *types.SignedMessage, *types.MessageReceipt) error) error
 	messageQuery func(ctx context.Context, optFrom, to address.Address, method string, params ...interface{}) ([][]byte, *exec.FunctionSignature, error)
+	recordIssued func(ctx context.Context, voucher *paymentbroker.PaymentVoucher) error
+
+	// signed counts the vouchers signed.
+	signed int
 }
 
 func newTestCreatePaymentsPlumbing() *paymentsTestPlumbing {
@@ -65,8 +70,9 @@ func newTestCreatePaymentsPlumbing() *paymentsTestPlumbing {
 				Channel: *channelID,
 				Payer:   payer,
 				Target:  target,
-				Amount:  *params[1].(*types.AttoFIL),
-				ValidAt: *params[2].(*types.BlockHeight),
+				Lane:    params[1].(*big.Int).Uint64(),
+				Amount:  *params[2].(*types.AttoFIL),
+				ValidAt: *params[3].(*types.BlockHeight),
 			}
 			voucherBytes, err := actor.MarshalStorage(voucher)
 			if err != nil {
@@ -74,6 +80,9 @@ func newTestCreatePaymentsPlumbing() *paymentsTestPlumbing {
 			}
 			return [][]byte{voucherBytes}, nil, nil
 		},
+		recordIssued: func(ctx context.Context, voucher *paymentbroker.PaymentVoucher) error {
+			return nil
+		},
 	}
 }
 
@@ -104,9 +113,14 @@ func (ptp *paymentsTestPlumbing) ChainLs(ctx context.Context) <-chan interface{}
 }
 
 func (ptp *paymentsTestPlumbing) SignBytes(data []byte, addr address.Address) (types.Signature, error) {
+	ptp.signed++
 	return []byte("signature"), nil
 }
 
+func (ptp *paymentsTestPlumbing) PaychRecordIssued(ctx context.Context, voucher *paymentbroker.PaymentVoucher) error {
+	return ptp.recordIssued(ctx, voucher)
+}
+
 func validPaymentsConfig() CreatePaymentsParams {
 	addresses := address.NewForTestGetter()
 	from := addresses()
@@ -299,4 +313,46 @@ func TestCreatePayments(t *testing.T) {
 		require.Error(err)
 		assert.Contains(err.Error(), "MessageQuery")
 	})
+
+	t.Run("Vouchers are recorded as issued before they are signed", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		plumbing := newTestCreatePaymentsPlumbing()
+		var recorded []*paymentbroker.PaymentVoucher
+		plumbing.recordIssued = func(ctx context.Context, voucher *paymentbroker.PaymentVoucher) error {
+			assert.Equal(len(recorded), plumbing.signed)
+			assert.Empty(voucher.Signature)
+			recorded = append(recorded, voucher)
+			return nil
+		}
+
+		config := validPaymentsConfig()
+		paymentResponse, err := CreatePayments(context.Background(), plumbing, config)
+		require.NoError(err)
+		assert.Len(recorded, 10)
+		assert.Equal(paymentResponse.Vouchers[9].Amount, recorded[9].Amount)
+	})
+
+	t.Run("Vouchers which cannot be recorded are not signed", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		plumbing := newTestCreatePaymentsPlumbing()
+		recorded := 0
+		plumbing.recordIssued = func(ctx context.Context, voucher *paymentbroker.PaymentVoucher) error {
+			if recorded == 2 {
+				return ErrDoubleIssuance
+			}
+			recorded++
+			return nil
+		}
+
+		config := validPaymentsConfig()
+		paymentResponse, err := CreatePayments(context.Background(), plumbing, config)
+		require.Error(err)
+		assert.Equal(ErrDoubleIssuance, pkgerrors.Cause(err))
+		assert.Equal(2, plumbing.signed)
+		assert.Len(paymentResponse.Vouchers, 2)
+	})
 }
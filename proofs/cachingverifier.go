@@ -0,0 +1,243 @@
+package proofs
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// DefaultVerifierCacheSize is the number of verification results a
+// CachingVerifier created by a node remembers.
+const DefaultVerifierCacheSize = 4096
+
+// OperationStats describes the verifications of one kind of proof performed
+// through a CachingVerifier.
+type OperationStats struct {
+	// Calls is the number of requests made to the CachingVerifier.
+	Calls uint64
+	// CacheHits is the number of requests answered from the cache.
+	CacheHits uint64
+	// Verified is the number of proofs checked by the underlying verifier.
+	Verified uint64
+	// TotalTime is the time spent in the underlying verifier.
+	TotalTime time.Duration
+	// MaxTime is the longest single call to the underlying verifier.
+	MaxTime time.Duration
+}
+
+// VerificationStats is a snapshot of the metrics recorded by a
+// CachingVerifier.
+type VerificationStats struct {
+	Seal      OperationStats
+	SealBatch OperationStats
+	PoSt      OperationStats
+}
+
+// CachingVerifier is a Verifier which remembers the results of the
+// verifications performed by the Verifier it decorates, so that proofs seen
+// more than once (e.g. in blocks on competing forks) are only checked once.
+// Results are keyed by a hash of the request. Errors are not cached.
+//
+// A CachingVerifier also records how long verification takes; see Stats.
+type CachingVerifier struct {
+	inner Verifier
+	size  int
+
+	lk      sync.Mutex
+	order   *list.List // of *cacheEntry, most recently used first
+	entries map[cacheKey]*list.Element
+	stats   VerificationStats
+}
+
+var _ Verifier = &CachingVerifier{}
+var _ SealBatchVerifier = &CachingVerifier{}
+
+type cacheKey [sha256.Size]byte
+
+type cacheEntry struct {
+	key     cacheKey
+	isValid bool
+}
+
+// NewCachingVerifier creates a CachingVerifier which remembers the results of
+// up to size verifications performed by inner.
+func NewCachingVerifier(inner Verifier, size int) *CachingVerifier {
+	return &CachingVerifier{
+		inner:   inner,
+		size:    size,
+		order:   list.New(),
+		entries: make(map[cacheKey]*list.Element),
+	}
+}
+
+// VerifySeal verifies a seal proof, consulting the cache first.
+func (cv *CachingVerifier) VerifySeal(req VerifySealRequest) (VerifySealResponse, error) {
+	key := sealCacheKey(req)
+	if isValid, ok := cv.lookup(key, &cv.stats.Seal); ok {
+		return VerifySealResponse{IsValid: isValid}, nil
+	}
+
+	start := time.Now()
+	res, err := cv.inner.VerifySeal(req)
+	cv.record(&cv.stats.Seal, 1, time.Since(start))
+	if err != nil {
+		return VerifySealResponse{}, err
+	}
+
+	cv.store(key, res.IsValid)
+
+	return res, nil
+}
+
+// VerifySeals verifies several seal proofs, passing those which are not
+// cached to the underlying verifier in a single batch.
+func (cv *CachingVerifier) VerifySeals(reqs []VerifySealRequest) ([]VerifySealResponse, error) {
+	responses := make([]VerifySealResponse, len(reqs))
+
+	var missKeys []cacheKey
+	var missIdxs []int
+	var misses []VerifySealRequest
+	for i, req := range reqs {
+		key := sealCacheKey(req)
+		if isValid, ok := cv.lookup(key, &cv.stats.SealBatch); ok {
+			responses[i] = VerifySealResponse{IsValid: isValid}
+			continue
+		}
+		missKeys = append(missKeys, key)
+		missIdxs = append(missIdxs, i)
+		misses = append(misses, req)
+	}
+
+	if len(misses) == 0 {
+		return responses, nil
+	}
+
+	start := time.Now()
+	results, err := VerifySeals(cv.inner, misses)
+	cv.record(&cv.stats.SealBatch, uint64(len(misses)), time.Since(start))
+	if err != nil {
+		return nil, err
+	}
+
+	for i, res := range results {
+		cv.store(missKeys[i], res.IsValid)
+		responses[missIdxs[i]] = res
+	}
+
+	return responses, nil
+}
+
+// VerifyPoST verifies a proof-of-spacetime, consulting the cache first.
+func (cv *CachingVerifier) VerifyPoST(req VerifyPoSTRequest) (VerifyPoSTResponse, error) {
+	key := postCacheKey(req)
+	if isValid, ok := cv.lookup(key, &cv.stats.PoSt); ok {
+		return VerifyPoSTResponse{IsValid: isValid}, nil
+	}
+
+	start := time.Now()
+	res, err := cv.inner.VerifyPoST(req)
+	cv.record(&cv.stats.PoSt, 1, time.Since(start))
+	if err != nil {
+		return VerifyPoSTResponse{}, err
+	}
+
+	cv.store(key, res.IsValid)
+
+	return res, nil
+}
+
+// Stats returns a snapshot of the verifier's metrics.
+func (cv *CachingVerifier) Stats() VerificationStats {
+	cv.lk.Lock()
+	defer cv.lk.Unlock()
+
+	return cv.stats
+}
+
+// lookup returns the cached result for key, if any, counting the request
+// against stats.
+func (cv *CachingVerifier) lookup(key cacheKey, stats *OperationStats) (bool, bool) {
+	cv.lk.Lock()
+	defer cv.lk.Unlock()
+
+	stats.Calls++
+
+	elem, ok := cv.entries[key]
+	if !ok {
+		return false, false
+	}
+	stats.CacheHits++
+	cv.order.MoveToFront(elem)
+
+	return elem.Value.(*cacheEntry).isValid, true
+}
+
+// store caches a result, evicting the least recently used one if the cache
+// is full.
+func (cv *CachingVerifier) store(key cacheKey, isValid bool) {
+	cv.lk.Lock()
+	defer cv.lk.Unlock()
+
+	if elem, ok := cv.entries[key]; ok {
+		elem.Value.(*cacheEntry).isValid = isValid
+		cv.order.MoveToFront(elem)
+		return
+	}
+
+	cv.entries[key] = cv.order.PushFront(&cacheEntry{key: key, isValid: isValid})
+
+	for cv.order.Len() > cv.size {
+		oldest := cv.order.Remove(cv.order.Back()).(*cacheEntry)
+		delete(cv.entries, oldest.key)
+	}
+}
+
+// record adds a call to the underlying verifier which checked count proofs to
+// stats.
+func (cv *CachingVerifier) record(stats *OperationStats, count uint64, took time.Duration) {
+	cv.lk.Lock()
+	defer cv.lk.Unlock()
+
+	stats.Verified += count
+	stats.TotalTime += took
+	if took > stats.MaxTime {
+		stats.MaxTime = took
+	}
+}
+
+func sealCacheKey(req VerifySealRequest) cacheKey {
+	h := sha256.New()
+	h.Write([]byte("seal"))                                 // nolint: errcheck
+	h.Write(req.CommD[:])                                   // nolint: errcheck
+	h.Write(req.CommR[:])                                   // nolint: errcheck
+	h.Write(req.CommRStar[:])                               // nolint: errcheck
+	h.Write(req.Proof[:])                                   // nolint: errcheck
+	h.Write(req.ProverID[:])                                // nolint: errcheck
+	h.Write(req.SectorID[:])                                // nolint: errcheck
+	binary.Write(h, binary.BigEndian, int64(req.StoreType)) // nolint: errcheck
+
+	var key cacheKey
+	copy(key[:], h.Sum(nil))
+	return key
+}
+
+func postCacheKey(req VerifyPoSTRequest) cacheKey {
+	h := sha256.New()
+	h.Write([]byte("post"))                                    // nolint: errcheck
+	h.Write(req.ChallengeSeed[:])                              // nolint: errcheck
+	h.Write(req.Proof[:])                                      // nolint: errcheck
+	binary.Write(h, binary.BigEndian, uint64(len(req.CommRs))) // nolint: errcheck
+	for _, commR := range req.CommRs {
+		h.Write(commR[:]) // nolint: errcheck
+	}
+	binary.Write(h, binary.BigEndian, uint64(len(req.Faults))) // nolint: errcheck
+	for _, fault := range req.Faults {
+		binary.Write(h, binary.BigEndian, fault) // nolint: errcheck
+	}
+
+	var key cacheKey
+	copy(key[:], h.Sum(nil))
+	return key
+}
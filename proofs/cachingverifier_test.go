@@ -0,0 +1,173 @@
+package proofs
+
+import (
+	"testing"
+
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingVerifier accepts seal proofs whose first byte is 1 and counts the
+// requests it receives.
+type countingVerifier struct {
+	seals   int
+	batches int
+	posts   int
+	err     error
+}
+
+func (cv *countingVerifier) VerifySeal(req VerifySealRequest) (VerifySealResponse, error) {
+	cv.seals++
+	return VerifySealResponse{IsValid: req.Proof[0] == 1}, cv.err
+}
+
+func (cv *countingVerifier) VerifySeals(reqs []VerifySealRequest) ([]VerifySealResponse, error) {
+	cv.batches++
+	var responses []VerifySealResponse
+	for _, req := range reqs {
+		res, err := cv.VerifySeal(req)
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, res)
+	}
+	return responses, nil
+}
+
+func (cv *countingVerifier) VerifyPoST(req VerifyPoSTRequest) (VerifyPoSTResponse, error) {
+	cv.posts++
+	return VerifyPoSTResponse{IsValid: true}, cv.err
+}
+
+func TestCachingVerifier(t *testing.T) {
+	validSeal := VerifySealRequest{Proof: SealProof{1}, SectorID: [31]byte{1}}
+	invalidSeal := VerifySealRequest{Proof: SealProof{2}, SectorID: [31]byte{2}}
+
+	t.Run("seal results are cached", func(t *testing.T) {
+		require := require.New(t)
+
+		inner := &countingVerifier{}
+		cv := NewCachingVerifier(inner, 10)
+
+		for i := 0; i < 2; i++ {
+			res, err := cv.VerifySeal(validSeal)
+			require.NoError(err)
+			require.True(res.IsValid)
+
+			res, err = cv.VerifySeal(invalidSeal)
+			require.NoError(err)
+			require.False(res.IsValid)
+		}
+
+		require.Equal(2, inner.seals)
+
+		stats := cv.Stats().Seal
+		require.Equal(uint64(4), stats.Calls)
+		require.Equal(uint64(2), stats.CacheHits)
+		require.Equal(uint64(2), stats.Verified)
+	})
+
+	t.Run("PoSt results are keyed by the whole request", func(t *testing.T) {
+		require := require.New(t)
+
+		inner := &countingVerifier{}
+		cv := NewCachingVerifier(inner, 10)
+
+		req := VerifyPoSTRequest{CommRs: []CommR{{1}}, Faults: []uint64{}, Proof: PoStProof{1}}
+		_, err := cv.VerifyPoST(req)
+		require.NoError(err)
+		_, err = cv.VerifyPoST(req)
+		require.NoError(err)
+		require.Equal(1, inner.posts)
+
+		req.Faults = []uint64{3}
+		_, err = cv.VerifyPoST(req)
+		require.NoError(err)
+		require.Equal(2, inner.posts)
+	})
+
+	t.Run("errors are not cached", func(t *testing.T) {
+		assert := assert.New(t)
+
+		inner := &countingVerifier{err: errors.New("boom")}
+		cv := NewCachingVerifier(inner, 10)
+
+		_, err := cv.VerifySeal(validSeal)
+		assert.Error(err)
+
+		inner.err = nil
+		res, err := cv.VerifySeal(validSeal)
+		assert.NoError(err)
+		assert.True(res.IsValid)
+		assert.Equal(2, inner.seals)
+	})
+
+	t.Run("least recently used results are evicted", func(t *testing.T) {
+		require := require.New(t)
+
+		inner := &countingVerifier{}
+		cv := NewCachingVerifier(inner, 1)
+
+		_, err := cv.VerifySeal(validSeal)
+		require.NoError(err)
+		_, err = cv.VerifySeal(invalidSeal)
+		require.NoError(err)
+		_, err = cv.VerifySeal(validSeal)
+		require.NoError(err)
+
+		require.Equal(3, inner.seals)
+	})
+
+	t.Run("batches only verify uncached proofs", func(t *testing.T) {
+		require := require.New(t)
+
+		inner := &countingVerifier{}
+		cv := NewCachingVerifier(inner, 10)
+
+		_, err := cv.VerifySeal(validSeal)
+		require.NoError(err)
+
+		responses, err := cv.VerifySeals([]VerifySealRequest{invalidSeal, validSeal})
+		require.NoError(err)
+		require.Equal([]VerifySealResponse{{IsValid: false}, {IsValid: true}}, responses)
+		require.Equal(1, inner.batches)
+		require.Equal(2, inner.seals)
+
+		// the batch warmed the cache for subsequent single verifications
+		res, err := cv.VerifySeal(invalidSeal)
+		require.NoError(err)
+		require.False(res.IsValid)
+		require.Equal(2, inner.seals)
+
+		stats := cv.Stats().SealBatch
+		require.Equal(uint64(2), stats.Calls)
+		require.Equal(uint64(1), stats.CacheHits)
+		require.Equal(uint64(1), stats.Verified)
+	})
+}
+
+func TestVerifySeals(t *testing.T) {
+	require := require.New(t)
+
+	proverID := [31]byte{1}
+	sectorID := [31]byte{2}
+	commD := GoDataCommitment([]byte("data"))
+	commR, commRStar := GoReplicaCommitments(proverID, sectorID, commD)
+	valid := VerifySealRequest{
+		CommD:     commD,
+		CommR:     commR,
+		CommRStar: commRStar,
+		Proof:     GoSealProof(proverID, sectorID, commD, commR, commRStar),
+		ProverID:  proverID,
+		SectorID:  sectorID,
+	}
+	invalid := valid
+	invalid.SectorID = [31]byte{3}
+
+	// GoVerifier is not a SealBatchVerifier, so proofs are verified one at a time
+	responses, err := VerifySeals(&GoVerifier{}, []VerifySealRequest{valid, invalid})
+	require.NoError(err)
+	require.Equal([]VerifySealResponse{{IsValid: true}, {IsValid: false}}, responses)
+}
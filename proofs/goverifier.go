@@ -0,0 +1,126 @@
+package proofs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+)
+
+// The functions in this file implement a pure-Go stand-in for rust-proofs.
+// Commitments and proofs are derived from hashes of their inputs, so they can
+// be produced and checked without the Rust toolchain, but they prove nothing
+// about the storage of data. They must only be used on development networks.
+
+// GoVerifier is a Verifier which checks proofs produced by the pure-Go
+// (hash-based) proofs backend.
+type GoVerifier struct{}
+
+var _ Verifier = &GoVerifier{}
+
+// VerifySeal checks that the commitments in the request were derived from one
+// another and that the proof binds them to the prover and sector.
+func (gv *GoVerifier) VerifySeal(req VerifySealRequest) (VerifySealResponse, error) {
+	commR, commRStar := GoReplicaCommitments(req.ProverID, req.SectorID, req.CommD)
+	if commR != req.CommR || commRStar != req.CommRStar {
+		return VerifySealResponse{IsValid: false}, nil
+	}
+
+	proof := GoSealProof(req.ProverID, req.SectorID, req.CommD, req.CommR, req.CommRStar)
+
+	return VerifySealResponse{IsValid: proof == req.Proof}, nil
+}
+
+// VerifyPoST checks that the proof was generated over the request's replica
+// commitments and faults.
+//
+// Block proofs are not yet generated over a miner's sectors: a miner's proof
+// is its challenge seed (see createProof in the mining package). Until they
+// are, a request without commitments is valid iff its proof is the challenge
+// seed, so that miners cannot grind proofs for winning tickets.
+// TODO: reject requests without commitments once block proofs are generated
+// over the miner's sectors, along with the RustVerifier.
+// See https://github.com/filecoin-project/go-filecoin/issues/1302
+func (gv *GoVerifier) VerifyPoST(req VerifyPoSTRequest) (VerifyPoSTResponse, error) {
+	if len(req.CommRs) == 0 {
+		var seedProof PoStProof
+		copy(seedProof[:], req.ChallengeSeed[:])
+		return VerifyPoSTResponse{IsValid: seedProof == req.Proof}, nil
+	}
+
+	proof := GoPoStProof(req.CommRs, req.Faults)
+
+	return VerifyPoSTResponse{IsValid: proof == req.Proof}, nil
+}
+
+// GoDataCommitment produces the pure-Go backend's CommD for the unsealed
+// contents of a sector.
+func GoDataCommitment(data []byte) CommD {
+	return CommD(sha256.Sum256(append([]byte("commD"), data...)))
+}
+
+// GoReplicaCommitments produces the pure-Go backend's CommR and CommRStar for
+// a sector with the provided prover id, sector id and data commitment.
+func GoReplicaCommitments(proverID, sectorID [31]byte, commD CommD) (CommR, CommRStar) {
+	buf := bytes.NewBufferString("commR")
+	buf.Write(proverID[:])
+	buf.Write(sectorID[:])
+	buf.Write(commD[:])
+	commR := CommR(sha256.Sum256(buf.Bytes()))
+
+	commRStar := CommRStar(sha256.Sum256(append([]byte("commRStar"), commR[:]...)))
+
+	return commR, commRStar
+}
+
+// GoSealProof produces the pure-Go backend's seal proof, which binds a
+// sector's commitments to its prover and sector ids.
+func GoSealProof(proverID, sectorID [31]byte, commD CommD, commR CommR, commRStar CommRStar) SealProof {
+	buf := bytes.NewBufferString("seal")
+	buf.Write(proverID[:])
+	buf.Write(sectorID[:])
+	buf.Write(commD[:])
+	buf.Write(commR[:])
+	buf.Write(commRStar[:])
+
+	var proof SealProof
+	expandHash(proof[:], buf.Bytes())
+
+	return proof
+}
+
+// GoPoStProof produces the pure-Go backend's proof-of-spacetime over a set of
+// replica commitments. The order of the commitments does not matter.
+//
+// The challenge seed is deliberately not part of the proof: the miner actor
+// does not yet know the seed a miner used to generate its proof.
+func GoPoStProof(commRs []CommR, faults []uint64) PoStProof {
+	sorted := make([]CommR, len(commRs))
+	copy(sorted, commRs)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i][:], sorted[j][:]) < 0
+	})
+
+	buf := bytes.NewBufferString("post")
+	for _, commR := range sorted {
+		buf.Write(commR[:])
+	}
+	for _, fault := range faults {
+		binary.Write(buf, binary.BigEndian, fault) // nolint: errcheck
+	}
+
+	var proof PoStProof
+	expandHash(proof[:], buf.Bytes())
+
+	return proof
+}
+
+// expandHash fills out with a sha256-based keystream derived from seed.
+func expandHash(out []byte, seed []byte) {
+	var counter [8]byte
+	for i := 0; i < len(out); i += sha256.Size {
+		binary.BigEndian.PutUint64(counter[:], uint64(i))
+		sum := sha256.Sum256(append(counter[:], seed...))
+		copy(out[i:], sum[:])
+	}
+}
@@ -0,0 +1,89 @@
+package proofs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoVerifier(t *testing.T) {
+	proverID := [31]byte{1, 2, 3}
+	sectorID := [31]byte{4}
+	commD := GoDataCommitment([]byte("sector contents"))
+	commR, commRStar := GoReplicaCommitments(proverID, sectorID, commD)
+
+	t.Run("accepts a seal produced by the Go backend", func(t *testing.T) {
+		require := require.New(t)
+
+		res, err := (&GoVerifier{}).VerifySeal(VerifySealRequest{
+			CommD:     commD,
+			CommR:     commR,
+			CommRStar: commRStar,
+			Proof:     GoSealProof(proverID, sectorID, commD, commR, commRStar),
+			ProverID:  proverID,
+			SectorID:  sectorID,
+		})
+		require.NoError(err)
+		require.True(res.IsValid)
+	})
+
+	t.Run("rejects a seal for another sector", func(t *testing.T) {
+		require := require.New(t)
+
+		res, err := (&GoVerifier{}).VerifySeal(VerifySealRequest{
+			CommD:     commD,
+			CommR:     commR,
+			CommRStar: commRStar,
+			Proof:     GoSealProof(proverID, sectorID, commD, commR, commRStar),
+			ProverID:  proverID,
+			SectorID:  [31]byte{5},
+		})
+		require.NoError(err)
+		require.False(res.IsValid)
+	})
+
+	t.Run("PoSt is independent of commitment order", func(t *testing.T) {
+		assert := assert.New(t)
+
+		other := CommR{9, 9, 9}
+		proof := GoPoStProof([]CommR{commR, other}, nil)
+
+		res, err := (&GoVerifier{}).VerifyPoST(VerifyPoSTRequest{
+			CommRs: []CommR{other, commR},
+			Faults: []uint64{},
+			Proof:  proof,
+		})
+		assert.NoError(err)
+		assert.True(res.IsValid)
+
+		res, err = (&GoVerifier{}).VerifyPoST(VerifyPoSTRequest{
+			CommRs: []CommR{other},
+			Proof:  proof,
+		})
+		assert.NoError(err)
+		assert.False(res.IsValid)
+	})
+
+	t.Run("PoSt without commitments must be its challenge seed", func(t *testing.T) {
+		require := require.New(t)
+
+		seed := PoStChallengeSeed{1, 2, 3}
+		var seedProof PoStProof
+		copy(seedProof[:], seed[:])
+
+		res, err := (&GoVerifier{}).VerifyPoST(VerifyPoSTRequest{
+			ChallengeSeed: seed,
+			Proof:         seedProof,
+		})
+		require.NoError(err)
+		require.True(res.IsValid)
+
+		res, err = (&GoVerifier{}).VerifyPoST(VerifyPoSTRequest{
+			ChallengeSeed: seed,
+			Proof:         GoPoStProof(nil, nil),
+		})
+		require.NoError(err)
+		require.False(res.IsValid)
+	})
+}
@@ -35,6 +35,12 @@ type Verifier interface {
 	VerifySeal(VerifySealRequest) (VerifySealResponse, error)
 }
 
+// SealBatchVerifier is implemented by Verifiers which can check several seal
+// proofs more cheaply together than one at a time.
+type SealBatchVerifier interface {
+	VerifySeals([]VerifySealRequest) ([]VerifySealResponse, error)
+}
+
 // SectorStoreType configures the behavior of the SectorStore used by the SectorBuilder.
 type SectorStoreType int
 
@@ -11,6 +11,25 @@ import (
 // #cgo LDFLAGS: -L${SRCDIR}/lib -lfilecoin_proofs
 // #cgo pkg-config: ${SRCDIR}/lib/pkgconfig/libfilecoin_proofs.pc
 // #include "./include/libfilecoin_proofs.h"
+//
+// // verify_seals verifies count seal proofs, whose inputs are laid out
+// // back-to-back in the provided buffers, in a single call across the cgo
+// // boundary. results[i] is set to 1 if proof i is valid, to 0 if it is
+// // invalid and to -1 if it could not be verified.
+// static void verify_seals(ConfiguredStore *cfgs, size_t count, uint8_t *comm_rs, uint8_t *comm_ds, uint8_t *comm_r_stars, uint8_t *prover_ids, uint8_t *sector_ids, uint8_t *proofs, int8_t *results) {
+//   for (size_t i = 0; i < count; i++) {
+//     VerifySealResponse *res = verify_seal(
+//       &cfgs[i],
+//       (uint8_t (*)[32])(comm_rs + 32 * i),
+//       (uint8_t (*)[32])(comm_ds + 32 * i),
+//       (uint8_t (*)[32])(comm_r_stars + 32 * i),
+//       (uint8_t (*)[31])(prover_ids + 31 * i),
+//       (uint8_t (*)[31])(sector_ids + 31 * i),
+//       (uint8_t (*)[384])(proofs + 384 * i));
+//     results[i] = res->status_code != 0 ? -1 : (res->is_valid ? 1 : 0);
+//     destroy_verify_seal_response(res);
+//   }
+// }
 import "C"
 
 var log = logging.Logger("fps") // nolint: deadcode
@@ -19,6 +38,7 @@ var log = logging.Logger("fps") // nolint: deadcode
 type RustVerifier struct{}
 
 var _ Verifier = &RustVerifier{}
+var _ SealBatchVerifier = &RustVerifier{}
 
 func elapsed(what string) func() {
 	start := time.Now()
@@ -76,6 +96,87 @@ func (rp *RustVerifier) VerifySeal(req VerifySealRequest) (VerifySealResponse, e
 	}, nil
 }
 
+// VerifySeals verifies several seal proofs with a single call into
+// rust-proofs. If any proof could not be verified, it is verified again on its
+// own to produce an error.
+func (rp *RustVerifier) VerifySeals(reqs []VerifySealRequest) ([]VerifySealResponse, error) {
+	defer elapsed("VerifySeals")()
+
+	if len(reqs) == 0 {
+		return []VerifySealResponse{}, nil
+	}
+
+	// flattening the inputs makes it easier to copy them into the C heap
+	var commRs, commDs, commRStars, proverIDs, sectorIDs, sealProofs []byte
+	for _, req := range reqs {
+		commRs = append(commRs, req.CommR[:]...)
+		commDs = append(commDs, req.CommD[:]...)
+		commRStars = append(commRStars, req.CommRStar[:]...)
+		proverIDs = append(proverIDs, req.ProverID[:]...)
+		sectorIDs = append(sectorIDs, req.SectorID[:]...)
+		sealProofs = append(sealProofs, req.Proof[:]...)
+	}
+
+	commRsCBytes := C.CBytes(commRs)
+	defer C.free(commRsCBytes)
+
+	commDsCBytes := C.CBytes(commDs)
+	defer C.free(commDsCBytes)
+
+	commRStarsCBytes := C.CBytes(commRStars)
+	defer C.free(commRStarsCBytes)
+
+	proverIDsCBytes := C.CBytes(proverIDs)
+	defer C.free(proverIDsCBytes)
+
+	sectorIDsCBytes := C.CBytes(sectorIDs)
+	defer C.free(sectorIDsCBytes)
+
+	proofsCBytes := C.CBytes(sealProofs)
+	defer C.free(proofsCBytes)
+
+	// allocate fixed-length arrays of store configurations and results in C heap
+	cfgsPtr := C.malloc(C.size_t(len(reqs)) * C.sizeof_ConfiguredStore)
+	defer C.free(cfgsPtr)
+	cfgs := (*[1 << 30]C.ConfiguredStore)(cfgsPtr)
+	for i, req := range reqs {
+		cfg, err := CSectorStoreType(req.StoreType)
+		if err != nil {
+			return nil, err
+		}
+		cfgs[i] = *cfg
+	}
+
+	resultsPtr := C.malloc(C.size_t(len(reqs)) * C.sizeof_int8_t)
+	defer C.free(resultsPtr)
+
+	C.verify_seals(
+		(*C.ConfiguredStore)(cfgsPtr),
+		C.size_t(len(reqs)),
+		(*C.uint8_t)(commRsCBytes),
+		(*C.uint8_t)(commDsCBytes),
+		(*C.uint8_t)(commRStarsCBytes),
+		(*C.uint8_t)(proverIDsCBytes),
+		(*C.uint8_t)(sectorIDsCBytes),
+		(*C.uint8_t)(proofsCBytes),
+		(*C.int8_t)(resultsPtr),
+	)
+
+	results := (*[1 << 30]C.int8_t)(resultsPtr)
+	responses := make([]VerifySealResponse, len(reqs))
+	for i := range reqs {
+		if results[i] < 0 {
+			if _, err := rp.VerifySeal(reqs[i]); err != nil {
+				return nil, errors.Wrapf(err, "failed to verify seal %d of %d", i+1, len(reqs))
+			}
+			return nil, errors.Errorf("failed to verify seal %d of %d", i+1, len(reqs))
+		}
+		responses[i] = VerifySealResponse{IsValid: results[i] == 1}
+	}
+
+	return responses, nil
+}
+
 // VerifyPoST verifies that a proof-of-spacetime is valid.
 func (rp *RustVerifier) VerifyPoST(req VerifyPoSTRequest) (VerifyPoSTResponse, error) {
 	defer elapsed("VerifyPoST")()
@@ -0,0 +1,34 @@
+//go:build !cgo
+// +build !cgo
+
+package proofs
+
+import (
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+)
+
+// ErrRustProofsUnavailable is returned by the rust-proofs backend when the
+// binary was built without cgo. Use the Go proofs backend instead.
+var ErrRustProofsUnavailable = errors.New("built without cgo: rust-proofs are unavailable")
+
+// RustVerifier provides proof-verification methods. Without cgo, every method
+// returns ErrRustProofsUnavailable.
+type RustVerifier struct{}
+
+var _ Verifier = &RustVerifier{}
+var _ SealBatchVerifier = &RustVerifier{}
+
+// VerifySeal returns ErrRustProofsUnavailable.
+func (rp *RustVerifier) VerifySeal(req VerifySealRequest) (VerifySealResponse, error) {
+	return VerifySealResponse{}, ErrRustProofsUnavailable
+}
+
+// VerifySeals returns ErrRustProofsUnavailable.
+func (rp *RustVerifier) VerifySeals(reqs []VerifySealRequest) ([]VerifySealResponse, error) {
+	return nil, ErrRustProofsUnavailable
+}
+
+// VerifyPoST returns ErrRustProofsUnavailable.
+func (rp *RustVerifier) VerifyPoST(req VerifyPoSTRequest) (VerifyPoSTResponse, error) {
+	return VerifyPoSTResponse{}, ErrRustProofsUnavailable
+}
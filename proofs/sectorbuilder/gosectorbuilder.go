@@ -0,0 +1,466 @@
+package sectorbuilder
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	uio "gx/ipfs/QmQXze9tG878pa4Euya4rrDpyTNX3kQe4dhCaBzBozGgpe/go-unixfs/io"
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	dag "gx/ipfs/QmTQdH4848iTVCJmKXYyRiK72HufWTLYQQ8iN3JaQ8K1Hq/go-merkledag"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+	bserv "gx/ipfs/QmYPZzd9VqmJDwxUnThfeSbV1Y5o53aVPDijTB7j7rS9Ep/go-blockservice"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/proofs"
+)
+
+// GoLiveSectorSize and GoTestSectorSize are the number of user piece-bytes
+// which fit into a GoSectorBuilder sector, chosen to match rust-proofs' Live
+// and Test sector store types.
+const (
+	GoLiveSectorSize = uint64(266338304)
+	GoTestSectorSize = uint64(1016)
+)
+
+// goMetadataFilename is the name of the file in which GoSectorBuilder keeps
+// its metadata.
+const goMetadataFilename = "go-sector-builder.json"
+
+// ErrSectorNotStaged is returned when attempting to seal a sector which is not
+// staged, e.g. because it does not exist or is already being sealed.
+var ErrSectorNotStaged = errors.New("sector is not staged")
+
+// ErrPieceNotFound is returned when a piece cannot be found in any sealed
+// sector.
+var ErrPieceNotFound = errors.New("piece not found in any sealed sector")
+
+// GoSectorBuilderConfig is a configuration object used when instantiating a
+// GoSectorBuilder. All fields are required.
+type GoSectorBuilderConfig struct {
+	BlockService     bserv.BlockService
+	LastUsedSectorID uint64
+	MetadataDir      string
+	MinerAddr        address.Address
+	SealedSectorDir  string
+	SectorStoreType  proofs.SectorStoreType
+	StagedSectorDir  string
+}
+
+// goPiece records where a piece's bytes live within a sector.
+type goPiece struct {
+	Ref    cid.Cid `json:"ref"`
+	Size   uint64  `json:"size"`
+	Offset uint64  `json:"offset"`
+}
+
+// goSector is GoSectorBuilder's metadata for a staged or sealed sector.
+type goSector struct {
+	SectorID uint64    `json:"sectorID"`
+	Pieces   []goPiece `json:"pieces"`
+	NumBytes uint64    `json:"numBytes"`
+	// Sealing is true once sealing of the sector has started.
+	Sealing bool `json:"sealing"`
+	// Sealed is set once sealing has completed successfully.
+	Sealed *SealedSectorMetadata `json:"sealed,omitempty"`
+}
+
+// goSectorBuilderMetadata is persisted whenever GoSectorBuilder's state
+// changes so that staged and sealed sectors survive a restart.
+type goSectorBuilderMetadata struct {
+	LastUsedSectorID uint64               `json:"lastUsedSectorID"`
+	OpenSectorID     uint64               `json:"openSectorID"`
+	Sectors          map[uint64]*goSector `json:"sectors"`
+}
+
+// GoSectorBuilder is a pure-Go SectorBuilder for development networks. It
+// stages piece-bytes on disk, "seals" sectors by deriving hash-based
+// commitments (see proofs.GoVerifier) and storing a reversibly scrambled
+// replica, unseals pieces from replicas and produces deterministic PoSts. Its
+// proofs prove nothing and are only accepted by proofs.GoVerifier.
+type GoSectorBuilder struct {
+	blockService bserv.BlockService
+	proverID     [31]byte
+	maxBytes     uint64
+
+	metadataPath string
+	stagedDir    string
+	sealedDir    string
+
+	lk   sync.Mutex
+	meta goSectorBuilderMetadata
+
+	sealWg  sync.WaitGroup
+	closeCh chan struct{}
+
+	sectorSealResults chan SectorSealResult
+}
+
+var _ SectorBuilder = &GoSectorBuilder{}
+var _ SectorSealer = &GoSectorBuilder{}
+
+// NewGoSectorBuilder creates a GoSectorBuilder, loading any metadata left in
+// cfg.MetadataDir by a previous instance. Sectors whose sealing was
+// interrupted are sealed again.
+func NewGoSectorBuilder(cfg GoSectorBuilderConfig) (*GoSectorBuilder, error) {
+	maxBytes := GoLiveSectorSize
+	if cfg.SectorStoreType == proofs.Test {
+		maxBytes = GoTestSectorSize
+	}
+
+	for _, dir := range []string{cfg.MetadataDir, cfg.StagedSectorDir, cfg.SealedSectorDir} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, errors.Wrapf(err, "failed to create directory %s", dir)
+		}
+	}
+
+	sb := &GoSectorBuilder{
+		blockService:      cfg.BlockService,
+		proverID:          AddressToProverID(cfg.MinerAddr),
+		maxBytes:          maxBytes,
+		metadataPath:      filepath.Join(cfg.MetadataDir, goMetadataFilename),
+		stagedDir:         cfg.StagedSectorDir,
+		sealedDir:         cfg.SealedSectorDir,
+		closeCh:           make(chan struct{}),
+		sectorSealResults: make(chan SectorSealResult),
+		meta: goSectorBuilderMetadata{
+			Sectors: make(map[uint64]*goSector),
+		},
+	}
+
+	if err := sb.loadMetadata(); err != nil {
+		return nil, errors.Wrap(err, "failed to load sector builder metadata")
+	}
+
+	if cfg.LastUsedSectorID > sb.meta.LastUsedSectorID {
+		sb.meta.LastUsedSectorID = cfg.LastUsedSectorID
+	}
+
+	for id, sector := range sb.meta.Sectors {
+		if sector.Sealing && sector.Sealed == nil {
+			sb.startSeal(id)
+		}
+	}
+
+	return sb, nil
+}
+
+// GetMaxUserBytesPerStagedSector produces the number of user piece-bytes which
+// will fit into a newly-provisioned staged sector.
+func (sb *GoSectorBuilder) GetMaxUserBytesPerStagedSector() (uint64, error) {
+	return sb.maxBytes, nil
+}
+
+// AddPiece writes the given piece into an unsealed sector and returns the id
+// of that sector. If the piece does not fit into the sector currently
+// accepting data, a new sector is staged; the old sector stays staged until it
+// is sealed.
+func (sb *GoSectorBuilder) AddPiece(ctx context.Context, pi *PieceInfo) (uint64, error) {
+	if pi.Size > sb.maxBytes {
+		return 0, ErrPieceTooLarge
+	}
+
+	pieceBytes, err := sb.readPiece(ctx, pi)
+	if err != nil {
+		return 0, err
+	}
+
+	sb.lk.Lock()
+	defer sb.lk.Unlock()
+
+	sector, ok := sb.meta.Sectors[sb.meta.OpenSectorID]
+	if !ok || sector.Sealing || sector.NumBytes+pi.Size > sb.maxBytes {
+		sb.meta.LastUsedSectorID++
+		sector = &goSector{SectorID: sb.meta.LastUsedSectorID}
+		sb.meta.Sectors[sector.SectorID] = sector
+		sb.meta.OpenSectorID = sector.SectorID
+	}
+
+	f, err := os.OpenFile(sb.stagedPath(sector.SectorID), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to open staged sector")
+	}
+	defer f.Close() // nolint: errcheck
+
+	if _, err := f.Write(pieceBytes); err != nil {
+		return 0, errors.Wrap(err, "failed to write piece to staged sector")
+	}
+
+	sector.Pieces = append(sector.Pieces, goPiece{
+		Ref:    pi.Ref,
+		Size:   pi.Size,
+		Offset: sector.NumBytes,
+	})
+	sector.NumBytes += pi.Size
+
+	if err := sb.saveMetadata(); err != nil {
+		return 0, err
+	}
+
+	return sector.SectorID, nil
+}
+
+// SealSector starts sealing the staged sector with the given id. The result is
+// sent to the SectorSealResults channel once sealing completes.
+func (sb *GoSectorBuilder) SealSector(ctx context.Context, sectorID uint64) error {
+	sb.lk.Lock()
+	defer sb.lk.Unlock()
+
+	sector, ok := sb.meta.Sectors[sectorID]
+	if !ok || sector.Sealing {
+		return ErrSectorNotStaged
+	}
+
+	return sb.sealLocked(sector)
+}
+
+// StagedSectors returns the staged sectors which are not being sealed.
+func (sb *GoSectorBuilder) StagedSectors() ([]StagedSectorInfo, error) {
+	sb.lk.Lock()
+	defer sb.lk.Unlock()
+
+	var infos []StagedSectorInfo
+	for _, sector := range sb.meta.Sectors {
+		if !sector.Sealing {
+			infos = append(infos, StagedSectorInfo{SectorID: sector.SectorID, NumBytes: sector.NumBytes})
+		}
+	}
+	return infos, nil
+}
+
+// SealAllStagedSectors starts sealing every non-empty staged sector.
+func (sb *GoSectorBuilder) SealAllStagedSectors(ctx context.Context) error {
+	sb.lk.Lock()
+	defer sb.lk.Unlock()
+
+	for _, sector := range sb.meta.Sectors {
+		if !sector.Sealing && sector.NumBytes > 0 {
+			if err := sb.sealLocked(sector); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ReadPieceFromSealedSector produces a Reader used to get original piece-bytes
+// from a sealed sector.
+func (sb *GoSectorBuilder) ReadPieceFromSealedSector(pieceCid cid.Cid) (io.Reader, error) {
+	sb.lk.Lock()
+	var sealed *SealedSectorMetadata
+	var piece goPiece
+	for _, sector := range sb.meta.Sectors {
+		if sector.Sealed == nil {
+			continue
+		}
+		for _, p := range sector.Pieces {
+			if p.Ref.Equals(pieceCid) {
+				sealed, piece = sector.Sealed, p
+			}
+		}
+	}
+	sb.lk.Unlock()
+
+	if sealed == nil {
+		return nil, ErrPieceNotFound
+	}
+
+	replica, err := ioutil.ReadFile(sb.sealedPath(sealed.SectorID))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read sealed sector")
+	}
+
+	data := scramble(replica, sealed.CommR)
+
+	return bytes.NewReader(data[piece.Offset : piece.Offset+piece.Size]), nil
+}
+
+// SectorSealResults returns an unbuffered channel that is sent a value whenever
+// sealing completes.
+func (sb *GoSectorBuilder) SectorSealResults() <-chan SectorSealResult {
+	return sb.sectorSealResults
+}
+
+// GeneratePoST produces a proof-of-spacetime for the provided replica
+// commitments. The proof can be verified by proofs.GoVerifier.
+func (sb *GoSectorBuilder) GeneratePoST(req GeneratePoSTRequest) (GeneratePoSTResponse, error) {
+	return GeneratePoSTResponse{
+		Proof:  proofs.GoPoStProof(req.CommRs, nil),
+		Faults: []uint64{},
+	}, nil
+}
+
+// Close stops any seals in progress from reporting their results and waits for
+// them to finish.
+func (sb *GoSectorBuilder) Close() error {
+	close(sb.closeCh)
+	sb.sealWg.Wait()
+	return nil
+}
+
+// sealLocked marks a sector as sealing and starts sealing it. Callers must
+// hold sb.lk.
+func (sb *GoSectorBuilder) sealLocked(sector *goSector) error {
+	sector.Sealing = true
+	if sb.meta.OpenSectorID == sector.SectorID {
+		sb.meta.OpenSectorID = 0
+	}
+
+	if err := sb.saveMetadata(); err != nil {
+		return err
+	}
+
+	sb.startSeal(sector.SectorID)
+
+	return nil
+}
+
+// startSeal seals a sector in the background and reports the result.
+func (sb *GoSectorBuilder) startSeal(sectorID uint64) {
+	sb.sealWg.Add(1)
+	go func() {
+		defer sb.sealWg.Done()
+
+		meta, err := sb.seal(sectorID)
+		result := SectorSealResult{
+			SectorID:      sectorID,
+			SealingErr:    err,
+			SealingResult: meta,
+		}
+
+		select {
+		case sb.sectorSealResults <- result:
+		case <-sb.closeCh:
+		}
+	}()
+}
+
+// seal produces commitments for a staged sector and replaces it with a
+// replica.
+func (sb *GoSectorBuilder) seal(sectorID uint64) (*SealedSectorMetadata, error) {
+	sb.lk.Lock()
+	sector := sb.meta.Sectors[sectorID]
+	pieces := make([]*PieceInfo, len(sector.Pieces))
+	for i, p := range sector.Pieces {
+		pieces[i] = &PieceInfo{Ref: p.Ref, Size: p.Size}
+	}
+	sb.lk.Unlock()
+
+	data, err := ioutil.ReadFile(sb.stagedPath(sectorID))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read staged sector")
+	}
+
+	sectorIDBytes := SectorIDToBytes(sectorID)
+	commD := proofs.GoDataCommitment(data)
+	commR, commRStar := proofs.GoReplicaCommitments(sb.proverID, sectorIDBytes, commD)
+
+	if err := ioutil.WriteFile(sb.sealedPath(sectorID), scramble(data, commR), 0600); err != nil {
+		return nil, errors.Wrap(err, "failed to write sealed sector")
+	}
+
+	meta := &SealedSectorMetadata{
+		CommD:     commD,
+		CommR:     commR,
+		CommRStar: commRStar,
+		Pieces:    pieces,
+		Proof:     proofs.GoSealProof(sb.proverID, sectorIDBytes, commD, commR, commRStar),
+		SectorID:  sectorID,
+	}
+
+	sb.lk.Lock()
+	defer sb.lk.Unlock()
+
+	sector.Sealed = meta
+	if err := sb.saveMetadata(); err != nil {
+		return nil, err
+	}
+
+	if err := os.Remove(sb.stagedPath(sectorID)); err != nil {
+		log.Warningf("failed to remove staged sector %d: %s", sectorID, err)
+	}
+
+	return meta, nil
+}
+
+// readPiece reads a piece's bytes from the DAG.
+func (sb *GoSectorBuilder) readPiece(ctx context.Context, pi *PieceInfo) ([]byte, error) {
+	dagService := dag.NewDAGService(sb.blockService)
+
+	rootIpldNode, err := dagService.Get(ctx, pi.Ref)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := uio.NewDagReader(ctx, rootIpldNode, dagService)
+	if err != nil {
+		return nil, err
+	}
+
+	pieceBytes := make([]byte, pi.Size)
+	if _, err := io.ReadFull(r, pieceBytes); err != nil {
+		return nil, errors.Wrapf(err, "error reading piece bytes into buffer")
+	}
+
+	return pieceBytes, nil
+}
+
+func (sb *GoSectorBuilder) stagedPath(sectorID uint64) string {
+	return filepath.Join(sb.stagedDir, fmt.Sprintf("go-sector-%d", sectorID))
+}
+
+func (sb *GoSectorBuilder) sealedPath(sectorID uint64) string {
+	return filepath.Join(sb.sealedDir, fmt.Sprintf("go-sector-%d", sectorID))
+}
+
+// loadMetadata reads the builder's metadata file, if there is one.
+func (sb *GoSectorBuilder) loadMetadata() error {
+	raw, err := ioutil.ReadFile(sb.metadataPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(raw, &sb.meta)
+}
+
+// saveMetadata writes the builder's metadata file. Callers must hold sb.lk.
+func (sb *GoSectorBuilder) saveMetadata() error {
+	raw, err := json.Marshal(sb.meta)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal sector builder metadata")
+	}
+
+	tmp := sb.metadataPath + ".tmp"
+	if err := ioutil.WriteFile(tmp, raw, 0600); err != nil {
+		return errors.Wrap(err, "failed to write sector builder metadata")
+	}
+
+	return os.Rename(tmp, sb.metadataPath)
+}
+
+// scramble XORs data with a keystream derived from commR. Applying it twice
+// yields the original data, so it both "replicates" and unseals.
+func scramble(data []byte, commR proofs.CommR) []byte {
+	out := make([]byte, len(data))
+	var counter [8]byte
+	for i := 0; i < len(data); i += sha256.Size {
+		binary.BigEndian.PutUint64(counter[:], uint64(i))
+		key := sha256.Sum256(append(counter[:], commR[:]...))
+		for j := 0; j < sha256.Size && i+j < len(data); j++ {
+			out[i+j] = data[i+j] ^ key[j]
+		}
+	}
+	return out
+}
@@ -0,0 +1,113 @@
+package sectorbuilder
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	bstore "gx/ipfs/QmS2aqUZLJp8kF1ihE5rvDGE5LvmKDPnx32w9Z1BW9xLV5/go-ipfs-blockstore"
+	dag "gx/ipfs/QmTQdH4848iTVCJmKXYyRiK72HufWTLYQQ8iN3JaQ8K1Hq/go-merkledag"
+	bserv "gx/ipfs/QmYPZzd9VqmJDwxUnThfeSbV1Y5o53aVPDijTB7j7rS9Ep/go-blockservice"
+	offline "gx/ipfs/QmYZwey1thDTynSrvd6qQkX24UpTka6TFhQ2v569UpoqxD/go-ipfs-exchange-offline"
+	"gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/proofs"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoSectorBuilder(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "gosectorbuilder")
+	require.NoError(err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	bs := bstore.NewBlockstore(datastore.NewMapDatastore())
+	blockService := bserv.New(bs, offline.Exchange(bs))
+	minerAddr := address.MakeTestAddress("wombat")
+
+	cfg := GoSectorBuilderConfig{
+		BlockService:    blockService,
+		MetadataDir:     dir,
+		MinerAddr:       minerAddr,
+		SealedSectorDir: dir,
+		SectorStoreType: proofs.Test,
+		StagedSectorDir: dir,
+	}
+
+	sb, err := NewGoSectorBuilder(cfg)
+	require.NoError(err)
+
+	addPiece := func(data []byte) *PieceInfo {
+		node := dag.NewRawNode(data)
+		require.NoError(blockService.AddBlock(node))
+		pi := &PieceInfo{Ref: node.Cid(), Size: uint64(len(data))}
+		_, err := sb.AddPiece(ctx, pi)
+		require.NoError(err)
+		return pi
+	}
+
+	first := addPiece([]byte("first piece"))
+	second := addPiece([]byte("second piece"))
+
+	require.NoError(sb.SealAllStagedSectors(ctx))
+	result := <-sb.SectorSealResults()
+	require.NoError(result.SealingErr)
+	require.Equal(uint64(1), result.SectorID)
+	require.Len(result.SealingResult.Pieces, 2)
+
+	t.Run("seal proof is accepted by the Go verifier", func(t *testing.T) {
+		meta := result.SealingResult
+		res, err := (&proofs.GoVerifier{}).VerifySeal(proofs.VerifySealRequest{
+			CommD:     meta.CommD,
+			CommR:     meta.CommR,
+			CommRStar: meta.CommRStar,
+			Proof:     meta.Proof,
+			ProverID:  AddressToProverID(minerAddr),
+			SectorID:  SectorIDToBytes(meta.SectorID),
+		})
+		require.NoError(err)
+		require.True(res.IsValid)
+	})
+
+	t.Run("pieces can be unsealed", func(t *testing.T) {
+		r, err := sb.ReadPieceFromSealedSector(second.Ref)
+		require.NoError(err)
+		data, err := ioutil.ReadAll(r)
+		require.NoError(err)
+		require.Equal("second piece", string(data))
+	})
+
+	t.Run("PoSt is accepted by the Go verifier", func(t *testing.T) {
+		commRs := []proofs.CommR{result.SealingResult.CommR}
+		res, err := sb.GeneratePoST(GeneratePoSTRequest{CommRs: commRs})
+		require.NoError(err)
+
+		valid, err := proofs.IsPoStValidWithVerifier(&proofs.GoVerifier{}, commRs, proofs.PoStChallengeSeed{}, res.Faults, res.Proof)
+		require.NoError(err)
+		require.True(valid)
+	})
+
+	t.Run("sealed sectors survive a restart", func(t *testing.T) {
+		require.NoError(sb.Close())
+
+		restarted, err := NewGoSectorBuilder(cfg)
+		require.NoError(err)
+		defer restarted.Close() // nolint: errcheck
+
+		r, err := restarted.ReadPieceFromSealedSector(first.Ref)
+		require.NoError(err)
+		data, err := ioutil.ReadAll(r)
+		require.NoError(err)
+		require.Equal("first piece", string(data))
+	})
+
+	t.Run("pieces larger than a sector are rejected", func(t *testing.T) {
+		_, err := sb.AddPiece(ctx, &PieceInfo{Ref: first.Ref, Size: GoTestSectorSize + 1})
+		require.Equal(ErrPieceTooLarge, err)
+	})
+}
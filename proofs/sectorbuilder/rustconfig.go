@@ -0,0 +1,27 @@
+package sectorbuilder
+
+import (
+	bserv "gx/ipfs/QmYPZzd9VqmJDwxUnThfeSbV1Y5o53aVPDijTB7j7rS9Ep/go-blockservice"
+	logging "gx/ipfs/QmcuXC5cxs79ro2cUuHs4HQ2bkDLJUYokwL8aivcX6HW3C/go-log"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/proofs"
+)
+
+var log = logging.Logger("sectorbuilder") // nolint: deadcode
+
+// MaxNumStagedSectors configures the maximum number of staged sectors which can
+// be open and accepting data at any time.
+const MaxNumStagedSectors = 1
+
+// RustSectorBuilderConfig is a configuration object used when instantiating a
+// Rust-backed SectorBuilder through the FFI. All fields are required.
+type RustSectorBuilderConfig struct {
+	BlockService     bserv.BlockService
+	LastUsedSectorID uint64
+	MetadataDir      string
+	MinerAddr        address.Address
+	SealedSectorDir  string
+	SectorStoreType  proofs.SectorStoreType
+	StagedSectorDir  string
+}
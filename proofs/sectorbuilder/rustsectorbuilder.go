@@ -8,7 +8,6 @@ import (
 	"time"
 	"unsafe"
 
-	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/proofs"
 
 	uio "gx/ipfs/QmQXze9tG878pa4Euya4rrDpyTNX3kQe4dhCaBzBozGgpe/go-unixfs/io"
@@ -16,7 +15,6 @@ import (
 	dag "gx/ipfs/QmTQdH4848iTVCJmKXYyRiK72HufWTLYQQ8iN3JaQ8K1Hq/go-merkledag"
 	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
 	bserv "gx/ipfs/QmYPZzd9VqmJDwxUnThfeSbV1Y5o53aVPDijTB7j7rS9Ep/go-blockservice"
-	logging "gx/ipfs/QmcuXC5cxs79ro2cUuHs4HQ2bkDLJUYokwL8aivcX6HW3C/go-log"
 )
 
 // #cgo LDFLAGS: -L${SRCDIR}/../lib -lfilecoin_proofs
@@ -24,12 +22,6 @@ import (
 // #include "../include/libfilecoin_proofs.h"
 import "C"
 
-var log = logging.Logger("sectorbuilder") // nolint: deadcode
-
-// MaxNumStagedSectors configures the maximum number of staged sectors which can
-// be open and accepting data at any time.
-const MaxNumStagedSectors = 1
-
 // stagedSectorMetadata is a sector into which we write user piece-data before
 // sealing. Note: sectorID is unique across all staged and sealed sectors for a
 // miner.
@@ -60,18 +52,6 @@ type RustSectorBuilder struct {
 
 var _ SectorBuilder = &RustSectorBuilder{}
 
-// RustSectorBuilderConfig is a configuration object used when instantiating a
-// Rust-backed SectorBuilder through the FFI. All fields are required.
-type RustSectorBuilderConfig struct {
-	BlockService     bserv.BlockService
-	LastUsedSectorID uint64
-	MetadataDir      string
-	MinerAddr        address.Address
-	SealedSectorDir  string
-	SectorStoreType  proofs.SectorStoreType
-	StagedSectorDir  string
-}
-
 // NewRustSectorBuilder instantiates a SectorBuilder through the FFI.
 func NewRustSectorBuilder(cfg RustSectorBuilderConfig) (*RustSectorBuilder, error) {
 	defer elapsed("NewRustSectorBuilder")()
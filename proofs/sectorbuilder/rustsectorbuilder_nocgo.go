@@ -0,0 +1,59 @@
+//go:build !cgo
+// +build !cgo
+
+package sectorbuilder
+
+import (
+	"context"
+	"io"
+
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+
+	"github.com/filecoin-project/go-filecoin/proofs"
+)
+
+// RustSectorBuilder is a SectorBuilder backed by rust-proofs. Without cgo it
+// cannot be constructed; use GoSectorBuilder instead.
+type RustSectorBuilder struct{}
+
+var _ SectorBuilder = &RustSectorBuilder{}
+
+// NewRustSectorBuilder returns proofs.ErrRustProofsUnavailable.
+func NewRustSectorBuilder(cfg RustSectorBuilderConfig) (*RustSectorBuilder, error) {
+	return nil, proofs.ErrRustProofsUnavailable
+}
+
+// AddPiece returns proofs.ErrRustProofsUnavailable.
+func (sb *RustSectorBuilder) AddPiece(ctx context.Context, pi *PieceInfo) (uint64, error) {
+	return 0, proofs.ErrRustProofsUnavailable
+}
+
+// ReadPieceFromSealedSector returns proofs.ErrRustProofsUnavailable.
+func (sb *RustSectorBuilder) ReadPieceFromSealedSector(pieceCid cid.Cid) (io.Reader, error) {
+	return nil, proofs.ErrRustProofsUnavailable
+}
+
+// SealAllStagedSectors returns proofs.ErrRustProofsUnavailable.
+func (sb *RustSectorBuilder) SealAllStagedSectors(ctx context.Context) error {
+	return proofs.ErrRustProofsUnavailable
+}
+
+// SectorSealResults returns a channel which is never sent a value.
+func (sb *RustSectorBuilder) SectorSealResults() <-chan SectorSealResult {
+	return nil
+}
+
+// GetMaxUserBytesPerStagedSector returns proofs.ErrRustProofsUnavailable.
+func (sb *RustSectorBuilder) GetMaxUserBytesPerStagedSector() (uint64, error) {
+	return 0, proofs.ErrRustProofsUnavailable
+}
+
+// GeneratePoST returns proofs.ErrRustProofsUnavailable.
+func (sb *RustSectorBuilder) GeneratePoST(req GeneratePoSTRequest) (GeneratePoSTResponse, error) {
+	return GeneratePoSTResponse{}, proofs.ErrRustProofsUnavailable
+}
+
+// Close does nothing.
+func (sb *RustSectorBuilder) Close() error {
+	return nil
+}
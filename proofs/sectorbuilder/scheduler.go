@@ -0,0 +1,547 @@
+package sectorbuilder
+
+import (
+	"container/heap"
+	"context"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+)
+
+// NoSealDeadline is the deadline assigned to staged sectors whose pieces were
+// added without a deal start deadline. Such sectors are sealed after all
+// sectors with a deadline.
+const NoSealDeadline = uint64(math.MaxUint64)
+
+// DefaultMaxConcurrentSeals is the number of sectors a SealScheduler will seal
+// at once if not otherwise configured.
+const DefaultMaxConcurrentSeals = 1
+
+// DefaultSealRetryDelay is the time after which a SealScheduler queues a
+// sector whose seal failed to start again, if not otherwise configured.
+const DefaultSealRetryDelay = time.Minute
+
+// ErrSchedulerClosed is returned when a SealScheduler is used after Close.
+var ErrSchedulerClosed = errors.New("seal scheduler is closed")
+
+// StagedSectorInfo describes a staged sector which is not being sealed.
+type StagedSectorInfo struct {
+	SectorID uint64
+	NumBytes uint64
+}
+
+// SectorSealer is implemented by SectorBuilders which are able to seal a
+// single staged sector. SealScheduler uses it to order seals and enforce its
+// concurrency limit.
+type SectorSealer interface {
+	SealSector(ctx context.Context, sectorID uint64) error
+
+	// StagedSectors returns the staged sectors which are not being sealed,
+	// including those staged before a restart.
+	StagedSectors() ([]StagedSectorInfo, error)
+}
+
+// SealSchedulerConfig configures a SealScheduler.
+type SealSchedulerConfig struct {
+	// MaxConcurrentSeals is the maximum number of sectors being sealed at
+	// any time. Values less than one are treated as DefaultMaxConcurrentSeals.
+	MaxConcurrentSeals int
+
+	// AutoSealInterval, when non-zero, causes every non-empty staged sector
+	// to be queued for sealing on the given interval.
+	AutoSealInterval time.Duration
+
+	// RetryDelay is the time after which a sector whose seal failed to
+	// start is queued again. Zero means DefaultSealRetryDelay.
+	RetryDelay time.Duration
+}
+
+// SealSchedulerStatus is a snapshot of a SealScheduler's state.
+type SealSchedulerStatus struct {
+	Paused             bool
+	MaxConcurrentSeals int
+	// Queued holds the ids of sectors waiting to be sealed, in the order
+	// in which they will be sealed.
+	Queued []uint64
+	// Sealing holds the ids of sectors which are currently being sealed.
+	Sealing []uint64
+	// Staged holds the ids of sectors which are accepting pieces and have
+	// not yet been queued for sealing.
+	Staged []uint64
+}
+
+// stagedSector is the scheduler's book-keeping for a sector which has been
+// written to but not yet sealed.
+type stagedSector struct {
+	sectorID uint64
+	// deadline is the earliest deal start deadline (block height) of any
+	// piece in the sector.
+	deadline uint64
+	numBytes uint64
+	// index is the sector's position in the seal queue, or -1 if the
+	// sector is not queued.
+	index int
+}
+
+// sealQueue is a priority queue of staged sectors ordered by deadline, then
+// by sector id. It implements heap.Interface.
+type sealQueue []*stagedSector
+
+func (q sealQueue) Len() int { return len(q) }
+
+func (q sealQueue) Less(i, j int) bool {
+	if q[i].deadline != q[j].deadline {
+		return q[i].deadline < q[j].deadline
+	}
+	return q[i].sectorID < q[j].sectorID
+}
+
+func (q sealQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *sealQueue) Push(x interface{}) {
+	s := x.(*stagedSector)
+	s.index = len(*q)
+	*q = append(*q, s)
+}
+
+func (q *sealQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	s := old[n-1]
+	s.index = -1
+	*q = old[:n-1]
+	return s
+}
+
+// SealScheduler is a SectorBuilder which decorates another SectorBuilder,
+// deciding when its staged sectors get sealed. Sectors are queued for sealing
+// when they are full, when SealAllStagedSectors is called or when the auto-seal
+// interval elapses. Queued sectors are sealed in order of their earliest deal
+// start deadline, never more than MaxConcurrentSeals at once. Sealing can be
+// paused and resumed; sectors which are already being sealed are unaffected by
+// a pause.
+//
+// If the decorated SectorBuilder cannot seal a single sector, the scheduler
+// seals every non-empty staged sector at once with SealAllStagedSectors when
+// a sector is queued and no seal is in progress.
+type SealScheduler struct {
+	inner SectorBuilder
+	// sealer is nil if inner cannot seal a single sector.
+	sealer SectorSealer
+	cfg    SealSchedulerConfig
+
+	lk      sync.Mutex
+	paused  bool
+	closed  bool
+	staged  map[uint64]*stagedSector
+	queue   sealQueue
+	sealing map[uint64]struct{}
+
+	// maxBytesPerSector caches inner.GetMaxUserBytesPerStagedSector.
+	maxBytesPerSector uint64
+
+	// wakeCh is signalled whenever the scheduler may be able to start
+	// sealing another sector.
+	wakeCh chan struct{}
+	stopCh chan struct{}
+	doneWg sync.WaitGroup
+
+	sectorSealResults chan SectorSealResult
+}
+
+var _ SectorBuilder = &SealScheduler{}
+
+// NewSealScheduler creates a SealScheduler wrapping the provided SectorBuilder
+// and starts it. The SealScheduler takes ownership of inner: it consumes
+// inner's seal results and closes inner when it is itself closed. If inner
+// implements SectorSealer, the non-empty sectors it staged before a restart
+// are queued right away, as their deadlines are not known anymore.
+func NewSealScheduler(inner SectorBuilder, cfg SealSchedulerConfig) (*SealScheduler, error) {
+	if cfg.MaxConcurrentSeals < 1 {
+		cfg.MaxConcurrentSeals = DefaultMaxConcurrentSeals
+	}
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = DefaultSealRetryDelay
+	}
+
+	maxBytes, err := inner.GetMaxUserBytesPerStagedSector()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get max bytes per staged sector")
+	}
+
+	var stagedSectors []StagedSectorInfo
+	sealer, ok := inner.(SectorSealer)
+	if ok {
+		stagedSectors, err = sealer.StagedSectors()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get staged sectors")
+		}
+	}
+
+	s := &SealScheduler{
+		inner:             inner,
+		sealer:            sealer,
+		cfg:               cfg,
+		staged:            make(map[uint64]*stagedSector),
+		sealing:           make(map[uint64]struct{}),
+		maxBytesPerSector: maxBytes,
+		wakeCh:            make(chan struct{}, 1),
+		stopCh:            make(chan struct{}),
+		sectorSealResults: make(chan SectorSealResult),
+	}
+
+	for _, info := range stagedSectors {
+		sector := &stagedSector{
+			sectorID: info.SectorID,
+			deadline: NoSealDeadline,
+			numBytes: info.NumBytes,
+			index:    -1,
+		}
+		s.staged[info.SectorID] = sector
+	}
+	s.enqueueAll()
+
+	s.doneWg.Add(2)
+	go s.dispatchLoop()
+	go s.resultsLoop()
+
+	return s, nil
+}
+
+// AddPiece writes the given piece into an unsealed sector and returns the id
+// of that sector. The sector is given no deadline.
+func (s *SealScheduler) AddPiece(ctx context.Context, pi *PieceInfo) (uint64, error) {
+	return s.AddPieceWithDeadline(ctx, pi, NoSealDeadline)
+}
+
+// AddPieceWithDeadline writes the given piece into an unsealed sector and
+// returns the id of that sector. The deadline is the block height at which the
+// deal for the piece starts; the sector containing the piece is sealed ahead of
+// sectors with later deadlines. If the piece fills the sector, the sector is
+// queued for sealing immediately.
+func (s *SealScheduler) AddPieceWithDeadline(ctx context.Context, pi *PieceInfo, deadline uint64) (uint64, error) {
+	sectorID, err := s.inner.AddPiece(ctx, pi)
+	if err != nil {
+		return 0, err
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	if _, ok := s.sealing[sectorID]; ok {
+		// the inner sector builder sealed the sector on its own
+		return sectorID, nil
+	}
+
+	sector, ok := s.staged[sectorID]
+	if !ok {
+		sector = &stagedSector{
+			sectorID: sectorID,
+			deadline: NoSealDeadline,
+			index:    -1,
+		}
+		s.staged[sectorID] = sector
+	}
+
+	sector.numBytes += pi.Size
+	if deadline < sector.deadline {
+		sector.deadline = deadline
+		if sector.index >= 0 {
+			heap.Fix(&s.queue, sector.index)
+		}
+	}
+
+	if sector.numBytes >= s.maxBytesPerSector {
+		s.enqueue(sector)
+	}
+
+	return sectorID, nil
+}
+
+// ReadPieceFromSealedSector produces a Reader used to get original piece-bytes
+// from a sealed sector.
+func (s *SealScheduler) ReadPieceFromSealedSector(pieceCid cid.Cid) (io.Reader, error) {
+	return s.inner.ReadPieceFromSealedSector(pieceCid)
+}
+
+// SealAllStagedSectors queues every non-empty staged sector for sealing. The
+// sectors are sealed subject to the scheduler's concurrency limit and are not
+// sealed while the scheduler is paused.
+func (s *SealScheduler) SealAllStagedSectors(ctx context.Context) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	if s.closed {
+		return ErrSchedulerClosed
+	}
+
+	s.enqueueAll()
+
+	return nil
+}
+
+// SectorSealResults returns an unbuffered channel that is sent a value
+// whenever sealing completes.
+func (s *SealScheduler) SectorSealResults() <-chan SectorSealResult {
+	return s.sectorSealResults
+}
+
+// GetMaxUserBytesPerStagedSector produces the number of user piece-bytes which
+// will fit into a newly-provisioned staged sector.
+func (s *SealScheduler) GetMaxUserBytesPerStagedSector() (uint64, error) {
+	return s.maxBytesPerSector, nil
+}
+
+// GeneratePoST creates a proof-of-spacetime for the replicas managed by the
+// inner SectorBuilder.
+func (s *SealScheduler) GeneratePoST(req GeneratePoSTRequest) (GeneratePoSTResponse, error) {
+	return s.inner.GeneratePoST(req)
+}
+
+// Pause stops the scheduler from starting new seals. Seals which are in
+// progress run to completion.
+func (s *SealScheduler) Pause() {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	s.paused = true
+}
+
+// Resume allows the scheduler to start sealing queued sectors again.
+func (s *SealScheduler) Resume() {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	s.paused = false
+	s.wake()
+}
+
+// Status produces a snapshot of the scheduler's queue and in-flight seals.
+func (s *SealScheduler) Status() SealSchedulerStatus {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	queue := make(sealQueue, len(s.queue))
+	copy(queue, s.queue)
+	sort.Slice(queue, queue.Less)
+
+	status := SealSchedulerStatus{
+		Paused:             s.paused,
+		MaxConcurrentSeals: s.cfg.MaxConcurrentSeals,
+		Queued:             make([]uint64, 0, len(queue)),
+		Sealing:            make([]uint64, 0, len(s.sealing)),
+		Staged:             []uint64{},
+	}
+
+	for _, sector := range queue {
+		status.Queued = append(status.Queued, sector.sectorID)
+	}
+	for id := range s.sealing {
+		status.Sealing = append(status.Sealing, id)
+	}
+	for id, sector := range s.staged {
+		if sector.index < 0 {
+			status.Staged = append(status.Staged, id)
+		}
+	}
+
+	sortUint64s(status.Sealing)
+	sortUint64s(status.Staged)
+
+	return status
+}
+
+// Close stops the scheduler and closes the inner SectorBuilder. Sectors which
+// are queued but not yet being sealed remain staged in the inner SectorBuilder.
+func (s *SealScheduler) Close() error {
+	s.lk.Lock()
+	if s.closed {
+		s.lk.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.lk.Unlock()
+
+	close(s.stopCh)
+	s.doneWg.Wait()
+
+	return s.inner.Close()
+}
+
+// enqueue adds a staged sector to the seal queue if it is not already queued.
+// Callers must hold s.lk.
+func (s *SealScheduler) enqueue(sector *stagedSector) {
+	if sector.index >= 0 {
+		return
+	}
+	heap.Push(&s.queue, sector)
+	s.wake()
+}
+
+// enqueueAll adds every staged sector to the seal queue. Callers must hold
+// s.lk.
+func (s *SealScheduler) enqueueAll() {
+	for _, sector := range s.staged {
+		if sector.numBytes > 0 {
+			s.enqueue(sector)
+		}
+	}
+}
+
+// wake signals the dispatch loop without blocking.
+func (s *SealScheduler) wake() {
+	select {
+	case s.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// dispatchLoop starts sealing queued sectors whenever there is capacity and
+// periodically queues all staged sectors if auto-seal is configured.
+func (s *SealScheduler) dispatchLoop() {
+	defer s.doneWg.Done()
+
+	var autoSealCh <-chan time.Time
+	if s.cfg.AutoSealInterval > 0 {
+		ticker := time.NewTicker(s.cfg.AutoSealInterval)
+		defer ticker.Stop()
+		autoSealCh = ticker.C
+	}
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-autoSealCh:
+			log.Info("auto-seal has been triggered")
+			s.lk.Lock()
+			s.enqueueAll()
+			s.lk.Unlock()
+		case <-s.wakeCh:
+			s.dispatch()
+		}
+	}
+}
+
+// dispatch starts sealing as many queued sectors as the concurrency limit
+// allows. s.lk is not held while the inner SectorBuilder starts a seal, which
+// may take a while.
+func (s *SealScheduler) dispatch() {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	if s.sealer == nil {
+		s.dispatchAll()
+		return
+	}
+
+	for !s.paused && !s.closed && len(s.queue) > 0 && len(s.sealing) < s.cfg.MaxConcurrentSeals {
+		sector := heap.Pop(&s.queue).(*stagedSector)
+
+		// The sector counts against the limit, and takes no more pieces,
+		// while the seal is started.
+		delete(s.staged, sector.sectorID)
+		s.sealing[sector.sectorID] = struct{}{}
+
+		log.Debugf("sealing sector %d (deadline %d)", sector.sectorID, sector.deadline)
+		s.lk.Unlock()
+		err := s.sealer.SealSector(context.Background(), sector.sectorID)
+		s.lk.Lock()
+
+		if err != nil {
+			log.Errorf("failed to seal sector %d, retrying in %s: %s", sector.sectorID, s.cfg.RetryDelay, err)
+			delete(s.sealing, sector.sectorID)
+			s.staged[sector.sectorID] = sector
+			s.retryLater(sector)
+		}
+	}
+}
+
+// dispatchAll seals every non-empty staged sector with the inner
+// SectorBuilder's SealAllStagedSectors, if a sector is queued and no seal is
+// in progress. Callers must hold s.lk.
+func (s *SealScheduler) dispatchAll() {
+	if s.paused || s.closed || len(s.queue) == 0 || len(s.sealing) > 0 {
+		return
+	}
+
+	var sectors []*stagedSector
+	for id, sector := range s.staged {
+		if sector.numBytes == 0 {
+			continue
+		}
+		delete(s.staged, id)
+		s.sealing[id] = struct{}{}
+		sectors = append(sectors, sector)
+	}
+	s.queue = s.queue[:0]
+	for _, sector := range sectors {
+		sector.index = -1
+	}
+
+	log.Debugf("sealing all %d staged sectors", len(sectors))
+	s.lk.Unlock()
+	err := s.inner.SealAllStagedSectors(context.Background())
+	s.lk.Lock()
+
+	if err != nil {
+		log.Errorf("failed to seal staged sectors, retrying in %s: %s", s.cfg.RetryDelay, err)
+		for _, sector := range sectors {
+			delete(s.sealing, sector.sectorID)
+			s.staged[sector.sectorID] = sector
+			s.retryLater(sector)
+		}
+	}
+}
+
+// retryLater queues a staged sector again once the retry delay has passed.
+// Callers must hold s.lk.
+func (s *SealScheduler) retryLater(sector *stagedSector) {
+	time.AfterFunc(s.cfg.RetryDelay, func() {
+		s.lk.Lock()
+		defer s.lk.Unlock()
+
+		if s.closed || s.staged[sector.sectorID] != sector {
+			return
+		}
+		s.enqueue(sector)
+	})
+}
+
+// resultsLoop forwards seal results from the inner SectorBuilder, releasing
+// the capacity held by each completed seal.
+func (s *SealScheduler) resultsLoop() {
+	defer s.doneWg.Done()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case result := <-s.inner.SectorSealResults():
+			s.lk.Lock()
+			delete(s.sealing, result.SectorID)
+			delete(s.staged, result.SectorID)
+			s.wake()
+			s.lk.Unlock()
+
+			select {
+			case s.sectorSealResults <- result:
+			case <-s.stopCh:
+				return
+			}
+		}
+	}
+}
+
+func sortUint64s(ids []uint64) {
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+}
@@ -0,0 +1,293 @@
+package sectorbuilder
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+
+	"github.com/filecoin-project/go-filecoin/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// schedulerTestBuilder is a SectorBuilder which puts every piece into its own
+// sector and only completes a seal when told to by the test.
+type schedulerTestBuilder struct {
+	lk           sync.Mutex
+	lastSectorID uint64
+	sealCalls    []uint64
+	results      chan SectorSealResult
+	// staged are the sectors staged before the scheduler is created.
+	staged []StagedSectorInfo
+	// sealStarted, if set, blocks SealSector until it is closed.
+	sealStarted chan struct{}
+	// failures is the number of seals which fail to start.
+	failures     int
+	sealAllCalls int
+}
+
+var _ SectorSealer = &schedulerTestBuilder{}
+
+func newSchedulerTestBuilder() *schedulerTestBuilder {
+	return &schedulerTestBuilder{results: make(chan SectorSealResult)}
+}
+
+func (b *schedulerTestBuilder) AddPiece(ctx context.Context, pi *PieceInfo) (uint64, error) {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	b.lastSectorID++
+	return b.lastSectorID, nil
+}
+
+func (b *schedulerTestBuilder) SealSector(ctx context.Context, sectorID uint64) error {
+	b.lk.Lock()
+	b.sealCalls = append(b.sealCalls, sectorID)
+	if b.failures > 0 {
+		b.failures--
+		b.lk.Unlock()
+		return errors.New("failed to start seal")
+	}
+	b.lk.Unlock()
+	if b.sealStarted != nil {
+		<-b.sealStarted
+	}
+	return nil
+}
+
+func (b *schedulerTestBuilder) StagedSectors() ([]StagedSectorInfo, error) {
+	return b.staged, nil
+}
+
+func (b *schedulerTestBuilder) sealed() []uint64 {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	return append([]uint64{}, b.sealCalls...)
+}
+
+func (b *schedulerTestBuilder) sealedAll() int {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	return b.sealAllCalls
+}
+
+func (b *schedulerTestBuilder) finish(sectorID uint64) {
+	b.results <- SectorSealResult{SectorID: sectorID, SealingResult: &SealedSectorMetadata{SectorID: sectorID}}
+}
+
+func (b *schedulerTestBuilder) ReadPieceFromSealedSector(pieceCid cid.Cid) (io.Reader, error) {
+	return nil, nil
+}
+func (b *schedulerTestBuilder) SealAllStagedSectors(ctx context.Context) error {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	b.sealAllCalls++
+	return nil
+}
+func (b *schedulerTestBuilder) SectorSealResults() <-chan SectorSealResult { return b.results }
+func (b *schedulerTestBuilder) GetMaxUserBytesPerStagedSector() (uint64, error) {
+	return 100, nil
+}
+func (b *schedulerTestBuilder) GeneratePoST(GeneratePoSTRequest) (GeneratePoSTResponse, error) {
+	return GeneratePoSTResponse{}, nil
+}
+func (b *schedulerTestBuilder) Close() error { return nil }
+
+func TestSealScheduler(t *testing.T) {
+	ctx := context.Background()
+	newCid := types.NewCidForTestGetter()
+
+	requireSealCalls := func(t *testing.T, b *schedulerTestBuilder, expected []uint64) {
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			if assert.ObjectsAreEqual(expected, b.sealed()) {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		require.Equal(t, expected, b.sealed())
+	}
+
+	requireSoon := func(t *testing.T, cond func() bool) {
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) && !cond() {
+			time.Sleep(10 * time.Millisecond)
+		}
+		require.True(t, cond())
+	}
+
+	t.Run("seals full sectors immediately and respects the concurrency limit", func(t *testing.T) {
+		require := require.New(t)
+
+		inner := newSchedulerTestBuilder()
+		s, err := NewSealScheduler(inner, SealSchedulerConfig{MaxConcurrentSeals: 1})
+		require.NoError(err)
+		defer s.Close() // nolint: errcheck
+
+		_, err = s.AddPiece(ctx, &PieceInfo{Ref: newCid(), Size: 100})
+		require.NoError(err)
+		_, err = s.AddPiece(ctx, &PieceInfo{Ref: newCid(), Size: 100})
+		require.NoError(err)
+
+		requireSealCalls(t, inner, []uint64{1})
+		require.Equal([]uint64{2}, s.Status().Queued)
+
+		go inner.finish(1)
+		result := <-s.SectorSealResults()
+		require.Equal(uint64(1), result.SectorID)
+
+		requireSealCalls(t, inner, []uint64{1, 2})
+	})
+
+	t.Run("seals all staged sectors at once if the sector builder cannot seal single sectors", func(t *testing.T) {
+		require := require.New(t)
+
+		inner := newSchedulerTestBuilder()
+		s, err := NewSealScheduler(struct{ SectorBuilder }{inner}, SealSchedulerConfig{MaxConcurrentSeals: 1})
+		require.NoError(err)
+		defer s.Close() // nolint: errcheck
+
+		_, err = s.AddPiece(ctx, &PieceInfo{Ref: newCid(), Size: 10})
+		require.NoError(err)
+		_, err = s.AddPiece(ctx, &PieceInfo{Ref: newCid(), Size: 100})
+		require.NoError(err)
+
+		requireSoon(t, func() bool { return inner.sealedAll() == 1 })
+		require.Equal([]uint64{1, 2}, s.Status().Sealing)
+		require.Empty(inner.sealed())
+
+		// no other batch is started while the first one is sealing
+		_, err = s.AddPiece(ctx, &PieceInfo{Ref: newCid(), Size: 100})
+		require.NoError(err)
+		require.NoError(s.SealAllStagedSectors(ctx))
+		time.Sleep(50 * time.Millisecond)
+		require.Equal(1, inner.sealedAll())
+
+		go inner.finish(1)
+		<-s.SectorSealResults()
+		go inner.finish(2)
+		<-s.SectorSealResults()
+
+		requireSoon(t, func() bool { return inner.sealedAll() == 2 })
+		require.Equal([]uint64{3}, s.Status().Sealing)
+	})
+
+	t.Run("retries a seal which failed to start after a delay", func(t *testing.T) {
+		require := require.New(t)
+
+		inner := newSchedulerTestBuilder()
+		inner.failures = 1
+		s, err := NewSealScheduler(inner, SealSchedulerConfig{MaxConcurrentSeals: 1, RetryDelay: 100 * time.Millisecond})
+		require.NoError(err)
+		defer s.Close() // nolint: errcheck
+
+		_, err = s.AddPiece(ctx, &PieceInfo{Ref: newCid(), Size: 100})
+		require.NoError(err)
+
+		requireSealCalls(t, inner, []uint64{1})
+		requireSoon(t, func() bool { return len(s.Status().Staged) == 1 })
+
+		requireSealCalls(t, inner, []uint64{1, 1})
+		require.Equal([]uint64{1}, s.Status().Sealing)
+	})
+
+	t.Run("seals sectors staged before a restart", func(t *testing.T) {
+		require := require.New(t)
+
+		inner := newSchedulerTestBuilder()
+		inner.staged = []StagedSectorInfo{{SectorID: 7, NumBytes: 10}, {SectorID: 8}}
+		s, err := NewSealScheduler(inner, SealSchedulerConfig{MaxConcurrentSeals: 2})
+		require.NoError(err)
+		defer s.Close() // nolint: errcheck
+
+		requireSealCalls(t, inner, []uint64{7})
+		require.Equal([]uint64{8}, s.Status().Staged)
+	})
+
+	t.Run("is not locked while a seal is started", func(t *testing.T) {
+		require := require.New(t)
+
+		inner := newSchedulerTestBuilder()
+		inner.sealStarted = make(chan struct{})
+		s, err := NewSealScheduler(inner, SealSchedulerConfig{MaxConcurrentSeals: 1})
+		require.NoError(err)
+		defer s.Close() // nolint: errcheck
+
+		_, err = s.AddPiece(ctx, &PieceInfo{Ref: newCid(), Size: 100})
+		require.NoError(err)
+		requireSealCalls(t, inner, []uint64{1})
+
+		require.Equal([]uint64{1}, s.Status().Sealing)
+		_, err = s.AddPiece(ctx, &PieceInfo{Ref: newCid(), Size: 100})
+		require.NoError(err)
+		require.Equal([]uint64{2}, s.Status().Queued)
+
+		close(inner.sealStarted)
+	})
+
+	t.Run("partially filled sectors wait for SealAllStagedSectors", func(t *testing.T) {
+		require := require.New(t)
+
+		inner := newSchedulerTestBuilder()
+		s, err := NewSealScheduler(inner, SealSchedulerConfig{MaxConcurrentSeals: 2})
+		require.NoError(err)
+		defer s.Close() // nolint: errcheck
+
+		_, err = s.AddPiece(ctx, &PieceInfo{Ref: newCid(), Size: 10})
+		require.NoError(err)
+		require.Equal([]uint64{1}, s.Status().Staged)
+		require.Empty(inner.sealed())
+
+		require.NoError(s.SealAllStagedSectors(ctx))
+		requireSealCalls(t, inner, []uint64{1})
+	})
+
+	t.Run("queue is ordered by deal start deadline", func(t *testing.T) {
+		require := require.New(t)
+
+		inner := newSchedulerTestBuilder()
+		s, err := NewSealScheduler(inner, SealSchedulerConfig{MaxConcurrentSeals: 1})
+		require.NoError(err)
+		defer s.Close() // nolint: errcheck
+
+		s.Pause()
+
+		_, err = s.AddPieceWithDeadline(ctx, &PieceInfo{Ref: newCid(), Size: 10}, 300)
+		require.NoError(err)
+		_, err = s.AddPiece(ctx, &PieceInfo{Ref: newCid(), Size: 10})
+		require.NoError(err)
+		_, err = s.AddPieceWithDeadline(ctx, &PieceInfo{Ref: newCid(), Size: 10}, 100)
+		require.NoError(err)
+
+		require.NoError(s.SealAllStagedSectors(ctx))
+		require.Equal([]uint64{3, 1, 2}, s.Status().Queued)
+	})
+
+	t.Run("pause stops new seals and resume restarts them", func(t *testing.T) {
+		require := require.New(t)
+
+		inner := newSchedulerTestBuilder()
+		s, err := NewSealScheduler(inner, SealSchedulerConfig{MaxConcurrentSeals: 1})
+		require.NoError(err)
+		defer s.Close() // nolint: errcheck
+
+		s.Pause()
+		require.True(s.Status().Paused)
+
+		_, err = s.AddPiece(ctx, &PieceInfo{Ref: newCid(), Size: 100})
+		require.NoError(err)
+
+		time.Sleep(50 * time.Millisecond)
+		require.Empty(inner.sealed())
+		require.Equal([]uint64{1}, s.Status().Queued)
+
+		s.Resume()
+		requireSealCalls(t, inner, []uint64{1})
+		require.Equal([]uint64{1}, s.Status().Sealing)
+	})
+}
@@ -38,3 +38,23 @@ func IsPoStValidWithVerifier(verifier Verifier, commRs []CommR, challengeSeed Po
 	}
 	return true, nil
 }
+
+// VerifySeals verifies each of the provided seal proofs, in a single batch if
+// the verifier is a SealBatchVerifier and one at a time otherwise. Responses
+// are returned in the order of their requests.
+func VerifySeals(verifier Verifier, reqs []VerifySealRequest) ([]VerifySealResponse, error) {
+	if bv, ok := verifier.(SealBatchVerifier); ok {
+		return bv.VerifySeals(reqs)
+	}
+
+	responses := make([]VerifySealResponse, len(reqs))
+	for i, req := range reqs {
+		res, err := verifier.VerifySeal(req)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to verify seal %d of %d", i+1, len(reqs))
+		}
+		responses[i] = res
+	}
+
+	return responses, nil
+}
@@ -0,0 +1,202 @@
+package blocksync
+
+import (
+	"context"
+	"time"
+
+	net "gx/ipfs/QmNgLg1NTw37iWbYPKcyK85YJ9Whs1MkPtJwhfqbNYAyKg/go-libp2p-net"
+	cid "gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	cbor "gx/ipfs/QmRoARq3nkUb13HSKZGepCZSWe5GrVPwx7xURJGZ7KWv9V/go-ipld-cbor"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+	host "gx/ipfs/QmaoXrM4Z41PD48JY36YqQGKQpLGjyLA2cKcLsES7YddAq/go-libp2p-host"
+	logging "gx/ipfs/QmcuXC5cxs79ro2cUuHs4HQ2bkDLJUYokwL8aivcX6HW3C/go-log"
+
+	cbu "github.com/filecoin-project/go-filecoin/cborutil"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func init() {
+	cbor.RegisterCborType(Request{})
+	cbor.RegisterCborType(Response{})
+	cbor.RegisterCborType(TipSetBundle{})
+}
+
+// protocol is the libp2p protocol identifier for the block sync protocol.
+const protocol = "/fil/sync/blk/1.0.0"
+
+var log = logging.Logger("/fil/sync/blk")
+
+// MaxRequestLength is the maximum number of tipsets served in response to a
+// single request.
+const MaxRequestLength = 100
+
+// maxResponseSize bounds the encoded size of the blocks of a response, so
+// that it stays below the size limit of the message reader.
+const maxResponseSize = cbu.MaxMessageSize * 3 / 4
+
+// requestTimeout bounds the time spent serving or making a request.
+const requestTimeout = 30 * time.Second
+
+// Request options, which are combined in Request.Options.
+const (
+	// IncludeMessages requests the messages and receipts of the blocks.
+	IncludeMessages = 1 << iota
+)
+
+// Response status codes.
+const (
+	// StatusOK means the response holds all requested tipsets, or all of
+	// them back to genesis.
+	StatusOK = uint64(iota)
+	// StatusPartial means the response holds fewer tipsets than requested,
+	// because of the size limit or because some were not found.
+	StatusPartial
+	// StatusNotFound means the requested tipset was not found.
+	StatusNotFound
+	// StatusBadRequest means the request was malformed.
+	StatusBadRequest
+)
+
+// Request asks for Length tipsets going back from, and including, the
+// tipset with the block cids Start.
+type Request struct {
+	Start   []cid.Cid
+	Length  uint64
+	Options uint64
+}
+
+// TipSetBundle is a tipset of a response. Its blocks are sent without their
+// messages and receipts, which, if they were requested, are sent per block
+// in Messages and Receipts.
+type TipSetBundle struct {
+	Blocks   []*types.Block
+	Messages [][]*types.SignedMessage
+	Receipts [][]*types.MessageReceipt
+}
+
+// Response holds the tipsets served for a request, from the requested one
+// back towards genesis.
+type Response struct {
+	Status  uint64
+	Message string
+	TipSets []*TipSetBundle
+}
+
+// chainReader is the subset of the chain store tipsets are served from.
+type chainReader interface {
+	GetBlock(ctx context.Context, id cid.Cid) (*types.Block, error)
+}
+
+// Handler serves the tipsets of the chain store to peers over the block sync
+// protocol.
+type Handler struct {
+	chain chainReader
+}
+
+// New creates a new instance of the block sync protocol and registers it to
+// the given host.
+func New(h host.Host, chain chainReader) *Handler {
+	handler := &Handler{chain: chain}
+	h.SetStreamHandler(protocol, handler.handleNewStream)
+	return handler
+}
+
+func (h *Handler) handleNewStream(s net.Stream) {
+	defer s.Close() // nolint: errcheck
+
+	from := s.Conn().RemotePeer()
+
+	var req Request
+	if err := cbu.NewMsgReader(s).ReadMsg(&req); err != nil {
+		log.Warningf("bad block sync request from peer %s: %s", from, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	resp := h.processRequest(ctx, &req)
+	if err := cbu.NewMsgWriter(s).WriteMsg(resp); err != nil {
+		log.Warningf("failed to send block sync response to peer %s: %s", from, err)
+	}
+}
+
+func (h *Handler) processRequest(ctx context.Context, req *Request) *Response {
+	if len(req.Start) == 0 || req.Length == 0 {
+		return &Response{Status: StatusBadRequest, Message: "empty request"}
+	}
+	length := req.Length
+	if length > MaxRequestLength {
+		length = MaxRequestLength
+	}
+
+	resp := &Response{Status: StatusOK}
+	cids := req.Start
+	size := 0
+	for uint64(len(resp.TipSets)) < length {
+		var blks []*types.Block
+		for _, c := range cids {
+			blk, err := h.chain.GetBlock(ctx, c)
+			if err != nil {
+				if len(resp.TipSets) == 0 {
+					return &Response{Status: StatusNotFound, Message: err.Error()}
+				}
+				resp.Status = StatusPartial
+				return resp
+			}
+			blks = append(blks, blk)
+		}
+
+		bundle, bundleSize := newTipSetBundle(blks, req.Options&IncludeMessages != 0)
+		if size+bundleSize > maxResponseSize && len(resp.TipSets) > 0 {
+			resp.Status = StatusPartial
+			return resp
+		}
+		size += bundleSize
+		resp.TipSets = append(resp.TipSets, bundle)
+
+		if blks[0].Parents.Empty() {
+			break
+		}
+		cids = blks[0].Parents.ToSlice()
+	}
+	return resp
+}
+
+// newTipSetBundle bundles blks for a response and returns the bundle and the
+// encoded size of the blocks.
+func newTipSetBundle(blks []*types.Block, withMessages bool) (*TipSetBundle, int) {
+	bundle := &TipSetBundle{}
+	size := 0
+	for _, blk := range blks {
+		size += len(blk.ToNode().RawData())
+
+		header := *blk
+		header.Messages = nil
+		header.MessageReceipts = nil
+		bundle.Blocks = append(bundle.Blocks, &header)
+		if withMessages {
+			bundle.Messages = append(bundle.Messages, blk.Messages)
+			bundle.Receipts = append(bundle.Receipts, blk.MessageReceipts)
+		}
+	}
+	return bundle, size
+}
+
+// tipSet reassembles the tipset of the bundle. Blocks are only complete,
+// and their cids only match those of the chain, if withMessages is true.
+func (bundle *TipSetBundle) tipSet(withMessages bool) (types.TipSet, error) {
+	if withMessages && (len(bundle.Messages) != len(bundle.Blocks) || len(bundle.Receipts) != len(bundle.Blocks)) {
+		return nil, errors.New("messages or receipts are missing from tipset")
+	}
+
+	var blks []*types.Block
+	for i, blk := range bundle.Blocks {
+		if withMessages {
+			blk.Messages = bundle.Messages[i]
+			blk.MessageReceipts = bundle.Receipts[i]
+		}
+		blks = append(blks, blk)
+	}
+	return types.NewTipSet(blks...)
+}
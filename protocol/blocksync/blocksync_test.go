@@ -0,0 +1,144 @@
+package blocksync
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	"gx/ipfs/QmYxivS34F2M2n44WQQnRHGAKS8aoRUxwGpi9wk4Cdn4Jf/go-libp2p/p2p/net/mock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// testChain serves the blocks of a chain built by newTestChain.
+type testChain struct {
+	blocks map[cid.Cid]*types.Block
+}
+
+func (c *testChain) GetBlock(ctx context.Context, id cid.Cid) (*types.Block, error) {
+	blk, ok := c.blocks[id]
+	if !ok {
+		return nil, errors.New("block not found")
+	}
+	return blk, nil
+}
+
+// newTestChain builds a chain of length tipsets of two blocks each, on top
+// of a genesis block, with a message in every block, and returns it from
+// the head down.
+func newTestChain(require *require.Assertions, length int) (*testChain, []types.TipSet) {
+	mockSigner := types.NewMockSigner(types.MustGenerateKeyInfo(1, types.GenerateKeyInfoSeed()))
+	chain := &testChain{blocks: make(map[cid.Cid]*types.Block)}
+
+	genesis := &types.Block{}
+	chain.blocks[genesis.Cid()] = genesis
+	tss := []types.TipSet{th.RequireNewTipSet(require, genesis)}
+	for h := 1; h <= length; h++ {
+		parents := tss[0].ToSortedCidSet()
+		var blks []*types.Block
+		for nonce := 0; nonce < 2; nonce++ {
+			blk := &types.Block{
+				Height:          types.Uint64(h),
+				Nonce:           types.Uint64(nonce),
+				Parents:         parents,
+				Messages:        types.NewSignedMsgs(1, mockSigner),
+				MessageReceipts: []*types.MessageReceipt{{ExitCode: 0}},
+			}
+			chain.blocks[blk.Cid()] = blk
+			blks = append(blks, blk)
+		}
+		tss = append([]types.TipSet{th.RequireNewTipSet(require, blks...)}, tss...)
+	}
+	return chain, tss
+}
+
+func TestGetTipSets(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn, err := mocknet.FullMeshConnected(ctx, 2)
+	require.NoError(err)
+	server, client := mn.Hosts()[0], mn.Hosts()[1]
+
+	chain, tss := newTestChain(require, 5)
+	New(server, chain)
+	c := NewClient(client)
+
+	t.Log("tipsets are returned from the requested one back, with their messages")
+	got, err := c.GetTipSets(ctx, server.ID(), tss[1].ToSortedCidSet(), 3, true)
+	require.NoError(err)
+	require.Len(got, 3)
+	for i, ts := range got {
+		assert.Equal(tss[i+1].String(), ts.String())
+		for _, blk := range ts {
+			assert.Len(blk.Messages, 1)
+		}
+	}
+
+	t.Log("the response ends at genesis")
+	got, err = c.GetTipSets(ctx, server.ID(), tss[3].ToSortedCidSet(), 10, true)
+	require.NoError(err)
+	assert.Len(got, 3)
+
+	t.Log("without messages only block headers are returned")
+	got, err = c.GetTipSets(ctx, server.ID(), tss[0].ToSortedCidSet(), 2, false)
+	require.NoError(err)
+	require.Len(got, 2)
+	for _, blk := range got[0] {
+		assert.Empty(blk.Messages)
+		assert.Empty(blk.MessageReceipts)
+	}
+
+	t.Log("unknown tipsets are not found")
+	unknown := types.NewSortedCidSet(types.SomeCid())
+	_, err = c.GetTipSets(ctx, server.ID(), unknown, 2, true)
+	assert.Error(err)
+}
+
+func TestFetcher(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn, err := mocknet.FullMeshConnected(ctx, 3)
+	require.NoError(err)
+	server, empty, client := mn.Hosts()[0], mn.Hosts()[1], mn.Hosts()[2]
+
+	chain, tss := newTestChain(require, 5)
+	New(server, chain)
+	New(empty, &testChain{blocks: make(map[cid.Cid]*types.Block)})
+
+	f := NewFetcher(client)
+	_, err = f.FetchTipSets(ctx, tss[0].ToSortedCidSet(), 2)
+	assert.Equal(ErrNoPeers, err)
+	assert.Equal(uint64(0), f.BestHeight())
+
+	t.Log("peers without the tipset are skipped")
+	f.AddPeer(empty.ID(), 10)
+	f.AddPeer(server.ID(), 5)
+	assert.Equal(uint64(10), f.BestHeight())
+	got, err := f.FetchTipSets(ctx, tss[0].ToSortedCidSet(), 2)
+	require.NoError(err)
+	require.Len(got, 2)
+	assert.Equal(tss[0].String(), got[0].String())
+	assert.Equal(tss[1].String(), got[1].String())
+
+	t.Log("disconnected peers are forgotten")
+	require.NoError(mn.DisconnectPeers(client.ID(), server.ID()))
+	require.NoError(th.WaitForIt(10, 50*time.Millisecond, func() (bool, error) {
+		return len(f.peers()) == 1, nil
+	}))
+}
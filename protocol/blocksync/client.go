@@ -0,0 +1,188 @@
+package blocksync
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	ma "gx/ipfs/QmNTCey11oxhb1AxDnQBRHtdhap6Ctud872NjAYPYYXPuc/go-multiaddr"
+	net "gx/ipfs/QmNgLg1NTw37iWbYPKcyK85YJ9Whs1MkPtJwhfqbNYAyKg/go-libp2p-net"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+	peer "gx/ipfs/QmY5Grm8pJdiSSVsYxx4uNRgweY72EmYwuSDbRnbFok3iY/go-libp2p-peer"
+	host "gx/ipfs/QmaoXrM4Z41PD48JY36YqQGKQpLGjyLA2cKcLsES7YddAq/go-libp2p-host"
+
+	cbu "github.com/filecoin-project/go-filecoin/cborutil"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// maxPeerAttempts is the number of peers a Fetcher asks for tipsets before
+// giving up.
+const maxPeerAttempts = 3
+
+// ErrNoPeers is returned by a Fetcher which knows no peers to fetch from.
+var ErrNoPeers = errors.New("no peers to fetch tipsets from")
+
+// Client requests tipsets from peers over the block sync protocol.
+type Client struct {
+	host host.Host
+}
+
+// NewClient returns a Client making requests from the given host.
+func NewClient(h host.Host) *Client {
+	return &Client{host: h}
+}
+
+// GetTipSets requests length tipsets from peer p, going back from the tipset
+// with the key start, and returns those it received, which may be fewer.
+// With messages, the tipsets are checked to be the requested one and its
+// ancestors. Without, their blocks lack messages and receipts, so that
+// their cids differ from those of the chain and can't be checked.
+func (c *Client) GetTipSets(ctx context.Context, p peer.ID, start types.SortedCidSet, length uint64, withMessages bool) ([]types.TipSet, error) {
+	s, err := c.host.NewStream(ctx, p, protocol)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close() // nolint: errcheck
+
+	req := &Request{
+		Start:  start.ToSlice(),
+		Length: length,
+	}
+	if withMessages {
+		req.Options |= IncludeMessages
+	}
+	if err := cbu.NewMsgWriter(s).WriteMsg(req); err != nil {
+		return nil, errors.Wrap(err, "failed to send block sync request")
+	}
+
+	var resp Response
+	if err := cbu.NewMsgReader(s).ReadMsg(&resp); err != nil {
+		return nil, errors.Wrap(err, "failed to read block sync response")
+	}
+	if resp.Status != StatusOK && resp.Status != StatusPartial {
+		return nil, errors.Errorf("block sync request failed with status %d: %s", resp.Status, resp.Message)
+	}
+
+	var tss []types.TipSet
+	next := start
+	for _, bundle := range resp.TipSets {
+		ts, err := bundle.tipSet(withMessages)
+		if err != nil {
+			return nil, errors.Wrap(err, "bad tipset in block sync response")
+		}
+		if withMessages && !ts.ToSortedCidSet().Equals(next) {
+			return nil, errors.Errorf("block sync response has tipset %s, expected %s", ts.String(), next.String())
+		}
+		if next, err = ts.Parents(); err != nil {
+			return nil, err
+		}
+		tss = append(tss, ts)
+	}
+	return tss, nil
+}
+
+// Fetcher fetches tipsets over the block sync protocol from the peers whose
+// heads the node learned of through the hello handshake, trying the peers
+// with the highest heads first.
+type Fetcher struct {
+	client *Client
+
+	mu      sync.Mutex
+	heights map[peer.ID]uint64
+}
+
+// NewFetcher returns a Fetcher fetching tipsets from the peers of the given
+// host. Peers are forgotten when they disconnect.
+func NewFetcher(h host.Host) *Fetcher {
+	f := &Fetcher{
+		client:  NewClient(h),
+		heights: make(map[peer.ID]uint64),
+	}
+	h.Network().Notify((*fetcherNotify)(f))
+	return f
+}
+
+// AddPeer records that the head of peer p is at the given height.
+func (f *Fetcher) AddPeer(p peer.ID, height uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.heights[p] = height
+}
+
+// BestHeight returns the height of the highest head reported by the known
+// peers, zero if there are none.
+func (f *Fetcher) BestHeight() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var best uint64
+	for _, h := range f.heights {
+		if h > best {
+			best = h
+		}
+	}
+	return best
+}
+
+func (f *Fetcher) removePeer(p peer.ID) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.heights, p)
+}
+
+// peers returns the known peers, highest head first.
+func (f *Fetcher) peers() []peer.ID {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var peers []peer.ID
+	for p := range f.heights {
+		peers = append(peers, p)
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return f.heights[peers[i]] > f.heights[peers[j]]
+	})
+	return peers
+}
+
+// FetchTipSets fetches up to count tipsets, going back from the tipset with
+// the key tsKey, from the first of the known peers which serves them.
+func (f *Fetcher) FetchTipSets(ctx context.Context, tsKey types.SortedCidSet, count int) ([]types.TipSet, error) {
+	peers := f.peers()
+	if len(peers) == 0 {
+		return nil, ErrNoPeers
+	}
+	if len(peers) > maxPeerAttempts {
+		peers = peers[:maxPeerAttempts]
+	}
+
+	var err error
+	for _, p := range peers {
+		var tss []types.TipSet
+		rctx, cancel := context.WithTimeout(ctx, requestTimeout)
+		tss, err = f.client.GetTipSets(rctx, p, tsKey, uint64(count), true)
+		cancel()
+		if err == nil && len(tss) > 0 {
+			return tss, nil
+		}
+		if err == nil {
+			err = errors.Errorf("peer %s returned no tipsets", p)
+		}
+		log.Debugf("failed to fetch tipsets %s from peer %s: %s", tsKey.String(), p, err)
+	}
+	return nil, err
+}
+
+// Peer disconnection notifications
+
+type fetcherNotify Fetcher
+
+func (fn *fetcherNotify) Disconnected(n net.Network, c net.Conn) {
+	(*Fetcher)(fn).removePeer(c.RemotePeer())
+}
+
+func (fn *fetcherNotify) Listen(n net.Network, a ma.Multiaddr)      {}
+func (fn *fetcherNotify) ListenClose(n net.Network, a ma.Multiaddr) {}
+func (fn *fetcherNotify) Connected(n net.Network, c net.Conn)       {}
+func (fn *fetcherNotify) OpenedStream(n net.Network, s net.Stream)  {}
+func (fn *fetcherNotify) ClosedStream(n net.Network, s net.Stream)  {}
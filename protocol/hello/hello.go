@@ -3,26 +3,39 @@ package hello
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	ma "gx/ipfs/QmNTCey11oxhb1AxDnQBRHtdhap6Ctud872NjAYPYYXPuc/go-multiaddr"
 	net "gx/ipfs/QmNgLg1NTw37iWbYPKcyK85YJ9Whs1MkPtJwhfqbNYAyKg/go-libp2p-net"
 	cid "gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
 	cbor "gx/ipfs/QmRoARq3nkUb13HSKZGepCZSWe5GrVPwx7xURJGZ7KWv9V/go-ipld-cbor"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
 	peer "gx/ipfs/QmY5Grm8pJdiSSVsYxx4uNRgweY72EmYwuSDbRnbFok3iY/go-libp2p-peer"
 	host "gx/ipfs/QmaoXrM4Z41PD48JY36YqQGKQpLGjyLA2cKcLsES7YddAq/go-libp2p-host"
 	logging "gx/ipfs/QmcuXC5cxs79ro2cUuHs4HQ2bkDLJUYokwL8aivcX6HW3C/go-log"
 
 	cbu "github.com/filecoin-project/go-filecoin/cborutil"
+	"github.com/filecoin-project/go-filecoin/flags"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
 func init() {
 	cbor.RegisterCborType(Message{})
+	cbor.RegisterCborType(Response{})
 }
 
 // Protocol is the libp2p protocol identifier for the hello protocol.
-const protocol = "/fil/hello/1.0.0"
+const protocol = "/fil/hello/2.0.0"
+
+// protocolV1 is the identifier of the first version of the hello protocol,
+// which peers on the previous network version speak. Their hellos are
+// answered with ErrBadNetworkVersion.
+const protocolV1 = "/fil/hello/1.0.0"
+
+// NetworkVersion is the version of the network protocols and consensus rules
+// this node follows. Peers on another network version are disconnected.
+const NetworkVersion = uint64(1)
 
 var log = logging.Logger("/fil/hello")
 
@@ -30,7 +43,23 @@ var log = logging.Logger("/fil/hello")
 type Message struct {
 	HeaviestTipSetCids   []cid.Cid
 	HeaviestTipSetHeight uint64
+	// HeaviestTipSetWeight is the parent weight of the heaviest tipset,
+	// which tells peers on forks apart from peers on heavier chains.
+	HeaviestTipSetWeight uint64
 	GenesisHash          cid.Cid
+	// NetworkVersion is the network version of the peer, see
+	// NetworkVersion.
+	NetworkVersion uint64
+	// SoftwareVersion is the commit of the software the peer runs.
+	SoftwareVersion string
+	// SupportedProtocols are the libp2p protocols the peer serves.
+	SupportedProtocols []string
+}
+
+// Response is the answer to a hello message. Reason is empty if the message
+// was accepted, otherwise it explains why the connection is closed.
+type Response struct {
+	Reason string
 }
 
 type syncCallback func(from peer.ID, cids []cid.Cid, height uint64)
@@ -52,6 +81,10 @@ type Handler struct {
 	// getHeaviestTipSet is used to retrieve the current heaviest tipset
 	// for filling out our hello messages.
 	getHeaviestTipSet getTipSetFunc
+
+	// peers holds the latest hello message of each connected peer.
+	peersLk sync.Mutex
+	peers   map[peer.ID]*Message
 }
 
 // New creates a new instance of the hello protocol and registers it to
@@ -62,8 +95,10 @@ func New(h host.Host, gen cid.Cid, syncCallback syncCallback, getHeaviestTipSet
 		genesis:           gen,
 		chainSyncCB:       syncCallback,
 		getHeaviestTipSet: getHeaviestTipSet,
+		peers:             make(map[peer.ID]*Message),
 	}
 	h.SetStreamHandler(protocol, hello.handleNewStream)
+	h.SetStreamHandler(protocolV1, hello.handleV1Stream)
 
 	// register for connection notifications
 	h.Network().Notify((*helloNotify)(hello))
@@ -82,41 +117,119 @@ func (h *Handler) handleNewStream(s net.Stream) {
 		return
 	}
 
-	switch err := h.processHelloMessage(from, &hello); err {
+	err := h.checkHelloMessage(&hello)
+	var resp Response
+	if err != nil {
+		resp.Reason = err.Error()
+	}
+	if werr := cbu.NewMsgWriter(s).WriteMsg(&resp); werr != nil {
+		log.Warningf("failed to answer hello message from peer %s: %s", from, werr)
+	}
+
+	switch err {
 	case ErrBadGenesis:
 		log.Warningf("genesis cid: %s does not match: %s, disconnecting from peer: %s", &hello.GenesisHash, h.genesis, from)
-		s.Conn().Close() // nolint: errcheck
+	case ErrBadNetworkVersion:
+		log.Warningf("network version: %d does not match: %d, disconnecting from peer: %s", hello.NetworkVersion, NetworkVersion, from)
+	case nil:
+		h.processHelloMessage(from, &hello)
+		return
+	}
+	// Close the stream first so that the peer reads the reason.
+	s.Close()        // nolint: errcheck
+	s.Conn().Close() // nolint: errcheck
+}
+
+// handleV1Stream answers the hello of a peer speaking the first version of the
+// protocol with the reason it is disconnected. Such peers do not send their
+// network version, and are on the previous one.
+func (h *Handler) handleV1Stream(s net.Stream) {
+	defer s.Close() // nolint: errcheck
+
+	from := s.Conn().RemotePeer()
+
+	var hello Message
+	if err := cbu.NewMsgReader(s).ReadMsg(&hello); err != nil {
+		log.Warningf("bad hello message from peer %s: %s", from, err)
 		return
-	case nil: // ok, noop
-	default:
-		log.Error(err)
 	}
+
+	resp := Response{Reason: ErrBadNetworkVersion.Error()}
+	if err := cbu.NewMsgWriter(s).WriteMsg(&resp); err != nil {
+		log.Warningf("failed to answer hello message from peer %s: %s", from, err)
+	}
+	log.Warningf("peer %s speaks hello protocol %s, disconnecting", from, protocolV1)
+
+	s.Close()        // nolint: errcheck
+	s.Conn().Close() // nolint: errcheck
 }
 
 // ErrBadGenesis is the error returned when a missmatch in genesis blocks happens.
 var ErrBadGenesis = fmt.Errorf("bad genesis block")
 
-func (h *Handler) processHelloMessage(from peer.ID, msg *Message) error {
+// ErrBadNetworkVersion is the error returned when a peer is on another
+// network version.
+var ErrBadNetworkVersion = fmt.Errorf("bad network version")
+
+// checkHelloMessage returns an error if the peer which sent msg is not on
+// our network.
+func (h *Handler) checkHelloMessage(msg *Message) error {
 	if !msg.GenesisHash.Equals(h.genesis) {
 		return ErrBadGenesis
 	}
+	if msg.NetworkVersion != NetworkVersion {
+		return ErrBadNetworkVersion
+	}
+	return nil
+}
+
+// processHelloMessage records the hello message of a peer and syncs its chain,
+// unless it is lighter than ours.
+func (h *Handler) processHelloMessage(from peer.ID, msg *Message) {
+	h.peersLk.Lock()
+	h.peers[from] = msg
+	h.peersLk.Unlock()
+
+	weight, err := h.getHeaviestTipSet().ParentWeight()
+	if err != nil {
+		log.Warningf("failed to get weight of heaviest tipset: %s", err)
+	} else if msg.HeaviestTipSetWeight < weight {
+		log.Debugf("not syncing the chain of peer %s, which is lighter than ours", from)
+		return
+	}
 
 	h.chainSyncCB(from, msg.HeaviestTipSetCids, msg.HeaviestTipSetHeight)
-	return nil
 }
 
-func (h *Handler) getOurHelloMessage() *Message {
+// PeerInfo returns the latest hello message of connected peer p, if it sent
+// one.
+func (h *Handler) PeerInfo(p peer.ID) (*Message, bool) {
+	h.peersLk.Lock()
+	defer h.peersLk.Unlock()
+	msg, ok := h.peers[p]
+	return msg, ok
+}
+
+func (h *Handler) getOurHelloMessage() (*Message, error) {
 	heaviest := h.getHeaviestTipSet()
 	height, err := heaviest.Height()
 	if err != nil {
-		panic("somehow heaviest tipset is empty")
+		return nil, errors.Wrap(err, "failed to get height of heaviest tipset")
+	}
+	weight, err := heaviest.ParentWeight()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get weight of heaviest tipset")
 	}
 
 	return &Message{
 		GenesisHash:          h.genesis,
 		HeaviestTipSetCids:   heaviest.ToSortedCidSet().ToSlice(),
 		HeaviestTipSetHeight: height,
-	}
+		HeaviestTipSetWeight: weight,
+		NetworkVersion:       NetworkVersion,
+		SoftwareVersion:      flags.Commit,
+		SupportedProtocols:   h.host.Mux().Protocols(),
+	}, nil
 }
 
 func (h *Handler) sayHello(ctx context.Context, p peer.ID) error {
@@ -125,10 +238,27 @@ func (h *Handler) sayHello(ctx context.Context, p peer.ID) error {
 		return err
 	}
 	defer s.Close() // nolint: errcheck
+	if deadline, ok := ctx.Deadline(); ok {
+		s.SetDeadline(deadline) // nolint: errcheck
+	}
 
-	msg := h.getOurHelloMessage()
+	msg, err := h.getOurHelloMessage()
+	if err != nil {
+		return err
+	}
+	if err := cbu.NewMsgWriter(s).WriteMsg(&msg); err != nil {
+		return err
+	}
 
-	return cbu.NewMsgWriter(s).WriteMsg(&msg)
+	var resp Response
+	if err := cbu.NewMsgReader(s).ReadMsg(&resp); err != nil {
+		return err
+	}
+	if resp.Reason != "" {
+		log.Warningf("peer %s rejected our hello message: %s, disconnecting", p, resp.Reason)
+		return h.host.Network().ClosePeer(p)
+	}
+	return nil
 }
 
 // New peer connection notifications
@@ -154,6 +284,16 @@ func (hn *helloNotify) Connected(n net.Network, c net.Conn) {
 
 func (hn *helloNotify) Listen(n net.Network, a ma.Multiaddr)      {}
 func (hn *helloNotify) ListenClose(n net.Network, a ma.Multiaddr) {}
-func (hn *helloNotify) Disconnected(n net.Network, c net.Conn)    {}
-func (hn *helloNotify) OpenedStream(n net.Network, s net.Stream)  {}
-func (hn *helloNotify) ClosedStream(n net.Network, s net.Stream)  {}
+func (hn *helloNotify) Disconnected(n net.Network, c net.Conn) {
+	p := c.RemotePeer()
+	if n.Connectedness(p) == net.Connected {
+		return
+	}
+	h := hn.hello()
+	h.peersLk.Lock()
+	delete(h.peers, p)
+	h.peersLk.Unlock()
+}
+
+func (hn *helloNotify) OpenedStream(n net.Network, s net.Stream) {}
+func (hn *helloNotify) ClosedStream(n net.Network, s net.Stream) {}
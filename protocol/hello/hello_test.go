@@ -13,6 +13,7 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
+	cbu "github.com/filecoin-project/go-filecoin/cborutil"
 	th "github.com/filecoin-project/go-filecoin/testhelpers"
 	"github.com/filecoin-project/go-filecoin/types"
 )
@@ -54,7 +55,7 @@ func TestHelloHandshake(t *testing.T) {
 	msc1, msc2 := new(mockSyncCallback), new(mockSyncCallback)
 	hg1, hg2 := &mockHeaviestGetter{heavy1}, &mockHeaviestGetter{heavy2}
 
-	New(a, genesisA.Cid(), msc1.SyncCallback, hg1.getHeaviestTipSet)
+	helloA := New(a, genesisA.Cid(), msc1.SyncCallback, hg1.getHeaviestTipSet)
 	New(b, genesisA.Cid(), msc2.SyncCallback, hg2.getHeaviestTipSet)
 
 	msc1.On("SyncCallback", b.ID(), heavy2.ToSortedCidSet().ToSlice(), uint64(3)).Return()
@@ -85,6 +86,147 @@ func TestHelloHandshake(t *testing.T) {
 
 		return msc1Done && msc2Done, nil
 	}))
+
+	info, ok := helloA.PeerInfo(b.ID())
+	require.True(ok)
+	assert.Equal(t, uint64(3), info.HeaviestTipSetHeight)
+	assert.Equal(t, NetworkVersion, info.NetworkVersion)
+	assert.Contains(t, info.SupportedProtocols, protocol)
+}
+
+func TestHelloBadNetworkVersion(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn, err := mocknet.WithNPeers(ctx, 2)
+	require.NoError(err)
+
+	a := mn.Hosts()[0]
+	b := mn.Hosts()[1]
+
+	genesisA := &types.Block{Nonce: 451}
+	heavy := th.RequireNewTipSet(require, &types.Block{Nonce: 1000, Height: 2})
+
+	msc := new(mockSyncCallback)
+	hg := &mockHeaviestGetter{heavy}
+	helloA := New(a, genesisA.Cid(), msc.SyncCallback, hg.getHeaviestTipSet)
+
+	require.NoError(mn.LinkAll())
+	_, err = mn.ConnectPeers(b.ID(), a.ID())
+	require.NoError(err)
+
+	s, err := b.NewStream(ctx, a.ID(), protocol)
+	require.NoError(err)
+	msg := &Message{
+		GenesisHash:          genesisA.Cid(),
+		HeaviestTipSetCids:   heavy.ToSortedCidSet().ToSlice(),
+		HeaviestTipSetHeight: 2,
+		NetworkVersion:       NetworkVersion + 1,
+	}
+	require.NoError(cbu.NewMsgWriter(s).WriteMsg(msg))
+
+	var resp Response
+	require.NoError(cbu.NewMsgReader(s).ReadMsg(&resp))
+	assert.Equal(ErrBadNetworkVersion.Error(), resp.Reason)
+
+	require.NoError(th.WaitForIt(10, 50*time.Millisecond, func() (bool, error) {
+		return len(a.Network().ConnsToPeer(b.ID())) == 0, nil
+	}))
+	msc.AssertNumberOfCalls(t, "SyncCallback", 0)
+	_, ok := helloA.PeerInfo(b.ID())
+	assert.False(ok)
+}
+
+func TestHelloV1(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn, err := mocknet.WithNPeers(ctx, 2)
+	require.NoError(err)
+
+	a := mn.Hosts()[0]
+	b := mn.Hosts()[1]
+
+	genesisA := &types.Block{Nonce: 451}
+	heavy := th.RequireNewTipSet(require, &types.Block{Nonce: 1000, Height: 2})
+
+	msc := new(mockSyncCallback)
+	hg := &mockHeaviestGetter{heavy}
+	helloA := New(a, genesisA.Cid(), msc.SyncCallback, hg.getHeaviestTipSet)
+
+	require.NoError(mn.LinkAll())
+	_, err = mn.ConnectPeers(b.ID(), a.ID())
+	require.NoError(err)
+
+	s, err := b.NewStream(ctx, a.ID(), protocolV1)
+	require.NoError(err)
+	msg := &Message{
+		GenesisHash:          genesisA.Cid(),
+		HeaviestTipSetCids:   heavy.ToSortedCidSet().ToSlice(),
+		HeaviestTipSetHeight: 2,
+	}
+	require.NoError(cbu.NewMsgWriter(s).WriteMsg(msg))
+
+	var resp Response
+	require.NoError(cbu.NewMsgReader(s).ReadMsg(&resp))
+	assert.Equal(ErrBadNetworkVersion.Error(), resp.Reason)
+
+	require.NoError(th.WaitForIt(10, 50*time.Millisecond, func() (bool, error) {
+		return len(a.Network().ConnsToPeer(b.ID())) == 0, nil
+	}))
+	msc.AssertNumberOfCalls(t, "SyncCallback", 0)
+	_, ok := helloA.PeerInfo(b.ID())
+	assert.False(ok)
+}
+
+func TestHelloLighterPeer(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn, err := mocknet.WithNPeers(ctx, 2)
+	require.NoError(err)
+
+	a := mn.Hosts()[0]
+	b := mn.Hosts()[1]
+
+	genesisA := &types.Block{Nonce: 451}
+
+	heavy1 := th.RequireNewTipSet(require, &types.Block{Nonce: 1000, Height: 2, ParentWeight: 20})
+	heavy2 := th.RequireNewTipSet(require, &types.Block{Nonce: 1001, Height: 3, ParentWeight: 10})
+
+	msc1, msc2 := new(mockSyncCallback), new(mockSyncCallback)
+	hg1, hg2 := &mockHeaviestGetter{heavy1}, &mockHeaviestGetter{heavy2}
+
+	helloA := New(a, genesisA.Cid(), msc1.SyncCallback, hg1.getHeaviestTipSet)
+	New(b, genesisA.Cid(), msc2.SyncCallback, hg2.getHeaviestTipSet)
+
+	msc2.On("SyncCallback", a.ID(), heavy1.ToSortedCidSet().ToSlice(), uint64(2)).Return()
+
+	require.NoError(mn.LinkAll())
+	require.NoError(mn.ConnectAllButSelf())
+
+	require.NoError(th.WaitForIt(10, 50*time.Millisecond, func() (bool, error) {
+		_, ok := helloA.PeerInfo(b.ID())
+		return ok && len(msc2.Calls) > 0, nil
+	}))
+
+	// a records the lighter peer b without syncing its chain.
+	info, ok := helloA.PeerInfo(b.ID())
+	require.True(ok)
+	assert.Equal(t, uint64(10), info.HeaviestTipSetWeight)
+	msc1.AssertNumberOfCalls(t, "SyncCallback", 0)
+	msc2.AssertNumberOfCalls(t, "SyncCallback", 1)
 }
 
 func TestHelloBadGenesis(t *testing.T) {
@@ -86,6 +86,7 @@ type minerPorcelain interface {
 	MessageSend(ctx context.Context, from, to address.Address, value *types.AttoFIL, gasPrice types.AttoFIL, gasLimit types.GasUnits, method string, params ...interface{}) (cid.Cid, error)
 	MessageQuery(ctx context.Context, optFrom, to address.Address, method string, params ...interface{}) ([][]byte, *exec.FunctionSignature, error)
 	MessageWait(ctx context.Context, msgCid cid.Cid, cb func(*types.Block, *types.SignedMessage, *types.MessageReceipt) error) error
+	PaychRecordReceived(ctx context.Context, voucher *paymentbroker.PaymentVoucher) error
 }
 
 // node is subset of node on which this protocol depends. These deps
@@ -99,6 +100,12 @@ type node interface {
 	SectorBuilder() sectorbuilder.SectorBuilder
 }
 
+// sealScheduler is implemented by sector builders which order sealing by deal
+// start deadline.
+type sealScheduler interface {
+	AddPieceWithDeadline(ctx context.Context, pi *sectorbuilder.PieceInfo, deadline uint64) (uint64, error)
+}
+
 // generatePostInput is a struct containing sector id and related commitments
 // used to generate a proof-of-spacetime
 type generatePostInput struct {
@@ -204,11 +211,6 @@ func (sm *Miner) validateDealPayment(ctx context.Context, p *DealProposal) error
 		return fmt.Errorf("miner account (%s) is not target of payment channel (%s)", sm.minerOwnerAddr.String(), channel.Target.String())
 	}
 
-	// confirm channel contains enough funds
-	if channel.Amount.LessThan(expectedPrice) {
-		return fmt.Errorf("payment channel does not contain enough funds (%s < %s)", channel.Amount.String(), expectedPrice.String())
-	}
-
 	// start with current block height
 	blockHeight, err := sm.porcelainAPI.ChainBlockHeight(ctx)
 	if err != nil {
@@ -228,9 +230,21 @@ func (sm *Miner) validateDealPayment(ctx context.Context, p *DealProposal) error
 	}
 
 	lastValidAt := expectedFirstPayment
+	lane := p.Payment.Vouchers[0].Lane
 	for _, v := range p.Payment.Vouchers {
+		// voucher amounts are cumulative within a lane, so the payment must use only one
+		if v.Lane != lane {
+			return errors.New("payment vouchers are in different lanes")
+		}
+
+		// the miner does not ask for conditions, and a payer chosen one could
+		// keep it from redeeming the voucher
+		if v.Condition != nil {
+			return errors.New("payment vouchers must not have a condition")
+		}
+
 		// confirm signature is valid against expected actor and channel id
-		if !paymentbroker.VerifyVoucherSignature(p.Payment.Payer, p.Payment.Channel, &v.Amount, &v.ValidAt, v.Signature) {
+		if !paymentbroker.VerifyVoucherSignature(p.Payment.Payer, p.Payment.Channel, v.Lane, &v.Amount, &v.ValidAt, v.Condition, v.Signature) {
 			return errors.New("invalid signature in voucher")
 		}
 
@@ -258,6 +272,23 @@ func (sm *Miner) validateDealPayment(ctx context.Context, p *DealProposal) error
 		return fmt.Errorf("last payment (%s) does not cover total price (%s)", lastVoucher.Amount.String(), p.TotalPrice.String())
 	}
 
+	// the lane must be new, as its vouchers' amounts would otherwise include
+	// payments for something else
+	if redeemed := channel.RedeemedInLane(lane); !redeemed.IsZero() {
+		return fmt.Errorf("payment lane %d has already been redeemed from", lane)
+	}
+
+	// confirm channel contains enough funds besides those redeemed and owed to
+	// the miner for other deals
+	outstanding, err := sm.outstandingPayments(p, channel, lane)
+	if err != nil {
+		return err
+	}
+	available := channel.Amount.Sub(channel.AmountRedeemed).Sub(outstanding)
+	if available.LessThan(expectedPrice) {
+		return fmt.Errorf("payment channel does not contain enough funds (%s available < %s)", available.String(), expectedPrice.String())
+	}
+
 	// require channel expires at or after last voucher + ChannelExpiryInterval
 	expectedEol := lastVoucher.ValidAt.Add(types.NewBlockHeight(ChannelExpiryInterval))
 	if channel.Eol.LessThan(expectedEol) {
@@ -267,6 +298,43 @@ func (sm *Miner) validateDealPayment(ctx context.Context, p *DealProposal) error
 	return nil
 }
 
+// outstandingPayments returns the funds the miner's other live deals paid
+// through the proposal's channel are owed but have not redeemed. It errors if
+// one of those deals is paid in the given lane.
+func (sm *Miner) outstandingPayments(p *DealProposal, channel *paymentbroker.PaymentChannel, lane uint64) (*types.AttoFIL, error) {
+	proposalCid, err := convert.ToCid(p)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get cid of proposal")
+	}
+
+	sm.dealsLk.Lock()
+	defer sm.dealsLk.Unlock()
+
+	outstanding := types.NewZeroAttoFIL()
+	for dealCid, deal := range sm.deals {
+		if dealCid.Equals(proposalCid) || deal.Response.State == Rejected || deal.Response.State == Failed {
+			continue
+		}
+
+		payment := deal.Proposal.Payment
+		if payment.Payer != p.Payment.Payer || payment.Channel == nil || payment.Channel.KeyString() != p.Payment.Channel.KeyString() || len(payment.Vouchers) == 0 {
+			continue
+		}
+
+		dealLane := payment.Vouchers[0].Lane
+		if dealLane == lane {
+			return nil, fmt.Errorf("payment lane %d is already used by deal %s", lane, dealCid.String())
+		}
+
+		owed := payment.Vouchers[len(payment.Vouchers)-1].Amount.Sub(channel.RedeemedInLane(dealLane))
+		if owed.IsPositive() {
+			outstanding = outstanding.Add(owed)
+		}
+	}
+
+	return outstanding, nil
+}
+
 func (sm *Miner) getStoragePrice() (*types.AttoFIL, error) {
 	storagePrice, err := sm.porcelainAPI.ConfigGet("mining.storagePrice")
 	if err != nil {
@@ -324,6 +392,12 @@ func acceptProposal(ctx context.Context, sm *Miner, p *DealProposal) (*DealRespo
 		return nil, errors.Wrap(err, "failed to get cid of proposal")
 	}
 
+	for _, v := range p.Payment.Vouchers {
+		if err := sm.porcelainAPI.PaychRecordReceived(ctx, v); err != nil {
+			return nil, errors.Wrap(err, "failed to record payment voucher")
+		}
+	}
+
 	resp := &DealResponse{
 		State:       Accepted,
 		ProposalCid: proposalCid,
@@ -449,7 +523,13 @@ func (sm *Miner) processStorageDeal(c cid.Cid) {
 	//
 	// Also, this pattern of not being able to set up book-keeping ahead of
 	// the call is inelegant.
-	sectorID, err := sm.node.SectorBuilder().AddPiece(ctx, pi)
+	var sectorID uint64
+	var err error
+	if scheduler, ok := sm.node.SectorBuilder().(sealScheduler); ok {
+		sectorID, err = scheduler.AddPieceWithDeadline(ctx, pi, dealStartDeadline(d.Proposal))
+	} else {
+		sectorID, err = sm.node.SectorBuilder().AddPiece(ctx, pi)
+	}
 	if err != nil {
 		fail("failed to submit seal proof", fmt.Sprintf("failed to add piece: %s", err))
 		return
@@ -470,6 +550,19 @@ func (sm *Miner) processStorageDeal(c cid.Cid) {
 	}
 }
 
+// dealStartDeadline is the block height by which the piece in a deal should be
+// sealed: the earliest height at which one of the deal's vouchers can be
+// redeemed. Redeeming requires the piece to be provably in a sealed sector.
+func dealStartDeadline(p *DealProposal) uint64 {
+	deadline := sectorbuilder.NoSealDeadline
+	for _, v := range p.Payment.Vouchers {
+		if h := v.ValidAt.AsBigInt(); h.IsUint64() && h.Uint64() < deadline {
+			deadline = h.Uint64()
+		}
+	}
+	return deadline
+}
+
 // dealsAwaitingSealStruct is a container for keeping track of which sectors have
 // pieces from which deals. We need it to accommodate a race condition where
 // a sector commit message is added to chain before we can add the sector/deal
@@ -14,6 +14,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/proofs/sectorbuilder"
 	"github.com/filecoin-project/go-filecoin/repo"
 	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/util/convert"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -138,6 +139,34 @@ func TestReceiveStorageProposal(t *testing.T) {
 		assert.Contains(res.Message, "invalid signature in voucher")
 	})
 
+	t.Run("Rejects proposals with conditional vouchers", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		_, miner, proposal := newMinerTestSetup()
+		proposal.Payment.Vouchers[0].Condition = &paymentbroker.Condition{To: proposal.Payment.Payer, Method: "hasReturnValue"}
+
+		res, err := miner.receiveStorageProposal(context.Background(), proposal)
+		require.NoError(err)
+
+		assert.Equal(Rejected, res.State)
+		assert.Contains(res.Message, "must not have a condition")
+	})
+
+	t.Run("Rejects proposals with vouchers in different lanes", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		_, miner, proposal := newMinerTestSetup()
+		proposal.Payment.Vouchers[3].Lane = 1
+
+		res, err := miner.receiveStorageProposal(context.Background(), proposal)
+		require.NoError(err)
+
+		assert.Equal(Rejected, res.State)
+		assert.Contains(res.Message, "different lanes")
+	})
+
 	t.Run("Rejects proposals with when payments start too late", func(t *testing.T) {
 		assert := assert.New(t)
 		require := require.New(t)
@@ -181,6 +210,73 @@ func TestReceiveStorageProposal(t *testing.T) {
 		assert.Equal(Rejected, res.State)
 		assert.Contains(res.Message, "voucher amount")
 	})
+
+	t.Run("Rejects proposals in lanes which have been redeemed from", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		porcelainAPI, miner, proposal := newMinerTestSetup()
+		porcelainAPI.redeemed = types.NewAttoFILFromFIL(1)
+		porcelainAPI.laneRedeemed = map[string]*types.AttoFIL{"0": types.NewAttoFILFromFIL(1)}
+
+		res, err := miner.receiveStorageProposal(context.Background(), proposal)
+		require.NoError(err)
+
+		assert.Equal(Rejected, res.State)
+		assert.Contains(res.Message, "payment lane 0 has already been redeemed from")
+	})
+
+	t.Run("Rejects proposals in lanes used by other deals", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		porcelainAPI, miner, proposal := newMinerTestSetup()
+		otherDeal := testDealProposal(porcelainAPI, VoucherInterval, 1774, porcelainAPI.targetAddress)
+		otherCid, err := convert.ToCid(otherDeal)
+		require.NoError(err)
+		miner.deals = map[cid.Cid]*storageDeal{
+			otherCid: {Proposal: otherDeal, Response: &DealResponse{State: Staged}},
+		}
+
+		res, err := miner.receiveStorageProposal(context.Background(), proposal)
+		require.NoError(err)
+
+		assert.Equal(Rejected, res.State)
+		assert.Contains(res.Message, "payment lane 0 is already used by deal")
+	})
+
+	t.Run("Rejects proposals the channel cannot pay besides other deals", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		porcelainAPI, miner, proposal := newMinerTestSetup()
+		porcelainAPI.redeemed = types.NewAttoFILFromFIL(80000)
+
+		// the other deal is owed 17730 of the 20000 left in the channel
+		otherDeal := testDealProposal(porcelainAPI, VoucherInterval, 1773, porcelainAPI.targetAddress)
+		otherDeal.Duration = 20000
+		for _, v := range otherDeal.Payment.Vouchers {
+			v.Lane = 1
+		}
+		otherCid, err := convert.ToCid(otherDeal)
+		require.NoError(err)
+		miner.deals = map[cid.Cid]*storageDeal{
+			otherCid: {Proposal: otherDeal, Response: &DealResponse{State: Accepted}},
+		}
+
+		res, err := miner.receiveStorageProposal(context.Background(), proposal)
+		require.NoError(err)
+
+		assert.Equal(Rejected, res.State)
+		assert.Contains(res.Message, "payment channel does not contain enough funds (2270 available < 2500)")
+
+		// once the other deal has been paid, the proposal is covered
+		porcelainAPI.laneRedeemed = map[string]*types.AttoFIL{"1": types.NewAttoFILFromFIL(17730)}
+
+		res, err = miner.receiveStorageProposal(context.Background(), proposal)
+		require.NoError(err)
+		assert.Equal(Accepted, res.State)
+	})
 }
 
 func TestDealsAwaitingSeal(t *testing.T) {
@@ -322,6 +418,9 @@ type minerTestPorcelain struct {
 	blockHeight   *types.BlockHeight
 	channelEol    *types.BlockHeight
 	paymentStart  *types.BlockHeight
+	// redeemed and laneRedeemed are the funds redeemed from the channel.
+	redeemed     *types.AttoFIL
+	laneRedeemed map[string]*types.AttoFIL
 }
 
 func newMinerTestPorcelain() *minerTestPorcelain {
@@ -352,6 +451,7 @@ func newMinerTestPorcelain() *minerTestPorcelain {
 		channelEol:    types.NewBlockHeight(13773),
 		blockHeight:   blockHeight,
 		paymentStart:  blockHeight,
+		redeemed:      types.NewAttoFILFromFIL(0),
 	}
 }
 
@@ -367,8 +467,9 @@ func (mtp *minerTestPorcelain) MessageQuery(ctx context.Context, optFrom, to add
 		channels[id] = &paymentbroker.PaymentChannel{
 			Target:         mtp.targetAddress,
 			Amount:         types.NewAttoFILFromFIL(100000),
-			AmountRedeemed: types.NewAttoFILFromFIL(0),
+			AmountRedeemed: mtp.redeemed,
 			Eol:            mtp.channelEol,
+			LaneRedeemed:   mtp.laneRedeemed,
 		}
 	}
 
@@ -391,6 +492,10 @@ func (mtp *minerTestPorcelain) MessageWait(ctx context.Context, msgCid cid.Cid,
 	return nil
 }
 
+func (mtp *minerTestPorcelain) PaychRecordReceived(ctx context.Context, voucher *paymentbroker.PaymentVoucher) error {
+	return nil
+}
+
 func newTestMiner(api *minerTestPorcelain) *Miner {
 	return &Miner{
 		porcelainAPI:   api,
@@ -415,7 +520,7 @@ func testDealProposal(porcelainAPI *minerTestPorcelain, voucherInterval int, amo
 	for i := 0; i < 10; i++ {
 		validAt := porcelainAPI.paymentStart.Add(types.NewBlockHeight(uint64((i + 1) * voucherInterval)))
 		amount := types.NewAttoFILFromFIL(uint64(i+1) * amountInc)
-		signature, err := paymentbroker.SignVoucher(porcelainAPI.channelID, amount, validAt, porcelainAPI.payerAddress, porcelainAPI.signer)
+		signature, err := paymentbroker.SignVoucher(porcelainAPI.channelID, 0, amount, validAt, nil, porcelainAPI.payerAddress, porcelainAPI.signer)
 		if err != nil {
 			panic("Could not sign valid proposal")
 		}
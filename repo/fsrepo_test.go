@@ -47,6 +47,7 @@ const (
 	"mining": {
 		"minerAddress": "",
 		"autoSealIntervalSeconds": 120,
+		"maxConcurrentSeals": 1,
 		"storagePrice": "0"
 	},
 	"wallet": {
@@ -57,6 +58,20 @@ const (
 		"beatPeriod": "3s",
 		"reconnectPeriod": "10s",
 		"nickname": ""
+	},
+	"proofs": {
+		"backend": "rust"
+	},
+	"gc": {
+		"retention": 1000,
+		"period": "1h"
+	},
+	"sync": {
+		"checkpoints": []
+	},
+	"paych": {
+		"settleGasPrice": "0",
+		"settleGasLimit": 300
 	}
 }`
 )
@@ -114,10 +114,14 @@ func (e actorNotFoundError) ActorNotFound() bool {
 }
 
 func (t *tree) GetBuiltinActorCode(codePointer cid.Cid) (exec.ExecutableActor, error) {
+	return builtinActorCode(t.builtinActors, codePointer)
+}
+
+func builtinActorCode(builtinActors map[cid.Cid]exec.ExecutableActor, codePointer cid.Cid) (exec.ExecutableActor, error) {
 	if !codePointer.Defined() {
 		return nil, fmt.Errorf("missing code")
 	}
-	actor, ok := t.builtinActors[codePointer]
+	actor, ok := builtinActors[codePointer]
 	if !ok {
 		return nil, fmt.Errorf("unknown code: %s", codePointer.String())
 	}
@@ -125,6 +129,25 @@ func (t *tree) GetBuiltinActorCode(codePointer cid.Cid) (exec.ExecutableActor, e
 	return actor, nil
 }
 
+// actorsTree is a state tree which executes its actors with its own builtin
+// actors rather than those of the tree it wraps.
+type actorsTree struct {
+	Tree
+	builtinActors map[cid.Cid]exec.ExecutableActor
+}
+
+// WithBuiltinActors returns a view of st whose actors are executed with the
+// provided builtin actors. Changes to the view are changes to st.
+func WithBuiltinActors(st Tree, builtinActors map[cid.Cid]exec.ExecutableActor) Tree {
+	return &actorsTree{Tree: st, builtinActors: builtinActors}
+}
+
+// GetBuiltinActorCode implements Tree.GetBuiltinActorCode with the builtin
+// actors of the view.
+func (t *actorsTree) GetBuiltinActorCode(codePointer cid.Cid) (exec.ExecutableActor, error) {
+	return builtinActorCode(t.builtinActors, codePointer)
+}
+
 // GetActor retrieves an actor by their address. If no actor
 // exists at the given address then an error will be returned
 // for which IsActorNotFoundError(err) is true.
@@ -11,6 +11,7 @@ import (
 
 	"github.com/filecoin-project/go-filecoin/actor"
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/exec"
 	"github.com/filecoin-project/go-filecoin/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -143,3 +144,38 @@ func TestGetAllActors(t *testing.T) {
 	assert.Equal(actor.Nonce, found.Nonce)
 	assert.Equal(actor.Balance, found.Balance)
 }
+
+type fakeBuiltinActor struct{}
+
+func (a *fakeBuiltinActor) Exports() exec.Exports { return nil }
+
+func (a *fakeBuiltinActor) InitializeState(storage exec.Storage, initializerData interface{}) error {
+	return nil
+}
+
+func TestWithBuiltinActors(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+	cst := hamt.NewCborStore()
+	underlying := NewEmptyStateTree(cst)
+
+	code := &fakeBuiltinActor{}
+	tree := WithBuiltinActors(underlying, map[cid.Cid]exec.ExecutableActor{types.AccountActorCodeCid: code})
+
+	_, err := underlying.GetBuiltinActorCode(types.AccountActorCodeCid)
+	assert.Error(err)
+	actual, err := tree.GetBuiltinActorCode(types.AccountActorCodeCid)
+	require.NoError(err)
+	assert.Equal(code, actual)
+	_, err = tree.GetBuiltinActorCode(types.MinerActorCodeCid)
+	assert.Error(err)
+
+	t.Log("actors are set in the underlying tree")
+	addr := address.NewForTestGetter()()
+	act := actor.NewActor(types.AccountActorCodeCid, nil)
+	require.NoError(tree.SetActor(ctx, addr, act))
+	stored, err := underlying.GetActor(ctx, addr)
+	require.NoError(err)
+	assert.Equal(act.Code, stored.Code)
+}
@@ -0,0 +1,118 @@
+// remote-signer is a reference signer for the remote wallet backend of
+// go-filecoin, see package wallet/remote for the protocol. It holds the keys
+// of a file written by `go-filecoin wallet export` and answers the requests
+// of the node on a unix socket. Point the node at it with the config
+// wallet.remoteSigner.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	logging "gx/ipfs/QmcuXC5cxs79ro2cUuHs4HQ2bkDLJUYokwL8aivcX6HW3C/go-log"
+	"gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/wallet"
+	"github.com/filecoin-project/go-filecoin/wallet/remote"
+)
+
+var log = logging.Logger("remote-signer")
+
+func init() {
+	// Info level
+	logging.SetAllLoggers(4)
+}
+
+// backendSigner serves the keys of a wallet backend.
+type backendSigner struct {
+	backend *wallet.DSBackend
+}
+
+func (s *backendSigner) Keys() ([]remote.Key, error) {
+	var keys []remote.Key
+	for _, addr := range s.backend.Addresses() {
+		pk, err := wallet.PublicKey(s.backend, addr)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, remote.Key{Address: addr, PublicKey: pk})
+	}
+	return keys, nil
+}
+
+func (s *backendSigner) Sign(addr address.Address, data []byte) (types.Signature, error) {
+	if !s.backend.HasAddress(addr) {
+		return nil, wallet.ErrUnknownAddress
+	}
+	log.Infof("signing %d bytes with %s", len(data), addr)
+	return s.backend.SignBytes(data, addr)
+}
+
+func main() {
+	socket := flag.String("socket", "", "(required) path of the unix socket to listen on")
+	keyfile := flag.String("keyfile", "", "(required) file written by `go-filecoin wallet export` holding the keys to serve")
+	flag.Parse()
+
+	if *socket == "" || *keyfile == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	backend, err := loadKeys(*keyfile)
+	if err != nil {
+		log.Fatalf("failed to load keys: %s", err)
+	}
+
+	l, err := net.Listen("unix", *socket)
+	if err != nil {
+		log.Fatalf("failed to listen on %s: %s", *socket, err)
+	}
+	// Only the user running the node may ask for signatures.
+	if err := os.Chmod(*socket, 0600); err != nil {
+		log.Fatalf("failed to restrict access to %s: %s", *socket, err)
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		l.Close() // nolint: errcheck
+	}()
+
+	log.Infof("serving %d keys on %s", len(backend.Addresses()), *socket)
+	if err := remote.Serve(l, &backendSigner{backend: backend}); err != nil {
+		log.Infof("stopped serving: %s", err)
+	}
+}
+
+func loadKeys(keyfile string) (*wallet.DSBackend, error) {
+	f, err := os.Open(keyfile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // nolint: errcheck
+
+	var export struct {
+		KeyInfo []*types.KeyInfo
+	}
+	if err := json.NewDecoder(f).Decode(&export); err != nil {
+		return nil, err
+	}
+
+	backend, err := wallet.NewDSBackend(datastore.NewMapDatastore())
+	if err != nil {
+		return nil, err
+	}
+	for _, ki := range export.KeyInfo {
+		if err := backend.ImportKey(ki); err != nil {
+			return nil, err
+		}
+	}
+	return backend, nil
+}
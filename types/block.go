@@ -44,6 +44,11 @@ type Block struct {
 	// TODO: should be a merkletree-ish thing
 	Messages []*SignedMessage `json:"messages"`
 
+	// BLSAggregateSignature is the aggregate of the signatures of the
+	// messages from BLS addresses, which are stripped off the messages. It is
+	// empty if the block holds no such messages.
+	BLSAggregateSignature Signature `json:"blsAggregateSignature"`
+
 	// StateRoot is a cid pointer to the state tree after application of the
 	// transactions state transitions.
 	StateRoot cid.Cid `json:"stateRoot,omitempty" refmt:",omitempty"`
@@ -0,0 +1,79 @@
+package types
+
+import (
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/bls-signatures"
+	wutil "github.com/filecoin-project/go-filecoin/wallet/util"
+)
+
+// AggregateBLSSignatures aggregates the signatures of the messages from BLS
+// addresses into one signature and returns the messages with their BLS
+// signatures stripped, so that only the public key of the sender remains.
+// Other messages are returned unchanged. The returned signature is nil if
+// there are no messages to aggregate.
+func AggregateBLSSignatures(msgs []*SignedMessage) ([]*SignedMessage, Signature, error) {
+	var sigs []bls.Signature
+	out := make([]*SignedMessage, len(msgs))
+	for i, msg := range msgs {
+		if !msg.From.IsBLS() || msg.IsBLSAggregated() {
+			out[i] = msg
+			continue
+		}
+
+		_, sig, err := wutil.SplitBLSSignature(msg.Signature)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "could not aggregate signature of message from %s", msg.From)
+		}
+		sigs = append(sigs, sig)
+		out[i] = msg.withoutBLSSignature()
+	}
+
+	if len(sigs) == 0 {
+		return out, nil, nil
+	}
+
+	aggregate := bls.Aggregate(sigs)
+	return out, aggregate[:], nil
+}
+
+// VerifyBLSAggregate returns true iff aggregate is the aggregate signature of
+// the messages whose BLS signatures have been stripped, by the public keys
+// they carry, and these public keys match the sender addresses. It returns
+// true for an empty aggregate if there are no such messages.
+func VerifyBLSAggregate(msgs []*SignedMessage, aggregate Signature) bool {
+	var digests []bls.Digest
+	var pubs []bls.PublicKey
+	for _, msg := range msgs {
+		if !msg.IsBLSAggregated() {
+			continue
+		}
+
+		var pub bls.PublicKey
+		copy(pub[:], msg.Signature)
+		if address.NewBLS(msg.From.Network(), address.Hash(pub[:])) != msg.From {
+			return false
+		}
+
+		bmsg, err := msg.MeteredMessage.Marshal()
+		if err != nil {
+			log.Infof("invalid aggregate signature: %s", err)
+			return false
+		}
+
+		digests = append(digests, bls.Hash(bmsg))
+		pubs = append(pubs, pub)
+	}
+
+	if len(digests) == 0 {
+		return len(aggregate) == 0
+	}
+	if len(aggregate) != bls.SignatureBytes {
+		return false
+	}
+
+	var sig bls.Signature
+	copy(sig[:], aggregate)
+	return bls.Verify(sig, digests, pubs)
+}
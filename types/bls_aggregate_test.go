@@ -0,0 +1,77 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/bls-signatures"
+	wutil "github.com/filecoin-project/go-filecoin/wallet/util"
+)
+
+type blsTestSigner map[address.Address]bls.PrivateKey
+
+func (s blsTestSigner) newAddress() address.Address {
+	prv := bls.PrivateKeyGenerate()
+	pub := bls.PrivateKeyPublicKey(prv)
+	addr := address.NewBLS(address.Mainnet, address.Hash(pub[:]))
+	s[addr] = prv
+	return addr
+}
+
+func (s blsTestSigner) SignBytes(data []byte, addr address.Address) (Signature, error) {
+	return wutil.SignBLS(s[addr], data), nil
+}
+
+func TestAggregateBLSSignatures(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	signer := blsTestSigner{}
+	to := address.NewForTestGetter()()
+
+	var msgs []*SignedMessage
+	for i := 0; i < 3; i++ {
+		msg := NewMessage(signer.newAddress(), to, 0, NewAttoFILFromFIL(1), "", nil)
+		smsg, err := NewSignedMessage(*msg, signer, NewGasPrice(0), NewGasUnits(0))
+		require.NoError(err)
+		require.True(smsg.VerifySignature())
+		msgs = append(msgs, smsg)
+	}
+	// secp256k1 messages are left alone
+	secpMsg := newSignedMessage()
+	msgs = append(msgs, secpMsg)
+
+	aggregated, aggregate, err := AggregateBLSSignatures(msgs)
+	require.NoError(err)
+	require.Len(aggregated, len(msgs))
+	assert.Len(aggregate, bls.SignatureBytes)
+
+	for i, msg := range aggregated[:3] {
+		assert.True(msg.IsBLSAggregated())
+
+		// stripping the signature does not change the cid
+		before, err := msgs[i].Cid()
+		require.NoError(err)
+		after, err := msg.Cid()
+		require.NoError(err)
+		assert.Equal(before, after)
+	}
+	assert.Equal(secpMsg, aggregated[3])
+
+	assert.True(VerifyBLSAggregate(aggregated, aggregate))
+
+	// the aggregate does not verify without one of its messages
+	assert.False(VerifyBLSAggregate(aggregated[1:], aggregate))
+
+	// nor with a changed message
+	changed := *aggregated[0]
+	changed.Nonce = Uint64(1)
+	assert.False(VerifyBLSAggregate([]*SignedMessage{&changed, aggregated[1], aggregated[2]}, aggregate))
+
+	// messages without BLS signatures need no aggregate
+	assert.True(VerifyBLSAggregate([]*SignedMessage{secpMsg}, nil))
+	assert.False(VerifyBLSAggregate([]*SignedMessage{secpMsg}, aggregate))
+}
@@ -40,6 +40,18 @@ var BootstrapMinerActorCodeObj ipld.Node
 // BootstrapMinerActorCodeCid is the cid of the above object
 var BootstrapMinerActorCodeCid cid.Cid
 
+// MultisigActorCodeObj is the code representation of the builtin multisig actor.
+var MultisigActorCodeObj ipld.Node
+
+// MultisigActorCodeCid is the cid of the above object
+var MultisigActorCodeCid cid.Cid
+
+// VestingActorCodeObj is the code representation of the builtin vesting account actor.
+var VestingActorCodeObj ipld.Node
+
+// VestingActorCodeCid is the cid of the above object
+var VestingActorCodeCid cid.Cid
+
 // ActorCodeCidTypeNames maps Actor codeCid's to the name of the associated Actor type.
 var ActorCodeCidTypeNames = make(map[cid.Cid]string)
 
@@ -54,6 +66,10 @@ func init() {
 	MinerActorCodeCid = MinerActorCodeObj.Cid()
 	BootstrapMinerActorCodeObj = dag.NewRawNode([]byte("bootstrapmineractor"))
 	BootstrapMinerActorCodeCid = BootstrapMinerActorCodeObj.Cid()
+	MultisigActorCodeObj = dag.NewRawNode([]byte("multisigactor"))
+	MultisigActorCodeCid = MultisigActorCodeObj.Cid()
+	VestingActorCodeObj = dag.NewRawNode([]byte("vestingactor"))
+	VestingActorCodeCid = VestingActorCodeObj.Cid()
 
 	// New Actors need to be added here.
 	// TODO: Make this work with reflection -- but note that nasty import cycles lie on that path.
@@ -63,6 +79,8 @@ func init() {
 	ActorCodeCidTypeNames[PaymentBrokerActorCodeCid] = "PaymentBrokerActor"
 	ActorCodeCidTypeNames[MinerActorCodeCid] = "MinerActor"
 	ActorCodeCidTypeNames[BootstrapMinerActorCodeCid] = "MinerActor"
+	ActorCodeCidTypeNames[MultisigActorCodeCid] = "MultisigActor"
+	ActorCodeCidTypeNames[VestingActorCodeCid] = "VestingActor"
 }
 
 // ActorCodeTypeName returns the (string) name of the Go type of the actor with cid, code.
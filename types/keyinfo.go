@@ -8,6 +8,7 @@ import (
 	cbor "gx/ipfs/QmRoARq3nkUb13HSKZGepCZSWe5GrVPwx7xURJGZ7KWv9V/go-ipld-cbor"
 
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/bls-signatures"
 	"github.com/filecoin-project/go-filecoin/crypto"
 	cu "github.com/filecoin-project/go-filecoin/crypto/util"
 )
@@ -69,11 +70,18 @@ func (ki *KeyInfo) Address() (address.Address, error) {
 	addrHash := address.Hash(pub)
 
 	// TODO: Use the address type we are running on from the config.
+	if ki.Curve == BLS {
+		return address.NewBLS(address.Mainnet, addrHash), nil
+	}
 	return address.NewMainnet(addrHash), nil
 }
 
 // PublicKey returns the public key part as uncompressed bytes.
 func (ki *KeyInfo) PublicKey() ([]byte, error) {
+	if ki.Curve == BLS {
+		return blsPublicKey(ki.Key())
+	}
+
 	prv, err := crypto.BytesToECDSA(ki.Key())
 	if err != nil {
 		return nil, err
@@ -87,3 +95,14 @@ func (ki *KeyInfo) PublicKey() ([]byte, error) {
 
 	return cu.SerializeUncompressed(pub), nil
 }
+
+func blsPublicKey(key []byte) ([]byte, error) {
+	if len(key) != bls.PrivateKeyBytes {
+		return nil, fmt.Errorf("invalid BLS private key length %d", len(key))
+	}
+
+	var prv bls.PrivateKey
+	copy(prv[:], key)
+	pub := bls.PrivateKeyPublicKey(prv)
+	return pub[:], nil
+}
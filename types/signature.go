@@ -15,6 +15,10 @@ type Signature = Bytes
 // IsValidSignature cryptographically verifies that 'sig' is the signed hash of 'data' with
 // the public key belonging to `addr`.
 func IsValidSignature(data []byte, addr address.Address, sig Signature) bool {
+	if addr.IsBLS() {
+		return isValidBLSSignature(data, addr, sig)
+	}
+
 	maybePk, err := wutil.Ecrecover(data, sig)
 	if err != nil {
 		// Any error returned from Ecrecover means this signature is not valid.
@@ -25,3 +29,24 @@ func IsValidSignature(data []byte, addr address.Address, sig Signature) bool {
 
 	return address.NewMainnet(maybeAddrHash) == addr
 }
+
+// isValidBLSSignature verifies a signature for a BLS address. As the public
+// key cannot be recovered from a BLS signature, the signature carries it and
+// it must hash to the address.
+func isValidBLSSignature(data []byte, addr address.Address, sig Signature) bool {
+	pk, _, err := wutil.SplitBLSSignature(sig)
+	if err != nil {
+		log.Infof("error in signature validation: %s", err)
+		return false
+	}
+	if address.NewBLS(addr.Network(), address.Hash(pk[:])) != addr {
+		return false
+	}
+
+	valid, err := wutil.VerifyBLS(pk[:], data, sig)
+	if err != nil {
+		log.Infof("error in signature validation: %s", err)
+		return false
+	}
+	return valid
+}
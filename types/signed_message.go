@@ -9,6 +9,8 @@ import (
 	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
 
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/bls-signatures"
+	wutil "github.com/filecoin-project/go-filecoin/wallet/util"
 )
 
 var (
@@ -40,10 +42,12 @@ func (smsg *SignedMessage) Marshal() ([]byte, error) {
 	return cbor.DumpObject(smsg)
 }
 
-// Cid returns the canonical CID for the SignedMessage.
+// Cid returns the canonical CID for the SignedMessage. The CID of a message
+// from a BLS address does not cover its BLS signature, so that it stays the
+// same when the signature is aggregated into a block.
 // TODO: can we avoid returning an error?
 func (smsg *SignedMessage) Cid() (cid.Cid, error) {
-	obj, err := cbor.WrapObject(smsg, DefaultHashFunction, -1)
+	obj, err := cbor.WrapObject(smsg.withoutBLSSignature(), DefaultHashFunction, -1)
 	if err != nil {
 		return cid.Undef, errors.Wrap(err, "failed to marshal to cbor")
 	}
@@ -51,12 +55,41 @@ func (smsg *SignedMessage) Cid() (cid.Cid, error) {
 	return obj.Cid(), nil
 }
 
+// IsBLSAggregated returns true if the message is from a BLS address and its
+// signature has been aggregated into the signature of a block, leaving only
+// the public key of the sender.
+func (smsg *SignedMessage) IsBLSAggregated() bool {
+	return smsg.From.IsBLS() && len(smsg.Signature) == bls.PublicKeyBytes
+}
+
+// withoutBLSSignature returns a copy of a message from a BLS address whose
+// signature holds only the public key of the sender. Other messages are
+// returned as they are.
+func (smsg *SignedMessage) withoutBLSSignature() *SignedMessage {
+	if !smsg.From.IsBLS() || len(smsg.Signature) != wutil.BLSSignatureLength {
+		return smsg
+	}
+
+	cpy := *smsg
+	cpy.Signature = smsg.Signature[:bls.PublicKeyBytes]
+	return &cpy
+}
+
 // RecoverAddress returns the address derived from the signature and message encapsulated in `SignedMessage`
 func (smsg *SignedMessage) RecoverAddress(r Recoverer) (address.Address, error) {
 	if len(smsg.Signature) < 1 {
 		return address.Address{}, ErrMessageUnsigned
 	}
 
+	if smsg.From.IsBLS() {
+		// BLS signatures carry the public key of the signer.
+		pk, _, err := wutil.SplitBLSSignature(smsg.Signature)
+		if err != nil {
+			return address.Address{}, err
+		}
+		return address.NewBLS(address.Mainnet, address.Hash(pk[:])), nil
+	}
+
 	bmsg, err := smsg.MeteredMessage.Marshal()
 	if err != nil {
 		return address.Address{}, err
@@ -74,7 +107,8 @@ func (smsg *SignedMessage) RecoverAddress(r Recoverer) (address.Address, error)
 }
 
 // VerifySignature returns true iff the signature over the message as calculated
-// from EC recover matches the message sender address.
+// from EC recover matches the message sender address. Signatures of BLS sender
+// addresses are verified against the public key they carry.
 func (smsg *SignedMessage) VerifySignature() bool {
 	bmsg, err := smsg.MeteredMessage.Marshal()
 	if err != nil {
@@ -28,6 +28,9 @@ type Context struct {
 	blockHeight *types.BlockHeight
 	ancestors   []types.TipSet
 	lookBack    int
+	// readOnly is true in the context of a Query, in which no state may be
+	// changed.
+	readOnly bool
 
 	deps *deps // Inject external dependencies so we can unit test robustly.
 }
@@ -67,7 +70,22 @@ var _ exec.VMContext = (*Context)(nil)
 
 // Storage returns an implementation of the storage module for this context.
 func (ctx *Context) Storage() exec.Storage {
-	return ctx.storageMap.NewStorage(ctx.message.To, ctx.to)
+	storage := ctx.storageMap.NewStorage(ctx.message.To, ctx.to)
+	if ctx.readOnly {
+		return readOnlyStorage{storage}
+	}
+	return storage
+}
+
+// readOnlyStorage is the storage of an actor called by a query, which
+// refuses to commit a new head.
+type readOnlyStorage struct {
+	exec.Storage
+}
+
+// Commit returns a revert error: a query must not change actor storage.
+func (s readOnlyStorage) Commit(newCid cid.Cid, oldCid cid.Cid) error {
+	return errors.NewRevertError("cannot change actor storage in a query")
 }
 
 // Message retrieves the message associated with this context.
@@ -133,6 +151,21 @@ func (ctx *Context) IsFromAccountActor() bool {
 // Send sends a message to another actor.
 // This method assumes to be called from inside the `to` actor.
 func (ctx *Context) Send(to address.Address, method string, value *types.AttoFIL, params []interface{}) ([][]byte, uint8, error) {
+	if ctx.readOnly && value != nil && !value.IsZero() {
+		return nil, 1, errors.NewRevertError("cannot transfer value in a query")
+	}
+	return ctx.send(to, method, value, params, ctx.readOnly)
+}
+
+// Query calls a method of another actor without changing any state: the
+// called actor, and the actors it calls in turn, can read but not change
+// their storage, transfer value or create actors. Unlike Send, Query fails if
+// there is no actor at the address.
+func (ctx *Context) Query(to address.Address, method string, params []interface{}) ([][]byte, uint8, error) {
+	return ctx.send(to, method, types.NewZeroAttoFIL(), params, true)
+}
+
+func (ctx *Context) send(to address.Address, method string, value *types.AttoFIL, params []interface{}, readOnly bool) ([][]byte, uint8, error) {
 	deps := ctx.deps
 
 	// the message sender is the `to` actor, so this is what we set as `from` in the new message
@@ -155,9 +188,17 @@ func (ctx *Context) Send(to address.Address, method string, value *types.AttoFIL
 		return nil, 1, errors.NewFaultErrorf("unhandled: sending to self (%s)", msg.From)
 	}
 
-	toActor, err := deps.GetOrCreateActor(context.TODO(), msg.To, func() (*actor.Actor, error) {
-		return &actor.Actor{}, nil
-	})
+	var toActor *actor.Actor
+	if readOnly {
+		toActor, err = ctx.state.GetActor(context.TODO(), msg.To)
+		if state.IsActorNotFoundError(err) {
+			return nil, 1, errors.NewRevertErrorf("cannot query missing actor %s", msg.To)
+		}
+	} else {
+		toActor, err = deps.GetOrCreateActor(context.TODO(), msg.To, func() (*actor.Actor, error) {
+			return &actor.Actor{}, nil
+		})
+	}
 	if err != nil {
 		return nil, 1, errors.FaultErrorWrapf(err, "failed to get or create To actor %s", msg.To)
 	}
@@ -173,6 +214,7 @@ func (ctx *Context) Send(to address.Address, method string, value *types.AttoFIL
 		Ancestors:   ctx.ancestors,
 	}
 	innerCtx := NewVMContext(innerParams)
+	innerCtx.readOnly = readOnly
 
 	out, ret, err := deps.Send(context.Background(), innerCtx)
 	if err != nil {
@@ -209,6 +251,10 @@ func computeActorAddress(creator address.Address, nonce uint64) (address.Address
 // CreateNewActor creates and initializes an actor at the given address.
 // If the address is occupied by a non-empty actor, this method will fail.
 func (ctx *Context) CreateNewActor(addr address.Address, code cid.Cid, initializerData interface{}) error {
+	if ctx.readOnly {
+		return errors.NewRevertError("cannot create an actor in a query")
+	}
+
 	// Check existing address. If nothing there, create empty actor.
 	newActor, err := ctx.state.GetOrCreateActor(context.TODO(), addr, func() (*actor.Actor, error) {
 		return &actor.Actor{}, nil
@@ -72,6 +72,59 @@ func TestVMContextStorage(t *testing.T) {
 	assert.Equal(storage, node.RawData())
 }
 
+func TestVMContextQueryIsReadOnly(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	addrGetter := address.NewForTestGetter()
+	ctx := context.Background()
+
+	cst := hamt.NewCborStore()
+	st := state.NewEmptyStateTree(cst)
+	cstate := state.NewCachedStateTree(st)
+
+	bs := blockstore.NewBlockstore(datastore.NewMapDatastore())
+	vms := NewStorageMap(bs)
+
+	toActor, err := account.NewActor(types.NewAttoFILFromFIL(100))
+	require.NoError(err)
+	toAddr := addrGetter()
+	require.NoError(st.SetActor(ctx, toAddr, toActor))
+
+	to, err := cstate.GetActor(ctx, toAddr)
+	require.NoError(err)
+	vmCtx := NewVMContext(NewContextParams{
+		From:        nil,
+		To:          to,
+		Message:     types.NewMessage(addrGetter(), toAddr, 0, nil, "hello", nil),
+		State:       cstate,
+		StorageMap:  vms,
+		GasTracker:  NewGasTracker(),
+		BlockHeight: types.NewBlockHeight(0),
+	})
+	vmCtx.readOnly = true
+
+	node, err := cbor.WrapObject([]byte("hello"), types.DefaultHashFunction, -1)
+	require.NoError(err)
+	err = vmCtx.WriteStorage(node.RawData())
+	assert.Error(err)
+	assert.True(errors.ShouldRevert(err))
+
+	_, _, err = vmCtx.Send(addrGetter(), "", types.NewAttoFILFromFIL(1), nil)
+	assert.Error(err)
+	assert.True(errors.ShouldRevert(err))
+
+	newAddr := addrGetter()
+	err = vmCtx.CreateNewActor(newAddr, types.AccountActorCodeCid, nil)
+	assert.Error(err)
+	assert.True(errors.ShouldRevert(err))
+
+	_, _, err = vmCtx.Query(newAddr, "hello", nil)
+	assert.Error(err)
+	assert.True(errors.ShouldRevert(err))
+	_, err = cstate.GetActor(ctx, newAddr)
+	assert.True(state.IsActorNotFoundError(err))
+}
+
 func TestVMContextSendFailures(t *testing.T) {
 	actor1 := actor.NewActor(cid.Undef, types.NewAttoFILFromFIL(100))
 	actor2 := actor.NewActor(cid.Undef, types.NewAttoFILFromFIL(50))
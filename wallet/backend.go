@@ -34,3 +34,24 @@ type Importer interface {
 	// into the backend
 	ImportKey(ki *types.KeyInfo) error
 }
+
+// PublicKeyer is a specialization of a wallet backend that knows the public
+// keys of its addresses without exposing the private keys. Remote signers
+// can do this.
+type PublicKeyer interface {
+	// PublicKey returns the public key of addr.
+	PublicKey(addr address.Address) ([]byte, error)
+}
+
+// PublicKey returns the public key of addr stored in backend.
+func PublicKey(backend Backend, addr address.Address) ([]byte, error) {
+	if pker, ok := backend.(PublicKeyer); ok {
+		return pker.PublicKey(addr)
+	}
+
+	ki, err := backend.GetKeyInfo(addr)
+	if err != nil {
+		return nil, err
+	}
+	return ki.PublicKey()
+}
@@ -6,12 +6,15 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 
+	cbor "gx/ipfs/QmRoARq3nkUb13HSKZGepCZSWe5GrVPwx7xURJGZ7KWv9V/go-ipld-cbor"
 	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
 	ds "gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore"
 	dsq "gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore/query"
 
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/bls-signatures"
 	"github.com/filecoin-project/go-filecoin/crypto"
 	"github.com/filecoin-project/go-filecoin/repo"
 	"github.com/filecoin-project/go-filecoin/types"
@@ -21,20 +24,30 @@ import (
 const (
 	// SECP256K1 is a curve used to computer private keys
 	SECP256K1 = "secp256k1"
+	// BLS is the type of BLS12-381 keys
+	BLS = "bls"
 )
 
 // DSBackendType is the reflect type of the DSBackend.
 var DSBackendType = reflect.TypeOf(&DSBackend{})
 
 // DSBackend is a wallet backend implementation for storing addresses in a datastore.
+// Once encrypted with a passphrase, it stores the keys encrypted and can
+// only sign or hand out keys while it is unlocked.
 type DSBackend struct {
 	lk sync.RWMutex
 
-	// TODO: use a better interface that supports time locks, encryption, etc.
 	ds repo.Datastore
 
 	// TODO: proper cache
 	cache map[address.Address]struct{}
+
+	// meta is nil if the keys are stored in plain text.
+	meta *keystoreMeta
+	// key is the keystore key while the backend is unlocked.
+	key []byte
+	// lockAt is when an unlock with a timeout expires, zero otherwise.
+	lockAt time.Time
 }
 
 var _ Backend = (*DSBackend)(nil)
@@ -55,6 +68,9 @@ func NewDSBackend(ds repo.Datastore) (*DSBackend, error) {
 
 	cache := make(map[address.Address]struct{})
 	for _, el := range list {
+		if isReservedKey(el.Key) {
+			continue
+		}
 		parsedAddr, err := address.NewFromString(strings.Trim(el.Key, "/"))
 		if err != nil {
 			return nil, errors.Wrapf(err, "trying to restore invalid address: %s", el.Key)
@@ -62,12 +78,168 @@ func NewDSBackend(ds repo.Datastore) (*DSBackend, error) {
 		cache[parsedAddr] = struct{}{}
 	}
 
+	meta, err := loadKeystoreMeta(ds)
+	if err != nil {
+		return nil, err
+	}
+
 	return &DSBackend{
 		ds:    ds,
 		cache: cache,
+		meta:  meta,
 	}, nil
 }
 
+func loadKeystoreMeta(d repo.Datastore) (*keystoreMeta, error) {
+	metab, err := d.Get(keystoreMetaKey)
+	if err == ds.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read keystore metadata")
+	}
+
+	var meta keystoreMeta
+	if err := cbor.DecodeInto(metab, &meta); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal keystore metadata")
+	}
+	return &meta, nil
+}
+
+// IsEncrypted returns true if the backend stores its keys encrypted.
+func (backend *DSBackend) IsEncrypted() bool {
+	backend.lk.RLock()
+	defer backend.lk.RUnlock()
+
+	return backend.meta != nil
+}
+
+// IsLocked returns true if the backend is encrypted and locked.
+func (backend *DSBackend) IsLocked() bool {
+	backend.lk.RLock()
+	defer backend.lk.RUnlock()
+
+	return backend.meta != nil && backend.key == nil
+}
+
+// Encrypt encrypts the keys stored in plain text with a key derived from
+// passphrase, which must not be empty. This migrates wallets created without
+// a passphrase. The backend is unlocked afterwards.
+func (backend *DSBackend) Encrypt(passphrase []byte) error {
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+
+	if backend.meta != nil {
+		return ErrAlreadyEncrypted
+	}
+	if len(passphrase) == 0 {
+		return ErrEmptyPassphrase
+	}
+
+	meta, key, err := newKeystoreMeta(passphrase)
+	if err != nil {
+		return err
+	}
+
+	// Keys and metadata are written in one batch, so that the keystore is
+	// never partially encrypted.
+	batch, err := backend.ds.Batch()
+	if err != nil {
+		return errors.Wrap(err, "failed to create batch")
+	}
+
+	secrets := []ds.Key{hdSeedKey}
+	for addr := range backend.cache {
+		secrets = append(secrets, ds.NewKey(addr.String()))
+	}
+
+	for _, k := range secrets {
+		secret, err := backend.ds.Get(k)
+		if err == ds.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to fetch private key from backend")
+		}
+
+		sealed, err := seal(key, secret)
+		if err != nil {
+			return errors.Wrap(err, "failed to encrypt private key")
+		}
+		if err := batch.Put(k, sealed); err != nil {
+			return errors.Wrap(err, "failed to store encrypted private key")
+		}
+	}
+
+	metab, err := cbor.DumpObject(meta)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal keystore metadata")
+	}
+	if err := batch.Put(keystoreMetaKey, metab); err != nil {
+		return errors.Wrap(err, "failed to store keystore metadata")
+	}
+
+	if err := batch.Commit(); err != nil {
+		return errors.Wrap(err, "failed to encrypt keystore")
+	}
+
+	backend.meta = meta
+	backend.key = key
+	return nil
+}
+
+// Unlock makes the keys of an encrypted backend usable until Lock is called
+// or, if timeout is not zero, until timeout has passed.
+func (backend *DSBackend) Unlock(passphrase []byte, timeout time.Duration) error {
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+
+	if backend.meta == nil {
+		return ErrNotEncrypted
+	}
+
+	key, err := backend.meta.unlock(passphrase)
+	if err != nil {
+		return err
+	}
+
+	backend.key = key
+	backend.lockAt = time.Time{}
+	if timeout > 0 {
+		backend.lockAt = time.Now().Add(timeout)
+		time.AfterFunc(timeout, backend.lockIfExpired)
+	}
+	return nil
+}
+
+// lockIfExpired locks the backend if its unlock has expired. Timers of
+// earlier unlocks find a later or no expiry and leave the backend unlocked.
+func (backend *DSBackend) lockIfExpired() {
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+
+	if !backend.lockAt.IsZero() && !time.Now().Before(backend.lockAt) {
+		backend.lock()
+	}
+}
+
+// Lock forgets the keystore key, so that keys can no longer be used until
+// the backend is unlocked again.
+func (backend *DSBackend) Lock() {
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+
+	backend.lock()
+}
+
+func (backend *DSBackend) lock() {
+	for i := range backend.key {
+		backend.key[i] = 0
+	}
+	backend.key = nil
+	backend.lockAt = time.Time{}
+}
+
 // ImportKey loads the address in `ai` and KeyInfo `ki` into the backend
 func (backend *DSBackend) ImportKey(ki *types.KeyInfo) error {
 	return backend.putKeyInfo(ki)
@@ -95,18 +267,37 @@ func (backend *DSBackend) HasAddress(addr address.Address) bool {
 	return ok
 }
 
-// NewAddress creates a new address and stores it.
+// NewAddress creates a new secp256k1 address and stores it.
 // Safe for concurrent access.
 func (backend *DSBackend) NewAddress() (address.Address, error) {
-	prv, err := crypto.GenerateKey()
-	if err != nil {
-		return address.Address{}, err
-	}
+	return backend.NewAddressOfType(SECP256K1)
+}
 
-	// TODO: maybe the above call should just return a keyinfo?
-	ki := &types.KeyInfo{
-		PrivateKey: crypto.ECDSAToBytes(prv),
-		Curve:      SECP256K1,
+// NewAddressOfType creates a new address with a key of the given type and
+// stores it.
+// Safe for concurrent access.
+func (backend *DSBackend) NewAddressOfType(keyType string) (address.Address, error) {
+	var ki *types.KeyInfo
+	switch keyType {
+	case SECP256K1:
+		prv, err := crypto.GenerateKey()
+		if err != nil {
+			return address.Address{}, err
+		}
+
+		// TODO: maybe the above call should just return a keyinfo?
+		ki = &types.KeyInfo{
+			PrivateKey: crypto.ECDSAToBytes(prv),
+			Curve:      SECP256K1,
+		}
+	case BLS:
+		prv := bls.PrivateKeyGenerate()
+		ki = &types.KeyInfo{
+			PrivateKey: prv[:],
+			Curve:      BLS,
+		}
+	default:
+		return address.Address{}, fmt.Errorf("unknown key type %s", keyType)
 	}
 
 	if err := backend.putKeyInfo(ki); err != nil {
@@ -122,22 +313,74 @@ func (backend *DSBackend) putKeyInfo(ki *types.KeyInfo) error {
 		return err
 	}
 
-	backend.lk.Lock()
-	defer backend.lk.Unlock()
-
 	kib, err := ki.Marshal()
 	if err != nil {
 		return err
 	}
 
-	if err := backend.ds.Put(ds.NewKey(a.String()), kib); err != nil {
-		return errors.Wrap(err, "failed to store new address")
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+
+	if err := backend.putSecretLocked(ds.NewKey(a.String()), kib); err != nil {
+		return err
 	}
 
 	backend.cache[a] = struct{}{}
 	return nil
 }
 
+// putSecret stores secret under key, encrypted if the backend is. It fails
+// with ErrLocked while the backend is locked.
+func (backend *DSBackend) putSecret(key ds.Key, secret []byte) error {
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+
+	return backend.putSecretLocked(key, secret)
+}
+
+func (backend *DSBackend) putSecretLocked(key ds.Key, secret []byte) error {
+	if backend.meta != nil {
+		if backend.key == nil {
+			return ErrLocked
+		}
+
+		var err error
+		secret, err = seal(backend.key, secret)
+		if err != nil {
+			return errors.Wrap(err, "failed to encrypt secret")
+		}
+	}
+
+	if err := backend.ds.Put(key, secret); err != nil {
+		return errors.Wrap(err, "failed to store secret")
+	}
+	return nil
+}
+
+// getSecret returns the secret stored under key by putSecret. It fails with
+// ErrLocked while the backend is locked.
+func (backend *DSBackend) getSecret(key ds.Key) ([]byte, error) {
+	backend.lk.RLock()
+	defer backend.lk.RUnlock()
+
+	if backend.meta != nil && backend.key == nil {
+		return nil, ErrLocked
+	}
+
+	secret, err := backend.ds.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if backend.meta != nil {
+		secret, err = open(backend.key, secret)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to decrypt secret")
+		}
+	}
+	return secret, nil
+}
+
 // SignBytes cryptographically signs `data` using the private key `priv`.
 func (backend *DSBackend) SignBytes(data []byte, addr address.Address) (types.Signature, error) {
 	ki, err := backend.GetKeyInfo(addr)
@@ -145,6 +388,17 @@ func (backend *DSBackend) SignBytes(data []byte, addr address.Address) (types.Si
 		return nil, err
 	}
 
+	return signWithKeyInfo(ki, data)
+}
+
+// signWithKeyInfo signs data with the private key of ki.
+func signWithKeyInfo(ki *types.KeyInfo, data []byte) (types.Signature, error) {
+	if ki.Type() == BLS {
+		var privateKey bls.PrivateKey
+		copy(privateKey[:], ki.Key())
+		return wutil.SignBLS(privateKey, data), nil
+	}
+
 	privateKey, _, err := keysFromInfo(ki)
 	if err != nil {
 		return nil, err
@@ -167,7 +421,10 @@ func (backend *DSBackend) GetKeyInfo(addr address.Address) (*types.KeyInfo, erro
 	}
 
 	// kib is a cbor of types.KeyInfo
-	kib, err := backend.ds.Get(ds.NewKey(addr.String()))
+	kib, err := backend.getSecret(ds.NewKey(addr.String()))
+	if err == ErrLocked {
+		return nil, err
+	}
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to fetch private key from backend")
 	}
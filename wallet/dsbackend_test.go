@@ -3,10 +3,12 @@ package wallet
 import (
 	"sync"
 	"testing"
+	"time"
 
 	"gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestDSBackendSimple(t *testing.T) {
@@ -120,3 +122,67 @@ func TestDSBackendParallel(t *testing.T) {
 	wg.Wait()
 	assert.Len(fs.Addresses(), 10)
 }
+
+func TestDSBackendEncryption(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ds := datastore.NewMapDatastore()
+	defer ds.Close()
+
+	fs, err := NewDSBackend(ds)
+	require.NoError(err)
+	assert.False(fs.IsEncrypted())
+	assert.Equal(ErrNotEncrypted, fs.Unlock([]byte("secret"), 0))
+
+	addr, err := fs.NewAddress()
+	require.NoError(err)
+	plainKeyInfo, err := fs.GetKeyInfo(addr)
+	require.NoError(err)
+
+	t.Log("an empty passphrase is rejected")
+	assert.Equal(ErrEmptyPassphrase, fs.Encrypt(nil))
+	assert.False(fs.IsEncrypted())
+
+	t.Log("existing keys are encrypted")
+	require.NoError(fs.Encrypt([]byte("secret")))
+	assert.Equal(ErrAlreadyEncrypted, fs.Encrypt([]byte("secret")))
+	assert.True(fs.IsEncrypted())
+	assert.False(fs.IsLocked())
+
+	stored, err := ds.Get(datastore.NewKey(addr.String()))
+	require.NoError(err)
+	plainKeyInfoBytes, err := plainKeyInfo.Marshal()
+	require.NoError(err)
+	assert.NotEqual(plainKeyInfoBytes, stored)
+
+	t.Log("a locked backend refuses to sign")
+	fs.Lock()
+	assert.True(fs.IsLocked())
+	_, err = fs.SignBytes([]byte("data"), addr)
+	assert.Equal(ErrLocked, err)
+	_, err = fs.NewAddress()
+	assert.Equal(ErrLocked, err)
+
+	t.Log("an unlocked backend decrypts keys")
+	assert.Equal(ErrWrongPassphrase, fs.Unlock([]byte("wrong"), 0))
+	require.NoError(fs.Unlock([]byte("secret"), 0))
+	ki, err := fs.GetKeyInfo(addr)
+	require.NoError(err)
+	assert.True(plainKeyInfo.Equals(ki))
+	_, err = fs.SignBytes([]byte("data"), addr)
+	assert.NoError(err)
+
+	t.Log("encryption persists and a new backend starts locked")
+	fs2, err := NewDSBackend(ds)
+	require.NoError(err)
+	assert.True(fs2.HasAddress(addr))
+	assert.Len(fs2.Addresses(), 1)
+	assert.True(fs2.IsLocked())
+
+	t.Log("the backend locks itself after the unlock timeout")
+	require.NoError(fs2.Unlock([]byte("secret"), 10*time.Millisecond))
+	assert.False(fs2.IsLocked())
+	time.Sleep(50 * time.Millisecond)
+	assert.True(fs2.IsLocked())
+}
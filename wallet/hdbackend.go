@@ -0,0 +1,416 @@
+package wallet
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	cbor "gx/ipfs/QmRoARq3nkUb13HSKZGepCZSWe5GrVPwx7xURJGZ7KWv9V/go-ipld-cbor"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+	ds "gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore"
+
+	"github.com/tyler-smith/go-bip32"
+	"github.com/tyler-smith/go-bip39"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/types"
+	wutil "github.com/filecoin-project/go-filecoin/wallet/util"
+)
+
+func init() {
+	cbor.RegisterCborType(hdSeed{})
+	cbor.RegisterCborType(hdIndex{})
+}
+
+const (
+	// hdCoinType is the SLIP-44 coin type of Filecoin.
+	hdCoinType = 461
+
+	// hdGapLimit is the number of consecutive unused addresses after which
+	// Restore stops looking for used ones, as in BIP44.
+	hdGapLimit = 20
+
+	// mnemonicEntropyBits is the entropy of new mnemonics, giving 24 words.
+	mnemonicEntropyBits = 256
+)
+
+var (
+	// hdWalletPrefix is the datastore namespace of the HD wallet.
+	hdWalletPrefix = ds.NewKey("/hdwallet")
+	// hdSeedKey stores the seed and the imported keys, encrypted with the
+	// keystore of the DSBackend if it is.
+	hdSeedKey = hdWalletPrefix.ChildString("seed")
+	// hdIndexKey stores the addresses, which are readable while locked.
+	hdIndexKey = hdWalletPrefix.ChildString("index")
+)
+
+var (
+	// ErrHDNotInitialized is returned when using an HD backend without seed.
+	ErrHDNotInitialized = errors.New("HD wallet is not initialized")
+	// ErrHDAlreadyInitialized is returned when initializing an HD backend twice.
+	ErrHDAlreadyInitialized = errors.New("HD wallet is already initialized")
+	// ErrInvalidMnemonic is returned for a mnemonic with unknown words or a
+	// wrong checksum.
+	ErrInvalidMnemonic = errors.New("invalid mnemonic")
+)
+
+// HDBackendType is the reflect type of the HDBackend.
+var HDBackendType = reflect.TypeOf(&HDBackend{})
+
+// hdSeed is the secret part of the HD wallet.
+type hdSeed struct {
+	Seed     []byte
+	Imported []*types.KeyInfo
+}
+
+// hdIndex is the public part of the HD wallet.
+type hdIndex struct {
+	// Derived are the derived addresses, in the order of their indexes.
+	Derived []string
+	// Imported are the addresses of the imported keys.
+	Imported []string
+}
+
+// HDBackend is a hierarchical deterministic wallet backend (BIP32/BIP39). Its
+// secp256k1 keys are derived from a mnemonic seed along the BIP44 path
+// m/44'/461'/0'/0/i, so that a wallet can be restored from the mnemonic
+// alone. The seed is stored through a DSBackend, so that it is encrypted and
+// locked along with it.
+type HDBackend struct {
+	lk sync.RWMutex
+
+	store *DSBackend
+
+	derived  []address.Address
+	imported map[address.Address]struct{}
+}
+
+var _ Backend = (*HDBackend)(nil)
+var _ Importer = (*HDBackend)(nil)
+
+// NewHDBackend constructs an HD backend storing its seed through store.
+func NewHDBackend(store *DSBackend) (*HDBackend, error) {
+	hd := &HDBackend{
+		store:    store,
+		imported: make(map[address.Address]struct{}),
+	}
+
+	indexb, err := store.ds.Get(hdIndexKey)
+	if err == ds.ErrNotFound {
+		return hd, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read HD wallet index")
+	}
+
+	var index hdIndex
+	if err := cbor.DecodeInto(indexb, &index); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal HD wallet index")
+	}
+
+	for _, s := range index.Derived {
+		addr, err := address.NewFromString(s)
+		if err != nil {
+			return nil, errors.Wrapf(err, "trying to restore invalid address: %s", s)
+		}
+		hd.derived = append(hd.derived, addr)
+	}
+	for _, s := range index.Imported {
+		addr, err := address.NewFromString(s)
+		if err != nil {
+			return nil, errors.Wrapf(err, "trying to restore invalid address: %s", s)
+		}
+		hd.imported[addr] = struct{}{}
+	}
+
+	return hd, nil
+}
+
+// NewMnemonic generates a new random BIP39 mnemonic.
+func NewMnemonic() (string, error) {
+	entropy, err := bip39.NewEntropy(mnemonicEntropyBits)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to generate entropy")
+	}
+	return bip39.NewMnemonic(entropy)
+}
+
+// IsInitialized returns true if the backend has a seed.
+func (hd *HDBackend) IsInitialized() bool {
+	_, err := hd.store.ds.Get(hdSeedKey)
+	return err == nil
+}
+
+// Init sets the seed of the backend to the one of mnemonic. It does not
+// derive any address, see NewAddress and Restore.
+func (hd *HDBackend) Init(mnemonic string) error {
+	hd.lk.Lock()
+	defer hd.lk.Unlock()
+
+	if hd.IsInitialized() {
+		return ErrHDAlreadyInitialized
+	}
+
+	seed, err := bip39.NewSeedWithErrorChecking(strings.Join(strings.Fields(mnemonic), " "), "")
+	if err != nil {
+		return ErrInvalidMnemonic
+	}
+
+	return hd.putSeed(&hdSeed{Seed: seed})
+}
+
+// Restore derives the addresses of the seed which are used, as told by
+// isUsed, and all addresses before the last used one. It stops looking once
+// hdGapLimit consecutive addresses are unused.
+func (hd *HDBackend) Restore(isUsed func(address.Address) (bool, error)) ([]address.Address, error) {
+	hd.lk.Lock()
+	defer hd.lk.Unlock()
+
+	seed, err := hd.getSeed()
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []address.Address
+	used := len(hd.derived)
+	for i := len(hd.derived); i < used+hdGapLimit; i++ {
+		ki, err := deriveKeyInfo(seed.Seed, uint32(i))
+		if err != nil {
+			return nil, err
+		}
+		addr, err := ki.Address()
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, addr)
+
+		ok, err := isUsed(addr)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			used = i + 1
+		}
+	}
+
+	restored := candidates[:used-len(hd.derived)]
+	derived := append(append([]address.Address{}, hd.derived...), restored...)
+	if err := hd.putIndex(derived, hd.imported); err != nil {
+		return nil, err
+	}
+	hd.derived = derived
+
+	return restored, nil
+}
+
+// NewAddress derives the address with the next index.
+func (hd *HDBackend) NewAddress() (address.Address, error) {
+	hd.lk.Lock()
+	defer hd.lk.Unlock()
+
+	seed, err := hd.getSeed()
+	if err != nil {
+		return address.Address{}, err
+	}
+
+	ki, err := deriveKeyInfo(seed.Seed, uint32(len(hd.derived)))
+	if err != nil {
+		return address.Address{}, err
+	}
+	addr, err := ki.Address()
+	if err != nil {
+		return address.Address{}, err
+	}
+
+	derived := append(append([]address.Address{}, hd.derived...), addr)
+	if err := hd.putIndex(derived, hd.imported); err != nil {
+		return address.Address{}, err
+	}
+	hd.derived = derived
+
+	return addr, nil
+}
+
+// ImportKey stores a key which is not derived from the seed along with it.
+func (hd *HDBackend) ImportKey(ki *types.KeyInfo) error {
+	hd.lk.Lock()
+	defer hd.lk.Unlock()
+
+	addr, err := ki.Address()
+	if err != nil {
+		return err
+	}
+
+	seed, err := hd.getSeed()
+	if err != nil {
+		return err
+	}
+	seed.Imported = append(seed.Imported, ki)
+	if err := hd.putSeed(seed); err != nil {
+		return err
+	}
+
+	imported := make(map[address.Address]struct{}, len(hd.imported)+1)
+	for a := range hd.imported {
+		imported[a] = struct{}{}
+	}
+	imported[addr] = struct{}{}
+	if err := hd.putIndex(hd.derived, imported); err != nil {
+		return err
+	}
+	hd.imported = imported
+
+	return nil
+}
+
+// Addresses returns a list of all addresses that are stored in this backend.
+func (hd *HDBackend) Addresses() []address.Address {
+	hd.lk.RLock()
+	defer hd.lk.RUnlock()
+
+	cpy := append([]address.Address{}, hd.derived...)
+	for addr := range hd.imported {
+		cpy = append(cpy, addr)
+	}
+	return cpy
+}
+
+// HasAddress checks if the passed in address is stored in this backend.
+// Safe for concurrent access.
+func (hd *HDBackend) HasAddress(addr address.Address) bool {
+	hd.lk.RLock()
+	defer hd.lk.RUnlock()
+
+	_, ok := hd.imported[addr]
+	return ok || hd.derivedIndex(addr) >= 0
+}
+
+// SignBytes cryptographically signs `data` using the private key of `addr`.
+func (hd *HDBackend) SignBytes(data []byte, addr address.Address) (types.Signature, error) {
+	ki, err := hd.GetKeyInfo(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return signWithKeyInfo(ki, data)
+}
+
+// Verify cryptographically verifies that 'sig' is the signed hash of 'data' with
+// the public key `pk`.
+func (hd *HDBackend) Verify(data []byte, pk []byte, sig types.Signature) (bool, error) {
+	return wutil.Verify(pk, data, sig)
+}
+
+// GetKeyInfo will return the private & public keys associated with address `addr`
+// iff backend contains the addr.
+func (hd *HDBackend) GetKeyInfo(addr address.Address) (*types.KeyInfo, error) {
+	hd.lk.RLock()
+	defer hd.lk.RUnlock()
+
+	seed, err := hd.getSeed()
+	if err != nil {
+		return nil, err
+	}
+
+	if i := hd.derivedIndex(addr); i >= 0 {
+		return deriveKeyInfo(seed.Seed, uint32(i))
+	}
+
+	for _, ki := range seed.Imported {
+		a, err := ki.Address()
+		if err != nil {
+			return nil, err
+		}
+		if a == addr {
+			return ki, nil
+		}
+	}
+
+	return nil, errors.New("backend does not contain address")
+}
+
+func (hd *HDBackend) derivedIndex(addr address.Address) int {
+	for i, a := range hd.derived {
+		if a == addr {
+			return i
+		}
+	}
+	return -1
+}
+
+func (hd *HDBackend) getSeed() (*hdSeed, error) {
+	seedb, err := hd.store.getSecret(hdSeedKey)
+	if err == ds.ErrNotFound {
+		return nil, ErrHDNotInitialized
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var seed hdSeed
+	if err := cbor.DecodeInto(seedb, &seed); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal HD wallet seed")
+	}
+	return &seed, nil
+}
+
+func (hd *HDBackend) putSeed(seed *hdSeed) error {
+	seedb, err := cbor.DumpObject(seed)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal HD wallet seed")
+	}
+	return hd.store.putSecret(hdSeedKey, seedb)
+}
+
+func (hd *HDBackend) putIndex(derived []address.Address, imported map[address.Address]struct{}) error {
+	var index hdIndex
+	for _, addr := range derived {
+		index.Derived = append(index.Derived, addr.String())
+	}
+	for addr := range imported {
+		index.Imported = append(index.Imported, addr.String())
+	}
+
+	indexb, err := cbor.DumpObject(index)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal HD wallet index")
+	}
+	if err := hd.store.ds.Put(hdIndexKey, indexb); err != nil {
+		return errors.Wrap(err, "failed to store HD wallet index")
+	}
+	return nil
+}
+
+// deriveKeyInfo derives the secp256k1 key with index i along the BIP44 path
+// m/44'/461'/0'/0/i.
+func deriveKeyInfo(seed []byte, i uint32) (*types.KeyInfo, error) {
+	key, err := bip32.NewMasterKey(seed)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to derive master key")
+	}
+
+	path := []uint32{
+		bip32.FirstHardenedChild + 44,
+		bip32.FirstHardenedChild + hdCoinType,
+		bip32.FirstHardenedChild,
+		0,
+		i,
+	}
+	for _, child := range path {
+		key, err = key.NewChildKey(child)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to derive child key")
+		}
+	}
+
+	return &types.KeyInfo{
+		PrivateKey: key.Key,
+		Curve:      SECP256K1,
+	}, nil
+}
+
+// isReservedKey returns true for datastore keys of the wallet datastore
+// which do not hold the key of an address of the DSBackend.
+func isReservedKey(key string) bool {
+	return key == keystoreMetaKey.String() || ds.NewKey(key).IsDescendantOf(hdWalletPrefix)
+}
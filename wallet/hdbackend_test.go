@@ -0,0 +1,182 @@
+package wallet
+
+import (
+	"testing"
+
+	"gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/repo"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func newTestHDBackend(t *testing.T, ds repo.Datastore) (*DSBackend, *HDBackend) {
+	dsb, err := NewDSBackend(ds)
+	require.NoError(t, err)
+	hd, err := NewHDBackend(dsb)
+	require.NoError(t, err)
+	return dsb, hd
+}
+
+func TestHDBackendDeterministic(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	_, hd1 := newTestHDBackend(t, datastore.NewMapDatastore())
+	_, hd2 := newTestHDBackend(t, datastore.NewMapDatastore())
+
+	_, err := hd1.NewAddress()
+	assert.Equal(ErrHDNotInitialized, err)
+
+	require.NoError(hd1.Init(testMnemonic))
+	require.NoError(hd2.Init(testMnemonic))
+	assert.Equal(ErrHDAlreadyInitialized, hd1.Init(testMnemonic))
+
+	a1, err := hd1.NewAddress()
+	require.NoError(err)
+	b1, err := hd1.NewAddress()
+	require.NoError(err)
+	a2, err := hd2.NewAddress()
+	require.NoError(err)
+
+	assert.NotEqual(a1, b1)
+	assert.Equal(a1, a2)
+	assert.True(hd1.HasAddress(b1))
+	assert.False(hd2.HasAddress(b1))
+
+	ki, err := hd1.GetKeyInfo(b1)
+	require.NoError(err)
+	addr, err := ki.Address()
+	require.NoError(err)
+	assert.Equal(b1, addr)
+}
+
+func TestHDBackendInvalidMnemonic(t *testing.T) {
+	_, hd := newTestHDBackend(t, datastore.NewMapDatastore())
+	assert.Equal(t, ErrInvalidMnemonic, hd.Init("abandon abandon abandon"))
+	assert.False(t, hd.IsInitialized())
+}
+
+func TestHDBackendPersistence(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ds := datastore.NewMapDatastore()
+	dsb, hd := newTestHDBackend(t, ds)
+	require.NoError(hd.Init(testMnemonic))
+
+	addr, err := hd.NewAddress()
+	require.NoError(err)
+	imported := types.MustGenerateKeyInfo(1, types.GenerateKeyInfoSeed())[0]
+	require.NoError(hd.ImportKey(&imported))
+	importedAddr, err := imported.Address()
+	require.NoError(err)
+
+	t.Log("the hd records are not mistaken for addresses of the ds backend")
+	assert.Len(dsb.Addresses(), 0)
+
+	dsb2, hd2 := newTestHDBackend(t, ds)
+	assert.Len(dsb2.Addresses(), 0)
+	assert.Len(hd2.Addresses(), 2)
+	assert.True(hd2.HasAddress(addr))
+	assert.True(hd2.HasAddress(importedAddr))
+
+	ki, err := hd2.GetKeyInfo(importedAddr)
+	require.NoError(err)
+	assert.Equal(&imported, ki)
+}
+
+func TestHDBackendRestore(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	_, hd := newTestHDBackend(t, datastore.NewMapDatastore())
+	require.NoError(hd.Init(testMnemonic))
+
+	var derived []address.Address
+	for i := 0; i < 4; i++ {
+		addr, err := hd.NewAddress()
+		require.NoError(err)
+		derived = append(derived, addr)
+	}
+
+	_, restored := newTestHDBackend(t, datastore.NewMapDatastore())
+	require.NoError(restored.Init(testMnemonic))
+
+	// only the first and the fourth address are used
+	addrs, err := restored.Restore(func(addr address.Address) (bool, error) {
+		return addr == derived[0] || addr == derived[3], nil
+	})
+	require.NoError(err)
+	assert.Equal(derived, addrs)
+	assert.Equal(derived, restored.Addresses())
+
+	t.Log("the next address follows the restored ones")
+	next, err := restored.NewAddress()
+	require.NoError(err)
+	assert.NotContains(derived, next)
+}
+
+func TestHDBackendEncryption(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dsb, hd := newTestHDBackend(t, datastore.NewMapDatastore())
+	require.NoError(hd.Init(testMnemonic))
+	addr, err := hd.NewAddress()
+	require.NoError(err)
+
+	require.NoError(dsb.Encrypt([]byte("passphrase")))
+	dsb.Lock()
+
+	t.Log("addresses are listed but keys are unavailable while locked")
+	assert.True(hd.HasAddress(addr))
+	_, err = hd.SignBytes([]byte("data"), addr)
+	assert.Equal(ErrLocked, err)
+	_, err = hd.NewAddress()
+	assert.Equal(ErrLocked, err)
+
+	require.NoError(dsb.Unlock([]byte("passphrase"), 0))
+	sig, err := hd.SignBytes([]byte("data"), addr)
+	require.NoError(err)
+
+	ki, err := hd.GetKeyInfo(addr)
+	require.NoError(err)
+	valid, err := hd.Verify([]byte("data"), ki.PublicKey(), sig)
+	require.NoError(err)
+	assert.True(valid)
+	assert.True(types.IsValidSignature([]byte("data"), addr, sig))
+}
+
+func TestKeyImporter(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dsb, hd := newTestHDBackend(t, datastore.NewMapDatastore())
+	w := New(dsb, hd)
+
+	other, err := NewDSBackend(datastore.NewMapDatastore())
+	require.NoError(err)
+	addr, err := other.NewAddress()
+	require.NoError(err)
+	ki, err := other.GetKeyInfo(addr)
+	require.NoError(err)
+
+	t.Log("keys are imported into the datastore backend until the hd backend is initialized")
+	imp, err := KeyImporter(w)
+	require.NoError(err)
+	assert.Equal(dsb, imp)
+
+	require.NoError(hd.Init(testMnemonic))
+	imp, err = KeyImporter(w)
+	require.NoError(err)
+	assert.Equal(hd, imp)
+
+	require.NoError(imp.ImportKey(ki))
+	assert.True(hd.HasAddress(addr))
+}
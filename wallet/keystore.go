@@ -0,0 +1,131 @@
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+
+	cbor "gx/ipfs/QmRoARq3nkUb13HSKZGepCZSWe5GrVPwx7xURJGZ7KWv9V/go-ipld-cbor"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+	ds "gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+func init() {
+	cbor.RegisterCborType(keystoreMeta{})
+}
+
+// keystoreMetaKey is the datastore key of the metadata of an encrypted
+// keystore. A wallet datastore without it holds its keys in plain text.
+var keystoreMetaKey = ds.NewKey("/keystore")
+
+// keystoreCheck is sealed with the key derived from the passphrase, so that
+// a wrong passphrase is detected even if the keystore holds no keys.
+var keystoreCheck = []byte("filecoin wallet keystore")
+
+// scrypt parameters used to derive the keystore key from the passphrase.
+var (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+const (
+	keystoreKeyLength  = 32
+	keystoreSaltLength = 32
+)
+
+// keystoreMeta is the metadata of an encrypted keystore.
+type keystoreMeta struct {
+	// Salt is the scrypt salt the keystore key is derived with.
+	Salt []byte
+	// Check is keystoreCheck sealed with the keystore key.
+	Check []byte
+}
+
+// newKeystoreMeta creates the metadata of a new encrypted keystore and
+// returns it with the keystore key derived from passphrase.
+func newKeystoreMeta(passphrase []byte) (*keystoreMeta, []byte, error) {
+	salt := make([]byte, keystoreSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to generate salt")
+	}
+
+	key, err := deriveKeystoreKey(passphrase, salt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	check, err := seal(key, keystoreCheck)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &keystoreMeta{Salt: salt, Check: check}, key, nil
+}
+
+// unlock derives the keystore key from passphrase and returns it if the
+// passphrase is the one the keystore was encrypted with.
+func (meta *keystoreMeta) unlock(passphrase []byte) ([]byte, error) {
+	key, err := deriveKeystoreKey(passphrase, meta.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := open(key, meta.Check); err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return key, nil
+}
+
+func deriveKeystoreKey(passphrase, salt []byte) ([]byte, error) {
+	key, err := scrypt.Key(passphrase, salt, scryptN, scryptR, scryptP, keystoreKeyLength)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to derive keystore key")
+	}
+	return key, nil
+}
+
+// seal encrypts and authenticates plaintext with AES-GCM. The random nonce is
+// prepended to the returned ciphertext.
+func seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "failed to generate nonce")
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// open decrypts a ciphertext created by seal.
+func open(key, sealed []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("sealed data too short")
+	}
+
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt")
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create cipher")
+	}
+	return cipher.NewGCM(block)
+}
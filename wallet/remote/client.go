@@ -0,0 +1,117 @@
+package remote
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"sync"
+	"time"
+
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// DefaultTimeout is the time the client waits for a response of the signer.
+var DefaultTimeout = 30 * time.Second
+
+// Client sends requests to a signer listening on a unix socket. It connects
+// lazily and reconnects after a failed request, so that the signer can be
+// restarted while the node is running.
+// Safe for concurrent access.
+type Client struct {
+	path    string
+	timeout time.Duration
+
+	lk   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// NewClient returns a client of the signer listening at socket path.
+func NewClient(path string) *Client {
+	return &Client{
+		path:    path,
+		timeout: DefaultTimeout,
+	}
+}
+
+// Keys returns the addresses of the signer with their public keys.
+func (c *Client) Keys() ([]Key, error) {
+	resp, err := c.do(&Request{Method: MethodKeys})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Keys, nil
+}
+
+// Sign asks the signer to sign data with the key of addr.
+func (c *Client) Sign(addr address.Address, data []byte) (types.Signature, error) {
+	resp, err := c.do(&Request{Method: MethodSign, Address: addr, Data: data})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Signature, nil
+}
+
+// Close closes the connection to the signer, if any.
+func (c *Client) Close() error {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+func (c *Client) do(req *Request) (*Response, error) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	if c.conn == nil {
+		conn, err := net.DialTimeout("unix", c.path, c.timeout)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to connect to signer at %s", c.path)
+		}
+		c.conn = conn
+		c.r = bufio.NewReader(conn)
+	}
+
+	resp, err := c.roundTrip(req)
+	if err != nil {
+		// The connection is in an unknown state, start over with a new one.
+		c.conn.Close() // nolint: errcheck
+		c.conn = nil
+		return nil, err
+	}
+
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	return resp, nil
+}
+
+func (c *Client) roundTrip(req *Request) (*Response, error) {
+	if err := c.conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return nil, errors.Wrap(err, "failed to set deadline")
+	}
+
+	if err := json.NewEncoder(c.conn).Encode(req); err != nil {
+		return nil, errors.Wrap(err, "failed to send request to signer")
+	}
+
+	line, err := c.r.ReadBytes('\n')
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read response of signer")
+	}
+
+	var resp Response
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return nil, errors.Wrap(err, "failed to decode response of signer")
+	}
+	return &resp, nil
+}
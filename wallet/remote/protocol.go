@@ -0,0 +1,53 @@
+// Package remote implements the protocol between a filecoin node and an
+// external signer process holding its keys.
+//
+// The signer listens on a local (unix) socket. The node sends requests on a
+// connection one at a time and reads one response for each, both encoded as
+// a single line of JSON:
+//
+//	{"method":"keys","address":""}
+//	{"keys":[{"address":"fcq...","publicKey":"<base64>"}]}
+//
+//	{"method":"sign","address":"fcq...","data":"<base64>"}
+//	{"signature":"<base64>"}
+//
+// Fields a method does not use are empty or omitted. A request which fails
+// gets a response with only the error set:
+//
+//	{"error":"unknown address"}
+//
+// The "keys" method lists the addresses of the signer with their public keys.
+// The "sign" method signs data with the key of address, with the signature
+// scheme of the key type, exactly as a local wallet backend would.
+package remote
+
+import (
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// Methods of the protocol.
+const (
+	MethodKeys = "keys"
+	MethodSign = "sign"
+)
+
+// Request is a request of the node to the signer.
+type Request struct {
+	Method  string          `json:"method"`
+	Address address.Address `json:"address"`
+	Data    []byte          `json:"data,omitempty"`
+}
+
+// Response is the response of the signer to a Request.
+type Response struct {
+	Keys      []Key           `json:"keys,omitempty"`
+	Signature types.Signature `json:"signature,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// Key is an address of the signer with its public key.
+type Key struct {
+	Address   address.Address `json:"address"`
+	PublicKey []byte          `json:"publicKey"`
+}
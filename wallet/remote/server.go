@@ -0,0 +1,78 @@
+package remote
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+
+	logging "gx/ipfs/QmcuXC5cxs79ro2cUuHs4HQ2bkDLJUYokwL8aivcX6HW3C/go-log"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+var log = logging.Logger("wallet/remote")
+
+// Signer holds the keys served by Serve.
+type Signer interface {
+	Keys() ([]Key, error)
+	Sign(addr address.Address, data []byte) (types.Signature, error)
+}
+
+// Serve answers the requests of the connections accepted on l with signer,
+// until l is closed.
+func Serve(l net.Listener, signer Signer) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go serveConn(conn, signer)
+	}
+}
+
+func serveConn(conn net.Conn, signer Signer) {
+	defer conn.Close() // nolint: errcheck
+
+	r := bufio.NewReader(conn)
+	enc := json.NewEncoder(conn)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			return
+		}
+
+		var resp *Response
+		var req Request
+		if err := json.Unmarshal(line, &req); err != nil {
+			resp = &Response{Error: fmt.Sprintf("invalid request: %s", err)}
+		} else {
+			resp = handle(&req, signer)
+		}
+
+		if err := enc.Encode(resp); err != nil {
+			log.Warningf("failed to send response: %s", err)
+			return
+		}
+	}
+}
+
+func handle(req *Request, signer Signer) *Response {
+	switch req.Method {
+	case MethodKeys:
+		keys, err := signer.Keys()
+		if err != nil {
+			return &Response{Error: err.Error()}
+		}
+		return &Response{Keys: keys}
+	case MethodSign:
+		sig, err := signer.Sign(req.Address, req.Data)
+		if err != nil {
+			return &Response{Error: err.Error()}
+		}
+		return &Response{Signature: sig}
+	default:
+		return &Response{Error: fmt.Sprintf("unknown method %q", req.Method)}
+	}
+}
@@ -0,0 +1,189 @@
+package wallet
+
+import (
+	"bytes"
+	"reflect"
+	"sync"
+	"time"
+
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+	logging "gx/ipfs/QmcuXC5cxs79ro2cUuHs4HQ2bkDLJUYokwL8aivcX6HW3C/go-log"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/wallet/remote"
+	wutil "github.com/filecoin-project/go-filecoin/wallet/util"
+)
+
+var log = logging.Logger("wallet")
+
+// ErrKeyNotExportable is returned when asking a remote backend for a private key.
+var ErrKeyNotExportable = errors.New("private keys of a remote signer cannot be exported")
+
+// remoteRefreshInterval is the minimum time between two lookups of an unknown
+// address refreshing the keys of the signer.
+const remoteRefreshInterval = 5 * time.Second
+
+// RemoteBackendType is the reflect type of the RemoteBackend.
+var RemoteBackendType = reflect.TypeOf(&RemoteBackend{})
+
+// RemoteBackend is a wallet backend whose keys are held by an external signer
+// process, see package remote for the protocol. The node never sees the
+// private keys; it only caches the addresses of the signer with their public
+// keys. The cache is refreshed when an address which is not in it is looked
+// up, so that the signer may be started after the node and keys may be added
+// to it later.
+type RemoteBackend struct {
+	lk sync.RWMutex
+
+	client *remote.Client
+
+	// publicKeys caches the public keys of the addresses of the signer.
+	publicKeys map[address.Address][]byte
+	// lastRefresh is the time of the last attempt to refresh publicKeys.
+	lastRefresh time.Time
+}
+
+var _ Backend = (*RemoteBackend)(nil)
+var _ PublicKeyer = (*RemoteBackend)(nil)
+
+// NewRemoteBackend constructs a backend of the signer listening at socket
+// path, and lists its addresses. A signer which is not available yet is
+// tolerated: its addresses are listed once it is.
+func NewRemoteBackend(path string) (*RemoteBackend, error) {
+	backend := &RemoteBackend{
+		client:     remote.NewClient(path),
+		publicKeys: make(map[address.Address][]byte),
+	}
+
+	if err := backend.Refresh(); err != nil {
+		log.Warningf("remote signer is not available: %s", err)
+	}
+
+	return backend, nil
+}
+
+// Refresh lists the addresses of the signer again, for keys which were added
+// to it after the backend was constructed. It fails if the signer returns a
+// public key which is not the one of its address.
+func (backend *RemoteBackend) Refresh() error {
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+
+	return backend.refreshLocked()
+}
+
+func (backend *RemoteBackend) refreshLocked() error {
+	backend.lastRefresh = time.Now()
+
+	keys, err := backend.client.Keys()
+	if err != nil {
+		return errors.Wrap(err, "failed to list keys of signer")
+	}
+
+	publicKeys := make(map[address.Address][]byte, len(keys))
+	for _, k := range keys {
+		if err := checkPublicKey(k.Address, k.PublicKey); err != nil {
+			return err
+		}
+		publicKeys[k.Address] = k.PublicKey
+	}
+	backend.publicKeys = publicKeys
+
+	return nil
+}
+
+// lookup returns the cached public key of addr, refreshing the cache first
+// if addr is not in it and it was not refreshed recently.
+func (backend *RemoteBackend) lookup(addr address.Address) ([]byte, bool) {
+	backend.lk.RLock()
+	pk, ok := backend.publicKeys[addr]
+	backend.lk.RUnlock()
+	if ok {
+		return pk, true
+	}
+
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+
+	if time.Since(backend.lastRefresh) >= remoteRefreshInterval {
+		if err := backend.refreshLocked(); err != nil {
+			log.Warningf("failed to refresh keys of remote signer: %s", err)
+		}
+	}
+	pk, ok = backend.publicKeys[addr]
+	return pk, ok
+}
+
+// checkPublicKey returns an error unless pk is the public key of addr.
+func checkPublicKey(addr address.Address, pk []byte) error {
+	if !bytes.Equal(address.Hash(pk), addr.Hash()) {
+		return errors.Errorf("signer returned a public key which is not the one of address %s", addr)
+	}
+	return nil
+}
+
+// Addresses returns a list of all addresses of the signer.
+func (backend *RemoteBackend) Addresses() []address.Address {
+	backend.lk.RLock()
+	defer backend.lk.RUnlock()
+
+	var cpy []address.Address
+	for addr := range backend.publicKeys {
+		cpy = append(cpy, addr)
+	}
+	return cpy
+}
+
+// HasAddress checks if the passed in address is held by the signer.
+// Safe for concurrent access.
+func (backend *RemoteBackend) HasAddress(addr address.Address) bool {
+	_, ok := backend.lookup(addr)
+	return ok
+}
+
+// PublicKey returns the public key of addr, which is checked to be the one
+// of addr.
+func (backend *RemoteBackend) PublicKey(addr address.Address) ([]byte, error) {
+	pk, ok := backend.lookup(addr)
+	if !ok {
+		return nil, errors.New("backend does not contain address")
+	}
+	if err := checkPublicKey(addr, pk); err != nil {
+		return nil, err
+	}
+	return pk, nil
+}
+
+// SignBytes asks the signer to sign `data` with the private key of `addr`.
+// The signature is checked against the cached public key, so that a
+// misbehaving signer is noticed before its signatures are used.
+func (backend *RemoteBackend) SignBytes(data []byte, addr address.Address) (types.Signature, error) {
+	pk, err := backend.PublicKey(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := backend.client.Sign(addr, data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "signer failed to sign with address %s", addr)
+	}
+
+	valid, err := wutil.Verify(pk, data, sig)
+	if err != nil || !valid {
+		return nil, errors.Errorf("signer returned an invalid signature for address %s", addr)
+	}
+
+	return sig, nil
+}
+
+// Verify cryptographically verifies that 'sig' is the signed hash of 'data' with
+// the public key `pk`.
+func (backend *RemoteBackend) Verify(data []byte, pk []byte, sig types.Signature) (bool, error) {
+	return wutil.Verify(pk, data, sig)
+}
+
+// GetKeyInfo always fails, the private keys never leave the signer.
+func (backend *RemoteBackend) GetKeyInfo(addr address.Address) (*types.KeyInfo, error) {
+	return nil, ErrKeyNotExportable
+}
@@ -0,0 +1,156 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/wallet/remote"
+)
+
+// testSigner serves the keys of a DSBackend, optionally signing with the
+// wrong key or listing the wrong public key.
+type testSigner struct {
+	backend *DSBackend
+	forge   address.Address
+}
+
+func (s *testSigner) Keys() ([]remote.Key, error) {
+	var keys []remote.Key
+	for _, addr := range s.backend.Addresses() {
+		pkAddr := addr
+		if !s.forge.Empty() {
+			pkAddr = s.forge
+		}
+		pk, err := PublicKey(s.backend, pkAddr)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, remote.Key{Address: addr, PublicKey: pk})
+	}
+	return keys, nil
+}
+
+func (s *testSigner) Sign(addr address.Address, data []byte) (types.Signature, error) {
+	if !s.forge.Empty() {
+		addr = s.forge
+	}
+	return s.backend.SignBytes(data, addr)
+}
+
+func startTestSigner(t *testing.T, signer remote.Signer) (string, func()) {
+	dir, err := ioutil.TempDir("", "remote-signer")
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "signer.sock")
+	l, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	go remote.Serve(l, signer) // nolint: errcheck
+
+	return path, func() {
+		l.Close()         // nolint: errcheck
+		os.RemoveAll(dir) // nolint: errcheck
+	}
+}
+
+func TestRemoteBackend(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dsb, err := NewDSBackend(datastore.NewMapDatastore())
+	require.NoError(err)
+	secpAddr, err := dsb.NewAddressOfType(SECP256K1)
+	require.NoError(err)
+	blsAddr, err := dsb.NewAddressOfType(BLS)
+	require.NoError(err)
+
+	signer := &testSigner{backend: dsb}
+	path, stop := startTestSigner(t, signer)
+	defer stop()
+
+	backend, err := NewRemoteBackend(path)
+	require.NoError(err)
+
+	t.Log("addresses and public keys are listed from the signer")
+	assert.Len(backend.Addresses(), 2)
+	assert.True(backend.HasAddress(secpAddr))
+	assert.True(backend.HasAddress(blsAddr))
+
+	_, err = backend.GetKeyInfo(secpAddr)
+	assert.Equal(ErrKeyNotExportable, err)
+
+	for _, addr := range []address.Address{secpAddr, blsAddr} {
+		sig, err := backend.SignBytes([]byte("data"), addr)
+		require.NoError(err)
+		assert.True(types.IsValidSignature([]byte("data"), addr, sig))
+	}
+
+	_, err = backend.SignBytes([]byte("data"), address.NewForTestGetter()())
+	assert.Error(err)
+
+	t.Log("keys added to the signer are listed after a refresh")
+	newAddr, err := dsb.NewAddress()
+	require.NoError(err)
+	assert.False(backend.HasAddress(newAddr))
+	require.NoError(backend.Refresh())
+	assert.True(backend.HasAddress(newAddr))
+
+	t.Log("signatures of the wrong key are rejected")
+	signer.forge = newAddr
+	_, err = backend.SignBytes([]byte("data"), secpAddr)
+	assert.Error(err)
+
+	t.Log("public keys which are not the ones of their address are rejected")
+	assert.Error(backend.Refresh())
+	forgedBackend, err := NewRemoteBackend(path)
+	require.NoError(err)
+	assert.False(forgedBackend.HasAddress(secpAddr))
+	_, err = forgedBackend.PublicKey(secpAddr)
+	assert.Error(err)
+}
+
+// The signer may be started after the backend, whose addresses are then
+// listed when they are looked up.
+func TestRemoteBackendLateSigner(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "remote-signer")
+	require.NoError(err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+	path := filepath.Join(dir, "signer.sock")
+
+	backend, err := NewRemoteBackend(path)
+	require.NoError(err)
+	assert.Empty(backend.Addresses())
+
+	dsb, err := NewDSBackend(datastore.NewMapDatastore())
+	require.NoError(err)
+	addr, err := dsb.NewAddress()
+	require.NoError(err)
+
+	l, err := net.Listen("unix", path)
+	require.NoError(err)
+	defer l.Close() // nolint: errcheck
+
+	go remote.Serve(l, &testSigner{backend: dsb}) // nolint: errcheck
+
+	t.Log("unknown addresses only refresh the keys once per interval")
+	assert.False(backend.HasAddress(addr))
+
+	backend.lastRefresh = time.Time{}
+	assert.True(backend.HasAddress(addr))
+	sig, err := backend.SignBytes([]byte("data"), addr)
+	require.NoError(err)
+	assert.True(types.IsValidSignature([]byte("data"), addr, sig))
+}
@@ -174,3 +174,57 @@ func TestSignedMessageCorrupted(t *testing.T) {
 	smsg.Message.Nonce = types.Uint64(uint64(42))
 	assert.False(smsg.VerifySignature())
 }
+
+/* Test BLS signatures */
+
+func requireBLSSignerAddr(require *require.Assertions) (*DSBackend, address.Address) {
+	ds := datastore.NewMapDatastore()
+	fs, err := NewDSBackend(ds)
+	require.NoError(err)
+
+	addr, err := fs.NewAddressOfType(BLS)
+	require.NoError(err)
+	require.True(addr.IsBLS())
+	return fs, addr
+}
+
+func TestBLSSignature(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	fs, addr := requireBLSSignerAddr(require)
+
+	data := []byte("THESE BYTES WILL BE SIGNED")
+	sig, err := fs.SignBytes(data, addr)
+	require.NoError(err)
+
+	assert.True(types.IsValidSignature(data, addr, sig))
+	assert.False(types.IsValidSignature([]byte("THESE BYTEZ WILL BE SIGNED"), addr, sig))
+	assert.False(types.IsValidSignature(data, addr, nil))
+
+	// a valid signature by another BLS address does not verify
+	_, otherAddr := requireBLSSignerAddr(require)
+	assert.False(types.IsValidSignature(data, otherAddr, sig))
+
+	sig[len(sig)-1] = sig[len(sig)-1] ^ 0xFF
+	assert.False(types.IsValidSignature(data, addr, sig))
+}
+
+func TestBLSSignedMessage(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	fs, addr := requireBLSSignerAddr(require)
+
+	msg := types.NewMessage(addr, addr, 1, nil, "", nil)
+	smsg, err := types.NewSignedMessage(*msg, fs, types.NewGasPrice(0), types.NewGasUnits(0))
+	require.NoError(err)
+	assert.True(smsg.VerifySignature())
+
+	recovered, err := smsg.RecoverAddress(New(fs))
+	require.NoError(err)
+	assert.Equal(addr, recovered)
+
+	smsg.Message.Nonce = types.Uint64(uint64(42))
+	assert.False(smsg.VerifySignature())
+}
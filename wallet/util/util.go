@@ -1,14 +1,20 @@
 package walletutil
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 
 	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
 	"gx/ipfs/QmZp3eKdYQHHAneECmeK6HhiMwTPufmjC8DuuaGKv3unvx/blake2b-simd"
 
+	"github.com/filecoin-project/go-filecoin/bls-signatures"
 	"github.com/filecoin-project/go-filecoin/crypto"
 )
 
+// BLSSignatureLength is the length of the signatures made with BLS keys:
+// the public key of the signer followed by the BLS signature.
+const BLSSignatureLength = bls.PublicKeyBytes + bls.SignatureBytes
+
 // Sign cryptographically signs `data` using the private key `priv`.
 func Sign(priv *ecdsa.PrivateKey, data []byte) ([]byte, error) {
 	hash := blake2b.Sum256(data)
@@ -21,9 +27,51 @@ func Sign(priv *ecdsa.PrivateKey, data []byte) ([]byte, error) {
 	return sig, nil
 }
 
+// SignBLS cryptographically signs `data` using the BLS private key `priv`.
+// The returned signature is prefixed with the public key of `priv`, so that
+// it can be verified against an address.
+func SignBLS(priv bls.PrivateKey, data []byte) []byte {
+	pub := bls.PrivateKeyPublicKey(priv)
+	sig := bls.PrivateKeySign(priv, data)
+	return append(pub[:], sig[:]...)
+}
+
+// SplitBLSSignature splits a signature made by SignBLS into the public key
+// and the BLS signature.
+func SplitBLSSignature(signature []byte) (bls.PublicKey, bls.Signature, error) {
+	var pub bls.PublicKey
+	var sig bls.Signature
+	if len(signature) != BLSSignatureLength {
+		return pub, sig, errors.Errorf("invalid BLS signature length %d", len(signature))
+	}
+
+	copy(pub[:], signature[:bls.PublicKeyBytes])
+	copy(sig[:], signature[bls.PublicKeyBytes:])
+	return pub, sig, nil
+}
+
+// VerifyBLS cryptographically verifies that 'signature', as made by SignBLS,
+// is the BLS signature of 'data' with the public key `pk`.
+func VerifyBLS(pk, data, signature []byte) (bool, error) {
+	pub, sig, err := SplitBLSSignature(signature)
+	if err != nil {
+		return false, err
+	}
+	if !bytes.Equal(pk, pub[:]) {
+		return false, nil
+	}
+
+	digest := bls.Hash(data)
+	return bls.Verify(sig, []bls.Digest{digest}, []bls.PublicKey{pub}), nil
+}
+
 // Verify cryptographically verifies that 'sig' is the signed hash of 'data' with
-// the public key `pk`.
+// the public key `pk`. BLS public keys are verified with VerifyBLS.
 func Verify(pk, data, signature []byte) (bool, error) {
+	if len(pk) == bls.PublicKeyBytes {
+		return VerifyBLS(pk, data, signature)
+	}
+
 	hash := blake2b.Sum256(data)
 	// remove recovery id
 	sig := signature[:len(signature)-1]
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"reflect"
 	"sync"
+	"time"
 
 	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
 
@@ -15,6 +16,16 @@ import (
 var (
 	// ErrUnknownAddress is returned when the given address is not stored in this wallet.
 	ErrUnknownAddress = errors.New("unknown address")
+	// ErrLocked is returned when keys are needed while the wallet is locked.
+	ErrLocked = errors.New("wallet is locked")
+	// ErrWrongPassphrase is returned when unlocking with a wrong passphrase.
+	ErrWrongPassphrase = errors.New("wrong passphrase")
+	// ErrNotEncrypted is returned when unlocking a wallet that is not encrypted.
+	ErrNotEncrypted = errors.New("wallet is not encrypted")
+	// ErrAlreadyEncrypted is returned when encrypting a wallet twice.
+	ErrAlreadyEncrypted = errors.New("wallet is already encrypted")
+	// ErrEmptyPassphrase is returned when encrypting a wallet without a passphrase.
+	ErrEmptyPassphrase = errors.New("passphrase must not be empty")
 )
 
 // Wallet manages the locally stored addresses.
@@ -122,11 +133,97 @@ func (w *Wallet) Ecrecover(data []byte, sig types.Signature) ([]byte, error) {
 
 // NewAddress creates a new account address on the default wallet backend.
 func NewAddress(w *Wallet) (address.Address, error) {
-	backends := w.Backends(DSBackendType)
+	return NewAddressOfType(w, SECP256K1)
+}
+
+// NewAddressOfType creates a new account address with a key of the given type.
+// Secp256k1 keys are derived from the seed of the HD wallet backend if it is
+// initialized, other keys are created on the default wallet backend.
+func NewAddressOfType(w *Wallet, keyType string) (address.Address, error) {
+	if keyType == SECP256K1 {
+		if hd, err := hdBackend(w); err == nil && hd.IsInitialized() {
+			return hd.NewAddress()
+		}
+	}
+
+	backend, err := defaultBackend(w)
+	if err != nil {
+		return address.Address{}, err
+	}
+	return backend.NewAddressOfType(keyType)
+}
+
+// Encrypt encrypts the keys of the default wallet backend with a key derived
+// from passphrase.
+func Encrypt(w *Wallet, passphrase []byte) error {
+	backend, err := defaultBackend(w)
+	if err != nil {
+		return err
+	}
+	return backend.Encrypt(passphrase)
+}
+
+// Unlock unlocks the default wallet backend. If timeout is not zero, the
+// backend locks itself again once it has passed.
+func Unlock(w *Wallet, passphrase []byte, timeout time.Duration) error {
+	backend, err := defaultBackend(w)
+	if err != nil {
+		return err
+	}
+	return backend.Unlock(passphrase, timeout)
+}
+
+// Lock locks the default wallet backend.
+func Lock(w *Wallet) error {
+	backend, err := defaultBackend(w)
+	if err != nil {
+		return err
+	}
+	backend.Lock()
+	return nil
+}
+
+// InitHD initializes the HD wallet backend with the seed of mnemonic.
+func InitHD(w *Wallet, mnemonic string) error {
+	hd, err := hdBackend(w)
+	if err != nil {
+		return err
+	}
+	return hd.Init(mnemonic)
+}
+
+// RestoreHD adds the addresses of the HD wallet backend which are used, as
+// told by isUsed, and returns them.
+func RestoreHD(w *Wallet, isUsed func(address.Address) (bool, error)) ([]address.Address, error) {
+	hd, err := hdBackend(w)
+	if err != nil {
+		return nil, err
+	}
+	return hd.Restore(isUsed)
+}
+
+// KeyImporter returns the backend keys are imported into: the HD wallet
+// backend once it is initialized, so that they are kept along with its seed,
+// and the default datastore backend otherwise.
+func KeyImporter(w *Wallet) (Importer, error) {
+	if hd, err := hdBackend(w); err == nil && hd.IsInitialized() {
+		return hd, nil
+	}
+	return defaultBackend(w)
+}
+
+func hdBackend(w *Wallet) (*HDBackend, error) {
+	backends := w.Backends(HDBackendType)
 	if len(backends) == 0 {
-		return address.Address{}, fmt.Errorf("missing default ds backend")
+		return nil, fmt.Errorf("missing hd backend")
 	}
+	return (backends[0]).(*HDBackend), nil
+}
 
-	backend := (backends[0]).(*DSBackend)
-	return backend.NewAddress()
+func defaultBackend(w *Wallet) (*DSBackend, error) {
+	backends := w.Backends(DSBackendType)
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("missing default ds backend")
+	}
+	return (backends[0]).(*DSBackend), nil
 }